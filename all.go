@@ -17,8 +17,8 @@ import (
 	"context"
 	"time"
 
+	"github.com/conprof/db/storage"
 	"github.com/conprof/db/tsdb"
-	"github.com/conprof/db/tsdb/wal"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	grpc_logging "github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
@@ -41,6 +41,7 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	conprofapi "github.com/conprof/conprof/api"
+	"github.com/conprof/conprof/pkg/chunkenc"
 	"github.com/conprof/conprof/pkg/store"
 	"github.com/conprof/conprof/pkg/store/storepb"
 	"github.com/conprof/conprof/scrape"
@@ -63,7 +64,9 @@ func registerAll(m map[string]setupFunc, app *kingpin.Application, name string,
 		Default("./data").String()
 	configFile := cmd.Flag("config.file", "Config file to use.").
 		Default("conprof.yaml").String()
-	retention := extkingpin.ModelDuration(cmd.Flag("storage.tsdb.retention.time", "How long to retain raw samples on local storage. 0d - disables this retention").Default("15d"))
+	retention := registerTSDBRetentionFlag(cmd)
+	profileCompression := registerProfileCompressionFlag(cmd)
+	enableAdminAPI := registerAdminAPIFlag(cmd)
 	maxMergeBatchSize := cmd.Flag("max-merge-batch-size", "Bytes loaded in one batch for merging. This is to limit the amount of memory a merge query can use.").
 		Default("64MB").Bytes()
 	grpcBindAddr, grpcGracePeriod, grpcCert, grpcKey, grpcClientCA := extkingpin.RegisterGRPCFlags(cmd)
@@ -72,6 +75,18 @@ func registerAll(m map[string]setupFunc, app *kingpin.Application, name string,
 	symbolServer := cmd.Flag("symbol-server", "Symbol server to request to symbolize native stacktraces. When not configured, non-symbolized stack traces will just show their memory address.").String()
 	symbolCache := cmd.Flag("symbol-cache", "Directory to use to cache symbol data from object storage.").
 		Default("/tmp").String()
+	symbolExtractDebugSections := cmd.Flag("symbol-extract-debug-sections", "Only keep the sections needed for symbolization of uploaded debug information, which reduces the storage used for unstripped binaries.").
+		Default("false").Bool()
+	symbolCacheSize := cmd.Flag("symbol-cache-size", "Maximum size of the symbol data cached on local disk, the least recently used symbol data is evicted. 0 disables the limit.").
+		Default("10GB").Bytes()
+	symbolRetention := extkingpin.ModelDuration(cmd.Flag("symbol-retention", "How long to retain symbol data in object storage that is neither uploaded, nor used to symbolize, nor referenced by stored profiles. 0d disables this retention.").
+		Default("0d"))
+	symbolGCInterval := extkingpin.ModelDuration(cmd.Flag("symbol-gc-interval", "Interval of deleting symbol data from object storage that is past the retention.").
+		Default("1h"))
+	debuginfodUpstreams := cmd.Flag("debuginfod-upstream", "Upstream debuginfod server to download debug information missing from object storage from, for example a distribution's mirror. Can be repeated.").Strings()
+	debuginfodTimeout := extkingpin.ModelDuration(cmd.Flag("debuginfod-timeout", "Timeout of downloading debug information from an upstream debuginfod server.").
+		Default("5m"))
+	ingestFlags := registerIngestSymbolizationFlags(cmd)
 	objStoreConfig := *extkingpin.RegisterCommonObjStoreFlags(cmd, "", false, "When not set, the gRPC server will be started without serving the symbol management service.")
 	reqLogConfig := extkingpin.RegisterRequestLoggingFlags(cmd)
 
@@ -86,6 +101,19 @@ func registerAll(m map[string]setupFunc, app *kingpin.Application, name string,
 			return probe, errors.Wrap(err, "error while parsing config for request logging")
 		}
 
+		symbolStoreOpts := []symbol.SymbolStoreOption{
+			symbol.WithCacheSize(int64(*symbolCacheSize)),
+			symbol.WithRetention(time.Duration(*symbolRetention)),
+		}
+		if *symbolExtractDebugSections {
+			symbolStoreOpts = append(symbolStoreOpts, symbol.WithDebugSectionExtraction())
+		}
+		if len(*debuginfodUpstreams) > 0 {
+			symbolStoreOpts = append(symbolStoreOpts, symbol.WithDebuginfod(
+				symbol.NewDebuginfodClient(logger, *debuginfodUpstreams, time.Duration(*debuginfodTimeout)),
+			))
+		}
+
 		return runAll(
 			comp,
 			g,
@@ -99,12 +127,17 @@ func registerAll(m map[string]setupFunc, app *kingpin.Application, name string,
 			*storagePath,
 			*configFile,
 			time.Duration(*retention),
+			chunkenc.Codec(*profileCompression),
+			*enableAdminAPI,
 			reloadCh,
 			reloaders,
 			int64(*maxMergeBatchSize),
 			*queryTimeout,
 			*symbolServer,
 			*symbolCache,
+			symbolStoreOpts,
+			time.Duration(*symbolGCInterval),
+			ingestFlags,
 			objStoreConfig,
 			&grpcSettings{
 				grpcBindAddr:    *grpcBindAddr,
@@ -130,47 +163,34 @@ func runAll(
 	storagePath,
 	configFile string,
 	retention time.Duration,
+	codec chunkenc.Codec,
+	enableAdminAPI bool,
 	reloadCh chan struct{},
 	reloaders *configReloaders,
 	maxMergeBatchSize int64,
 	queryTimeout model.Duration,
 	symbolServer string,
 	symbolCache string,
+	symbolStoreOpts []symbol.SymbolStoreOption,
+	symbolGCInterval time.Duration,
+	ingestFlags *ingestSymbolizationFlags,
 	objStoreConfig extflag.PathOrContent,
 	srv *grpcSettings,
 ) (prober.Probe, error) {
+	opts := tsdbOptions(retention)
+	// Rewriting the profiles symbolized after they are stored writes
+	// overlapping blocks.
+	opts.AllowOverlappingBlocks = *ingestFlags.enabled
 	db, err := tsdb.Open(
 		storagePath,
 		logger,
 		prometheus.DefaultRegisterer,
-		&tsdb.Options{
-			RetentionDuration:      retention.Milliseconds(),
-			WALSegmentSize:         wal.DefaultSegmentSize,
-			MinBlockDuration:       tsdb.DefaultBlockDuration,
-			MaxBlockDuration:       retention.Milliseconds() / 10,
-			NoLockfile:             true,
-			AllowOverlappingBlocks: false,
-			WALCompression:         true,
-			StripeSize:             tsdb.DefaultStripeSize,
-		},
+		opts,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	scrapeManager := scrape.NewManager(log.With(logger, "component", "scrape-manager"), db)
-
-	sampler, err := NewSampler(db, reloaders,
-		SamplerScraper(scrapeManager),
-		SamplerConfig(configFile),
-	)
-	if err != nil {
-		return nil, err
-	}
-	if err := sampler.Run(context.TODO(), g, reloadCh); err != nil {
-		return nil, err
-	}
-
 	confContentYaml, err := objStoreConfig.Content()
 	if err != nil {
 		return nil, err
@@ -182,7 +202,16 @@ func runAll(
 		if err != nil {
 			return nil, errors.Wrap(err, "create object store bucket client")
 		}
-		symStore = symbol.NewSymbolStore(logger, bkt, symbolCache)
+		s := symbol.NewSymbolStore(logger, reg, bkt, symbolCache, append(symbolStoreOpts, symbol.WithReferences(db))...)
+		symStore = s
+		mux.Handle(symbol.DebuginfodPrefix, symbol.NewDebuginfodHandler(logger, bkt))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return s.RunGarbageCollection(ctx, symbolGCInterval)
+		}, func(error) {
+			cancel()
+		})
 	}
 
 	var sym *symbol.Symbolizer
@@ -200,7 +229,31 @@ func runAll(
 		sym = symbol.NewSymbolizer(logger, symStore)
 	}
 
-	w := NewWeb(mux, db, maxMergeBatchSize, queryTimeout,
+	var app storage.Appendable = db
+	if codec != chunkenc.CodecNone {
+		app = store.NewCompressingAppendable(db, codec)
+	}
+	if *ingestFlags.enabled {
+		if sym == nil {
+			return nil, errors.New("symbolizing at ingest requires a symbol server or an object store config")
+		}
+		app = symbolizeAtIngest(g, logger, reg, db, app, codec, sym, ingestFlags)
+	}
+
+	scrapeManager := scrape.NewManager(log.With(logger, "component", "scrape-manager"), app)
+
+	sampler, err := NewSampler(app, reloaders,
+		SamplerScraper(scrapeManager),
+		SamplerConfig(configFile),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := sampler.Run(context.TODO(), g, reloadCh); err != nil {
+		return nil, err
+	}
+
+	webOpts := []WebOption{
 		WebLogger(logger),
 		WebRegistry(reg),
 		WebReloaders(reloaders),
@@ -209,7 +262,14 @@ func runAll(
 		}),
 		WebSymbolizer(sym),
 		WebLogOpts(httpLogOpts...),
-	)
+	}
+	var storeOpts []store.ProfileStoreOption
+	if enableAdminAPI {
+		deleter := store.NewTSDBSeriesDeleter(db)
+		webOpts = append(webOpts, WebSeriesDeleter(deleter))
+		storeOpts = append(storeOpts, store.WithAdminAPI(deleter))
+	}
+	w := NewWeb(mux, store.NewDecompressingQueryable(db), maxMergeBatchSize, queryTimeout, webOpts...)
 	if err = w.Run(context.TODO(), reloadCh); err != nil {
 		return nil, err
 	}
@@ -221,7 +281,7 @@ func runAll(
 		prober.NewInstrumentation(comp, logger, extprom.WrapRegistererWithPrefix("conprof_", reg)),
 	)
 	maxBytesPerFrame := 1024 * 1024 * 2 // 2 Mb default, might need to be tuned later on.
-	s := store.NewProfileStore(logger, db, maxBytesPerFrame)
+	s := store.NewProfileStore(logger, &writeDB{DB: db, app: app}, maxBytesPerFrame, storeOpts...)
 
 	gsrv := grpcserver.New(logger, reg, &opentracing.NoopTracer{}, grpcLogOpts, tagOpts, comp, grpcProbe,
 		grpcserver.WithServer(store.RegisterReadableStoreServer(s)),
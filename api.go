@@ -47,6 +47,8 @@ func registerApi(m map[string]setupFunc, app *kingpin.Application, name string)
 		Default("64MB").Bytes()
 	queryTimeout := extkingpin.ModelDuration(cmd.Flag("query.timeout", "Maximum time to process query by query node.").
 		Default("10s"))
+	enableAdminAPI := registerAdminAPIFlag(cmd)
+	profileChunks := registerProfileChunksFlag(cmd)
 	reqLogConfig := extkingpin.RegisterRequestLoggingFlags(cmd)
 
 	m[name] = func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
@@ -68,14 +70,23 @@ func registerApi(m map[string]setupFunc, app *kingpin.Application, name string)
 		if err != nil {
 			return probe, err
 		}
-		c := storepb.NewReadableProfileStoreClient(conn)
+		var storeOpts []store.GRPCQueryableOption
+		if *profileChunks {
+			storeOpts = append(storeOpts, store.WithProfileChunks())
+		}
+		db := store.NewGRPCQueryable(storepb.NewReadableProfileStoreClient(conn), storeOpts...)
+		var deleter conprofapi.SeriesDeleter
+		if *enableAdminAPI {
+			deleter = db
+		}
 		return probe, runApi(
 			mux,
 			probe,
 			reg,
 			logger,
 			httpLogOpts,
-			store.NewGRPCQueryable(c),
+			db,
+			deleter,
 			int64(*maxMergeBatchSize),
 			*queryTimeout,
 			*symbolServer,
@@ -90,6 +101,7 @@ func runApi(
 	logger log.Logger,
 	httpLogOpts []logging.Option,
 	db storage.Queryable,
+	deleter conprofapi.SeriesDeleter,
 	maxMergeBatchSize int64,
 	queryTimeout model.Duration,
 	symbolServer string,
@@ -118,13 +130,23 @@ func runApi(
 	logMiddleware := logging.NewHTTPServerMiddleware(logger, httpLogOpts...)
 
 	const apiPrefix = "/api/v1/"
-	api := conprofapi.New(logger, reg,
+	apiOpts := []conprofapi.Option{
 		conprofapi.WithDB(db),
 		conprofapi.WithMaxMergeBatchSize(maxMergeBatchSize),
 		conprofapi.WithPrefix(apiPrefix),
 		conprofapi.WithQueryTimeout(time.Duration(queryTimeout)),
 		conprofapi.WithSymbolizer(s),
-	)
+	}
+	if s != nil {
+		apiOpts = append(apiOpts,
+			conprofapi.WithSourceReader(s),
+			conprofapi.WithBinaryDownloader(s),
+		)
+	}
+	if deleter != nil {
+		apiOpts = append(apiOpts, conprofapi.WithSeriesDeleter(deleter))
+	}
+	api := conprofapi.New(logger, reg, apiOpts...)
 	mux.Handle(apiPrefix, logMiddleware.HTTPMiddleware("api", api.Routes()))
 
 	probe.Ready()
@@ -0,0 +1,97 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"math"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// SeriesDeleter deletes the profiles of series.
+type SeriesDeleter interface {
+	DeleteSeries(ctx context.Context, mint, maxt int64, ms ...*labels.Matcher) error
+	CleanTombstones(ctx context.Context) error
+}
+
+// WithSeriesDeleter enables the admin endpoints, deleting series with the
+// deleter.
+func WithSeriesDeleter(d SeriesDeleter) Option {
+	return func(a *API) {
+		a.deleter = d
+	}
+}
+
+// DeleteSeries deletes the profiles of the series matching any of the
+// match[] selectors between start and end, which default to all time.
+func (a *API) DeleteSeries(r *http.Request) (interface{}, []error, *ApiError) {
+	if err := r.ParseForm(); err != nil {
+		return nil, nil, &ApiError{Typ: ErrorInternal, Err: errors.Wrap(err, "parse form")}
+	}
+
+	if len(r.Form["match[]"]) == 0 {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: errors.New("no match[] parameter provided")}
+	}
+
+	start, err := parseTimestampParam(r, "start", math.MinInt64)
+	if err != nil {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: err}
+	}
+	end, err := parseTimestampParam(r, "end", math.MaxInt64)
+	if err != nil {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: err}
+	}
+
+	var matcherSets [][]*labels.Matcher
+	for _, s := range r.Form["match[]"] {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return nil, nil, &ApiError{Typ: ErrorBadData, Err: err}
+		}
+		matcherSets = append(matcherSets, matchers)
+	}
+
+	for _, matchers := range matcherSets {
+		if err := a.deleter.DeleteSeries(r.Context(), start, end, matchers...); err != nil {
+			return nil, nil, &ApiError{Typ: ErrorInternal, Err: err}
+		}
+	}
+	return nil, nil, nil
+}
+
+// CleanTombstones removes the profiles of deleted series from disk.
+func (a *API) CleanTombstones(r *http.Request) (interface{}, []error, *ApiError) {
+	if err := a.deleter.CleanTombstones(r.Context()); err != nil {
+		return nil, nil, &ApiError{Typ: ErrorInternal, Err: err}
+	}
+	return nil, nil, nil
+}
+
+// parseTimestampParam parses the parameter as milliseconds since the epoch.
+func parseTimestampParam(r *http.Request, paramName string, defaultValue int64) (int64, error) {
+	val := r.FormValue(paramName)
+	if val == "" {
+		return defaultValue, nil
+	}
+	t, err := parseTime(val)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Invalid time value for '%s'", paramName)
+	}
+	return timestamp.FromTime(t), nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+type deleteCall struct {
+	mint, maxt int64
+	matchers   string
+}
+
+type fakeSeriesDeleter struct {
+	deleted []deleteCall
+	cleaned int
+}
+
+func (d *fakeSeriesDeleter) DeleteSeries(_ context.Context, mint, maxt int64, ms ...*labels.Matcher) error {
+	d.deleted = append(d.deleted, deleteCall{mint: mint, maxt: maxt, matchers: fmt.Sprint(ms)})
+	return nil
+}
+
+func (d *fakeSeriesDeleter) CleanTombstones(_ context.Context) error {
+	d.cleaned++
+	return nil
+}
+
+func TestAPIDeleteSeries(t *testing.T) {
+	d := &fakeSeriesDeleter{}
+	api := New(log.NewNopLogger(), prometheus.NewRegistry(), WithSeriesDeleter(d))
+
+	for name, test := range map[string]endpointTestCase{
+		"no match": {
+			endpoint: api.DeleteSeries,
+			errType:  ErrorBadData,
+		},
+		"invalid selector": {
+			endpoint: api.DeleteSeries,
+			query:    url.Values{"match[]": []string{"{"}},
+			errType:  ErrorBadData,
+		},
+		"invalid start": {
+			endpoint: api.DeleteSeries,
+			query:    url.Values{"match[]": []string{"heap"}, "start": []string{"yesterday"}},
+			errType:  ErrorBadData,
+		},
+	} {
+		testEndpoint(t, test, name)
+	}
+	if len(d.deleted) != 0 {
+		t.Fatalf("unexpected deletions %v", d.deleted)
+	}
+
+	testEndpoint(t, endpointTestCase{
+		endpoint: api.DeleteSeries,
+		query: url.Values{
+			"match[]": []string{`heap{job="a"}`, `{instance="b"}`},
+			"start":   []string{"1000"},
+		},
+	}, "delete")
+	expected := []deleteCall{
+		{mint: 1000, maxt: math.MaxInt64, matchers: `[job="a" __name__="heap"]`},
+		{mint: 1000, maxt: math.MaxInt64, matchers: `[instance="b"]`},
+	}
+	if !reflect.DeepEqual(expected, d.deleted) {
+		t.Fatalf("expected deletions %v, got %v", expected, d.deleted)
+	}
+
+	testEndpoint(t, endpointTestCase{endpoint: api.CleanTombstones}, "clean tombstones")
+	if d.cleaned != 1 {
+		t.Fatalf("expected tombstones to be cleaned once, got %d", d.cleaned)
+	}
+}
+
+func TestAPIDeleteSeriesDisabled(t *testing.T) {
+	for _, test := range []struct {
+		opts   []Option
+		status int
+	}{
+		{status: http.StatusNotFound},
+		{opts: []Option{WithSeriesDeleter(&fakeSeriesDeleter{})}, status: http.StatusNoContent},
+	} {
+		api := New(log.NewNopLogger(), prometheus.NewRegistry(), test.opts...)
+		w := httptest.NewRecorder()
+		api.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/delete_series?match[]=heap", nil))
+		if w.Code != test.status {
+			t.Fatalf("expected status %d, got %d", test.status, w.Code)
+		}
+	}
+}
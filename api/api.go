@@ -89,6 +89,9 @@ type API struct {
 	queryTimeout      time.Duration
 
 	symbolizer Symbolizer
+	sources    SourceReader
+	binaries   BinaryDownloader
+	deleter    SeriesDeleter
 
 	mu     sync.RWMutex
 	config *config.Config
@@ -144,6 +147,22 @@ func WithSymbolizer(s Symbolizer) Option {
 	}
 }
 
+// WithSourceReader sets where the source files of source reports are read
+// from.
+func WithSourceReader(r SourceReader) Option {
+	return func(a *API) {
+		a.sources = r
+	}
+}
+
+// WithBinaryDownloader sets where the binaries of disassembly reports are
+// downloaded from.
+func WithBinaryDownloader(d BinaryDownloader) Option {
+	return func(a *API) {
+		a.binaries = d
+	}
+}
+
 func WithMaxMergeBatchSize(max int64) Option {
 	return func(a *API) {
 		a.maxMergeBatchSize = max
@@ -190,6 +209,11 @@ func (a *API) Routes() http.Handler {
 		r.GET(path.Join(a.prefix, "/series"), instr("series", a.Series))
 		r.GET(path.Join(a.prefix, "/labels"), instr("label_names", a.LabelNames))
 		r.GET(path.Join(a.prefix, "/label/:name/values"), instr("label_values", a.LabelValues))
+		r.GET(path.Join(a.prefix, "/outliers"), instr("outliers", a.Outliers))
+	}
+	if a.deleter != nil {
+		r.POST(path.Join(a.prefix, "/admin/delete_series"), instr("delete_series", a.DeleteSeries))
+		r.POST(path.Join(a.prefix, "/admin/clean_tombstones"), instr("clean_tombstones", a.CleanTombstones))
 	}
 	if a.config != nil {
 		r.GET(path.Join(a.prefix, "/status/config"), instr("config", a.Config))
@@ -314,15 +338,19 @@ func (a *API) findProfile(ctx context.Context, t time.Time, sel []*labels.Matche
 
 	requestedTime := timestamp.FromTime(t)
 
-	set := q.Select(false, nil, sel...)
+	set := q.Select(false, &storage.SelectHints{
+		Start: timestamp.FromTime(t.Add(-time.Minute * 5)),
+		End:   timestamp.FromTime(t.Add(time.Minute * 5)),
+		Func:  "profile",
+	}, sel...)
 	for set.Next() {
 		series := set.At()
 		i := series.Iterator()
 		for i.Next() {
-			ts, b := i.At()
+			ts, _ := i.At()
 			if ts >= requestedTime {
 				// First profile whose timestamp is larger than or equal to the timestamp being searched for.
-				return profile.ParseData(b)
+				return profileAt(i)
 			}
 		}
 		err = i.Err()
@@ -400,6 +428,10 @@ func (a *API) profileByParameters(ctx context.Context, mode, time, query, from,
 	}
 }
 
+// diffBaseLabel marks the samples of the base profile in profiles produced
+// by DiffProfiles.
+const diffBaseLabel = "pprof::base"
+
 func (a *API) DiffProfiles(r *http.Request) (*profile.Profile, storage.Warnings, *ApiError) {
 	ctx := r.Context()
 
@@ -428,7 +460,7 @@ func (a *API) DiffProfiles(r *http.Request) (*profile.Profile, storage.Warnings,
 	warnings := append(warningsA, warningsB...)
 
 	// compare totals of profiles, skip this to subtract profiles from each other
-	profileA.SetLabel("pprof::base", []string{"true"})
+	profileA.SetLabel(diffBaseLabel, []string{"true"})
 
 	profileA.Scale(-1)
 
@@ -459,6 +491,11 @@ func (a *API) Query(r *http.Request) (interface{}, []error, *ApiError) {
 
 	r = r.WithContext(ctx)
 
+	filter, err := parseProfileFilter(r.URL.Query())
+	if err != nil {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: err}
+	}
+
 	switch r.URL.Query().Get("mode") {
 	case "diff":
 		profile, warnings, apiErr = a.DiffProfiles(r)
@@ -483,16 +520,23 @@ func (a *API) Query(r *http.Request) (interface{}, []error, *ApiError) {
 	}
 
 	// Attempt to symbolize all unsymbolized data.
-	err := a.symbolizeProfile(r.Context(), profile)
+	err = a.symbolizeProfile(r.Context(), profile)
 	if err != nil {
 		return nil, nil, &ApiError{Typ: ErrorInternal, Err: err}
 	}
 
+	// Filters match function names, so they can only be applied once the
+	// profile is symbolized.
+	warnings = append(warnings, filter.apply(profile)...)
+	groupByTags(profile, parseTagKeys(r.URL.Query().Get("group_by_tag")))
+
 	return &ProfileResponseRenderer{
 		logger:   a.logger,
 		profile:  profile,
 		warnings: warnings,
 		req:      r,
+		sources:  a.sources,
+		binaries: a.binaries,
 	}, warnings, nil
 }
 
@@ -137,6 +137,14 @@ func (s *fakeProfileStore) LabelValues(ctx context.Context, r *storepb.LabelValu
 	return nil, nil
 }
 
+func (s *fakeProfileStore) DeleteSeries(ctx context.Context, r *storepb.DeleteSeriesRequest) (*storepb.DeleteSeriesResponse, error) {
+	return nil, nil
+}
+
+func (s *fakeProfileStore) CleanTombstones(ctx context.Context, r *storepb.CleanTombstonesRequest) (*storepb.CleanTombstonesResponse, error) {
+	return nil, nil
+}
+
 type endpointTestCase struct {
 	endpoint ApiFunc
 	params   map[string]string
@@ -0,0 +1,254 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/conprof/conprof/internal/pprof/binutils"
+	"github.com/conprof/conprof/internal/pprof/plugin"
+	"github.com/conprof/conprof/internal/pprof/report"
+)
+
+// maxDisasmFunctions is the number of functions with the highest flat values
+// that are disassembled at most.
+const maxDisasmFunctions = 20
+
+// BinaryDownloader downloads the binaries of profiles by build ID, to
+// disassemble them.
+type BinaryDownloader interface {
+	DownloadBinary(ctx context.Context, buildID string, w io.Writer) error
+}
+
+type disasmInstruction struct {
+	Address     string `json:"address"`
+	Instruction string `json:"instruction"`
+	Function    string `json:"function,omitempty"`
+	File        string `json:"file,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Flat        int64  `json:"flat,omitempty"`
+	Cum         int64  `json:"cum,omitempty"`
+	FlatFormat  string `json:"flatFormat,omitempty"`
+	CumFormat   string `json:"cumFormat,omitempty"`
+}
+
+type disasmFunction struct {
+	Name         string              `json:"name"`
+	Aliases      []string            `json:"aliases,omitempty"`
+	Flat         int64               `json:"flat"`
+	Cum          int64               `json:"cum"`
+	FlatFormat   string              `json:"flatFormat,omitempty"`
+	CumFormat    string              `json:"cumFormat,omitempty"`
+	Instructions []disasmInstruction `json:"instructions"`
+}
+
+type disasmReport struct {
+	Total     int64            `json:"total"`
+	Functions []disasmFunction `json:"functions"`
+}
+
+// generateDisasmReport annotates the instructions of the functions matching
+// the regexp with their cost. The binaries are downloaded by the build IDs of
+// the profile's mappings.
+func generateDisasmReport(ctx context.Context, p *profile.Profile, sampleIndex, function string, d BinaryDownloader) (*disasmReport, error) {
+	if function == "" {
+		return nil, errors.New("function must be specified for disassembly reports")
+	}
+	rx, err := regexp.Compile(function)
+	if err != nil {
+		return nil, fmt.Errorf("invalid function regexp: %w", err)
+	}
+	if d == nil {
+		return nil, errors.New("no symbol server configured to download binaries from")
+	}
+
+	numLabelUnits, _ := p.NumLabelUnits()
+	err = p.Aggregate(false, true, true, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	value, meanDiv, sample, err := sampleFormat(p, sampleIndex, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := report.New(p, &report.Options{
+		OutputFormat:  report.Dis,
+		OutputUnit:    "minimum",
+		Ratio:         1,
+		NumLabelUnits: numLabelUnits,
+
+		SampleValue:       value,
+		SampleMeanDivisor: meanDiv,
+		SampleType:        sample.Type,
+		SampleUnit:        sample.Unit,
+
+		Symbol: rx,
+	})
+
+	obj, err := newObjTool(ctx, d, p)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	functions, err := report.Disassembly(rep, obj, maxDisasmFunctions)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &disasmReport{
+		Total:     rep.Total(),
+		Functions: make([]disasmFunction, 0, len(functions)),
+	}
+	for _, f := range functions {
+		fn := disasmFunction{
+			Name:         f.Name,
+			Aliases:      f.Aliases,
+			Flat:         f.Flat,
+			Cum:          f.Cum,
+			FlatFormat:   rep.FormatValue(f.Flat),
+			CumFormat:    rep.FormatValue(f.Cum),
+			Instructions: make([]disasmInstruction, 0, len(f.Instructions)),
+		}
+		for _, i := range f.Instructions {
+			inst := disasmInstruction{
+				Address:     fmt.Sprintf("0x%x", i.Address),
+				Instruction: i.Instruction,
+				Function:    i.Function,
+				File:        i.File,
+				Line:        i.Line,
+				Flat:        i.Flat,
+				Cum:         i.Cum,
+			}
+			if i.Flat != 0 {
+				inst.FlatFormat = rep.FormatValue(i.Flat)
+			}
+			if i.Cum != 0 {
+				inst.CumFormat = rep.FormatValue(i.Cum)
+			}
+			fn.Instructions = append(fn.Instructions, inst)
+		}
+		res.Functions = append(res.Functions, fn)
+	}
+	return res, nil
+}
+
+// objTool opens the binaries of a profile's mappings, which are downloaded by
+// their build ID into a temporary directory.
+type objTool struct {
+	ctx context.Context
+	d   BinaryDownloader
+	bu  *binutils.Binutils
+	dir string
+
+	// buildIDs are the build IDs of the mapped files.
+	buildIDs map[string]string
+	// paths are the downloaded binaries of build IDs, empty if they couldn't
+	// be downloaded.
+	paths map[string]string
+	// errs are the errors of failed downloads.
+	errs map[string]error
+}
+
+func newObjTool(ctx context.Context, d BinaryDownloader, p *profile.Profile) (*objTool, error) {
+	dir, err := ioutil.TempDir("", "conprof-disasm")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+
+	buildIDs := map[string]string{}
+	for _, m := range p.Mapping {
+		if m.BuildID != "" {
+			buildIDs[m.File] = m.BuildID
+		}
+	}
+	return &objTool{
+		ctx:      ctx,
+		d:        d,
+		bu:       &binutils.Binutils{},
+		dir:      dir,
+		buildIDs: buildIDs,
+		paths:    map[string]string{},
+		errs:     map[string]error{},
+	}, nil
+}
+
+// Close removes the downloaded binaries.
+func (t *objTool) Close() error {
+	return os.RemoveAll(t.dir)
+}
+
+// path returns the path of the downloaded binary of the mapped file.
+func (t *objTool) path(file string) (string, error) {
+	id, ok := t.buildIDs[file]
+	if !ok {
+		return "", fmt.Errorf("no build id for %s", file)
+	}
+	if p, ok := t.paths[id]; ok {
+		return p, t.errs[id]
+	}
+
+	p := filepath.Join(t.dir, id)
+	err := t.download(id, p)
+	if err != nil {
+		err = fmt.Errorf("download binary of %s: %w", file, err)
+	}
+	t.paths[id], t.errs[id] = p, err
+	return p, err
+}
+
+func (t *objTool) download(id, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := t.d.DownloadBinary(t.ctx, id, f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (t *objTool) Open(file string, start, limit, offset uint64) (plugin.ObjFile, error) {
+	path, err := t.path(file)
+	if err != nil {
+		return nil, err
+	}
+	return t.bu.Open(path, start, limit, offset)
+}
+
+// Disasm disassembles the binary, which is the downloaded binary of the
+// object files returned by Open.
+func (t *objTool) Disasm(file string, start, end uint64, intelSyntax bool) ([]plugin.Inst, error) {
+	if filepath.Dir(file) != t.dir {
+		var err error
+		file, err = t.path(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t.bu.Disasm(file, start, end, intelSyntax)
+}
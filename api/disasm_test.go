@@ -0,0 +1,106 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBinaryID   = "910b52eaddce54ae8bbeb49f93c04ded113fcf4d"
+	testBinaryPath = "../internal/pprof/binutils/testdata/exe_linux_64"
+)
+
+type fakeBinaryDownloader map[string]string
+
+func (d fakeBinaryDownloader) DownloadBinary(ctx context.Context, buildID string, w io.Writer) error {
+	path, ok := d[buildID]
+	if !ok {
+		return errors.New("not found")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func TestRenderDisasm(t *testing.T) {
+	if _, err := exec.LookPath("objdump"); err != nil {
+		t.Skip("skipping, objdump is not installed")
+	}
+
+	m := &profile.Mapping{ID: 1, Start: 0x400000, Limit: 0x401000, File: "/usr/bin/exe", BuildID: testBinaryID}
+	fn := &profile.Function{ID: 1, Name: "main", Filename: "main.c"}
+	l1 := &profile.Location{ID: 1, Mapping: m, Address: 0x400531, Line: []profile.Line{{Function: fn, Line: 3}}}
+	l2 := &profile.Location{ID: 2, Mapping: m, Address: 0x400535, Line: []profile.Line{{Function: fn, Line: 4}}}
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{l1}, Value: []int64{100}},
+			{Location: []*profile.Location{l2}, Value: []int64{300}},
+		},
+		Location: []*profile.Location{l1, l2},
+		Function: []*profile.Function{fn},
+		Mapping:  []*profile.Mapping{m},
+	}
+
+	v := url.Values{}
+	v.Set("report", "disasm")
+	v.Set("function", "main")
+	req := httptest.NewRequest("GET", (&url.URL{Scheme: "http", Host: "example.com", RawQuery: v.Encode()}).String(), nil)
+
+	r := NewProfileResponseRenderer(log.NewNopLogger(), p, nil, req)
+	r.binaries = fakeBinaryDownloader{testBinaryID: testBinaryPath}
+
+	w := httptest.NewRecorder()
+	require.NoError(t, r.Render(w))
+
+	res := struct {
+		Data disasmReport `json:"data"`
+	}{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	require.Equal(t, int64(400), res.Data.Total)
+	require.Len(t, res.Data.Functions, 1)
+
+	f := res.Data.Functions[0]
+	require.Equal(t, "main", f.Name)
+	require.Equal(t, int64(400), f.Flat)
+	require.Equal(t, "0x40052d", f.Instructions[0].Address)
+
+	flat := int64(0)
+	for _, i := range f.Instructions {
+		require.NotEmpty(t, i.Instruction)
+		flat += i.Flat
+	}
+	require.Equal(t, int64(400), flat)
+
+	// Binaries have to be downloaded from a symbol server.
+	r = NewProfileResponseRenderer(log.NewNopLogger(), p, nil, req)
+	require.Error(t, r.Render(httptest.NewRecorder()))
+}
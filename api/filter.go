@@ -0,0 +1,252 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/conprof/conprof/internal/pprof/measurement"
+)
+
+var tagFilterRangeRx = regexp.MustCompile("([+-]?[[:digit:]]+)([[:alpha:]]+)?")
+
+// profileFilter holds pprof's focus, ignore, hide, show, show_from,
+// tagfocus, tagignore and prune_from options of a query. The tag filters
+// can also be set as tag_focus and tag_ignore.
+type profileFilter struct {
+	focus, ignore, hide, show, showFrom, pruneFrom *regexp.Regexp
+	tagFocus, tagIgnore                            profile.TagMatch
+
+	// numLabelUnits are the units of the numeric labels of the profile
+	// being filtered, which tag filter ranges are compared in.
+	numLabelUnits map[string]string
+}
+
+// parseProfileFilter parses the filter options from the query parameters,
+// it returns nil if none are set.
+func parseProfileFilter(q url.Values) (*profileFilter, error) {
+	f := &profileFilter{}
+
+	var err error
+	f.focus, err = compileRegexOption("focus", q.Get("focus"), err)
+	f.ignore, err = compileRegexOption("ignore", q.Get("ignore"), err)
+	f.hide, err = compileRegexOption("hide", q.Get("hide"), err)
+	f.show, err = compileRegexOption("show", q.Get("show"), err)
+	f.showFrom, err = compileRegexOption("show_from", q.Get("show_from"), err)
+	f.pruneFrom, err = compileRegexOption("prune_from", q.Get("prune_from"), err)
+	f.tagFocus, err = f.compileTagFilter("tag_focus", firstParam(q, "tag_focus", "tagfocus"), err)
+	f.tagIgnore, err = f.compileTagFilter("tag_ignore", firstParam(q, "tag_ignore", "tagignore"), err)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.focus == nil && f.ignore == nil && f.hide == nil && f.show == nil &&
+		f.showFrom == nil && f.pruneFrom == nil && f.tagFocus == nil && f.tagIgnore == nil {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// firstParam returns the value of the first of the parameters that is set.
+func firstParam(q url.Values, names ...string) string {
+	for _, name := range names {
+		if v := q.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// apply filters the profile in place. It returns warnings for the options
+// that matched no samples.
+func (f *profileFilter) apply(p *profile.Profile) []error {
+	if f == nil {
+		return nil
+	}
+	f.numLabelUnits, _ = p.NumLabelUnits()
+
+	var warnings []error
+	warnNoMatches := func(match bool, option string) {
+		if !match {
+			warnings = append(warnings, fmt.Errorf("%s expression matched no samples", option))
+		}
+	}
+
+	fm, im, hm, hnm := p.FilterSamplesByName(f.focus, f.ignore, f.hide, f.show)
+	warnNoMatches(f.focus == nil || fm, "focus")
+	warnNoMatches(f.ignore == nil || im, "ignore")
+	warnNoMatches(f.hide == nil || hm, "hide")
+	warnNoMatches(f.show == nil || hnm, "show")
+
+	sfm := p.ShowFrom(f.showFrom)
+	warnNoMatches(f.showFrom == nil || sfm, "show_from")
+
+	tfm, tim := p.FilterSamplesByTag(f.tagFocus, f.tagIgnore)
+	warnNoMatches(f.tagFocus == nil || tfm, "tag_focus")
+	warnNoMatches(f.tagIgnore == nil || tim, "tag_ignore")
+
+	if f.pruneFrom != nil {
+		p.PruneFrom(f.pruneFrom)
+	}
+	return warnings
+}
+
+func compileRegexOption(name, value string, err error) (*regexp.Regexp, error) {
+	if value == "" || err != nil {
+		return nil, err
+	}
+	rx, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s regexp: %w", name, err)
+	}
+	return rx, nil
+}
+
+// compileTagFilter compiles a tag filter the way pprof does. The value is
+// either a range of numeric label values, e.g. "1kb:4kb", or a comma
+// separated list of regexps matching label values, both optionally prefixed
+// by the label key, e.g. "method=GET".
+func (f *profileFilter) compileTagFilter(name, value string, err error) (profile.TagMatch, error) {
+	if value == "" || err != nil {
+		return nil, err
+	}
+
+	tagValuePair := strings.SplitN(value, "=", 2)
+	var wantKey string
+	if len(tagValuePair) == 2 {
+		wantKey = tagValuePair[0]
+		value = tagValuePair[1]
+	}
+
+	if numFilter := parseTagFilterRange(value); numFilter != nil {
+		labelFilter := func(vals []int64, key string) bool {
+			for _, val := range vals {
+				if numFilter(val, f.numLabelUnits[key]) {
+					return true
+				}
+			}
+			return false
+		}
+		if wantKey == "" {
+			return func(s *profile.Sample) bool {
+				for key, vals := range s.NumLabel {
+					if labelFilter(vals, key) {
+						return true
+					}
+				}
+				return false
+			}, nil
+		}
+		return func(s *profile.Sample) bool {
+			if vals, ok := s.NumLabel[wantKey]; ok {
+				return labelFilter(vals, wantKey)
+			}
+			return false
+		}, nil
+	}
+
+	var rfx []*regexp.Regexp
+	for _, tagf := range strings.Split(value, ",") {
+		fx, err := regexp.Compile(tagf)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s regexp: %w", name, err)
+		}
+		rfx = append(rfx, fx)
+	}
+	if wantKey == "" {
+		return func(s *profile.Sample) bool {
+		matchedrx:
+			for _, rx := range rfx {
+				for key, vals := range s.Label {
+					for _, val := range vals {
+						if rx.MatchString(key + ":" + val) {
+							continue matchedrx
+						}
+					}
+				}
+				return false
+			}
+			return true
+		}, nil
+	}
+	return func(s *profile.Sample) bool {
+		if vals, ok := s.Label[wantKey]; ok {
+			for _, rx := range rfx {
+				for _, val := range vals {
+					if rx.MatchString(val) {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}, nil
+}
+
+// parseTagFilterRange returns a function to check if a value is contained in
+// the range described by the filter, e.g. "32kb" for values equal to 32kb,
+// ":64kb" for values up to 64kb, "4mb:" for values from 4mb, or "12kb:64mb"
+// for values between 12kb and 64mb, both included. It returns nil if the
+// filter isn't a range.
+func parseTagFilterRange(filter string) func(int64, string) bool {
+	ranges := tagFilterRangeRx.FindAllStringSubmatch(filter, 2)
+	if len(ranges) == 0 {
+		return nil
+	}
+	v, err := strconv.ParseInt(ranges[0][1], 10, 64)
+	if err != nil {
+		return nil
+	}
+	scaledValue, unit := measurement.Scale(v, ranges[0][2], ranges[0][2])
+	if len(ranges) == 1 {
+		switch match := ranges[0][0]; filter {
+		case match:
+			return func(v int64, u string) bool {
+				sv, su := measurement.Scale(v, u, unit)
+				return su == unit && sv == scaledValue
+			}
+		case match + ":":
+			return func(v int64, u string) bool {
+				sv, su := measurement.Scale(v, u, unit)
+				return su == unit && sv >= scaledValue
+			}
+		case ":" + match:
+			return func(v int64, u string) bool {
+				sv, su := measurement.Scale(v, u, unit)
+				return su == unit && sv <= scaledValue
+			}
+		}
+		return nil
+	}
+	if filter != ranges[0][0]+":"+ranges[1][0] {
+		return nil
+	}
+	if v, err = strconv.ParseInt(ranges[1][1], 10, 64); err != nil {
+		return nil
+	}
+	scaledValue2, unit2 := measurement.Scale(v, ranges[1][2], unit)
+	if unit != unit2 {
+		return nil
+	}
+	return func(v int64, u string) bool {
+		sv, su := measurement.Scale(v, u, unit)
+		return su == unit && sv >= scaledValue && sv <= scaledValue2
+	}
+}
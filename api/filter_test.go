@@ -0,0 +1,164 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+// filterTestProfile returns a profile with a gRPC and an HTTP request path,
+// both allocating memory.
+func filterTestProfile() *profile.Profile {
+	fns := map[string]*profile.Function{}
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "alloc_space", Unit: "bytes"}},
+	}
+	stack := func(names ...string) []*profile.Location {
+		locs := make([]*profile.Location, 0, len(names))
+		for _, name := range names {
+			fn, ok := fns[name]
+			if !ok {
+				fn = &profile.Function{ID: uint64(len(p.Function) + 1), Name: name}
+				fns[name] = fn
+				p.Function = append(p.Function, fn)
+			}
+			loc := &profile.Location{ID: uint64(len(p.Location) + 1), Line: []profile.Line{{Function: fn}}}
+			p.Location = append(p.Location, loc)
+			locs = append(locs, loc)
+		}
+		return locs
+	}
+
+	p.Sample = []*profile.Sample{{
+		Location: stack("runtime.mallocgc", "proto.Marshal", "grpc.(*Server).handleStream", "runtime.goexit"),
+		Value:    []int64{300},
+		Label:    map[string][]string{"method": {"Write"}},
+		NumLabel: map[string][]int64{"bytes": {2048}},
+	}, {
+		Location: stack("runtime.mallocgc", "json.Marshal", "http.(*conn).serve", "runtime.goexit"),
+		Value:    []int64{100},
+		Label:    map[string][]string{"method": {"GET"}},
+		NumLabel: map[string][]int64{"bytes": {64}},
+	}}
+	return p
+}
+
+func total(p *profile.Profile) int64 {
+	t := int64(0)
+	for _, s := range p.Sample {
+		t += s.Value[0]
+	}
+	return t
+}
+
+func TestProfileFilter(t *testing.T) {
+	f, err := parseProfileFilter(url.Values{})
+	require.NoError(t, err)
+	require.Nil(t, f)
+	require.Empty(t, f.apply(filterTestProfile()))
+
+	for _, param := range []string{"focus", "ignore", "hide", "show", "show_from", "prune_from", "tagfocus", "tagignore", "tag_focus", "tag_ignore"} {
+		_, err := parseProfileFilter(url.Values{param: {"("}})
+		require.Error(t, err, param)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query url.Values
+		total int64
+		check func(t *testing.T, p *profile.Profile)
+	}{{
+		name:  "focus",
+		query: url.Values{"focus": {"grpc"}},
+		total: 300,
+	}, {
+		name:  "ignore",
+		query: url.Values{"ignore": {"grpc"}},
+		total: 100,
+	}, {
+		name:  "tagfocus by value",
+		query: url.Values{"tagfocus": {"method=GET"}},
+		total: 100,
+	}, {
+		name:  "tagfocus by range",
+		query: url.Values{"tagfocus": {"1kb:"}},
+		total: 300,
+	}, {
+		name:  "tag_focus",
+		query: url.Values{"tag_focus": {"method=Write"}},
+		total: 300,
+	}, {
+		name:  "tagignore",
+		query: url.Values{"tagignore": {"method:Write"}},
+		total: 100,
+	}, {
+		name:  "tag_ignore",
+		query: url.Values{"tag_ignore": {"bytes=:1kb"}},
+		total: 300,
+	}, {
+		name:  "hide",
+		query: url.Values{"hide": {"runtime\\."}},
+		total: 400,
+		check: func(t *testing.T, p *profile.Profile) {
+			require.Equal(t, "proto.Marshal", p.Sample[0].Location[0].Line[0].Function.Name)
+			require.Len(t, p.Sample[0].Location, 2)
+		},
+	}, {
+		name:  "show",
+		query: url.Values{"show": {"Marshal"}},
+		total: 400,
+		check: func(t *testing.T, p *profile.Profile) {
+			require.Len(t, p.Sample[0].Location, 1)
+		},
+	}, {
+		name:  "show_from",
+		query: url.Values{"show_from": {"handleStream"}},
+		total: 300,
+		check: func(t *testing.T, p *profile.Profile) {
+			require.Len(t, p.Sample, 1)
+			require.Len(t, p.Sample[0].Location, 3)
+		},
+	}, {
+		name:  "prune_from",
+		query: url.Values{"prune_from": {"Marshal"}},
+		total: 400,
+		check: func(t *testing.T, p *profile.Profile) {
+			require.Equal(t, "proto.Marshal", p.Sample[0].Location[0].Line[0].Function.Name)
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := parseProfileFilter(tc.query)
+			require.NoError(t, err)
+
+			p := filterTestProfile()
+			require.Empty(t, f.apply(p))
+			require.Equal(t, tc.total, total(p))
+			if tc.check != nil {
+				tc.check(t, p)
+			}
+		})
+	}
+
+	f, err = parseProfileFilter(url.Values{"focus": {"nonexistent"}})
+	require.NoError(t, err)
+	p := filterTestProfile()
+	warnings := f.apply(p)
+	require.Len(t, warnings, 1)
+	require.Equal(t, "focus expression matched no samples", warnings[0].Error())
+	require.Empty(t, p.Sample)
+}
@@ -0,0 +1,323 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+
+	"github.com/google/pprof/profile"
+)
+
+type FlamegraphHTMLNode struct {
+	Name     string                `json:"n"`
+	Values   []int64               `json:"v"`
+	Base     []int64               `json:"b,omitempty"`
+	Children []*FlamegraphHTMLNode `json:"c,omitempty"`
+
+	children map[string]*FlamegraphHTMLNode
+}
+
+type FlamegraphHTMLSampleType struct {
+	Type string `json:"type"`
+	Unit string `json:"unit"`
+}
+
+// FlamegraphHTMLData is embedded into the HTML flamegraph. It holds the
+// values of all sample types so the page can switch between them.
+type FlamegraphHTMLData struct {
+	SampleTypes []FlamegraphHTMLSampleType `json:"sampleTypes"`
+	SampleIndex int                        `json:"sampleIndex"`
+	Diff        bool                       `json:"diff"`
+	Root        *FlamegraphHTMLNode        `json:"root"`
+}
+
+type FlamegraphHTMLRenderer struct {
+	profile     *profile.Profile
+	sampleIndex string
+	diff        bool
+}
+
+func NewFlamegraphHTMLRenderer(profile *profile.Profile, sampleIndex string, diff bool) *FlamegraphHTMLRenderer {
+	return &FlamegraphHTMLRenderer{
+		profile:     profile,
+		sampleIndex: sampleIndex,
+		diff:        diff,
+	}
+}
+
+func (r *FlamegraphHTMLRenderer) Render(w http.ResponseWriter) error {
+	data, err := generateFlamegraphHTMLData(r.profile, r.sampleIndex, r.diff)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return flamegraphHTMLTemplate.Execute(w, data)
+}
+
+// generateFlamegraphHTMLData builds the call tree of the profile. For diffs
+// the values of the base profile are kept apart, so the page can size frames
+// by the new profile and color them by the difference to the base, like
+// differential flame graphs do.
+func generateFlamegraphHTMLData(p *profile.Profile, sampleIndex string, diff bool) (*FlamegraphHTMLData, error) {
+	index, err := p.SampleIndexByName(sampleIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &FlamegraphHTMLData{
+		SampleIndex: index,
+		Diff:        diff,
+	}
+	for _, st := range p.SampleType {
+		data.SampleTypes = append(data.SampleTypes, FlamegraphHTMLSampleType{Type: st.Type, Unit: st.Unit})
+	}
+
+	newNode := func(name string) *FlamegraphHTMLNode {
+		n := &FlamegraphHTMLNode{
+			Name:     name,
+			Values:   make([]int64, len(p.SampleType)),
+			children: map[string]*FlamegraphHTMLNode{},
+		}
+		if diff {
+			n.Base = make([]int64, len(p.SampleType))
+		}
+		return n
+	}
+	data.Root = newNode("root")
+
+	for _, s := range p.Sample {
+		isBase := diff && len(s.Label[diffBaseLabel]) > 0
+		add := func(n *FlamegraphHTMLNode) {
+			for i, v := range s.Value {
+				if isBase {
+					// Base samples are negated when diffing.
+					n.Base[i] -= v
+				} else {
+					n.Values[i] += v
+				}
+			}
+		}
+
+		n := data.Root
+		add(n)
+		for _, f := range sampleStack(s) {
+			child, ok := n.children[f.Name]
+			if !ok {
+				child = newNode(f.Name)
+				n.children[f.Name] = child
+				n.Children = append(n.Children, child)
+			}
+			n = child
+			add(n)
+		}
+	}
+
+	var sortChildren func(n *FlamegraphHTMLNode)
+	sortChildren = func(n *FlamegraphHTMLNode) {
+		sort.Slice(n.Children, func(i, j int) bool {
+			return n.Children[i].Name < n.Children[j].Name
+		})
+		for _, c := range n.Children {
+			sortChildren(c)
+		}
+	}
+	sortChildren(data.Root)
+
+	return data, nil
+}
+
+var flamegraphHTMLTemplate = template.Must(template.New("flamegraph").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>conprof flamegraph</title>
+<style>
+body { font-family: sans-serif; margin: 8px; }
+#toolbar { display: flex; gap: 8px; align-items: center; margin-bottom: 4px; }
+#toolbar input { flex: 1; }
+#details { font-size: 13px; min-height: 18px; margin-bottom: 4px; white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+#flamegraph { position: relative; width: 100%; }
+.frame { position: absolute; height: 17px; box-sizing: border-box; border: 1px solid #fff; font-size: 12px; line-height: 15px; padding: 0 2px; overflow: hidden; white-space: nowrap; cursor: pointer; }
+.frame.ancestor { opacity: 0.5; }
+</style>
+</head>
+<body>
+<div id="toolbar">
+<select id="sample-index"></select>
+<input id="search" type="search" placeholder="Search functions (regular expression)">
+<button id="reset">Reset zoom</button>
+<span id="matched"></span>
+</div>
+<div id="details">&nbsp;</div>
+<div id="flamegraph"></div>
+<script>
+(function() {
+  const data = {{.}};
+  const rowHeight = 18;
+  const root = data.root;
+  const container = document.getElementById('flamegraph');
+  const details = document.getElementById('details');
+  const select = document.getElementById('sample-index');
+  const search = document.getElementById('search');
+  const matched = document.getElementById('matched');
+
+  let index = data.sampleIndex;
+  let focus = root;
+  let pattern = null;
+
+  (function link(n, parent) {
+    n.parent = parent;
+    (n.c || []).forEach(function(c) { link(c, n); });
+  })(root, null);
+
+  data.sampleTypes.forEach(function(t, i) {
+    const o = document.createElement('option');
+    o.value = i;
+    o.textContent = t.type + (t.unit ? ' (' + t.unit + ')' : '');
+    select.appendChild(o);
+  });
+  select.value = index;
+
+  function value(n) { return n.v[index]; }
+  function base(n) { return n.b ? n.b[index] : 0; }
+
+  function format(v) {
+    const unit = data.sampleTypes[index].unit;
+    const scales = {
+      bytes: [[1 << 30, 'GB'], [1 << 20, 'MB'], [1 << 10, 'kB'], [1, 'B']],
+      nanoseconds: [[1e9, 's'], [1e6, 'ms'], [1e3, 'µs'], [1, 'ns']],
+    };
+    const s = scales[unit] || [];
+    for (let i = 0; i < s.length; i++) {
+      if (Math.abs(v) >= s[i][0]) {
+        return (v / s[i][0]).toFixed(s[i][0] === 1 ? 0 : 2) + s[i][1];
+      }
+    }
+    return String(v);
+  }
+
+  function percent(v, total) {
+    return total === 0 ? '0%' : (100 * v / total).toFixed(2) + '%';
+  }
+
+  function color(n) {
+    if (pattern && pattern.test(n.n)) {
+      return 'rgb(230,100,230)';
+    }
+    if (data.diff) {
+      const v = value(n), b = base(n), max = Math.max(v, b);
+      const c = Math.round(255 * (1 - (max === 0 ? 0 : Math.abs(v - b) / max)));
+      if (v > b) return 'rgb(255,' + c + ',' + c + ')';
+      if (v < b) return 'rgb(' + c + ',' + c + ',255)';
+      return 'rgb(240,240,240)';
+    }
+    let h = 0;
+    for (let i = 0; i < n.n.length; i++) {
+      h = (h * 31 + n.n.charCodeAt(i)) | 0;
+    }
+    h = Math.abs(h);
+    return 'rgb(' + (205 + h % 50) + ',' + (80 + (h >> 8) % 130) + ',' + (40 + (h >> 16) % 50) + ')';
+  }
+
+  function describe(n) {
+    let s = n.n + ': ' + format(value(n)) + ' (' + percent(value(n), value(root)) + ')';
+    if (data.diff) {
+      const d = value(n) - base(n);
+      s += ', base ' + format(base(n)) + ', diff ' + (d > 0 ? '+' : '') + format(d);
+    }
+    return s;
+  }
+
+  function draw(n, x, w, depth, ancestor) {
+    const el = document.createElement('div');
+    el.className = ancestor ? 'frame ancestor' : 'frame';
+    el.style.left = x + 'px';
+    el.style.top = (depth * rowHeight) + 'px';
+    el.style.width = w + 'px';
+    el.style.background = color(n);
+    if (w > 30) {
+      el.textContent = n.n;
+    }
+    el.title = describe(n);
+    el.onmouseover = function() { details.textContent = describe(n); };
+    el.onclick = function() { focus = n; render(); };
+    container.appendChild(el);
+  }
+
+  function updateMatched() {
+    if (!pattern) {
+      matched.textContent = '';
+      return;
+    }
+    let sum = 0;
+    (function walk(n) {
+      if (pattern.test(n.n)) {
+        sum += value(n);
+        return;
+      }
+      (n.c || []).forEach(walk);
+    })(focus);
+    matched.textContent = 'Matched: ' + format(sum) + ' (' + percent(sum, value(focus)) + ')';
+  }
+
+  function render() {
+    container.innerHTML = '';
+    const width = container.clientWidth;
+    const total = value(focus);
+
+    const ancestors = [];
+    for (let n = focus.parent; n; n = n.parent) {
+      ancestors.unshift(n);
+    }
+    ancestors.forEach(function(n, depth) { draw(n, 0, width, depth, true); });
+
+    let maxDepth = ancestors.length;
+    (function walk(n, x, depth) {
+      const w = total <= 0 ? 0 : width * value(n) / total;
+      if (w < 1) {
+        return;
+      }
+      draw(n, x, w, depth, false);
+      maxDepth = Math.max(maxDepth, depth);
+      (n.c || []).forEach(function(c) {
+        walk(c, x, depth + 1);
+        x += total <= 0 ? 0 : width * value(c) / total;
+      });
+    })(focus, 0, ancestors.length);
+
+    container.style.height = ((maxDepth + 1) * rowHeight) + 'px';
+    updateMatched();
+  }
+
+  select.onchange = function() { index = +select.value; render(); };
+  search.oninput = function() {
+    try {
+      pattern = search.value ? new RegExp(search.value) : null;
+    } catch (e) {
+      pattern = null;
+    }
+    render();
+  };
+  document.getElementById('reset').onclick = function() { focus = root; render(); };
+  window.onresize = render;
+
+  render();
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,79 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlamegraphHTMLData(t *testing.T) {
+	data, err := generateFlamegraphHTMLData(testStackProfile(), "cpu", false)
+	require.NoError(t, err)
+
+	require.Equal(t, 1, data.SampleIndex)
+	require.False(t, data.Diff)
+	require.Equal(t, []int64{6, 65}, data.Root.Values)
+	require.Nil(t, data.Root.Base)
+
+	require.Len(t, data.Root.Children, 1)
+	main := data.Root.Children[0]
+	require.Equal(t, "main.main", main.Name)
+	require.Equal(t, []int64{6, 65}, main.Values)
+	require.Len(t, main.Children, 2)
+	require.Equal(t, "0xcafe", main.Children[0].Name)
+	require.Equal(t, "main.handle", main.Children[1].Name)
+	require.Equal(t, "main.inlined", main.Children[1].Children[0].Name)
+	require.Equal(t, []int64{5, 50}, main.Children[1].Children[0].Values)
+}
+
+func TestFlamegraphHTMLDataDiff(t *testing.T) {
+	base := testStackProfile()
+	base.SetLabel(diffBaseLabel, []string{"true"})
+	base.Scale(-1)
+	p, err := profile.Merge([]*profile.Profile{base, testStackProfile()})
+	require.NoError(t, err)
+
+	data, err := generateFlamegraphHTMLData(p, "", true)
+	require.NoError(t, err)
+	require.True(t, data.Diff)
+	require.Equal(t, []int64{6, 65}, data.Root.Values)
+	require.Equal(t, []int64{6, 65}, data.Root.Base)
+}
+
+func TestRenderFlamegraphHTML(t *testing.T) {
+	f, err := os.Open("testdata/alloc_objects.pb.gz")
+	require.NoError(t, err)
+	p, err := profile.Parse(f)
+	require.NoError(t, err)
+
+	v := url.Values{}
+	v.Set("report", "flamegraph_html")
+	req := httptest.NewRequest("GET", (&url.URL{Scheme: "http", Host: "example.com", RawQuery: v.Encode()}).String(), nil)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, NewProfileResponseRenderer(log.NewNopLogger(), p, nil, req).Render(w))
+
+	res := w.Result()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+	require.Contains(t, w.Body.String(), `"sampleTypes":[{"type":"alloc_objects"`)
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// stackFrame is a single frame of a sample's stack, inlined functions get
+// frames of their own.
+type stackFrame struct {
+	Name string
+	File string
+	Line int64
+}
+
+// sampleStack returns the frames of the sample's stack, root first.
+func sampleStack(s *profile.Sample) []stackFrame {
+	frames := []stackFrame{}
+	for i := len(s.Location) - 1; i >= 0; i-- {
+		l := s.Location[i]
+		if len(l.Line) == 0 {
+			frames = append(frames, stackFrame{Name: fmt.Sprintf("0x%x", l.Address)})
+			continue
+		}
+		// The last line is the caller of the ones before it.
+		for j := len(l.Line) - 1; j >= 0; j-- {
+			line := l.Line[j]
+			if line.Function == nil {
+				frames = append(frames, stackFrame{Name: fmt.Sprintf("0x%x", l.Address), Line: line.Line})
+				continue
+			}
+			frames = append(frames, stackFrame{
+				Name: line.Function.Name,
+				File: line.Function.Filename,
+				Line: line.Line,
+			})
+		}
+	}
+	return frames
+}
+
+type FoldedRenderer struct {
+	profile     *profile.Profile
+	sampleIndex string
+}
+
+func NewFoldedRenderer(profile *profile.Profile, sampleIndex string) *FoldedRenderer {
+	return &FoldedRenderer{
+		profile:     profile,
+		sampleIndex: sampleIndex,
+	}
+}
+
+// Render writes the profile in Brendan Gregg's folded stack format, one line
+// per unique stack with its value of the selected sample type.
+func (r *FoldedRenderer) Render(w http.ResponseWriter) error {
+	stacks, err := foldedStacks(r.profile, r.sampleIndex)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for _, s := range stacks {
+		if _, err := fmt.Fprintf(bw, "%s %d\n", s.stack, s.value); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+type foldedStack struct {
+	stack string
+	value int64
+}
+
+// foldedStacks aggregates the samples of the profile by their stack. Stacks
+// are returned sorted, ones without value are left out.
+func foldedStacks(p *profile.Profile, sampleIndex string) ([]foldedStack, error) {
+	value, _, _, err := sampleFormat(p, sampleIndex, false)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]int64{}
+	for _, s := range p.Sample {
+		frames := sampleStack(s)
+		names := make([]string, 0, len(frames))
+		for _, f := range frames {
+			// Semicolons separate frames, so they can't be part of names.
+			names = append(names, strings.ReplaceAll(f.Name, ";", ":"))
+		}
+		values[strings.Join(names, ";")] += value(s.Value)
+	}
+
+	stacks := make([]foldedStack, 0, len(values))
+	for stack, v := range values {
+		if v == 0 || stack == "" {
+			continue
+		}
+		stacks = append(stacks, foldedStack{stack: stack, value: v})
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		return stacks[i].stack < stacks[j].stack
+	})
+	return stacks, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+// testStackProfile returns a profile with an inlined function and an
+// unsymbolized location.
+func testStackProfile() *profile.Profile {
+	var (
+		main    = &profile.Function{ID: 1, Name: "main.main", Filename: "main.go"}
+		handle  = &profile.Function{ID: 2, Name: "main.handle", Filename: "main.go"}
+		inlined = &profile.Function{ID: 3, Name: "main.inlined", Filename: "main.go"}
+
+		l1 = &profile.Location{ID: 1, Line: []profile.Line{{Function: main, Line: 10}}}
+		l2 = &profile.Location{ID: 2, Line: []profile.Line{{Function: inlined, Line: 30}, {Function: handle, Line: 20}}}
+		l3 = &profile.Location{ID: 3, Address: 0xcafe}
+	)
+	return &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10,
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{l2, l1}, Value: []int64{2, 20}},
+			{Location: []*profile.Location{l3, l1}, Value: []int64{1, 10}},
+			{Location: []*profile.Location{l2, l1}, Value: []int64{3, 30}},
+			{Location: []*profile.Location{l1}, Value: []int64{0, 5}},
+		},
+		Location: []*profile.Location{l1, l2, l3},
+		Function: []*profile.Function{main, handle, inlined},
+	}
+}
+
+func TestRenderFolded(t *testing.T) {
+	w := httptest.NewRecorder()
+	require.NoError(t, NewFoldedRenderer(testStackProfile(), "samples").Render(w))
+	require.Equal(t, "main.main;0xcafe 1\nmain.main;main.handle;main.inlined 5\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	require.NoError(t, NewFoldedRenderer(testStackProfile(), "cpu").Render(w))
+	require.Equal(t, "main.main 5\nmain.main;0xcafe 10\nmain.main;main.handle;main.inlined 50\n", w.Body.String())
+}
+
+func TestFoldedStacksTotal(t *testing.T) {
+	f, err := os.Open("testdata/alloc_objects.pb.gz")
+	require.NoError(t, err)
+	p, err := profile.Parse(f)
+	require.NoError(t, err)
+
+	index, err := p.SampleIndexByName("")
+	require.NoError(t, err)
+	var total int64
+	for _, s := range p.Sample {
+		total += s.Value[index]
+	}
+
+	stacks, err := foldedStacks(p, "")
+	require.NoError(t, err)
+	var foldedTotal int64
+	for _, s := range stacks {
+		foldedTotal += s.value
+	}
+	require.Equal(t, total, foldedTotal)
+}
@@ -19,6 +19,7 @@ import (
 	"net/http"
 	"time"
 
+	conprofchunkenc "github.com/conprof/conprof/pkg/chunkenc"
 	"github.com/conprof/db/storage"
 	"github.com/conprof/db/tsdb/chunkenc"
 	"github.com/google/pprof/profile"
@@ -48,7 +49,7 @@ type batchIterator struct {
 	maxBatchSize int64
 	err          error
 
-	batch [][]byte
+	batch []*profile.Profile
 }
 
 func newBatchIterator(set storage.SeriesSet, maxBatchSize int64) *batchIterator {
@@ -56,7 +57,7 @@ func newBatchIterator(set storage.SeriesSet, maxBatchSize int64) *batchIterator
 		set:          set,
 		curIterator:  nil,
 		maxBatchSize: maxBatchSize,
-		batch:        [][]byte{},
+		batch:        []*profile.Profile{},
 		err:          nil,
 	}
 }
@@ -65,36 +66,30 @@ func (i *batchIterator) Next() bool {
 	batchSize := int64(0)
 	i.batch = i.batch[:0]
 
-	// Finish previsous iterator if unfinished.
-	if i.curIterator != nil {
-		for i.curIterator.Next() {
-			_, b := i.curIterator.At()
-			if err := i.curIterator.Err(); err != nil {
-				i.err = i.curIterator.Err()
-				return false
-			}
-			i.batch = append(i.batch, b)
-			batchSize += int64(len(b))
-			if batchSize >= i.maxBatchSize {
-				return true
+	for {
+		// Finish previous iterator if unfinished.
+		if i.curIterator != nil {
+			for i.curIterator.Next() {
+				p, err := profileAt(i.curIterator)
+				if err != nil {
+					i.err = err
+					return false
+				}
+				i.batch = append(i.batch, p)
+				batchSize += profileSize(p)
+				if batchSize >= i.maxBatchSize {
+					return true
+				}
 			}
-		}
-	}
-	for i.set.Next() {
-		series := i.set.At()
-		i.curIterator = series.Iterator()
-		for i.curIterator.Next() {
-			_, b := i.curIterator.At()
 			if err := i.curIterator.Err(); err != nil {
-				i.err = i.curIterator.Err()
+				i.err = err
 				return false
 			}
-			i.batch = append(i.batch, b)
-			batchSize += int64(len(b))
-			if batchSize >= i.maxBatchSize {
-				return true
-			}
 		}
+		if !i.set.Next() {
+			break
+		}
+		i.curIterator = i.set.At().Iterator()
 	}
 	if err := i.set.Err(); err != nil {
 		i.err = i.set.Err()
@@ -105,7 +100,7 @@ func (i *batchIterator) Next() bool {
 	return len(i.batch) > 0
 }
 
-func (i *batchIterator) Batch() [][]byte {
+func (i *batchIterator) Batch() []*profile.Profile {
 	return i.batch
 }
 
@@ -113,6 +108,26 @@ func (i *batchIterator) Err() error {
 	return i.err
 }
 
+// profileSize estimates the size of the profile in the uncompressed pprof
+// format. Profiles decoded from the profile encoding share their tables, so
+// this overestimates the memory they use.
+func profileSize(p *profile.Profile) int64 {
+	n := 0
+	for _, s := range p.Sample {
+		n += 8 * (len(s.Location) + len(s.Value))
+	}
+	for _, l := range p.Location {
+		n += 16 + 16*len(l.Line)
+	}
+	for _, f := range p.Function {
+		n += 16 + len(f.Name) + len(f.SystemName) + len(f.Filename)
+	}
+	for _, m := range p.Mapping {
+		n += 32 + len(m.File) + len(m.BuildID)
+	}
+	return int64(n)
+}
+
 func (a *API) mergeProfiles(ctx context.Context, from, to time.Time, sel []*labels.Matcher) (*profile.Profile, storage.Warnings, *ApiError) {
 	q, err := a.db.Querier(ctx, timestamp.FromTime(from), timestamp.FromTime(to))
 	if err != nil {
@@ -139,49 +154,31 @@ func mergeSeriesSet(ctx context.Context, set storage.SeriesSet, maxMergeBatchSiz
 	var acc *profile.Profile = nil
 	count := 0
 	for bi.Next() {
-		profiles = profiles[:0]
 		batch := bi.Batch()
 
-		if acc == nil && len(batch) > 0 {
-			firstProfileBytes := batch[0]
-			var err error
-			acc, err = profile.ParseData(firstProfileBytes)
-			if err != nil {
-				return nil, 0, err
-			}
+		if acc == nil {
+			acc = batch[0]
+			count++
 
-			// Process all but the first profile as we have already parsed it
-			// to be the base profile.
+			// Merge all but the first profile into it, as it is the base
+			// profile.
 			batch = batch[1:]
 		}
 
-		for _, b := range batch {
-			select {
-			case <-ctx.Done():
-				return acc, count, ctx.Err()
-			default:
-			}
-
-			p, err := profile.ParseData(b)
-			if err != nil {
-				return acc, count, err
-			}
-			profiles = append(profiles, p)
-		}
-
 		select {
 		case <-ctx.Done():
 			return acc, count, ctx.Err()
 		default:
 		}
 
-		newAcc, err := profile.Merge(append([]*profile.Profile{acc}, profiles...))
+		profiles = append(append(profiles[:0], acc), batch...)
+		newAcc, err := profile.Merge(profiles)
 		if err != nil {
 			return acc, count, err
 		}
 
 		acc = newAcc
-		count += len(profiles)
+		count += len(batch)
 	}
 	if err := bi.Err(); err != nil {
 		return acc, count, bi.Err()
@@ -190,6 +187,16 @@ func mergeSeriesSet(ctx context.Context, set storage.SeriesSet, maxMergeBatchSiz
 	return acc, count, ctx.Err()
 }
 
+// profileAt returns the profile the iterator is at. Profiles of chunks in
+// the profile encoding are not parsed again.
+func profileAt(it chunkenc.Iterator) (*profile.Profile, error) {
+	if pit, ok := it.(conprofchunkenc.ProfileIterator); ok {
+		return pit.AtProfile()
+	}
+	_, b := it.At()
+	return profile.ParseData(b)
+}
+
 func (a *API) MergeProfiles(r *http.Request) (*profile.Profile, storage.Warnings, *ApiError) {
 	ctx := r.Context()
 
@@ -14,12 +14,14 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"testing"
 
 	"github.com/conprof/db/storage"
 	"github.com/conprof/db/tsdb/tsdbutil"
+	"github.com/google/pprof/profile"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/stretchr/testify/require"
 )
@@ -66,6 +68,27 @@ func (s *sliceSeriesSet) Warnings() storage.Warnings {
 	return nil
 }
 
+// periodSample returns a sample of a profile with a single value, which is
+// told apart by its period. The profile is 8 bytes in the batch size.
+func periodSample(t *testing.T, period int64) tsdbutil.Sample {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+		Sample:     []*profile.Sample{{Value: []int64{1}}},
+		Period:     period,
+	}
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, p.WriteUncompressed(buf))
+	return &sample{t: 0, v: buf.Bytes()}
+}
+
+func periods(batch []*profile.Profile) []int64 {
+	res := make([]int64, 0, len(batch))
+	for _, p := range batch {
+		res = append(res, p.Period)
+	}
+	return res
+}
+
 func TestBatchIteratorNoSeries(t *testing.T) {
 	set := newSliceSeriesSet([]storage.Series{})
 
@@ -85,44 +108,44 @@ func TestBatchIteratorNoSamples(t *testing.T) {
 func TestBatchIteratorSingleSeries(t *testing.T) {
 	set := newSliceSeriesSet([]storage.Series{
 		storage.NewListSeries(labels.Labels{{Name: "instance", Value: "a"}}, []tsdbutil.Sample{
-			&sample{t: 0, v: []byte("a")},
-			&sample{t: 0, v: []byte("b")},
-			&sample{t: 0, v: []byte("c")},
-			&sample{t: 0, v: []byte("d")},
-			&sample{t: 0, v: []byte("e")},
+			periodSample(t, 1),
+			periodSample(t, 2),
+			periodSample(t, 3),
+			periodSample(t, 4),
+			periodSample(t, 5),
 		}),
 	})
 
-	i := newBatchIterator(set, 2)
+	i := newBatchIterator(set, 16)
 	require.True(t, i.Next())
-	require.EqualValues(t, [][]byte{[]byte("a"), []byte("b")}, i.Batch())
+	require.Equal(t, []int64{1, 2}, periods(i.Batch()))
 	require.True(t, i.Next())
-	require.EqualValues(t, [][]byte{[]byte("c"), []byte("d")}, i.Batch())
+	require.Equal(t, []int64{3, 4}, periods(i.Batch()))
 	require.True(t, i.Next())
-	require.EqualValues(t, [][]byte{[]byte("e")}, i.Batch())
+	require.Equal(t, []int64{5}, periods(i.Batch()))
 	require.False(t, i.Next())
 }
 
 func TestBatchIteratorMultipleSeries(t *testing.T) {
 	set := newSliceSeriesSet([]storage.Series{
 		storage.NewListSeries(labels.Labels{{Name: "instance", Value: "a"}}, []tsdbutil.Sample{
-			&sample{t: 0, v: []byte("a")},
+			periodSample(t, 1),
 		}),
 		storage.NewListSeries(labels.Labels{{Name: "instance", Value: "b"}}, []tsdbutil.Sample{
-			&sample{t: 0, v: []byte("b")},
-			&sample{t: 0, v: []byte("c")},
-			&sample{t: 0, v: []byte("d")},
-			&sample{t: 0, v: []byte("e")},
+			periodSample(t, 2),
+			periodSample(t, 3),
+			periodSample(t, 4),
+			periodSample(t, 5),
 		}),
 	})
 
-	i := newBatchIterator(set, 2)
+	i := newBatchIterator(set, 16)
 	require.True(t, i.Next())
-	require.EqualValues(t, [][]byte{[]byte("a"), []byte("b")}, i.Batch())
+	require.Equal(t, []int64{1, 2}, periods(i.Batch()))
 	require.True(t, i.Next())
-	require.EqualValues(t, [][]byte{[]byte("c"), []byte("d")}, i.Batch())
+	require.Equal(t, []int64{3, 4}, periods(i.Batch()))
 	require.True(t, i.Next())
-	require.EqualValues(t, [][]byte{[]byte("e")}, i.Batch())
+	require.Equal(t, []int64{5}, periods(i.Batch()))
 	require.False(t, i.Next())
 }
 
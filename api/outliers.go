@@ -0,0 +1,326 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/conprof/db/storage"
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+const (
+	// defaultOutlierLimit is the number of most anomalous series returned.
+	defaultOutlierLimit = 10
+	// defaultOutlierTop is the number of functions with the highest flat
+	// values each series is compared by.
+	defaultOutlierTop = 20
+	// maxOutlierFunctions is the number of functions returned per series,
+	// that contribute most to its distance from the median.
+	maxOutlierFunctions = 5
+)
+
+type outlierFunction struct {
+	Name string `json:"name"`
+	// Value is the function's share of the series' total.
+	Value float64 `json:"value"`
+	// Median is the function's median share across all series.
+	Median float64 `json:"median"`
+	Diff   float64 `json:"diff"`
+}
+
+type outlierSeries struct {
+	Labels    map[string]string `json:"labels"`
+	Distance  float64           `json:"distance"`
+	Profiles  int               `json:"profiles"`
+	Total     int64             `json:"total"`
+	Functions []outlierFunction `json:"functions"`
+}
+
+type outlierReport struct {
+	// Compared is the number of series that were compared.
+	Compared int             `json:"compared"`
+	Series   []outlierSeries `json:"series"`
+}
+
+// seriesVector is the normalized top-N function vector of a series.
+type seriesVector struct {
+	labels   labels.Labels
+	profiles int
+	total    int64
+	shares   map[string]float64
+}
+
+// Outliers merges the profiles of each series matching the query within the
+// time window, and ranks the series by the distance of their normalized top
+// functions from the median of all series.
+func (a *API) Outliers(r *http.Request) (interface{}, []error, *ApiError) {
+	ctx, cancel := context.WithTimeout(r.Context(), a.queryTimeout)
+	defer cancel()
+
+	from, err := parseTime(r.URL.Query().Get("from"))
+	if err != nil {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: fmt.Errorf("failed to parse \"from\" time: %w", err)}
+	}
+
+	to, err := parseTime(r.URL.Query().Get("to"))
+	if err != nil {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: fmt.Errorf("failed to parse \"to\" time: %w", err)}
+	}
+
+	if to.Before(from) {
+		err := errors.New("to timestamp must not be before from time")
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: err}
+	}
+
+	limit, err := parsePositiveInt(r, "limit", defaultOutlierLimit)
+	if err != nil {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: err}
+	}
+
+	top, err := parsePositiveInt(r, "top", defaultOutlierTop)
+	if err != nil {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: err}
+	}
+
+	queryString := r.URL.Query().Get("query")
+	if queryString == "" {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: errors.New("query cannot be empty")}
+	}
+
+	sel, err := parser.ParseMetricSelector(queryString)
+	if err != nil {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: err}
+	}
+
+	filter, err := parseProfileFilter(r.URL.Query())
+	if err != nil {
+		return nil, nil, &ApiError{Typ: ErrorBadData, Err: err}
+	}
+
+	q, err := a.db.Querier(ctx, timestamp.FromTime(from), timestamp.FromTime(to))
+	if err != nil {
+		return nil, nil, &ApiError{Typ: ErrorExec, Err: err}
+	}
+
+	sampleIndex := r.URL.Query().Get("sample_index")
+	vectors := []*seriesVector{}
+	var warnings []error
+
+	set := q.Select(true, nil, sel...)
+	for set.Next() {
+		series := set.At()
+
+		p, count, err := mergeSeries(ctx, series, a.maxMergeBatchSize)
+		if err != nil {
+			return nil, nil, &ApiError{Typ: ErrorInternal, Err: fmt.Errorf("merge profiles of %s: %w", series.Labels(), err)}
+		}
+		if p == nil {
+			continue
+		}
+
+		if err := a.symbolizeProfile(ctx, p); err != nil {
+			return nil, nil, &ApiError{Typ: ErrorInternal, Err: err}
+		}
+		// Series not matching the filters are skipped below, as they have
+		// no samples left, so their warnings are not reported.
+		filter.apply(p)
+
+		v, err := topVector(p, sampleIndex, top)
+		if err != nil {
+			return nil, nil, &ApiError{Typ: ErrorBadData, Err: fmt.Errorf("series %s: %w", series.Labels(), err)}
+		}
+		if v.total == 0 {
+			warnings = append(warnings, fmt.Errorf("skipped series %s without samples", series.Labels()))
+			continue
+		}
+		v.labels = series.Labels()
+		v.profiles = count
+		vectors = append(vectors, v)
+	}
+	if err := set.Err(); err != nil {
+		return nil, nil, &ApiError{Typ: ErrorInternal, Err: err}
+	}
+	warnings = append(warnings, set.Warnings()...)
+
+	return rankOutliers(vectors, limit), warnings, nil
+}
+
+func parsePositiveInt(r *http.Request, name string, defaultValue int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return defaultValue, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q: %w", name, err)
+	}
+	if i <= 0 {
+		return 0, fmt.Errorf("%q must be positive", name)
+	}
+	return i, nil
+}
+
+// mergeSeries merges all profiles of the series in batches of at most
+// maxMergeBatchSize bytes, it returns nil if the series has no profiles.
+func mergeSeries(ctx context.Context, series storage.Series, maxMergeBatchSize int64) (*profile.Profile, int, error) {
+	return mergeSeriesSet(ctx, &singleSeriesSet{series: series}, maxMergeBatchSize)
+}
+
+// singleSeriesSet is a series set of a single series.
+type singleSeriesSet struct {
+	series storage.Series
+	done   bool
+}
+
+func (s *singleSeriesSet) Next() bool {
+	if s.done {
+		return false
+	}
+	s.done = true
+	return true
+}
+
+func (s *singleSeriesSet) At() storage.Series         { return s.series }
+func (s *singleSeriesSet) Err() error                 { return nil }
+func (s *singleSeriesSet) Warnings() storage.Warnings { return nil }
+
+// topVector returns the shares of the total of the top functions by flat
+// value.
+func topVector(p *profile.Profile, sampleIndex string, top int) (*seriesVector, error) {
+	rep, err := generateTopReport(p, sampleIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &seriesVector{
+		total:  rep.Total,
+		shares: map[string]float64{},
+	}
+	if rep.Total == 0 {
+		return v, nil
+	}
+
+	items := rep.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		return abs64(items[i].Flat) > abs64(items[j].Flat)
+	})
+	if len(items) > top {
+		items = items[:top]
+	}
+	for _, i := range items {
+		if i.Flat == 0 {
+			continue
+		}
+		v.shares[i.Name] += float64(i.Flat) / float64(rep.Total)
+	}
+	return v, nil
+}
+
+// rankOutliers ranks the vectors by their Euclidean distance from the
+// per-function median of all vectors.
+func rankOutliers(vectors []*seriesVector, limit int) *outlierReport {
+	names := map[string]struct{}{}
+	for _, v := range vectors {
+		for name := range v.shares {
+			names[name] = struct{}{}
+		}
+	}
+
+	median := make(map[string]float64, len(names))
+	values := make([]float64, len(vectors))
+	for name := range names {
+		for i, v := range vectors {
+			values[i] = v.shares[name]
+		}
+		median[name] = medianOf(values)
+	}
+
+	res := &outlierReport{
+		Compared: len(vectors),
+		Series:   make([]outlierSeries, 0, len(vectors)),
+	}
+	for _, v := range vectors {
+		s := outlierSeries{
+			Labels:    v.labels.Map(),
+			Profiles:  v.profiles,
+			Total:     v.total,
+			Functions: make([]outlierFunction, 0, len(names)),
+		}
+		sum := 0.0
+		for name := range names {
+			diff := v.shares[name] - median[name]
+			sum += diff * diff
+			if diff == 0 {
+				continue
+			}
+			s.Functions = append(s.Functions, outlierFunction{
+				Name:   name,
+				Value:  v.shares[name],
+				Median: median[name],
+				Diff:   diff,
+			})
+		}
+		s.Distance = math.Sqrt(sum)
+
+		sort.Slice(s.Functions, func(i, j int) bool {
+			di, dj := math.Abs(s.Functions[i].Diff), math.Abs(s.Functions[j].Diff)
+			if di != dj {
+				return di > dj
+			}
+			return s.Functions[i].Name < s.Functions[j].Name
+		})
+		if len(s.Functions) > maxOutlierFunctions {
+			s.Functions = s.Functions[:maxOutlierFunctions]
+		}
+		res.Series = append(res.Series, s)
+	}
+
+	sort.SliceStable(res.Series, func(i, j int) bool {
+		return res.Series[i].Distance > res.Series[j].Distance
+	})
+	if len(res.Series) > limit {
+		res.Series = res.Series[:limit]
+	}
+	return res
+}
+
+// medianOf returns the median of the values, which are sorted in place.
+func medianOf(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+func abs64(i int64) int64 {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/conprof/conprof/pkg/testutil"
+)
+
+// functionProfile returns a CPU profile with a sample of the given value per
+// function.
+func functionProfile(t *testing.T, values map[string]int64) []byte {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+	}
+	for name, v := range values {
+		fn := &profile.Function{ID: uint64(len(p.Function) + 1), Name: name}
+		loc := &profile.Location{ID: fn.ID, Line: []profile.Line{{Function: fn}}}
+		p.Function = append(p.Function, fn)
+		p.Location = append(p.Location, loc)
+		p.Sample = append(p.Sample, &profile.Sample{Location: []*profile.Location{loc}, Value: []int64{v}})
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, p.Write(buf))
+	return buf.Bytes()
+}
+
+func TestAPIOutliers(t *testing.T) {
+	db, err := testutil.NewTSDB()
+	require.NoError(t, err)
+	defer db.Close()
+
+	app := db.Appender(context.Background())
+	for i := 0; i < 5; i++ {
+		lbl := labels.FromStrings("__name__", "cpu", "instance", fmt.Sprintf("pod-%d", i))
+		values := map[string]int64{"main.serve": 500, "main.encode": 400, "runtime.mallocgc": 100}
+		if i == 3 {
+			values = map[string]int64{"main.serve": 200, "main.encode": 100, "runtime.mallocgc": 100, "main.retry": 600}
+		}
+		for ts := int64(1); ts <= 3; ts++ {
+			_, err := app.Add(lbl, ts, functionProfile(t, values))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, app.Commit())
+
+	// Merging in batches of a single profile yields the same result.
+	for _, batchSize := range []int64{DefaultMergeBatchSize, 1} {
+		api := New(log.NewNopLogger(), prometheus.NewRegistry(), WithDB(db), WithQueryTimeout(time.Minute), WithMaxMergeBatchSize(batchSize))
+		resp, warn, apiErr := executeEndpoint(t, endpointTestCase{
+			endpoint: api.Outliers,
+			query: url.Values{
+				"query": []string{"cpu"},
+				"from":  []string{"0"},
+				"to":    []string{"10"},
+				"limit": []string{"2"},
+			},
+		})
+		require.Nil(t, apiErr)
+		require.Empty(t, warn)
+
+		res := resp.(*outlierReport)
+		require.Equal(t, 5, res.Compared)
+		require.Len(t, res.Series, 2)
+
+		outlier := res.Series[0]
+		require.Equal(t, "pod-3", outlier.Labels["instance"])
+		require.Equal(t, 3, outlier.Profiles)
+		require.Equal(t, int64(3000), outlier.Total)
+		require.Greater(t, outlier.Distance, 0.5)
+		require.Equal(t, "main.retry", outlier.Functions[0].Name)
+		require.InDelta(t, 0.6, outlier.Functions[0].Value, 1e-9)
+		require.InDelta(t, 0.0, outlier.Functions[0].Median, 1e-9)
+
+		// All other instances match the median.
+		require.Equal(t, 0.0, res.Series[1].Distance)
+		require.Empty(t, res.Series[1].Functions)
+	}
+
+	api := New(log.NewNopLogger(), prometheus.NewRegistry(), WithDB(db), WithQueryTimeout(time.Minute))
+
+	for _, q := range []url.Values{
+		{"from": []string{"0"}, "to": []string{"10"}},
+		{"query": []string{"cpu"}, "from": []string{"10"}, "to": []string{"0"}},
+		{"query": []string{"cpu"}, "from": []string{"0"}, "to": []string{"10"}, "top": []string{"0"}},
+	} {
+		_, _, apiErr := executeEndpoint(t, endpointTestCase{endpoint: api.Outliers, query: q})
+		require.NotNil(t, apiErr)
+		require.Equal(t, ErrorBadData, apiErr.Typ)
+	}
+}
+
+func TestMedianOf(t *testing.T) {
+	require.Equal(t, 0.0, medianOf(nil))
+	require.Equal(t, 2.0, medianOf([]float64{3, 1, 2}))
+	require.Equal(t, 2.5, medianOf([]float64{4, 1, 3, 2}))
+}
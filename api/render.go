@@ -25,6 +25,8 @@ type ProfileResponseRenderer struct {
 	profile  *profile.Profile
 	warnings []error
 	req      *http.Request
+	sources  SourceReader
+	binaries BinaryDownloader
 }
 
 func NewProfileResponseRenderer(
@@ -57,6 +59,39 @@ func (r *ProfileResponseRenderer) Render(w http.ResponseWriter) error {
 		}
 
 		return NewSuccessResponse(top, r.warnings).Render(w)
+	case "tags":
+		tags, err := generateTagsReport(r.profile, r.req.URL.Query().Get("sample_index"))
+		if err != nil {
+			return err
+		}
+
+		return NewSuccessResponse(tags, r.warnings).Render(w)
+	case "source":
+		src, err := generateSourceReport(
+			r.req.Context(),
+			r.profile,
+			r.req.URL.Query().Get("sample_index"),
+			r.req.URL.Query().Get("function"),
+			r.sources,
+		)
+		if err != nil {
+			return err
+		}
+
+		return NewSuccessResponse(src, r.warnings).Render(w)
+	case "disasm":
+		dis, err := generateDisasmReport(
+			r.req.Context(),
+			r.profile,
+			r.req.URL.Query().Get("sample_index"),
+			r.req.URL.Query().Get("function"),
+			r.binaries,
+		)
+		if err != nil {
+			return err
+		}
+
+		return NewSuccessResponse(dis, r.warnings).Render(w)
 	case "flamegraph":
 		fg, err := generateFlamegraphReport(r.profile, r.req.URL.Query().Get("sample_index"))
 		if err != nil {
@@ -64,8 +99,18 @@ func (r *ProfileResponseRenderer) Render(w http.ResponseWriter) error {
 		}
 
 		return NewSuccessResponse(fg, r.warnings).Render(w)
+	case "flamegraph_html":
+		return NewFlamegraphHTMLRenderer(
+			r.profile,
+			r.req.URL.Query().Get("sample_index"),
+			r.req.URL.Query().Get("mode") == "diff",
+		).Render(w)
 	case "proto":
 		return NewProtoRenderer(r.profile).Render(w)
+	case "folded":
+		return NewFoldedRenderer(r.profile, r.req.URL.Query().Get("sample_index")).Render(w)
+	case "speedscope":
+		return NewSpeedscopeRenderer(r.profile, r.req.URL.Query().Get("sample_index")).Render(w)
 	case "svg":
 		return NewSVGRenderer(
 			r.logger,
@@ -0,0 +1,175 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/conprof/conprof/internal/pprof/report"
+)
+
+// SourceReader returns the source files binaries were compiled from, it
+// returns an error if the source file isn't available.
+type SourceReader interface {
+	Source(ctx context.Context, buildID, path string) ([]byte, error)
+}
+
+type sourceLine struct {
+	Line       int    `json:"line"`
+	Flat       int64  `json:"flat,omitempty"`
+	Cum        int64  `json:"cum,omitempty"`
+	FlatFormat string `json:"flatFormat,omitempty"`
+	CumFormat  string `json:"cumFormat,omitempty"`
+	Source     string `json:"source"`
+}
+
+type sourceFunction struct {
+	Name       string       `json:"name"`
+	File       string       `json:"file"`
+	Flat       int64        `json:"flat"`
+	Cum        int64        `json:"cum"`
+	FlatFormat string       `json:"flatFormat,omitempty"`
+	CumFormat  string       `json:"cumFormat,omitempty"`
+	Lines      []sourceLine `json:"lines,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
+
+type sourceReport struct {
+	Total     int64            `json:"total"`
+	Functions []sourceFunction `json:"functions"`
+}
+
+// generateSourceReport annotates the source lines of the functions matching
+// the regexp with their cost. Source files are read from the sources uploaded
+// for the binaries of the profile.
+func generateSourceReport(ctx context.Context, p *profile.Profile, sampleIndex, function string, sources SourceReader) (*sourceReport, error) {
+	if function == "" {
+		return nil, errors.New("function must be specified for source reports")
+	}
+	rx, err := regexp.Compile(function)
+	if err != nil {
+		return nil, fmt.Errorf("invalid function regexp: %w", err)
+	}
+
+	numLabelUnits, _ := p.NumLabelUnits()
+	err = p.Aggregate(true, true, true, true, false)
+	if err != nil {
+		return nil, err
+	}
+
+	value, meanDiv, sample, err := sampleFormat(p, sampleIndex, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := report.New(p, &report.Options{
+		OutputFormat:  report.List,
+		OutputUnit:    "minimum",
+		Ratio:         1,
+		NumLabelUnits: numLabelUnits,
+
+		SampleValue:       value,
+		SampleMeanDivisor: meanDiv,
+		SampleType:        sample.Type,
+		SampleUnit:        sample.Unit,
+
+		Symbol: rx,
+	})
+
+	functions := report.SourceListing(rep, sourceOpener(ctx, p, sources))
+	res := &sourceReport{
+		Total:     rep.Total(),
+		Functions: make([]sourceFunction, 0, len(functions)),
+	}
+	for _, f := range functions {
+		fn := sourceFunction{
+			Name:       f.Name,
+			File:       f.File,
+			Flat:       f.Flat,
+			Cum:        f.Cum,
+			FlatFormat: rep.FormatValue(f.Flat),
+			CumFormat:  rep.FormatValue(f.Cum),
+		}
+		if f.Err != nil {
+			fn.Error = f.Err.Error()
+		}
+		for _, l := range f.Lines {
+			line := sourceLine{
+				Line:   l.Line,
+				Flat:   l.Flat,
+				Cum:    l.Cum,
+				Source: l.Source,
+			}
+			if l.Flat != 0 {
+				line.FlatFormat = rep.FormatValue(l.Flat)
+			}
+			if l.Cum != 0 {
+				line.CumFormat = rep.FormatValue(l.Cum)
+			}
+			fn.Lines = append(fn.Lines, line)
+		}
+		res.Functions = append(res.Functions, fn)
+	}
+	return res, nil
+}
+
+// sourceOpener opens source files from the sources uploaded for the build IDs
+// of the mappings whose functions reference them.
+func sourceOpener(ctx context.Context, p *profile.Profile, sources SourceReader) report.SourceOpener {
+	buildIDs := map[string][]string{}
+	seen := map[string]bool{}
+	for _, l := range p.Location {
+		if l.Mapping == nil || l.Mapping.BuildID == "" {
+			continue
+		}
+		for _, line := range l.Line {
+			if line.Function == nil || line.Function.Filename == "" {
+				continue
+			}
+			key := line.Function.Filename + "\x00" + l.Mapping.BuildID
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			buildIDs[line.Function.Filename] = append(buildIDs[line.Function.Filename], l.Mapping.BuildID)
+		}
+	}
+
+	return func(path string) (io.ReadCloser, error) {
+		if sources == nil {
+			return nil, errors.New("no symbol server configured to read sources from")
+		}
+		var lastErr error
+		for _, id := range buildIDs[path] {
+			b, err := sources.Source(ctx, id, path)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+		if lastErr != nil {
+			return nil, fmt.Errorf("read source %s: %w", path, lastErr)
+		}
+		return nil, fmt.Errorf("no source uploaded for %s", path)
+	}
+}
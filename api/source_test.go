@@ -0,0 +1,134 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSourceReader map[string]string
+
+func (r fakeSourceReader) Source(ctx context.Context, buildID, path string) ([]byte, error) {
+	src, ok := r[buildID+":"+path]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(src), nil
+}
+
+const testSource = `package main
+
+func main() {
+	for {
+		work()
+	}
+}
+
+func work() {
+	a := 0
+	for i := 0; i < 100; i++ {
+		a += i
+	}
+}
+`
+
+func testSourceProfile() *profile.Profile {
+	m := &profile.Mapping{ID: 1, Start: 0x1000, Limit: 0x2000, File: "/app", BuildID: "abcd"}
+	mainFn := &profile.Function{ID: 1, Name: "main.main", Filename: "/src/main.go", StartLine: 3}
+	workFn := &profile.Function{ID: 2, Name: "main.work", Filename: "/src/main.go", StartLine: 9}
+	l1 := &profile.Location{ID: 1, Mapping: m, Address: 0x1010, Line: []profile.Line{{Function: mainFn, Line: 5}}}
+	l2 := &profile.Location{ID: 2, Mapping: m, Address: 0x1020, Line: []profile.Line{{Function: workFn, Line: 12}}}
+	l3 := &profile.Location{ID: 3, Mapping: m, Address: 0x1030, Line: []profile.Line{{Function: workFn, Line: 11}}}
+	return &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{l2, l1}, Value: []int64{300}},
+			{Location: []*profile.Location{l3, l1}, Value: []int64{100}},
+		},
+		Location: []*profile.Location{l1, l2, l3},
+		Function: []*profile.Function{mainFn, workFn},
+		Mapping:  []*profile.Mapping{m},
+	}
+}
+
+func TestRenderSource(t *testing.T) {
+	v := url.Values{}
+	v.Set("report", "source")
+	v.Set("function", "main.work")
+	req := httptest.NewRequest("GET", (&url.URL{Scheme: "http", Host: "example.com", RawQuery: v.Encode()}).String(), nil)
+
+	r := NewProfileResponseRenderer(log.NewNopLogger(), testSourceProfile(), nil, req)
+	r.sources = fakeSourceReader{"abcd:/src/main.go": testSource}
+
+	w := httptest.NewRecorder()
+	require.NoError(t, r.Render(w))
+	require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	res := struct {
+		Data sourceReport `json:"data"`
+	}{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	require.Equal(t, int64(400), res.Data.Total)
+	require.Len(t, res.Data.Functions, 1)
+
+	fn := res.Data.Functions[0]
+	require.Equal(t, "main.work", fn.Name)
+	require.Equal(t, "/src/main.go", fn.File)
+	require.Equal(t, int64(400), fn.Flat)
+	require.Empty(t, fn.Error)
+
+	lines := map[int]sourceLine{}
+	for _, l := range fn.Lines {
+		lines[l.Line] = l
+	}
+	require.Equal(t, "func work() {", lines[9].Source)
+	require.Equal(t, int64(100), lines[11].Flat)
+	require.Equal(t, "\tfor i := 0; i < 100; i++ {", lines[11].Source)
+	require.Equal(t, int64(300), lines[12].Flat)
+	require.Equal(t, int64(0), lines[10].Flat)
+}
+
+func TestRenderSourceWithoutSources(t *testing.T) {
+	v := url.Values{}
+	v.Set("report", "source")
+	v.Set("function", "main.main")
+	req := httptest.NewRequest("GET", (&url.URL{Scheme: "http", Host: "example.com", RawQuery: v.Encode()}).String(), nil)
+
+	r := NewProfileResponseRenderer(log.NewNopLogger(), testSourceProfile(), nil, req)
+	w := httptest.NewRecorder()
+	require.NoError(t, r.Render(w))
+
+	res := struct {
+		Data sourceReport `json:"data"`
+	}{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	require.Len(t, res.Data.Functions, 1)
+	require.Equal(t, int64(400), res.Data.Functions[0].Cum)
+	require.NotEmpty(t, res.Data.Functions[0].Error)
+
+	// The function is required.
+	v.Del("function")
+	req = httptest.NewRequest("GET", (&url.URL{Scheme: "http", Host: "example.com", RawQuery: v.Encode()}).String(), nil)
+	require.Error(t, NewProfileResponseRenderer(log.NewNopLogger(), testSourceProfile(), nil, req).Render(httptest.NewRecorder()))
+}
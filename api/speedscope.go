@@ -0,0 +1,140 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/pprof/profile"
+)
+
+const speedscopeSchema = "https://www.speedscope.app/file-format-schema.json"
+
+// SpeedscopeFile is the subset of the speedscope file format, see
+// https://github.com/jlfwong/speedscope/wiki/Importing-from-custom-sources,
+// needed for sampled profiles.
+type SpeedscopeFile struct {
+	Schema             string              `json:"$schema"`
+	Shared             SpeedscopeShared    `json:"shared"`
+	Profiles           []SpeedscopeProfile `json:"profiles"`
+	Name               string              `json:"name,omitempty"`
+	ActiveProfileIndex int                 `json:"activeProfileIndex"`
+	Exporter           string              `json:"exporter"`
+}
+
+type SpeedscopeShared struct {
+	Frames []SpeedscopeFrame `json:"frames"`
+}
+
+type SpeedscopeFrame struct {
+	Name string `json:"name"`
+	File string `json:"file,omitempty"`
+	Line int64  `json:"line,omitempty"`
+}
+
+type SpeedscopeProfile struct {
+	Type       string  `json:"type"`
+	Name       string  `json:"name"`
+	Unit       string  `json:"unit"`
+	StartValue int64   `json:"startValue"`
+	EndValue   int64   `json:"endValue"`
+	Samples    [][]int `json:"samples"`
+	Weights    []int64 `json:"weights"`
+}
+
+type SpeedscopeRenderer struct {
+	profile     *profile.Profile
+	sampleIndex string
+}
+
+func NewSpeedscopeRenderer(profile *profile.Profile, sampleIndex string) *SpeedscopeRenderer {
+	return &SpeedscopeRenderer{
+		profile:     profile,
+		sampleIndex: sampleIndex,
+	}
+}
+
+func (r *SpeedscopeRenderer) Render(w http.ResponseWriter) error {
+	f, err := generateSpeedscopeFile(r.profile, r.sampleIndex)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment;filename=profile.speedscope.json")
+	return json.NewEncoder(w).Encode(f)
+}
+
+// generateSpeedscopeFile converts the profile into a speedscope file with a
+// profile per sample type, the selected one being active.
+func generateSpeedscopeFile(p *profile.Profile, sampleIndex string) (*SpeedscopeFile, error) {
+	index, err := p.SampleIndexByName(sampleIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &SpeedscopeFile{
+		Schema:             speedscopeSchema,
+		Shared:             SpeedscopeShared{Frames: []SpeedscopeFrame{}},
+		Profiles:           make([]SpeedscopeProfile, 0, len(p.SampleType)),
+		ActiveProfileIndex: index,
+		Exporter:           "conprof",
+	}
+	for _, st := range p.SampleType {
+		f.Profiles = append(f.Profiles, SpeedscopeProfile{
+			Type:    "sampled",
+			Name:    st.Type,
+			Unit:    speedscopeUnit(st.Unit),
+			Samples: [][]int{},
+			Weights: []int64{},
+		})
+	}
+
+	frames := map[SpeedscopeFrame]int{}
+	for _, s := range p.Sample {
+		stack := []int{}
+		for _, sf := range sampleStack(s) {
+			frame := SpeedscopeFrame{Name: sf.Name, File: sf.File}
+			i, ok := frames[frame]
+			if !ok {
+				i = len(f.Shared.Frames)
+				frames[frame] = i
+				f.Shared.Frames = append(f.Shared.Frames, frame)
+			}
+			stack = append(stack, i)
+		}
+
+		for i, v := range s.Value {
+			if v == 0 {
+				continue
+			}
+			f.Profiles[i].Samples = append(f.Profiles[i].Samples, stack)
+			f.Profiles[i].Weights = append(f.Profiles[i].Weights, v)
+			f.Profiles[i].EndValue += v
+		}
+	}
+
+	return f, nil
+}
+
+// speedscopeUnit maps pprof units to the ones speedscope knows about.
+func speedscopeUnit(unit string) string {
+	switch unit {
+	case "nanoseconds", "microseconds", "milliseconds", "seconds", "bytes":
+		return unit
+	default:
+		return "none"
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderSpeedscope(t *testing.T) {
+	w := httptest.NewRecorder()
+	require.NoError(t, NewSpeedscopeRenderer(testStackProfile(), "cpu").Render(w))
+
+	f := &SpeedscopeFile{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), f))
+
+	require.Equal(t, speedscopeSchema, f.Schema)
+	require.Equal(t, 1, f.ActiveProfileIndex)
+	require.Equal(t, []SpeedscopeFrame{
+		{Name: "main.main", File: "main.go"},
+		{Name: "main.handle", File: "main.go"},
+		{Name: "main.inlined", File: "main.go"},
+		{Name: "0xcafe"},
+	}, f.Shared.Frames)
+
+	require.Len(t, f.Profiles, 2)
+	require.Equal(t, SpeedscopeProfile{
+		Type:     "sampled",
+		Name:     "samples",
+		Unit:     "none",
+		EndValue: 6,
+		Samples:  [][]int{{0, 1, 2}, {0, 3}, {0, 1, 2}},
+		Weights:  []int64{2, 1, 3},
+	}, f.Profiles[0])
+	require.Equal(t, SpeedscopeProfile{
+		Type:     "sampled",
+		Name:     "cpu",
+		Unit:     "nanoseconds",
+		EndValue: 65,
+		Samples:  [][]int{{0, 1, 2}, {0, 3}, {0, 1, 2}, {0}},
+		Weights:  []int64{20, 10, 30, 5},
+	}, f.Profiles[1])
+}
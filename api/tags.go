@@ -0,0 +1,172 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"strings"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/conprof/conprof/internal/pprof/measurement"
+	"github.com/conprof/conprof/internal/pprof/report"
+)
+
+type tagValue struct {
+	Value      string `json:"value"`
+	Flat       int64  `json:"flat"`
+	FlatFormat string `json:"flatFormat,omitempty"`
+}
+
+type tagKey struct {
+	Key         string     `json:"key"`
+	Total       int64      `json:"total"`
+	TotalFormat string     `json:"totalFormat,omitempty"`
+	Values      []tagValue `json:"values"`
+}
+
+type tagsReport struct {
+	Total int64    `json:"total"`
+	Tags  []tagKey `json:"tags"`
+}
+
+// generateTagsReport lists the sample label keys and values of the profile
+// with the total value of the samples they are attached to, except for the
+// label marking the base samples of diffs.
+func generateTagsReport(p *profile.Profile, sampleIndex string) (*tagsReport, error) {
+	numLabelUnits, _ := p.NumLabelUnits()
+
+	value, meanDiv, sample, err := sampleFormat(p, sampleIndex, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := report.New(p, &report.Options{
+		OutputFormat:  report.Tags,
+		OutputUnit:    "minimum",
+		Ratio:         1,
+		NumLabelUnits: numLabelUnits,
+
+		SampleValue:       value,
+		SampleMeanDivisor: meanDiv,
+		SampleType:        sample.Type,
+		SampleUnit:        sample.Unit,
+	})
+
+	items := report.TagItems(rep)
+	res := &tagsReport{
+		Total: rep.Total(),
+		Tags:  make([]tagKey, 0, len(items)),
+	}
+	for _, i := range items {
+		if i.Key == diffBaseLabel {
+			// The base samples of diffs are marked by a label, which isn't
+			// a tag of the profiles.
+			continue
+		}
+		k := tagKey{
+			Key:         i.Key,
+			Total:       i.Total,
+			TotalFormat: i.TotalFormat,
+			Values:      make([]tagValue, 0, len(i.Values)),
+		}
+		for _, v := range i.Values {
+			k.Values = append(k.Values, tagValue{
+				Value:      v.Value,
+				Flat:       v.Flat,
+				FlatFormat: v.FlatFormat,
+			})
+		}
+		res.Tags = append(res.Tags, k)
+	}
+	return res, nil
+}
+
+// parseTagKeys parses a comma separated list of label keys.
+func parseTagKeys(s string) []string {
+	var keys []string
+	for _, k := range strings.Split(s, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// groupByTags adds a pseudo frame per key to the root of every sample's
+// stack, named after the sample's values of the label, so that the samples
+// of every label value are grouped in all reports. Samples without the
+// label are grouped as "<key>=<none>".
+func groupByTags(p *profile.Profile, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	numLabelUnits, _ := p.NumLabelUnits()
+
+	var maxLocID, maxFunctionID uint64
+	for _, l := range p.Location {
+		if l.ID > maxLocID {
+			maxLocID = l.ID
+		}
+	}
+	for _, f := range p.Function {
+		if f.ID > maxFunctionID {
+			maxFunctionID = f.ID
+		}
+	}
+
+	locs := map[string]*profile.Location{}
+	internLoc := func(name, key string) *profile.Location {
+		if l, ok := locs[name]; ok {
+			return l
+		}
+		maxFunctionID++
+		fn := &profile.Function{ID: maxFunctionID, Name: name, SystemName: name, Filename: key}
+		p.Function = append(p.Function, fn)
+
+		maxLocID++
+		l := &profile.Location{ID: maxLocID, Line: []profile.Line{{Function: fn}}}
+		p.Location = append(p.Location, l)
+		locs[name] = l
+		return l
+	}
+
+	for _, s := range p.Sample {
+		roots := make([]*profile.Location, 0, len(keys))
+		// The first key is closest to the root, locations are ordered from
+		// the leaf to the root.
+		for i := len(keys) - 1; i >= 0; i-- {
+			key := keys[i]
+			values := tagValues(s, key, numLabelUnits[key])
+			name := key + "=<none>"
+			if len(values) > 0 {
+				name = key + "=" + strings.Join(values, ",")
+			}
+			roots = append(roots, internLoc(name, key))
+		}
+		s.Location = append(s.Location, roots...)
+	}
+}
+
+// tagValues returns the sample's values of the label, numeric values are
+// formatted with their unit.
+func tagValues(s *profile.Sample, key, unit string) []string {
+	if vals, ok := s.Label[key]; ok {
+		return vals
+	}
+	vals := make([]string, 0, len(s.NumLabel[key]))
+	for _, v := range s.NumLabel[key] {
+		vals = append(vals, measurement.ScaledLabel(v, unit, "auto"))
+	}
+	return vals
+}
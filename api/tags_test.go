@@ -0,0 +1,100 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTags(t *testing.T) {
+	p := filterTestProfile()
+	p.Sample[1].Label["method"] = []string{"GET", "HEAD"}
+
+	v := url.Values{}
+	v.Set("report", "tags")
+	req := httptest.NewRequest("GET", (&url.URL{Scheme: "http", Host: "example.com", RawQuery: v.Encode()}).String(), nil)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, NewProfileResponseRenderer(log.NewNopLogger(), p, nil, req).Render(w))
+
+	res := struct {
+		Data tagsReport `json:"data"`
+	}{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	require.Equal(t, int64(400), res.Data.Total)
+	require.Equal(t, []tagKey{{
+		Key:         "bytes",
+		Total:       400,
+		TotalFormat: "400B",
+		Values: []tagValue{
+			{Value: "2kB", Flat: 300, FlatFormat: "300B"},
+			{Value: "64B", Flat: 100, FlatFormat: "100B"},
+		},
+	}, {
+		Key:         "method",
+		Total:       500,
+		TotalFormat: "500B",
+		Values: []tagValue{
+			{Value: "Write", Flat: 300, FlatFormat: "300B"},
+			{Value: "GET", Flat: 100, FlatFormat: "100B"},
+			{Value: "HEAD", Flat: 100, FlatFormat: "100B"},
+		},
+	}}, res.Data.Tags)
+}
+
+func TestRenderTagsDiff(t *testing.T) {
+	p := filterTestProfile()
+	p.Sample[0].Label[diffBaseLabel] = []string{"true"}
+
+	rep, err := generateTagsReport(p, "")
+	require.NoError(t, err)
+	keys := make([]string, 0, len(rep.Tags))
+	for _, tag := range rep.Tags {
+		keys = append(keys, tag.Key)
+	}
+	require.Equal(t, []string{"bytes", "method"}, keys)
+}
+
+func TestGroupByTags(t *testing.T) {
+	p := filterTestProfile()
+	p.Sample[1].Label = nil
+	groupByTags(p, parseTagKeys("method, bytes"))
+	require.NoError(t, p.CheckValid())
+
+	root := func(i int) []string {
+		locs := p.Sample[i].Location
+		return []string{
+			locs[len(locs)-1].Line[0].Function.Name,
+			locs[len(locs)-2].Line[0].Function.Name,
+			locs[len(locs)-3].Line[0].Function.Name,
+		}
+	}
+	require.Equal(t, []string{"method=Write", "bytes=2kB", "runtime.goexit"}, root(0))
+	require.Equal(t, []string{"method=<none>", "bytes=64B", "runtime.goexit"}, root(1))
+
+	top, err := generateTopReport(p, "")
+	require.NoError(t, err)
+	cum := map[string]int64{}
+	for _, i := range top.Items {
+		cum[i.Name] = i.Cum
+	}
+	require.Equal(t, int64(300), cum["method=Write"])
+	require.Equal(t, int64(100), cum["method=<none>"])
+}
@@ -14,12 +14,15 @@
 package config
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"path/filepath"
 	"strings"
+	"text/template"
 	"time"
 
 	commonconfig "github.com/prometheus/common/config"
@@ -28,6 +31,8 @@ import (
 	"github.com/prometheus/prometheus/discovery/targetgroup"
 	"github.com/prometheus/prometheus/pkg/relabel"
 	"gopkg.in/yaml.v2"
+
+	"github.com/conprof/conprof/pkg/convert"
 )
 
 func trueValue() *bool {
@@ -136,6 +141,18 @@ type ScrapeConfig struct {
 	Scheme string `yaml:"scheme,omitempty"`
 
 	ProfilingConfig *ProfilingConfig `yaml:"profiling_config,omitempty"`
+	// SymbolzFallback makes the scraper resolve addresses of scraped
+	// profiles that aren't symbolized yet via the target's symbol handler,
+	// e.g. /debug/pprof/symbol, while the process is still alive.
+	SymbolzFallback bool `yaml:"symbolz_fallback,omitempty"`
+	// SplitByLabels are pprof sample label keys, e.g. tenant, by which
+	// scraped profiles are split into separate series, with the sample
+	// labels' values as series labels.
+	SplitByLabels []string `yaml:"split_by_labels,omitempty"`
+	// SplitLimit is the maximum number of live series the profiles of a
+	// target are split into across scrapes, DefaultSplitLimit if zero.
+	// Samples of further series are stored in the unsplit series.
+	SplitLimit int `yaml:"split_limit,omitempty"`
 
 	RelabelConfigs []*relabel.Config `yaml:"relabel_configs,omitempty"`
 	// We cannot do proper Go type embedding below as the parser will then parse
@@ -196,6 +213,15 @@ func (c *ScrapeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		}
 	}
 
+	for pt, pc := range unmarshalled.ProfilingConfig.PprofConfig {
+		if pc == nil {
+			return fmt.Errorf("empty pprof_config for profile %q", pt)
+		}
+		if err := pc.validate(); err != nil {
+			return fmt.Errorf("invalid pprof_config for profile %q: %w", pt, err)
+		}
+	}
+
 	*c = unmarshalled
 
 	if len(c.JobName) == 0 {
@@ -222,9 +248,31 @@ func (c *ScrapeConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		}
 	}
 
+	for _, l := range c.SplitByLabels {
+		if !model.LabelName(l).IsValid() || strings.HasPrefix(l, model.ReservedLabelPrefix) {
+			return fmt.Errorf("%q is not a valid label name to split profiles by", l)
+		}
+	}
+	if c.SplitLimit < 0 {
+		return errors.New("split_limit must not be negative")
+	}
+
 	return nil
 }
 
+// DefaultSplitLimit is the maximum number of live series the profiles of a
+// target are split into by default.
+const DefaultSplitLimit = 100
+
+// SplitSeriesLimit returns the maximum number of live series the profiles of
+// a target are split into.
+func (c *ScrapeConfig) SplitSeriesLimit() int {
+	if c.SplitLimit == 0 {
+		return DefaultSplitLimit
+	}
+	return c.SplitLimit
+}
+
 func checkStaticTargets(configs discovery.Configs) error {
 	for _, cfg := range configs {
 		sc, ok := cfg.(discovery.StaticConfig)
@@ -242,10 +290,130 @@ func checkStaticTargets(configs discovery.Configs) error {
 	return nil
 }
 
+// Formats of the profiles returned by targets.
+const (
+	ProfileFormatPprof  = convert.FormatPprof
+	ProfileFormatFolded = convert.FormatFolded
+	ProfileFormatJFR    = convert.FormatJFR
+)
+
+// DefaultDurationParam is the duration parameter template used by Go's
+// net/http/pprof handlers.
+const DefaultDurationParam = "seconds={{ .Seconds }}"
+
 type PprofProfilingConfig struct {
 	Enabled *bool  `yaml:"enabled,omitempty"`
 	Path    string `yaml:"path,omitempty"`
 	Seconds int    `yaml:"seconds"`
+
+	// Method is the HTTP method used to request the profile, GET if empty.
+	Method string `yaml:"method,omitempty"`
+	// Headers are sent with every request for the profile.
+	Headers map[string]string `yaml:"headers,omitempty"`
+	// Body is a template of the request body, e.g. for producers that expect
+	// the profiling options to be POSTed.
+	Body string `yaml:"body,omitempty"`
+	// DurationParam is a template of the query parameters requesting a profile
+	// of Seconds length, e.g. "duration={{ .Duration }}". It is only used when
+	// Seconds is set and defaults to DefaultDurationParam. Profiles other than
+	// the CPU profile are only requested for a duration if it is set.
+	DurationParam string `yaml:"duration_param,omitempty"`
+	// Format is the format of the returned profile, pprof if empty. Profiles
+	// in other formats are converted to pprof before they are stored.
+	Format string `yaml:"format,omitempty"`
+}
+
+// durationTemplateData is what the Body and DurationParam templates are
+// executed with.
+type durationTemplateData struct {
+	// Seconds is the profile duration in seconds.
+	Seconds int
+	// Duration is the profile duration formatted as a Go duration, e.g. 30s.
+	Duration string
+}
+
+func (c *PprofProfilingConfig) validate() error {
+	if c.Method != "" {
+		switch c.Method {
+		case http.MethodGet, http.MethodPost, http.MethodPut:
+		default:
+			return fmt.Errorf("unsupported method %q", c.Method)
+		}
+	}
+	switch c.Format {
+	case "", ProfileFormatPprof, ProfileFormatFolded, ProfileFormatJFR:
+	default:
+		return fmt.Errorf("unsupported format %q", c.Format)
+	}
+	if _, err := c.RequestBody(); err != nil {
+		return err
+	}
+	if _, err := c.DurationParams(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RequestMethod returns the HTTP method used to request the profile.
+func (c *PprofProfilingConfig) RequestMethod() string {
+	if c.Method == "" {
+		return http.MethodGet
+	}
+	return c.Method
+}
+
+// ProfileFormat returns the format of the returned profile.
+func (c *PprofProfilingConfig) ProfileFormat() string {
+	if c.Format == "" {
+		return ProfileFormatPprof
+	}
+	return c.Format
+}
+
+// RequestBody renders the configured body template, nil if there is none.
+func (c *PprofProfilingConfig) RequestBody() ([]byte, error) {
+	if c.Body == "" {
+		return nil, nil
+	}
+	return c.execute("body", c.Body)
+}
+
+// DurationParams renders the duration parameter template into the query
+// parameters to add to the profile's URL. No parameters are returned if
+// Seconds is not set.
+func (c *PprofProfilingConfig) DurationParams() (url.Values, error) {
+	if c.Seconds <= 0 {
+		return url.Values{}, nil
+	}
+	tmpl := c.DurationParam
+	if tmpl == "" {
+		tmpl = DefaultDurationParam
+	}
+	b, err := c.execute("duration_param", tmpl)
+	if err != nil {
+		return nil, err
+	}
+	params, err := url.ParseQuery(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parse rendered duration_param %q: %w", string(b), err)
+	}
+	return params, nil
+}
+
+func (c *PprofProfilingConfig) execute(name, text string) ([]byte, error) {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s template: %w", name, err)
+	}
+	buf := &bytes.Buffer{}
+	err = t.Execute(buf, durationTemplateData{
+		Seconds:  c.Seconds,
+		Duration: (time.Duration(c.Seconds) * time.Second).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execute %s template: %w", name, err)
+	}
+	return buf.Bytes(), nil
 }
 
 // CheckTargetAddress checks if target address is valid.
@@ -14,6 +14,7 @@
 package config
 
 import (
+	"net/url"
 	"testing"
 	"time"
 
@@ -106,3 +107,100 @@ scrape_configs:
 	require.Len(t, c.ScrapeConfigs, 1)
 	require.Equal(t, expected, c)
 }
+
+func TestLoadRequestShape(t *testing.T) {
+	c, err := Load(`
+scrape_configs:
+  - job_name: 'pprof-rs'
+    symbolz_fallback: true
+    split_by_labels: [tenant]
+    static_configs:
+      - targets: [ 'localhost:8080' ]
+    profiling_config:
+      pprof_config:
+        profile:
+          path: /debug/pprof/profile
+          seconds: 10
+          method: POST
+          headers:
+            Content-Type: application/json
+          body: '{"duration": "{{ .Duration }}"}'
+          duration_param: 'duration={{ .Seconds }}s&format=pprof'
+        cpu:
+          path: /debug/folded
+          format: folded
+        jvm:
+          path: /jfr
+          format: jfr
+`)
+	require.NoError(t, err)
+	require.True(t, c.ScrapeConfigs[0].SymbolzFallback)
+	require.Equal(t, []string{"tenant"}, c.ScrapeConfigs[0].SplitByLabels)
+	require.Equal(t, DefaultSplitLimit, c.ScrapeConfigs[0].SplitSeriesLimit())
+
+	pc := c.ScrapeConfigs[0].ProfilingConfig.PprofConfig["profile"]
+	require.Equal(t, "POST", pc.RequestMethod())
+	require.Equal(t, map[string]string{"Content-Type": "application/json"}, pc.Headers)
+
+	body, err := pc.RequestBody()
+	require.NoError(t, err)
+	require.Equal(t, `{"duration": "10s"}`, string(body))
+
+	params, err := pc.DurationParams()
+	require.NoError(t, err)
+	require.Equal(t, url.Values{"duration": {"10s"}, "format": {"pprof"}}, params)
+
+	require.Equal(t, ProfileFormatPprof, pc.ProfileFormat())
+	require.Equal(t, ProfileFormatFolded, c.ScrapeConfigs[0].ProfilingConfig.PprofConfig["cpu"].ProfileFormat())
+	require.Equal(t, ProfileFormatJFR, c.ScrapeConfigs[0].ProfilingConfig.PprofConfig["jvm"].ProfileFormat())
+
+	// Profiles without a duration get no duration parameters.
+	heap := c.ScrapeConfigs[0].ProfilingConfig.PprofConfig["heap"]
+	require.Equal(t, "GET", heap.RequestMethod())
+	params, err = heap.DurationParams()
+	require.NoError(t, err)
+	require.Empty(t, params)
+}
+
+func TestDefaultDurationParam(t *testing.T) {
+	c := DefaultScrapeConfig()
+	params, err := c.ProfilingConfig.PprofConfig["profile"].DurationParams()
+	require.NoError(t, err)
+	require.Equal(t, url.Values{"seconds": {"30"}}, params)
+}
+
+func TestLoadInvalidRequestShape(t *testing.T) {
+	for _, tc := range []string{
+		`method: DELETE`,
+		`body: '{{ .Unknown }}'`,
+		`duration_param: 'seconds={{ .Seconds'`,
+		`format: unknown`,
+	} {
+		_, err := Load(`
+scrape_configs:
+  - job_name: 'test'
+    static_configs:
+      - targets: [ 'localhost:8080' ]
+    profiling_config:
+      pprof_config:
+        profile:
+          ` + tc)
+		require.Error(t, err, tc)
+	}
+}
+
+func TestLoadInvalidSplit(t *testing.T) {
+	for _, tc := range []string{
+		`split_by_labels: ['__name__']`,
+		`split_by_labels: ['not-a-label']`,
+		`split_limit: -1`,
+	} {
+		_, err := Load(`
+scrape_configs:
+  - job_name: 'test'
+    ` + tc + `
+    static_configs:
+      - targets: [ 'localhost:8080' ]`)
+		require.Error(t, err, tc)
+	}
+}
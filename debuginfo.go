@@ -0,0 +1,256 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/run"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/thanos/pkg/component"
+	"github.com/thanos-io/thanos/pkg/prober"
+	"google.golang.org/grpc"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/conprof/conprof/pkg/store/storepb"
+	"github.com/conprof/conprof/symbol"
+)
+
+// registerDebuginfo registers the debuginfo commands.
+func registerDebuginfo(m map[string]setupFunc, app *kingpin.Application, name string) {
+	cmd := app.Command(name, "Manage the debug information used to symbolize profiles.")
+
+	registerDebuginfoUpload(m, cmd, name+" upload")
+	registerDebuginfoUploadKallsyms(m, cmd, name+" upload-kallsyms")
+	registerDebuginfoUploadSources(m, cmd, name+" upload-sources")
+}
+
+// registerDebuginfoUpload registers a command uploading the debug information
+// of binaries to a symbol server.
+func registerDebuginfoUpload(m map[string]setupFunc, parent *kingpin.CmdClause, name string) {
+	cmd := parent.Command("upload", "Upload the debug information of ELF binaries to a symbol server, unless it has it already.")
+
+	grpcFlags := registerGRPCClientFlags(cmd)
+	timeout := cmd.Flag("timeout", "Timeout of checking for and uploading the debug information of a binary.").
+		Default("5m").Duration()
+	installDir := cmd.Flag("install-dir", "Directory the binaries run from on the profiled hosts. Binaries without a GNU build ID are matched to profiles by the path they run at, which is the absolute path of the uploaded file if empty.").
+		String()
+	paths := cmd.Arg("binary", "Paths of the binaries to upload.").Required().ExistingFiles()
+
+	registerOneShot(m, name, func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
+		opts, err := grpcFlags.dialOptions()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := grpc.Dial(*grpcFlags.storeAddress, opts...)
+		if err != nil {
+			return probe, err
+		}
+		c := symbol.NewSymbolStoreClient(storepb.NewSymbolStoreClient(conn))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			defer conn.Close()
+			for _, path := range *paths {
+				uploadCtx, uploadCancel := context.WithTimeout(ctx, *timeout)
+				mappedPath := ""
+				if *installDir != "" {
+					mappedPath = filepath.Join(*installDir, filepath.Base(path))
+				}
+				id, size, err := c.UploadFile(uploadCtx, path, mappedPath)
+				uploadCancel()
+				if err != nil {
+					return fmt.Errorf("upload debuginfo of %s: %w", path, err)
+				}
+				if size == 0 {
+					level.Info(logger).Log("msg", "debuginfo exists already, skipping upload", "binary", path, "buildid", id)
+					continue
+				}
+				level.Info(logger).Log("msg", "uploaded debuginfo", "binary", path, "buildid", id, "size", size)
+			}
+			return nil
+		}, func(error) {
+			cancel()
+		})
+
+		probe.Ready()
+		return probe, nil
+	})
+}
+
+// registerDebuginfoUploadKallsyms registers a command uploading the kernel
+// symbols of the host to a symbol server.
+func registerDebuginfoUploadKallsyms(m map[string]setupFunc, parent *kingpin.CmdClause, name string) {
+	cmd := parent.Command("upload-kallsyms", "Upload a kallsyms snapshot of the running kernel to a symbol server, to symbolize kernel stacks. Reading kernel addresses requires root privileges.")
+
+	grpcFlags := registerGRPCClientFlags(cmd)
+	timeout := cmd.Flag("timeout", "Timeout of uploading the kernel symbols.").
+		Default("5m").Duration()
+	kallsymsPath := cmd.Flag("kallsyms", "Path of the kernel symbols to upload.").
+		Default("/proc/kallsyms").String()
+	release := cmd.Flag("kernel-release", "Release of the kernel, read from /proc/sys/kernel/osrelease if empty.").
+		String()
+	buildID := cmd.Flag("kernel-build-id", "Hex encoded build ID of the kernel, read from /sys/kernel/notes if empty.").
+		String()
+
+	registerOneShot(m, name, func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
+		if *release == "" {
+			b, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+			if err != nil {
+				return probe, fmt.Errorf("read kernel release: %w", err)
+			}
+			*release = strings.TrimSpace(string(b))
+		}
+		if *buildID == "" {
+			f, err := os.Open("/sys/kernel/notes")
+			if err != nil {
+				return probe, fmt.Errorf("read kernel notes: %w", err)
+			}
+			*buildID, err = symbol.KernelBuildID(f)
+			f.Close()
+			if err != nil {
+				return probe, fmt.Errorf("read kernel build id: %w", err)
+			}
+		}
+
+		opts, err := grpcFlags.dialOptions()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := grpc.Dial(*grpcFlags.storeAddress, opts...)
+		if err != nil {
+			return probe, err
+		}
+		c := symbol.NewSymbolStoreClient(storepb.NewSymbolStoreClient(conn))
+
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		g.Add(func() error {
+			defer conn.Close()
+			f, err := os.Open(*kallsymsPath)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			size, err := c.UploadKallsyms(ctx, *release, *buildID, f)
+			if err != nil {
+				return fmt.Errorf("upload kallsyms: %w", err)
+			}
+			level.Info(logger).Log("msg", "uploaded kallsyms", "release", *release, "buildid", *buildID, "size", size)
+			return nil
+		}, func(error) {
+			cancel()
+		})
+
+		probe.Ready()
+		return probe, nil
+	})
+}
+
+// registerDebuginfoUploadSources registers a command uploading the source
+// files of a binary to a symbol server, to annotate them with their cost.
+func registerDebuginfoUploadSources(m map[string]setupFunc, parent *kingpin.CmdClause, name string) {
+	cmd := parent.Command("upload-sources", "Upload the source files a binary was compiled from to a symbol server, to show them annotated with their cost.")
+
+	grpcFlags := registerGRPCClientFlags(cmd)
+	timeout := cmd.Flag("timeout", "Timeout of uploading a source file.").
+		Default("1m").Duration()
+	binary := cmd.Flag("binary", "Path of the binary the sources belong to, to read its build ID from.").
+		ExistingFile()
+	buildID := cmd.Flag("build-id", "Hex encoded build ID of the binary the sources belong to, instead of reading it from the binary.").
+		String()
+	compileDir := cmd.Flag("compile-dir", "Directory the binary was compiled in. Relative paths of source files are recorded relative to it, instead of the current directory.").
+		String()
+	paths := cmd.Arg("source", "Paths of the source files to upload.").Required().ExistingFiles()
+
+	registerOneShot(m, name, func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
+		if (*binary == "") == (*buildID == "") {
+			return probe, errors.New("either --binary or --build-id must be set")
+		}
+		if *binary != "" {
+			f, err := os.Open(*binary)
+			if err != nil {
+				return probe, err
+			}
+			*buildID, err = symbol.BuildID(f)
+			f.Close()
+			if err != nil {
+				return probe, err
+			}
+		}
+
+		opts, err := grpcFlags.dialOptions()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := grpc.Dial(*grpcFlags.storeAddress, opts...)
+		if err != nil {
+			return probe, err
+		}
+		c := symbol.NewSymbolStoreClient(storepb.NewSymbolStoreClient(conn))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			defer conn.Close()
+			for _, path := range *paths {
+				recorded, err := recordedSourcePath(path, *compileDir)
+				if err != nil {
+					return err
+				}
+				if err := uploadSource(ctx, c, *buildID, recorded, path, *timeout); err != nil {
+					return fmt.Errorf("upload source %s: %w", path, err)
+				}
+				level.Debug(logger).Log("msg", "uploaded source", "source", path, "path", recorded, "buildid", *buildID)
+			}
+			level.Info(logger).Log("msg", "uploaded sources", "buildid", *buildID, "files", len(*paths))
+			return nil
+		}, func(error) {
+			cancel()
+		})
+
+		probe.Ready()
+		return probe, nil
+	})
+}
+
+// recordedSourcePath returns the path of the source file as recorded in the
+// debuginfo of a binary compiled in compileDir.
+func recordedSourcePath(path, compileDir string) (string, error) {
+	if filepath.IsAbs(path) || compileDir == "" {
+		return filepath.Abs(path)
+	}
+	return filepath.Join(compileDir, path), nil
+}
+
+func uploadSource(ctx context.Context, c *symbol.SymbolStoreClient, buildID, recorded, path string, timeout time.Duration) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	_, err = c.UploadSource(ctx, buildID, filepath.ToSlash(recorded), f)
+	return err
+}
@@ -0,0 +1,570 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/conprof/db/storage"
+	"github.com/conprof/db/tsdb"
+	"github.com/conprof/db/tsdb/record"
+	"github.com/conprof/db/tsdb/wal"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/pprof/profile"
+	"github.com/oklog/run"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/thanos-io/thanos/pkg/component"
+	"github.com/thanos-io/thanos/pkg/prober"
+	"google.golang.org/grpc"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/conprof/conprof/pkg/chunkenc"
+	"github.com/conprof/conprof/pkg/store"
+	"github.com/conprof/conprof/pkg/store/storepb"
+)
+
+// importCommitSize is the number of profiles appended to a block before they
+// are committed.
+const importCommitSize = 1000
+
+// registerImport registers a command importing archives of profiles.
+func registerImport(m map[string]setupFunc, app *kingpin.Application, name string) {
+	cmd := app.Command(name, "Import a directory or tarball of pprof files, either into new blocks of a TSDB or by sending them to a store.")
+
+	grpcFlags := registerGRPCClientFlags(cmd)
+	timeout := cmd.Flag("timeout", "Timeout of sending a profile to the store.").
+		Default("1m").Duration()
+	storagePath := cmd.Flag("storage.tsdb.path", "Directory of a TSDB to write the profiles to as new blocks, instead of sending them to the store. Profiles can be of any time, but must not overlap the blocks of the TSDB or the profiles in its WAL, and the TSDB must not be in use by a running conprof.").
+		String()
+	profileCompression := registerProfileCompressionFlag(cmd)
+	blockDuration := cmd.Flag("block-duration", "Time range of the blocks written to the TSDB.").
+		Default("2h").Duration()
+	profileName := cmd.Flag("name", "Name of the profile series, e.g. heap or profile, unless set by a manifest.").String()
+	lbls := cmd.Flag("label", "Label to add to the profile series as name=value, can be repeated.").StringMap()
+	manifestPath := cmd.Flag("manifest", "Manifest in the format written by tsdb dump, listing the labels of the profiles in the subdirectories of the imported directory. Files named manifest.json in the imported directory are read as well.").
+		ExistingFile()
+	pattern := cmd.Flag("pattern", "Pattern the names of the files to import must match.").
+		Default("*.pb.gz").String()
+	path := cmd.Arg("path", "Directory or tarball of the profiles to import.").Required().ExistingFileOrDir()
+
+	registerOneShot(m, name, func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
+		if _, err := filepath.Match(*pattern, ""); err != nil {
+			return probe, fmt.Errorf("invalid pattern: %w", err)
+		}
+		if *blockDuration <= 0 {
+			return probe, errors.New("block duration must be positive")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			dir, cleanup, err := importDir(*path)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			lset := labels.FromMap(*lbls)
+			if *profileName != "" {
+				lset = labels.NewBuilder(lset).Set(labels.MetricName, *profileName).Labels()
+			}
+			profiles, err := findProfiles(dir, *pattern, *manifestPath, lset)
+			if err != nil {
+				return err
+			}
+			level.Info(logger).Log("msg", "found profiles to import", "profiles", len(profiles))
+
+			if *storagePath != "" {
+				return writeProfileBlocks(ctx, logger, *storagePath, profiles, blockDuration.Milliseconds(), chunkenc.Codec(*profileCompression))
+			}
+
+			opts, err := grpcFlags.dialOptions()
+			if err != nil {
+				return err
+			}
+			conn, err := grpc.Dial(*grpcFlags.storeAddress, opts...)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			return sendProfiles(ctx, logger, store.NewGRPCAppendable(logger, storepb.NewWritableProfileStoreClient(conn)), profiles, *timeout)
+		}, func(error) {
+			cancel()
+		})
+
+		probe.Ready()
+		return probe, nil
+	})
+}
+
+// importDir returns the directory of the profiles to import. Tarballs are
+// extracted to a temporary directory, which is removed by cleanup.
+func importDir(path string) (dir string, cleanup func(), err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if fi.IsDir() {
+		return path, func() {}, nil
+	}
+
+	dir, err = ioutil.TempDir("", "conprof-import")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+	if err := extractTarball(path, dir); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("extract %s: %w", path, err)
+	}
+	return dir, cleanup, nil
+}
+
+// extractTarball extracts the regular files of the tarball, which may be
+// gzipped, to dir, keeping their modification times.
+func extractTarball(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// Cleaning the name as an absolute path keeps it within dir.
+		name := filepath.Join(dir, filepath.Clean("/"+hdr.Name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		out, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, tr)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.Chtimes(name, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+}
+
+type importProfile struct {
+	path   string
+	labels labels.Labels
+	t      int64
+}
+
+// findProfiles returns the profiles in dir whose file names match the
+// pattern, sorted by their time. Their labels are those the manifests list
+// for their directory, overridden by lset. Their time is the one recorded in
+// the profile, or else the modification time of the file. Profiles are only
+// parsed as they are imported, but profiles of a series at the same time are
+// an error right away.
+func findProfiles(dir, pattern, manifestPath string, lset labels.Labels) ([]importProfile, error) {
+	dirLabels := map[string]labels.Labels{}
+	readManifest := func(path, root string) error {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var mf manifest
+		if err := json.Unmarshal(b, &mf); err != nil {
+			return fmt.Errorf("parse manifest %s: %w", path, err)
+		}
+		for _, s := range mf.Series {
+			dirLabels[filepath.Join(root, s.Dir)] = labels.FromMap(s.Labels)
+		}
+		return nil
+	}
+	if manifestPath != "" {
+		if err := readManifest(manifestPath, dir); err != nil {
+			return nil, err
+		}
+	}
+	if err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.Name() != manifestFile {
+			return err
+		}
+		return readManifest(path, filepath.Dir(path))
+	}); err != nil {
+		return nil, err
+	}
+
+	var profiles []importProfile
+	if err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		if ok, _ := filepath.Match(pattern, fi.Name()); !ok {
+			return nil
+		}
+
+		b := labels.NewBuilder(dirLabels[filepath.Dir(path)])
+		for _, l := range lset {
+			b.Set(l.Name, l.Value)
+		}
+		pl := b.Labels()
+		if pl.Get(labels.MetricName) == "" {
+			return fmt.Errorf("no profile name for %s, set it with --name or in a manifest", path)
+		}
+
+		timeNanos, err := readProfileTime(path)
+		if err != nil {
+			return err
+		}
+		t := timestamp.FromTime(fi.ModTime())
+		if timeNanos > 0 {
+			t = timestamp.FromTime(time.Unix(0, timeNanos))
+		}
+		profiles = append(profiles, importProfile{path: path, labels: pl, t: t})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(profiles, func(i, j int) bool {
+		return profiles[i].t < profiles[j].t
+	})
+
+	// A series has a single profile per timestamp, so check before anything
+	// is imported.
+	for i := 0; i < len(profiles); {
+		paths := map[uint64]string{}
+		for t := profiles[i].t; i < len(profiles) && profiles[i].t == t; i++ {
+			h := profiles[i].labels.Hash()
+			if path, ok := paths[h]; ok {
+				return nil, fmt.Errorf("%s and %s are both profiles of %s at %d, each profile of a series needs its own time, recorded in the profile or else as the modification time of its file", path, profiles[i].path, profiles[i].labels, t)
+			}
+			paths[h] = profiles[i].path
+		}
+	}
+	return profiles, nil
+}
+
+// readProfileTime returns the time recorded in the profile of the file, in
+// nanoseconds. Only the top-level fields of pprof profiles are read, profiles
+// in other formats are parsed.
+func readProfileTime(path string) (int64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if b, err := chunkenc.Decompress(b); err == nil {
+		if t, err := profileTimeNanos(b); err == nil {
+			return t, nil
+		}
+	}
+	p, err := profile.ParseData(b)
+	if err != nil {
+		return 0, fmt.Errorf("parse profile %s: %w", path, err)
+	}
+	return p.TimeNanos, nil
+}
+
+// profileTimeNanosField is the number of the time_nanos field of the pprof
+// profile message.
+const profileTimeNanosField = 9
+
+var errInvalidProfile = errors.New("invalid pprof profile")
+
+// profileTimeNanos returns the time_nanos field of the uncompressed pprof
+// profile, skipping over all other fields. Fields that the profile message
+// doesn't have with their wire type make it invalid, so that profiles in
+// other formats aren't taken for pprof profiles.
+func profileTimeNanos(b []byte) (int64, error) {
+	var timeNanos int64
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return 0, errInvalidProfile
+		}
+		b = b[n:]
+
+		field := tag >> 3
+		switch tag & 7 {
+		case 0: // Varint, of the integer fields.
+			if field < 7 || field > 14 || field == 11 {
+				return 0, errInvalidProfile
+			}
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return 0, errInvalidProfile
+			}
+			if field == profileTimeNanosField {
+				timeNanos = int64(v)
+			}
+			b = b[n:]
+		case 2: // Length-delimited, of the messages, strings and comments.
+			if field < 1 || (field > 6 && field != 11 && field != 13) {
+				return 0, errInvalidProfile
+			}
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return 0, errInvalidProfile
+			}
+			b = b[n+int(l):]
+		default:
+			return 0, errInvalidProfile
+		}
+	}
+	return timeNanos, nil
+}
+
+// readProfileData returns the profile of the file, as it is appended by the
+// upload command.
+func readProfileData(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := profile.ParseData(b)
+	if err != nil {
+		return nil, fmt.Errorf("parse profile %s: %w", path, err)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := p.WriteUncompressed(buf); err != nil {
+		return nil, fmt.Errorf("write profile %s: %w", path, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeProfileBlocks writes the profiles, sorted by their time, to new blocks
+// of the TSDB in dir, a block per time range of blockDuration. Writing blocks
+// rather than appending to the head allows profiles of any time to be
+// imported, as long as they don't overlap the existing blocks or the head
+// the storage replays from the WAL.
+func writeProfileBlocks(ctx context.Context, logger log.Logger, dir string, profiles []importProfile, blockDuration int64, codec chunkenc.Codec) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	db, err := tsdb.OpenDBReadOnly(dir, logger)
+	if err != nil {
+		return err
+	}
+	blocks, err := db.Blocks()
+	if err != nil {
+		db.Close()
+		return err
+	}
+	var ranges []timeRange
+	for _, b := range blocks {
+		meta := b.Meta()
+		ranges = append(ranges, timeRange{mint: meta.MinTime, maxt: meta.MaxTime, name: "block " + meta.ULID.String()})
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	// The storage replays the WAL into its head, which is compacted into
+	// blocks of its own later.
+	mint, maxt, ok, err := walTimeRange(filepath.Join(dir, "wal"))
+	if err != nil {
+		return fmt.Errorf("read WAL: %w", err)
+	}
+	if ok {
+		ranges = append(ranges, timeRange{mint: mint, maxt: maxt + 1, name: "the head"})
+	}
+
+	for i := 0; i < len(profiles); {
+		start := profiles[i].t - profiles[i].t%blockDuration
+		j := i
+		for j < len(profiles) && profiles[j].t < start+blockDuration {
+			j++
+		}
+		window := profiles[i:j]
+		i = j
+
+		mint, maxt := window[0].t, window[len(window)-1].t+1
+		for _, r := range ranges {
+			if mint < r.maxt && r.mint < maxt {
+				return fmt.Errorf("profiles from %d to %d overlap %s from %d to %d", mint, maxt, r.name, r.mint, r.maxt)
+			}
+		}
+		if err := writeProfileBlock(ctx, logger, dir, window, blockDuration, codec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timeRange is the time range of a block or the head, the maximum time
+// exclusive.
+type timeRange struct {
+	mint, maxt int64
+	name       string
+}
+
+// walTimeRange returns the time range of the samples in the WAL in dir and
+// its last checkpoint. ok is false if there are none.
+func walTimeRange(dir string) (mint, maxt int64, ok bool, err error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return 0, 0, false, nil
+	}
+	dirs := []string{dir}
+	cp, _, err := wal.LastCheckpoint(dir)
+	if err == nil {
+		dirs = []string{cp, dir}
+	} else if err != record.ErrNotFound {
+		return 0, 0, false, err
+	}
+
+	mint, maxt = math.MaxInt64, math.MinInt64
+	var (
+		dec     record.Decoder
+		samples []record.RefSample
+	)
+	for _, d := range dirs {
+		sr, err := wal.NewSegmentsReader(d)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		r := wal.NewReader(sr)
+		for r.Next() {
+			rec := r.Record()
+			if dec.Type(rec) != record.Samples {
+				continue
+			}
+			samples, err = dec.Samples(rec, samples[:0])
+			if err != nil {
+				sr.Close()
+				return 0, 0, false, err
+			}
+			for _, s := range samples {
+				if s.T < mint {
+					mint = s.T
+				}
+				if s.T > maxt {
+					maxt = s.T
+				}
+			}
+		}
+		if err := r.Err(); err != nil {
+			sr.Close()
+			return 0, 0, false, err
+		}
+		if err := sr.Close(); err != nil {
+			return 0, 0, false, err
+		}
+	}
+	return mint, maxt, mint <= maxt, nil
+}
+
+func writeProfileBlock(ctx context.Context, logger log.Logger, dir string, profiles []importProfile, blockDuration int64, codec chunkenc.Codec) error {
+	w, err := tsdb.NewBlockWriter(logger, dir, blockDuration)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	db := store.NewCompressingAppendable(w, codec)
+	app := db.Appender(ctx)
+	for i, p := range profiles {
+		b, err := readProfileData(p.path)
+		if err != nil {
+			app.Rollback()
+			return err
+		}
+		if _, err := app.Add(p.labels, p.t, b); err != nil {
+			app.Rollback()
+			return fmt.Errorf("append %s: %w", p.path, err)
+		}
+		if (i+1)%importCommitSize == 0 {
+			if err := app.Commit(); err != nil {
+				return err
+			}
+			app = db.Appender(ctx)
+		}
+	}
+	if err := app.Commit(); err != nil {
+		return err
+	}
+
+	id, err := w.Flush(ctx)
+	if err != nil {
+		return err
+	}
+	level.Info(logger).Log("msg", "wrote block", "block", id, "profiles", len(profiles))
+	return nil
+}
+
+// sendProfiles appends the profiles to db one by one. Stores append the
+// profiles to their head, which only accepts profiles newer than the ones of
+// the series it has already.
+func sendProfiles(ctx context.Context, logger log.Logger, db storage.Appendable, profiles []importProfile, timeout time.Duration) error {
+	for _, p := range profiles {
+		b, err := readProfileData(p.path)
+		if err != nil {
+			return err
+		}
+		if err := sendProfile(ctx, db, p, b, timeout); err != nil {
+			return fmt.Errorf("send %s: %w", p.path, err)
+		}
+		level.Debug(logger).Log("msg", "sent profile", "file", p.path, "labels", p.labels)
+	}
+	level.Info(logger).Log("msg", "sent profiles", "profiles", len(profiles))
+	return nil
+}
+
+func sendProfile(ctx context.Context, db storage.Appendable, p importProfile, b []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	app := db.Appender(ctx)
+	if _, err := app.Add(p.labels, p.t, b); err != nil {
+		return err
+	}
+	return app.Commit()
+}
@@ -0,0 +1,291 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/conprof/conprof/pkg/chunkenc"
+)
+
+// writeTestProfile writes the test profile with the time to path, gzipped as
+// written by pprof, and sets the modification time of the file to mtime.
+func writeTestProfile(t *testing.T, path string, timeNanos int64, mtime time.Time) {
+	p, err := profile.ParseData(testProfile(t))
+	require.NoError(t, err)
+	p.TimeNanos = timeNanos
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, p.Write(f))
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Chtimes(path, mtime, mtime))
+}
+
+func writeTestManifest(t *testing.T, path string, mf manifest) {
+	b, err := json.Marshal(mf)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, b, 0644))
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "conprof-import-test")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestFindProfiles(t *testing.T) {
+	dir := tempDir(t)
+	// The first profile records its time, the second only has the
+	// modification time of its file.
+	writeTestProfile(t, filepath.Join(dir, "a", "1.pb.gz"), int64(5*time.Second), time.Unix(100, 0))
+	writeTestProfile(t, filepath.Join(dir, "a", "2.pb.gz"), 0, time.Unix(3, 0))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "notes.txt"), []byte("not a profile"), 0644))
+
+	manifestDir := tempDir(t)
+	writeTestManifest(t, filepath.Join(manifestDir, "series.json"), manifest{Series: []manifestSeries{
+		{Dir: "a", Labels: map[string]string{"__name__": "heap", "job": "manifest", "instance": "a"}},
+	}})
+
+	for _, tc := range []struct {
+		name         string
+		manifestPath string
+		inDir        bool
+		lset         labels.Labels
+		exp          labels.Labels
+		err          string
+	}{
+		{
+			name:  "manifest in directory",
+			inDir: true,
+			exp:   labels.FromStrings("__name__", "heap", "instance", "a", "job", "manifest"),
+		},
+		{
+			name:         "manifest flag",
+			manifestPath: filepath.Join(manifestDir, "series.json"),
+			exp:          labels.FromStrings("__name__", "heap", "instance", "a", "job", "manifest"),
+		},
+		{
+			name:  "labels override manifest",
+			inDir: true,
+			lset:  labels.FromStrings("__name__", "allocs", "job", "import"),
+			exp:   labels.FromStrings("__name__", "allocs", "instance", "a", "job", "import"),
+		},
+		{
+			name: "labels without manifest",
+			lset: labels.FromStrings("__name__", "allocs"),
+			exp:  labels.FromStrings("__name__", "allocs"),
+		},
+		{
+			name: "no name",
+			lset: labels.FromStrings("job", "import"),
+			err:  "no profile name",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			manifestPath := filepath.Join(dir, manifestFile)
+			if tc.inDir {
+				writeTestManifest(t, manifestPath, manifest{Series: []manifestSeries{
+					{Dir: "a", Labels: map[string]string{"__name__": "heap", "job": "manifest", "instance": "a"}},
+				}})
+				defer os.Remove(manifestPath)
+			}
+
+			profiles, err := findProfiles(dir, "*.pb.gz", tc.manifestPath, tc.lset)
+			if tc.err != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, []importProfile{
+				{path: filepath.Join(dir, "a", "2.pb.gz"), labels: tc.exp, t: 3000},
+				{path: filepath.Join(dir, "a", "1.pb.gz"), labels: tc.exp, t: 5000},
+			}, profiles)
+		})
+	}
+}
+
+func TestFindProfilesSameTime(t *testing.T) {
+	dir := tempDir(t)
+	writeTestProfile(t, filepath.Join(dir, "a", "1.pb.gz"), 0, time.Unix(3, 0))
+	writeTestProfile(t, filepath.Join(dir, "b", "1.pb.gz"), 0, time.Unix(3, 0))
+
+	// Profiles of different series may have the same time.
+	writeTestManifest(t, filepath.Join(dir, manifestFile), manifest{Series: []manifestSeries{
+		{Dir: "a", Labels: map[string]string{"instance": "a"}},
+		{Dir: "b", Labels: map[string]string{"instance": "b"}},
+	}})
+	profiles, err := findProfiles(dir, "*.pb.gz", "", labels.FromStrings("__name__", "heap"))
+	require.NoError(t, err)
+	require.Len(t, profiles, 2)
+
+	_, err = findProfiles(dir, "*.pb.gz", "", labels.FromStrings("__name__", "heap", "instance", "a"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "needs its own time")
+}
+
+func TestProfileTimeNanos(t *testing.T) {
+	p, err := profile.ParseData(testProfile(t))
+	require.NoError(t, err)
+	p.TimeNanos = 1234
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, p.WriteUncompressed(buf))
+
+	timeNanos, err := profileTimeNanos(buf.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, int64(1234), timeNanos)
+
+	_, err = profileTimeNanos([]byte("heap profile: 1: 2 [3: 4] @ heapprofile\n"))
+	require.Equal(t, errInvalidProfile, err)
+}
+
+func TestExtractTarball(t *testing.T) {
+	mtime := time.Unix(1000, 0)
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "a/1.pb.gz", Typeflag: tar.TypeReg, Mode: 0644, Size: 1, ModTime: mtime},
+		{Name: "../2.pb.gz", Typeflag: tar.TypeReg, Mode: 0644, Size: 1, ModTime: mtime},
+		{Name: "a/link.pb.gz", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+	} {
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Size > 0 {
+			_, err := tw.Write([]byte("x"))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+
+	gzipped := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(gzipped)
+	_, err := io.Copy(gw, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	for _, tc := range []struct {
+		name    string
+		tarball []byte
+	}{
+		{name: "tar", tarball: buf.Bytes()},
+		{name: "gzipped tar", tarball: gzipped.Bytes()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(tempDir(t), "profiles.tar")
+			require.NoError(t, ioutil.WriteFile(path, tc.tarball, 0644))
+
+			dir := tempDir(t)
+			require.NoError(t, extractTarball(path, dir))
+
+			var files []string
+			require.NoError(t, filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+				if err != nil || fi.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(dir, path)
+				if err != nil {
+					return err
+				}
+				files = append(files, rel)
+				require.True(t, mtime.Equal(fi.ModTime()), "unexpected modification time of %s: %v", rel, fi.ModTime())
+				return nil
+			}))
+			// Entries that aren't regular files are skipped, and names are
+			// kept within the directory.
+			require.Equal(t, []string{"2.pb.gz", filepath.Join("a", "1.pb.gz")}, files)
+		})
+	}
+}
+
+func TestWriteProfileBlocks(t *testing.T) {
+	src := tempDir(t)
+	hour := int64(time.Hour)
+	// Two profiles in the first block, one in the second.
+	writeTestProfile(t, filepath.Join(src, "1.pb.gz"), 1*hour, time.Unix(0, 0))
+	writeTestProfile(t, filepath.Join(src, "2.pb.gz"), 1*hour+int64(time.Minute), time.Unix(0, 0))
+	writeTestProfile(t, filepath.Join(src, "3.pb.gz"), 3*hour, time.Unix(0, 0))
+	profiles, err := findProfiles(src, "*.pb.gz", "", labels.FromStrings("__name__", "heap"))
+	require.NoError(t, err)
+
+	dir := filepath.Join(tempDir(t), "data")
+	require.NoError(t, writeProfileBlocks(context.Background(), log.NewNopLogger(), dir, profiles, time.Duration(2*hour).Milliseconds(), chunkenc.CodecZstd))
+	require.Len(t, blockDirs(t, dir), 2)
+
+	res := readProfiles(t, dir, "heap")
+	require.Len(t, res, len(profiles))
+	for _, p := range profiles {
+		v, ok := res[p.t]
+		require.True(t, ok, "missing profile at %d", p.t)
+		require.Equal(t, chunkenc.CodecZstd, chunkenc.CodecOf(v))
+
+		exp, err := readProfileData(p.path)
+		require.NoError(t, err)
+		v, err = chunkenc.Decompress(v)
+		require.NoError(t, err)
+		require.Equal(t, exp, v)
+	}
+
+	// The profiles overlap the blocks now.
+	err = writeProfileBlocks(context.Background(), log.NewNopLogger(), dir, profiles, time.Duration(2*hour).Milliseconds(), chunkenc.CodecZstd)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "overlap block")
+}
+
+func TestWriteProfileBlocksHead(t *testing.T) {
+	// The storage has profiles in its WAL from 1s to 3s.
+	dir := newTestDB(t, map[string][]int64{"heap": {1000, 3000}}).Dir()
+
+	for _, tc := range []struct {
+		name      string
+		timeNanos int64
+		err       bool
+	}{
+		{name: "before", timeNanos: int64(500 * time.Millisecond)},
+		{name: "overlapping", timeNanos: int64(2 * time.Second), err: true},
+		{name: "after", timeNanos: int64(4 * time.Second)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := tempDir(t)
+			writeTestProfile(t, filepath.Join(src, "1.pb.gz"), tc.timeNanos, time.Unix(0, 0))
+			profiles, err := findProfiles(src, "*.pb.gz", "", labels.FromStrings("__name__", "allocs"))
+			require.NoError(t, err)
+
+			err = writeProfileBlocks(context.Background(), log.NewNopLogger(), dir, profiles, time.Duration(time.Hour).Milliseconds(), chunkenc.CodecZstd)
+			if tc.err {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "overlap the head")
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
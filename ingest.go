@@ -0,0 +1,85 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/conprof/db/storage"
+	"github.com/conprof/db/tsdb"
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/run"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/thanos-io/thanos/pkg/extkingpin"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/conprof/conprof/pkg/chunkenc"
+	"github.com/conprof/conprof/pkg/store"
+	"github.com/conprof/conprof/symbol"
+)
+
+// ingestSymbolizationFlags are the flags of commands that can symbolize
+// profiles as they are written.
+type ingestSymbolizationFlags struct {
+	enabled       *bool
+	retryInterval *model.Duration
+	maxAge        *model.Duration
+}
+
+func registerIngestSymbolizationFlags(cmd *kingpin.CmdClause) *ingestSymbolizationFlags {
+	enabled := cmd.Flag("symbolize-at-ingest", "Symbolize native profiles via the symbol server as they are written, instead of on every query. Profiles with locations whose debug information isn't uploaded yet are rewritten once it is, they are symbolized on query until then.").
+		Default("false").Bool()
+	retryInterval := extkingpin.ModelDuration(cmd.Flag("symbolize-at-ingest.retry-interval", "Interval of retrying to symbolize stored profiles with unresolved locations.").
+		Default("1m"))
+	maxAge := extkingpin.ModelDuration(cmd.Flag("symbolize-at-ingest.max-age", "How long to retry symbolizing stored profiles with unresolved locations. Profiles are only rewritten once they are compacted into a block, so this should exceed the block duration.").
+		Default("24h"))
+	return &ingestSymbolizationFlags{
+		enabled:       enabled,
+		retryInterval: retryInterval,
+		maxAge:        maxAge,
+	}
+}
+
+// writeDB reads from the TSDB, but writes through app.
+type writeDB struct {
+	*tsdb.DB
+	app storage.Appendable
+}
+
+func (db *writeDB) Appender(ctx context.Context) storage.Appender {
+	return db.app.Appender(ctx)
+}
+
+// symbolizeAtIngest returns an appendable symbolizing the profiles written
+// through app, and adds the actor rewriting the profiles of db that are
+// symbolized later.
+func symbolizeAtIngest(g *run.Group, logger log.Logger, reg prometheus.Registerer, db *tsdb.DB, app storage.Appendable, codec chunkenc.Codec, sym *symbol.Symbolizer, f *ingestSymbolizationFlags) storage.Appendable {
+	logger = log.With(logger, "component", "ingest-symbolizer")
+	i := symbol.NewIngestSymbolizer(logger, reg, app, sym,
+		symbol.WithIngestRewriter(store.NewTSDBProfileRewriter(logger, db, codec)),
+		symbol.WithIngestRetryInterval(time.Duration(*f.retryInterval)),
+		symbol.WithIngestMaxAge(time.Duration(*f.maxAge)),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g.Add(func() error {
+		return i.Run(ctx)
+	}, func(error) {
+		cancel()
+	})
+
+	return i
+}
@@ -75,8 +75,10 @@ func newLLVMSymbolizer(cmd, file string, base uint64, isData bool) (*llvmSymboli
 		cmd = defaultLLVMSymbolizer
 	}
 
+	// Newer llvm-symbolizer versions, e.g. LLVM 14, parse single dash
+	// options as grouped short flags, rejecting -inlining as unknown -n.
 	j := &llvmSymbolizerJob{
-		cmd:     exec.Command(cmd, "-inlining", "-demangle=false"),
+		cmd:     exec.Command(cmd, "--inlining", "-demangle=false"),
 		symType: "CODE",
 	}
 	if isData {
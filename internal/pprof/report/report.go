@@ -389,48 +389,9 @@ func printAssembly(w io.Writer, rpt *Report, obj plugin.ObjTool) error {
 // PrintAssembly prints annotated disassembly of rpt to w.
 func PrintAssembly(w io.Writer, rpt *Report, obj plugin.ObjTool, maxFuncs int) error {
 	o := rpt.options
-	prof := rpt.prof
-
-	g := rpt.newGraph(nil)
-
-	// If the regexp source can be parsed as an address, also match
-	// functions that land on that address.
-	var address *uint64
-	if hex, err := strconv.ParseUint(o.Symbol.String(), 0, 64); err == nil {
-		address = &hex
-	}
 
 	fmt.Fprintln(w, "Total:", rpt.formatValue(rpt.total))
-	symbols := symbolsFromBinaries(prof, g, o.Symbol, address, obj)
-	symNodes := nodesPerSymbol(g.Nodes, symbols)
-
-	// Sort for printing.
-	var syms []*objSymbol
-	for s := range symNodes {
-		syms = append(syms, s)
-	}
-	byName := func(a, b *objSymbol) bool {
-		if na, nb := a.sym.Name[0], b.sym.Name[0]; na != nb {
-			return na < nb
-		}
-		return a.sym.Start < b.sym.Start
-	}
-	if maxFuncs < 0 {
-		sort.Sort(orderSyms{syms, byName})
-	} else {
-		byFlatSum := func(a, b *objSymbol) bool {
-			suma, _ := symNodes[a].Sum()
-			sumb, _ := symNodes[b].Sum()
-			if suma != sumb {
-				return suma > sumb
-			}
-			return byName(a, b)
-		}
-		sort.Sort(orderSyms{syms, byFlatSum})
-		if len(syms) > maxFuncs {
-			syms = syms[:maxFuncs]
-		}
-	}
+	syms, symNodes := assemblySymbols(rpt, obj, maxFuncs)
 
 	// Correlate the symbols from the binary with the profile samples.
 	for _, s := range syms {
@@ -501,6 +462,113 @@ func PrintAssembly(w io.Writer, rpt *Report, obj plugin.ObjTool, maxFuncs int) e
 	return nil
 }
 
+// assemblySymbols returns the symbols of the binaries matching the regexp
+// rpt.options.symbol, together with their nodes. The symbols are sorted by
+// name, or if maxFuncs isn't negative, the maxFuncs symbols with the highest
+// flat values are returned.
+func assemblySymbols(rpt *Report, obj plugin.ObjTool, maxFuncs int) ([]*objSymbol, map[*objSymbol]graph.Nodes) {
+	o := rpt.options
+	prof := rpt.prof
+
+	g := rpt.newGraph(nil)
+
+	// If the regexp source can be parsed as an address, also match
+	// functions that land on that address.
+	var address *uint64
+	if hex, err := strconv.ParseUint(o.Symbol.String(), 0, 64); err == nil {
+		address = &hex
+	}
+
+	symbols := symbolsFromBinaries(prof, g, o.Symbol, address, obj)
+	symNodes := nodesPerSymbol(g.Nodes, symbols)
+
+	// Sort for printing.
+	var syms []*objSymbol
+	for s := range symNodes {
+		syms = append(syms, s)
+	}
+	byName := func(a, b *objSymbol) bool {
+		if na, nb := a.sym.Name[0], b.sym.Name[0]; na != nb {
+			return na < nb
+		}
+		return a.sym.Start < b.sym.Start
+	}
+	if maxFuncs < 0 {
+		sort.Sort(orderSyms{syms, byName})
+	} else {
+		byFlatSum := func(a, b *objSymbol) bool {
+			suma, _ := symNodes[a].Sum()
+			sumb, _ := symNodes[b].Sum()
+			if suma != sumb {
+				return suma > sumb
+			}
+			return byName(a, b)
+		}
+		sort.Sort(orderSyms{syms, byFlatSum})
+		if len(syms) > maxFuncs {
+			syms = syms[:maxFuncs]
+		}
+	}
+	return syms, symNodes
+}
+
+// AssemblyFunction is the annotated disassembly of a symbol.
+type AssemblyFunction struct {
+	Name string
+	// Aliases are further names of the symbol, if it was dedup'ed.
+	Aliases      []string
+	Flat, Cum    int64
+	Instructions []AssemblyInstruction
+}
+
+// AssemblyInstruction is an annotated instruction.
+type AssemblyInstruction struct {
+	Address     uint64
+	Instruction string
+	Function    string
+	File        string
+	Line        int
+	Flat, Cum   int64
+}
+
+// Disassembly returns the annotated disassembly of the symbols matching the
+// regexp rpt.options.symbol, like PrintAssembly.
+func Disassembly(rpt *Report, obj plugin.ObjTool, maxFuncs int) ([]AssemblyFunction, error) {
+	syms, symNodes := assemblySymbols(rpt, obj, maxFuncs)
+
+	res := make([]AssemblyFunction, 0, len(syms))
+	for _, s := range syms {
+		sns := symNodes[s]
+		flatSum, cumSum := sns.Sum()
+
+		insts, err := obj.Disasm(s.sym.File, s.sym.Start, s.sym.End, rpt.options.IntelSyntax)
+		if err != nil {
+			return nil, err
+		}
+
+		f := AssemblyFunction{
+			Name:         s.sym.Name[0],
+			Aliases:      s.sym.Name[1:],
+			Flat:         flatSum,
+			Cum:          cumSum,
+			Instructions: make([]AssemblyInstruction, 0, len(insts)),
+		}
+		for _, n := range annotateAssembly(insts, sns, s.file) {
+			f.Instructions = append(f.Instructions, AssemblyInstruction{
+				Address:     n.address,
+				Instruction: n.instruction,
+				Function:    n.function,
+				File:        n.file,
+				Line:        n.line,
+				Flat:        n.flatValue(),
+				Cum:         n.cumValue(),
+			})
+		}
+		res = append(res, f)
+	}
+	return res, nil
+}
+
 // symbolsFromBinaries examines the binaries listed on the profile
 // that have associated samples, and identifies symbols matching rx.
 func symbolsFromBinaries(prof *profile.Profile, g *graph.Graph, rx *regexp.Regexp, address *uint64, obj plugin.ObjTool) []*objSymbol {
@@ -675,9 +743,25 @@ func valueOrDot(value int64, rpt *Report) string {
 	return rpt.formatValue(value)
 }
 
-// printTags collects all tags referenced in the profile and prints
-// them in a sorted table.
-func printTags(w io.Writer, rpt *Report) error {
+// TagItem holds the total value of the samples referencing a tag key, and
+// the totals of its values.
+type TagItem struct {
+	Key         string
+	Total       int64
+	TotalFormat string
+	Values      []TagValueItem
+}
+
+// TagValueItem holds the total value of the samples referencing a tag value.
+type TagValueItem struct {
+	Value      string
+	Flat       int64
+	FlatFormat string
+}
+
+// TagItems collects all tags referenced in the profile. Keys are sorted by
+// name, and their values by their total.
+func TagItems(rpt *Report) []TagItem {
 	p := rpt.prof
 
 	o := rpt.options
@@ -716,24 +800,43 @@ func printTags(w io.Writer, rpt *Report) error {
 	for key := range tagMap {
 		tagKeys = append(tagKeys, &graph.Tag{Name: key})
 	}
-	tabw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.AlignRight)
+	items := make([]TagItem, 0, len(tagKeys))
 	for _, tagKey := range graph.SortTags(tagKeys, true) {
-		var total int64
-		key := tagKey.Name
-		tags := make([]*graph.Tag, 0, len(tagMap[key]))
-		for t, c := range tagMap[key] {
-			total += c
+		item := TagItem{Key: tagKey.Name}
+		tags := make([]*graph.Tag, 0, len(tagMap[item.Key]))
+		for t, c := range tagMap[item.Key] {
+			item.Total += c
 			tags = append(tags, &graph.Tag{Name: t, Flat: c})
 		}
+		item.TotalFormat = rpt.formatValue(item.Total)
 
-		f, u := measurement.Scale(total, o.SampleUnit, o.OutputUnit)
-		fmt.Fprintf(tabw, "%s:\t Total %.1f%s\n", key, f, u)
 		for _, t := range graph.SortTags(tags, true) {
-			f, u := measurement.Scale(t.FlatValue(), o.SampleUnit, o.OutputUnit)
-			if total > 0 {
-				fmt.Fprintf(tabw, " \t%.1f%s (%s):\t %s\n", f, u, measurement.Percentage(t.FlatValue(), total), t.Name)
+			item.Values = append(item.Values, TagValueItem{
+				Value:      t.Name,
+				Flat:       t.FlatValue(),
+				FlatFormat: rpt.formatValue(t.FlatValue()),
+			})
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// printTags collects all tags referenced in the profile and prints
+// them in a sorted table.
+func printTags(w io.Writer, rpt *Report) error {
+	o := rpt.options
+
+	tabw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.AlignRight)
+	for _, item := range TagItems(rpt) {
+		f, u := measurement.Scale(item.Total, o.SampleUnit, o.OutputUnit)
+		fmt.Fprintf(tabw, "%s:\t Total %.1f%s\n", item.Key, f, u)
+		for _, t := range item.Values {
+			f, u := measurement.Scale(t.Flat, o.SampleUnit, o.OutputUnit)
+			if item.Total > 0 {
+				fmt.Fprintf(tabw, " \t%.1f%s (%s):\t %s\n", f, u, measurement.Percentage(t.Flat, item.Total), t.Value)
 			} else {
-				fmt.Fprintf(tabw, " \t%.1f%s:\t %s\n", f, u, t.Name)
+				fmt.Fprintf(tabw, " \t%.1f%s:\t %s\n", f, u, t.Value)
 			}
 		}
 		fmt.Fprintln(tabw)
@@ -1304,6 +1407,9 @@ type Report struct {
 // Total returns the total number of samples in a report.
 func (rpt *Report) Total() int64 { return rpt.total }
 
+// FormatValue formats a sample value in the output unit of the report.
+func (rpt *Report) FormatValue(v int64) string { return rpt.formatValue(v) }
+
 func abs64(i int64) int64 {
 	if i < 0 {
 		return -i
@@ -118,6 +118,96 @@ func printSource(w io.Writer, rpt *Report) error {
 	return nil
 }
 
+// SourceOpener opens a source file by the name encoded in the profile.
+type SourceOpener func(path string) (io.ReadCloser, error)
+
+// SourceFunction is the annotated source listing of a function in one of its
+// source files.
+type SourceFunction struct {
+	Name      string
+	File      string
+	Flat, Cum int64
+	Lines     []SourceLine
+	// Err is set if the source file couldn't be read.
+	Err error
+}
+
+// SourceLine is an annotated line of source code.
+type SourceLine struct {
+	Line      int
+	Flat, Cum int64
+	Source    string
+}
+
+// SourceListing returns the annotated source listings of all functions with
+// samples that match the regexp rpt.options.symbol, like printSource, reading
+// the source files using open.
+func SourceListing(rpt *Report, open SourceOpener) []SourceFunction {
+	o := rpt.options
+	g := rpt.newGraph(nil)
+
+	var functions graph.Nodes
+	functionNodes := make(map[string]graph.Nodes)
+	for _, n := range g.Nodes {
+		if !o.Symbol.MatchString(n.Info.Name) {
+			continue
+		}
+		if functionNodes[n.Info.Name] == nil {
+			functions = append(functions, n)
+		}
+		functionNodes[n.Info.Name] = append(functionNodes[n.Info.Name], n)
+	}
+	functions.Sort(graph.NameOrder)
+
+	reader := newSourceReader("", o.TrimPath)
+	reader.open = open
+
+	res := []SourceFunction{}
+	for _, fn := range functions {
+		name := fn.Info.Name
+
+		var sourceFiles graph.Nodes
+		fileNodes := make(map[string]graph.Nodes)
+		for _, n := range functionNodes[name] {
+			if n.Info.File == "" {
+				continue
+			}
+			if fileNodes[n.Info.File] == nil {
+				sourceFiles = append(sourceFiles, n)
+			}
+			fileNodes[n.Info.File] = append(fileNodes[n.Info.File], n)
+		}
+		sourceFiles.Sort(graph.FileOrder)
+
+		for _, fl := range sourceFiles {
+			filename := fl.Info.File
+			fns := fileNodes[filename]
+			flatSum, cumSum := fns.Sum()
+
+			f := SourceFunction{
+				Name: name,
+				File: filename,
+				Flat: flatSum,
+				Cum:  cumSum,
+			}
+			fnodes, _, err := getSourceFromFile(filename, reader, fns, 0, 0)
+			if err != nil {
+				f.Err = err
+			}
+			for _, n := range fnodes {
+				f.Lines = append(f.Lines, SourceLine{
+					Line:   n.Info.Lineno,
+					Flat:   n.Flat,
+					Cum:    n.Cum,
+					Source: n.Info.Name,
+				})
+			}
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
 // printWebSource prints an annotated source listing, include all
 // functions with samples that match the regexp rpt.options.symbol.
 func printWebSource(w io.Writer, rpt *Report, obj plugin.ObjTool) error {
@@ -947,6 +1037,10 @@ type sourceReader struct {
 	// errors collects errors encountered per file. These errors are
 	// consulted before returning out of these module.
 	errors map[string]error
+
+	// open opens source files instead of searching them on the local file
+	// system, if set.
+	open SourceOpener
 }
 
 func newSourceReader(searchPath, trimPath string) *sourceReader {
@@ -955,6 +1049,7 @@ func newSourceReader(searchPath, trimPath string) *sourceReader {
 		trimPath,
 		make(map[string][]string),
 		make(map[string]error),
+		nil,
 	}
 }
 
@@ -968,7 +1063,13 @@ func (reader *sourceReader) line(path string, lineno int) (string, bool) {
 	if !ok {
 		// Read and cache file contents.
 		lines = []string{""} // Skip 0th line
-		f, err := openSourceFile(path, reader.searchPath, reader.trimPath)
+		var f io.ReadCloser
+		var err error
+		if reader.open != nil {
+			f, err = reader.open(path)
+		} else {
+			f, err = openSourceFile(path, reader.searchPath, reader.trimPath)
+		}
 		if err != nil {
 			reader.errors[path] = err
 		} else {
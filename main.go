@@ -56,6 +56,18 @@ type httpMux interface {
 
 type setupFunc func(component.Component, *run.Group, httpMux, prober.Probe, log.Logger, *prometheus.Registry, bool) (prober.Probe, error)
 
+// oneShotCommands are the commands that do a single task and exit.
+var oneShotCommands = map[string]bool{}
+
+// registerOneShot registers the setup of a command that does a single task
+// and exits. Unlike the servers it doesn't serve HTTP, so it neither competes
+// with a running conprof for the HTTP address nor logs the server starting
+// and stopping.
+func registerOneShot(m map[string]setupFunc, name string, setup setupFunc) {
+	m[name] = setup
+	oneShotCommands[name] = true
+}
+
 type configReloaders struct {
 	funcs []func(*config.Config) error
 }
@@ -88,17 +100,11 @@ func main() {
 		Default("").String()
 	httpBindAddr, httpGracePeriod, _ := extkingpin.RegisterHTTPFlags(app)
 
-	cmds := map[string]setupFunc{}
 	reloadCh := make(chan struct{}, 1)
 
 	reloaders := &configReloaders{}
 
-	registerSampler(cmds, app, "sampler", reloadCh, reloaders)
-	registerStorage(cmds, app, "storage", reloadCh)
-	registerWeb(cmds, app, "web", reloadCh, reloaders)
-	registerApi(cmds, app, "api")
-	registerSymbol(cmds, app, "symbol")
-	registerAll(cmds, app, "all", reloadCh, reloaders)
+	cmds := registerCommands(app, reloadCh, reloaders)
 
 	cmd, err := app.Parse(os.Args[1:])
 	if err != nil {
@@ -158,7 +164,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	{
+	if !oneShotCommands[cmd] {
 		srv := httpserver.New(logger, reg, comp, httpProbe,
 			httpserver.WithListen(*httpBindAddr),
 			httpserver.WithGracePeriod(time.Duration(*httpGracePeriod)),
@@ -189,6 +195,23 @@ func main() {
 	level.Info(logger).Log("msg", "exiting")
 }
 
+func registerCommands(app *kingpin.Application, reloadCh chan struct{}, reloaders *configReloaders) map[string]setupFunc {
+	cmds := map[string]setupFunc{}
+
+	registerSampler(cmds, app, "sampler", reloadCh, reloaders)
+	registerStorage(cmds, app, "storage", reloadCh)
+	registerWeb(cmds, app, "web", reloadCh, reloaders)
+	registerApi(cmds, app, "api")
+	registerSymbol(cmds, app, "symbol")
+	registerAll(cmds, app, "all", reloadCh, reloaders)
+	registerUpload(cmds, app, "upload")
+	registerImport(cmds, app, "import")
+	registerDebuginfo(cmds, app, "debuginfo")
+	registerTSDB(cmds, app, "tsdb")
+
+	return cmds
+}
+
 func cors(corsOrigin, corsMethods string, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if corsOrigin != "" {
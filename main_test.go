@@ -0,0 +1,53 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func TestOneShotCommands(t *testing.T) {
+	cmds := registerCommands(kingpin.New("conprof", ""), make(chan struct{}, 1), &configReloaders{})
+
+	var servers, oneShot []string
+	for name := range cmds {
+		if oneShotCommands[name] {
+			oneShot = append(oneShot, name)
+			continue
+		}
+		servers = append(servers, name)
+	}
+	sort.Strings(servers)
+	sort.Strings(oneShot)
+
+	// Only the servers serve HTTP, the other commands must not compete with
+	// them for the HTTP address.
+	require.Equal(t, []string{"all", "api", "sampler", "storage", "symbol", "web"}, servers)
+	require.Equal(t, []string{
+		"debuginfo upload",
+		"debuginfo upload-kallsyms",
+		"debuginfo upload-sources",
+		"import",
+		"tsdb analyze",
+		"tsdb delete",
+		"tsdb dump",
+		"tsdb ls",
+		"tsdb recompress",
+		"upload",
+	}, oneShot)
+}
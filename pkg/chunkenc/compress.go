@@ -0,0 +1,242 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunkenc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	tsdbchunkenc "github.com/conprof/db/tsdb/chunkenc"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is the compression of the profiles appended to bytes chunks. The
+// TSDB's chunk encodings are fixed, so the codec isn't recorded in the chunk
+// encoding. Instead, each compressed profile starts with a header byte
+// recording its codec, uncompressed profiles are stored as they are.
+type Codec string
+
+const (
+	CodecNone Codec = "none"
+	CodecGzip Codec = "gzip"
+	CodecZstd Codec = "zstd"
+)
+
+// Codecs are the names of all codecs.
+var Codecs = []string{string(CodecNone), string(CodecGzip), string(CodecZstd)}
+
+// The header bytes of compressed profiles have the protobuf wire type 7,
+// which is invalid, so no uncompressed pprof profile starts with them, and
+// neither does a gzip or zstd stream.
+const (
+	gzipHeader byte = 0x0f
+	zstdHeader byte = 0x17
+)
+
+// The magic numbers of profiles compressed before their codec was recorded.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// zstdCodec returns the zstd encoder and decoder shared by all chunks, which
+// can be used concurrently.
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+// CodecOf returns the codec the profile is compressed with, recorded by its
+// header. Profiles compressed before the codec was recorded are detected by
+// their magic number. Profiles without either are uncompressed, as the first
+// byte of a pprof profile is the tag of one of its fields, which is never the
+// first byte of a header or magic number.
+func CodecOf(b []byte) Codec {
+	if codec, ok := headerCodec(b); ok {
+		return codec
+	}
+	switch {
+	case bytes.HasPrefix(b, zstdMagic):
+		return CodecZstd
+	case bytes.HasPrefix(b, gzipMagic):
+		return CodecGzip
+	}
+	return CodecNone
+}
+
+// headerCodec returns the codec recorded by the header of the profile, and
+// whether it has one.
+func headerCodec(b []byte) (Codec, bool) {
+	if len(b) == 0 {
+		return "", false
+	}
+	switch b[0] {
+	case gzipHeader:
+		return CodecGzip, true
+	case zstdHeader:
+		return CodecZstd, true
+	}
+	return "", false
+}
+
+// Compress compresses the profile with the codec and records it in the
+// header, unless it is compressed with the codec already. Profiles
+// compressed with another codec are recompressed.
+func Compress(codec Codec, b []byte) ([]byte, error) {
+	if _, ok := headerCodec(b); ok && CodecOf(b) == codec {
+		return b, nil
+	}
+	b, err := Decompress(b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch codec {
+	case CodecNone:
+		return b, nil
+	case CodecGzip:
+		buf := bytes.NewBuffer(make([]byte, 0, len(b)/4))
+		buf.WriteByte(gzipHeader)
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CodecZstd:
+		enc, _, err := zstdCodec()
+		if err != nil {
+			return nil, err
+		}
+		return enc.EncodeAll(b, append(make([]byte, 0, len(b)/4), zstdHeader)), nil
+	}
+	return nil, fmt.Errorf("unknown codec %q", codec)
+}
+
+// Decompress returns the decompressed profile, profiles that aren't
+// compressed are returned as they are.
+func Decompress(b []byte) ([]byte, error) {
+	codec := CodecOf(b)
+	if _, ok := headerCodec(b); ok {
+		b = b[1:]
+	}
+
+	switch codec {
+	case CodecZstd:
+		_, dec, err := zstdCodec()
+		if err != nil {
+			return nil, err
+		}
+		return dec.DecodeAll(b, nil)
+	case CodecGzip:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	return b, nil
+}
+
+// Recompress returns a bytes chunk of the profiles of the chunk compressed
+// with the codec.
+func Recompress(codec Codec, c tsdbchunkenc.Chunk) (tsdbchunkenc.Chunk, error) {
+	rc := tsdbchunkenc.NewBytesChunk()
+	app, err := rc.Appender()
+	if err != nil {
+		return nil, err
+	}
+	it := c.Iterator(nil)
+	for it.Next() {
+		t, v := it.At()
+		v, err := Compress(codec, v)
+		if err != nil {
+			return nil, fmt.Errorf("compress profile at %d: %w", t, err)
+		}
+		app.Append(t, v)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// decompressingChunk is a bytes chunk, whose iterators decompress the
+// profiles.
+type decompressingChunk struct {
+	tsdbchunkenc.Chunk
+}
+
+func (c *decompressingChunk) Iterator(it tsdbchunkenc.Iterator) tsdbchunkenc.Iterator {
+	if dit, ok := it.(*decompressingIterator); ok {
+		it = dit.Iterator
+	}
+	return NewDecompressingIterator(c.Chunk.Iterator(it))
+}
+
+// NewDecompressingIterator returns an iterator decompressing the profiles
+// of the iterator.
+func NewDecompressingIterator(it tsdbchunkenc.Iterator) tsdbchunkenc.Iterator {
+	return &decompressingIterator{Iterator: it}
+}
+
+type decompressingIterator struct {
+	tsdbchunkenc.Iterator
+	err error
+}
+
+func (it *decompressingIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	return it.Iterator.Next()
+}
+
+// At returns the current decompressed profile. Profiles that fail to
+// decompress are returned as they are, and fail the iterator.
+func (it *decompressingIterator) At() (int64, []byte) {
+	t, v := it.Iterator.At()
+	d, err := Decompress(v)
+	if err != nil {
+		it.err = fmt.Errorf("decompress profile at %d: %w", t, err)
+		return t, v
+	}
+	return t, d
+}
+
+func (it *decompressingIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.Iterator.Err()
+}
@@ -0,0 +1,164 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunkenc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	tsdbchunkenc "github.com/conprof/db/tsdb/chunkenc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	raw := scrapes(t, 1)[0]
+	require.Equal(t, CodecNone, CodecOf(raw))
+
+	for _, codec := range []Codec{CodecNone, CodecGzip, CodecZstd} {
+		t.Run(string(codec), func(t *testing.T) {
+			b, err := Compress(codec, raw)
+			require.NoError(t, err)
+			require.Equal(t, codec, CodecOf(b))
+			recorded, ok := headerCodec(b)
+			require.Equal(t, codec != CodecNone, ok)
+			if ok {
+				require.Equal(t, codec, recorded)
+			}
+
+			// Profiles already compressed with the codec are left as they are.
+			again, err := Compress(codec, b)
+			require.NoError(t, err)
+			require.Equal(t, b, again)
+
+			d, err := Decompress(b)
+			require.NoError(t, err)
+			require.Equal(t, raw, d)
+
+			// Profiles compressed with another codec are recompressed.
+			for _, other := range []Codec{CodecNone, CodecGzip, CodecZstd} {
+				o, err := Compress(other, b)
+				require.NoError(t, err)
+				require.Equal(t, other, CodecOf(o))
+
+				d, err := Decompress(o)
+				require.NoError(t, err)
+				require.Equal(t, raw, d)
+			}
+		})
+	}
+
+	_, err := Compress(Codec("lz4"), raw)
+	require.Error(t, err)
+}
+
+func TestDecompressLegacy(t *testing.T) {
+	raw := scrapes(t, 1)[0]
+
+	// Profiles compressed before the codec was recorded have no header.
+	buf := bytes.NewBuffer(nil)
+	w := gzip.NewWriter(buf)
+	_, err := w.Write(raw)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	enc, _, err := zstdCodec()
+	require.NoError(t, err)
+
+	for codec, b := range map[Codec][]byte{
+		CodecGzip: buf.Bytes(),
+		CodecZstd: enc.EncodeAll(raw, nil),
+	} {
+		require.Equal(t, codec, CodecOf(b))
+		d, err := Decompress(b)
+		require.NoError(t, err)
+		require.Equal(t, raw, d)
+
+		// Compressing them again records the codec.
+		c, err := Compress(codec, b)
+		require.NoError(t, err)
+		_, ok := headerCodec(c)
+		require.True(t, ok)
+		d, err = Decompress(c)
+		require.NoError(t, err)
+		require.Equal(t, raw, d)
+	}
+}
+
+func TestRecompress(t *testing.T) {
+	profiles := scrapes(t, 4)
+
+	c := tsdbchunkenc.NewBytesChunk()
+	app, err := c.Appender()
+	require.NoError(t, err)
+	for i, p := range profiles {
+		app.Append(int64(i), p)
+	}
+
+	rc, err := Recompress(CodecZstd, c)
+	require.NoError(t, err)
+	b, err := rc.Bytes()
+	require.NoError(t, err)
+
+	// Bytes chunks read from the store decompress their profiles.
+	loaded, err := FromData(tsdbchunkenc.EncBytes, b)
+	require.NoError(t, err)
+
+	it := rc.Iterator(nil)
+	lit := loaded.Iterator(nil)
+	i := 0
+	for it.Next() {
+		require.True(t, lit.Next())
+
+		ts, v := it.At()
+		require.Equal(t, int64(i), ts)
+		require.Equal(t, CodecZstd, CodecOf(v))
+
+		ts, v = lit.At()
+		require.Equal(t, int64(i), ts)
+		require.Equal(t, profiles[i], v)
+		i++
+	}
+	require.NoError(t, it.Err())
+	require.False(t, lit.Next())
+	require.NoError(t, lit.Err())
+	require.Equal(t, len(profiles), i)
+
+	// Compressed profiles can be reencoded as well.
+	pc, err := Reencode(rc)
+	require.NoError(t, err)
+	pit := pc.Iterator(nil)
+	i = 0
+	for pit.Next() {
+		p, err := pit.(ProfileIterator).AtProfile()
+		require.NoError(t, err)
+		requireEqualProfiles(t, profiles[i], p)
+		i++
+	}
+	require.NoError(t, pit.Err())
+	require.Equal(t, len(profiles), i)
+}
+
+func TestDecompressingIteratorCorrupted(t *testing.T) {
+	c := tsdbchunkenc.NewBytesChunk()
+	app, err := c.Appender()
+	require.NoError(t, err)
+	app.Append(0, append(append([]byte{}, zstdMagic...), "not zstd"...))
+	app.Append(1, []byte("profile"))
+
+	it := NewDecompressingIterator(c.Iterator(nil))
+	require.True(t, it.Next())
+	it.At()
+	require.False(t, it.Next())
+	require.Error(t, it.Err())
+}
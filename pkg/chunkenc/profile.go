@@ -0,0 +1,1045 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunkenc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/conprof/db/storage"
+	tsdbchunkenc "github.com/conprof/db/tsdb/chunkenc"
+	"github.com/conprof/db/tsdb/tsdbutil"
+	"github.com/google/pprof/profile"
+)
+
+// EncProfile is the encoding of ProfileChunk. It is only used where conprof
+// controls the encoding, e.g. for the chunks sent by the store, and is not
+// persisted: the head of the TSDB always cuts bytes chunks, and the TSDB reads
+// blocks with a chunk pool that only loads bytes chunks, so blocks keep
+// storing whole profiles in bytes chunks. Storing profile chunks requires the
+// TSDB to accept a chunk pool and head chunk encoding.
+const EncProfile tsdbchunkenc.Encoding = 5
+
+// profileChunkHeaderSize is the size of the number of samples stored in
+// front of the compressed records.
+const profileChunkHeaderSize = 2
+
+// ProfileChunk stores pprof profiles, deduplicating the strings, mappings,
+// functions, locations and stacks of all profiles in the chunk. Each sample
+// of the chunk is a record of the table entries first referenced by its
+// profile, followed by the profile's header and its samples, which refer to
+// their stacks by ID. The records are compressed with zstd.
+type ProfileChunk struct {
+	// b is the encoded chunk, it is set when the chunk was loaded or once
+	// its bytes were requested.
+	b   []byte
+	num uint16
+
+	enc *profileEncoder
+}
+
+// NewProfileChunk returns a new chunk with profile encoding.
+func NewProfileChunk() *ProfileChunk {
+	return &ProfileChunk{enc: newProfileEncoder()}
+}
+
+// LoadProfileChunk returns the profile chunk of the encoded bytes.
+func LoadProfileChunk(b []byte) (*ProfileChunk, error) {
+	if len(b) < profileChunkHeaderSize {
+		return nil, errors.New("profile chunk too short")
+	}
+	return &ProfileChunk{
+		b:   b,
+		num: binary.BigEndian.Uint16(b[0:profileChunkHeaderSize]),
+	}, nil
+}
+
+func (c *ProfileChunk) Bytes() ([]byte, error) {
+	if c.b != nil {
+		return c.b, nil
+	}
+	if c.enc.err != nil {
+		return nil, c.enc.err
+	}
+
+	enc, _, err := zstdCodec()
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, profileChunkHeaderSize, profileChunkHeaderSize+len(c.enc.body)/4)
+	binary.BigEndian.PutUint16(b, c.num)
+	c.b = enc.EncodeAll(c.enc.body, b)
+	return c.b, nil
+}
+
+func (c *ProfileChunk) Encoding() tsdbchunkenc.Encoding {
+	return EncProfile
+}
+
+func (c *ProfileChunk) NumSamples() int {
+	return int(c.num)
+}
+
+func (c *ProfileChunk) Compact() {}
+
+// Appender returns an appender of profiles. Appending to a loaded chunk
+// decodes all of its records first.
+func (c *ProfileChunk) Appender() (tsdbchunkenc.Appender, error) {
+	if c.enc == nil {
+		body, err := c.body()
+		if err != nil {
+			return nil, err
+		}
+		enc, err := loadProfileEncoder(body)
+		if err != nil {
+			return nil, err
+		}
+		c.enc = enc
+	}
+	return &profileAppender{c: c}, nil
+}
+
+func (c *ProfileChunk) body() ([]byte, error) {
+	if c.enc != nil {
+		return c.enc.body, nil
+	}
+	_, dec, err := zstdCodec()
+	if err != nil {
+		return nil, err
+	}
+	return dec.DecodeAll(c.b[profileChunkHeaderSize:], nil)
+}
+
+// Iterator returns an iterator over the profiles of the chunk. The iterator
+// implements ProfileIterator.
+func (c *ProfileChunk) Iterator(tsdbchunkenc.Iterator) tsdbchunkenc.Iterator {
+	it := &profileIterator{num: int(c.num), pos: -1}
+	it.d.body, it.err = c.body()
+	it.Iterator = storage.NewListSeriesIterator(&recordWindow{it: it})
+	return it
+}
+
+type profileAppender struct {
+	c *ProfileChunk
+}
+
+// Append appends the pprof profile, which can be compressed with any codec.
+// As appenders can't return errors, an invalid profile fails all further
+// calls to Bytes.
+func (a *profileAppender) Append(t int64, v []byte) {
+	if a.c.enc.err != nil {
+		return
+	}
+	v, err := Decompress(v)
+	if err != nil {
+		a.c.enc.err = fmt.Errorf("decompress profile at %d: %w", t, err)
+		return
+	}
+	p, err := profile.ParseData(v)
+	if err != nil {
+		a.c.enc.err = fmt.Errorf("parse profile at %d: %w", t, err)
+		return
+	}
+	a.c.enc.append(t, p)
+	a.c.num++
+	a.c.b = nil
+}
+
+// ProfileIterator is implemented by iterators that decode profiles
+// themselves, so that their profiles don't need to be parsed again.
+type ProfileIterator interface {
+	tsdbchunkenc.Iterator
+	// AtProfile returns the current profile. The mappings, locations and
+	// functions of the profile are shared with the other profiles of the
+	// chunk, so they must not be modified before the iteration is done.
+	AtProfile() (*profile.Profile, error)
+}
+
+// profileIterator iterates over the records of a chunk, which are decoded in
+// order as they are needed. Seeking is done by the embedded list iterator over
+// a window of the records, which starts at the current record, as the list
+// iterator only seeks correctly from its first sample.
+type profileIterator struct {
+	tsdbchunkenc.Iterator
+
+	d   profileDecoder
+	num int
+	err error
+
+	// records are the decoded records. The profiles of records before the
+	// current one are dropped, as iterators only move forward.
+	records []profileRecord
+	// pos is the current record, -1 before the first call to Next or Seek.
+	pos int
+	// v is the encoded current profile, if it was requested.
+	v []byte
+}
+
+func (it *profileIterator) Next() bool {
+	if it.err != nil || it.pos+1 >= it.num {
+		it.pos = it.num
+		return false
+	}
+	it.moveTo(it.pos + 1)
+	return it.err == nil
+}
+
+// At returns the current profile in the uncompressed pprof format.
+func (it *profileIterator) At() (int64, []byte) {
+	rec := it.records[it.pos]
+	if it.v == nil && rec.p != nil {
+		buf := bytes.NewBuffer(nil)
+		if err := rec.p.WriteUncompressed(buf); err != nil {
+			it.err = err
+		}
+		it.v = buf.Bytes()
+	}
+	return rec.t, it.v
+}
+
+func (it *profileIterator) AtProfile() (*profile.Profile, error) {
+	return it.records[it.pos].p, it.err
+}
+
+func (it *profileIterator) Err() error {
+	return it.err
+}
+
+// moveTo moves the iterator to the record at pos, decodes it and starts a
+// new window there.
+func (it *profileIterator) moveTo(pos int) {
+	for i := it.pos; i < pos && i < len(it.records); i++ {
+		if i >= 0 {
+			it.records[i].p = nil
+		}
+	}
+	it.pos = pos
+	it.v = nil
+	// Decode the record right away, so that errors stop the iteration.
+	it.record(pos)
+	it.Iterator = storage.NewListSeriesIterator(&recordWindow{it: it, base: pos})
+}
+
+// record returns the record at i, decoding all records up to it. Records that
+// fail to decode are at the maximum time, so that seeking stops at them.
+func (it *profileIterator) record(i int) profileRecord {
+	for it.err == nil && len(it.records) <= i {
+		t, p, err := it.d.next()
+		if err != nil {
+			it.err = err
+			break
+		}
+		it.records = append(it.records, profileRecord{t: t, p: p})
+	}
+	if i >= len(it.records) {
+		return profileRecord{t: math.MaxInt64}
+	}
+	return it.records[i]
+}
+
+type profileRecord struct {
+	t int64
+	p *profile.Profile
+}
+
+func (r profileRecord) T() int64 { return r.t }
+
+// V returns nil, the profile iterator encodes the profile only if needed.
+func (r profileRecord) V() []byte { return nil }
+
+// recordWindow are the records from base on, as samples of a list iterator.
+type recordWindow struct {
+	it   *profileIterator
+	base int
+
+	// sought is set once a Seek of the list iterator reads a record.
+	sought bool
+	// found is the record at the list iterator, while it is read.
+	found *int
+}
+
+// Len returns the number of records in the window. The list iterator also
+// calls it at the end of a Seek, once it read the records, which is when the
+// profile iterator moves to the record found.
+func (w *recordWindow) Len() int {
+	n := w.it.num - w.base
+	if !w.sought {
+		return n
+	}
+	w.sought = false
+
+	var found int
+	w.found = &found
+	w.it.Iterator.At()
+	w.found = nil
+	if found >= w.it.num {
+		w.it.pos = w.it.num
+		return 0
+	}
+	// Moving starts a new window, the list iterator doing the Seek only
+	// compares its position to the returned length.
+	w.it.moveTo(found)
+	if w.it.err != nil {
+		return 0
+	}
+	return n
+}
+
+func (w *recordWindow) Get(i int) tsdbutil.Sample {
+	if w.found != nil {
+		*w.found = w.base + i
+		return profileRecord{}
+	}
+	w.sought = true
+	return w.it.record(w.base + i)
+}
+
+type mappingKey struct {
+	start, limit, offset uint64
+	file, buildID        string
+	flags                byte
+}
+
+type functionKey struct {
+	name, systemName, filename string
+	startLine                  int64
+}
+
+const (
+	mappingHasFunctions byte = 1 << iota
+	mappingHasFilenames
+	mappingHasLineNumbers
+	mappingHasInlineFrames
+)
+
+// profileEncoder encodes the records of a chunk. Each record starts with
+// the new strings, mappings, functions, locations and stacks of the
+// profile, in that order so every entry only refers to earlier ones.
+type profileEncoder struct {
+	body []byte
+	err  error
+
+	lastT     int64
+	strings   map[string]uint64
+	mappings  map[mappingKey]uint64
+	functions map[functionKey]uint64
+	locations map[string]uint64
+	stacks    map[string]uint64
+	// values are the last values of every stack, which the values of the
+	// next sample with the stack are encoded as the difference to.
+	values map[uint64][]int64
+
+	// The entries new to the profile being appended.
+	newStrings, newMappings, newFunctions, newLocations, newStacks []byte
+	numStrings, numMappings, numFunctions, numLocations, numStacks uint64
+
+	// mappingIDs and locationIDs map the IDs of the profile being appended
+	// to the IDs of the chunk.
+	mappingIDs  map[uint64]uint64
+	locationIDs map[uint64]uint64
+	key         []byte
+}
+
+func newProfileEncoder() *profileEncoder {
+	return &profileEncoder{
+		strings:   map[string]uint64{"": 0},
+		mappings:  map[mappingKey]uint64{},
+		functions: map[functionKey]uint64{},
+		locations: map[string]uint64{},
+		stacks:    map[string]uint64{},
+		values:    map[uint64][]int64{},
+	}
+}
+
+// loadProfileEncoder returns an encoder to append to the decoded records.
+func loadProfileEncoder(body []byte) (*profileEncoder, error) {
+	e := newProfileEncoder()
+	d := profileDecoder{body: body}
+	for len(d.body) > d.pos {
+		t, _, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		e.lastT = t
+	}
+	for i, s := range d.strings {
+		e.strings[s] = uint64(i)
+	}
+	for _, m := range d.mappings {
+		e.mappings[keyOfMapping(m)] = m.ID
+	}
+	for _, f := range d.functions {
+		e.functions[functionKey{f.Name, f.SystemName, f.Filename, f.StartLine}] = f.ID
+	}
+	for _, l := range d.locations {
+		e.locations[string(e.locationKey(l, l.Mapping))] = l.ID
+	}
+	for i, s := range d.stacks {
+		e.key = e.key[:0]
+		for _, l := range s {
+			e.key = appendUvarint(e.key, l.ID)
+		}
+		e.stacks[string(e.key)] = uint64(i)
+	}
+	if d.values != nil {
+		e.values = d.values
+	}
+	e.body = body
+	return e, nil
+}
+
+func keyOfMapping(m *profile.Mapping) mappingKey {
+	var flags byte
+	if m.HasFunctions {
+		flags |= mappingHasFunctions
+	}
+	if m.HasFilenames {
+		flags |= mappingHasFilenames
+	}
+	if m.HasLineNumbers {
+		flags |= mappingHasLineNumbers
+	}
+	if m.HasInlineFrames {
+		flags |= mappingHasInlineFrames
+	}
+	return mappingKey{
+		start:   m.Start,
+		limit:   m.Limit,
+		offset:  m.Offset,
+		file:    m.File,
+		buildID: m.BuildID,
+		flags:   flags,
+	}
+}
+
+func (e *profileEncoder) intern(s string) uint64 {
+	if id, ok := e.strings[s]; ok {
+		return id
+	}
+	id := uint64(len(e.strings))
+	e.strings[s] = id
+	e.newStrings = appendString(e.newStrings, s)
+	e.numStrings++
+	return id
+}
+
+// mapping returns the chunk's ID of the mapping, 0 is no mapping.
+func (e *profileEncoder) mapping(m *profile.Mapping) uint64 {
+	if m == nil {
+		return 0
+	}
+	if id, ok := e.mappingIDs[m.ID]; ok {
+		return id
+	}
+	k := keyOfMapping(m)
+	id, ok := e.mappings[k]
+	if !ok {
+		file, buildID := e.intern(k.file), e.intern(k.buildID)
+		id = uint64(len(e.mappings)) + 1
+		e.mappings[k] = id
+		e.newMappings = appendUvarint(e.newMappings, k.start)
+		e.newMappings = appendUvarint(e.newMappings, k.limit)
+		e.newMappings = appendUvarint(e.newMappings, k.offset)
+		e.newMappings = appendUvarint(e.newMappings, file)
+		e.newMappings = appendUvarint(e.newMappings, buildID)
+		e.newMappings = append(e.newMappings, k.flags)
+		e.numMappings++
+	}
+	e.mappingIDs[m.ID] = id
+	return id
+}
+
+func (e *profileEncoder) function(f *profile.Function) uint64 {
+	k := functionKey{f.Name, f.SystemName, f.Filename, f.StartLine}
+	if id, ok := e.functions[k]; ok {
+		return id
+	}
+	name, systemName, filename := e.intern(k.name), e.intern(k.systemName), e.intern(k.filename)
+	id := uint64(len(e.functions)) + 1
+	e.functions[k] = id
+	e.newFunctions = appendUvarint(e.newFunctions, name)
+	e.newFunctions = appendUvarint(e.newFunctions, systemName)
+	e.newFunctions = appendUvarint(e.newFunctions, filename)
+	e.newFunctions = appendVarint(e.newFunctions, k.startLine)
+	e.numFunctions++
+	return id
+}
+
+// locationKey returns the encoding of the location, which refers to the
+// mapping and functions by their chunk IDs.
+func (e *profileEncoder) locationKey(l *profile.Location, m *profile.Mapping) []byte {
+	key := appendUvarint(nil, mappingID(m))
+	key = appendUvarint(key, l.Address)
+	if l.IsFolded {
+		key = append(key, 1)
+	} else {
+		key = append(key, 0)
+	}
+	key = appendUvarint(key, uint64(len(l.Line)))
+	for _, line := range l.Line {
+		key = appendUvarint(key, functionID(line.Function))
+		key = appendVarint(key, line.Line)
+	}
+	return key
+}
+
+func mappingID(m *profile.Mapping) uint64 {
+	if m == nil {
+		return 0
+	}
+	return m.ID
+}
+
+func functionID(f *profile.Function) uint64 {
+	if f == nil {
+		return 0
+	}
+	return f.ID
+}
+
+func (e *profileEncoder) location(l *profile.Location) uint64 {
+	if id, ok := e.locationIDs[l.ID]; ok {
+		return id
+	}
+
+	// The key is built from a copy that refers to the chunk's IDs.
+	cl := &profile.Location{Address: l.Address, IsFolded: l.IsFolded, Line: make([]profile.Line, len(l.Line))}
+	for i, line := range l.Line {
+		cl.Line[i].Line = line.Line
+		if line.Function != nil {
+			cl.Line[i].Function = &profile.Function{ID: e.function(line.Function)}
+		}
+	}
+	var cm *profile.Mapping
+	if l.Mapping != nil {
+		cm = &profile.Mapping{ID: e.mapping(l.Mapping)}
+	}
+	key := e.locationKey(cl, cm)
+
+	id, ok := e.locations[string(key)]
+	if !ok {
+		id = uint64(len(e.locations)) + 1
+		e.locations[string(key)] = id
+		e.newLocations = append(e.newLocations, key...)
+		e.numLocations++
+	}
+	e.locationIDs[l.ID] = id
+	return id
+}
+
+func (e *profileEncoder) stack(locs []*profile.Location) uint64 {
+	e.key = e.key[:0]
+	e.key = appendUvarint(e.key, uint64(len(locs)))
+	for _, l := range locs {
+		e.key = appendUvarint(e.key, e.location(l))
+	}
+	// The stack's key has its length prepended, unlike in loadProfileEncoder,
+	// as it is written as is.
+	k := string(e.key[uvarintLen(uint64(len(locs))):])
+	if id, ok := e.stacks[k]; ok {
+		return id
+	}
+	id := uint64(len(e.stacks))
+	e.stacks[k] = id
+	e.newStacks = append(e.newStacks, e.key...)
+	e.numStacks++
+	return id
+}
+
+func (e *profileEncoder) append(t int64, p *profile.Profile) {
+	e.newStrings, e.newMappings, e.newFunctions, e.newLocations, e.newStacks = e.newStrings[:0], e.newMappings[:0], e.newFunctions[:0], e.newLocations[:0], e.newStacks[:0]
+	e.numStrings, e.numMappings, e.numFunctions, e.numLocations, e.numStacks = 0, 0, 0, 0, 0
+	e.mappingIDs = make(map[uint64]uint64, len(p.Mapping))
+	e.locationIDs = make(map[uint64]uint64, len(p.Location))
+
+	// The profile is encoded first, as that interns the new table entries
+	// which have to be written before it.
+	var rec []byte
+	rec = appendUvarint(rec, uint64(len(p.Mapping)))
+	for _, m := range p.Mapping {
+		rec = appendUvarint(rec, e.mapping(m))
+	}
+	rec = appendUvarint(rec, uint64(len(p.SampleType)))
+	for _, st := range p.SampleType {
+		rec = appendUvarint(rec, e.intern(st.Type))
+		rec = appendUvarint(rec, e.intern(st.Unit))
+	}
+	rec = appendUvarint(rec, e.intern(p.DefaultSampleType))
+	rec = appendUvarint(rec, e.intern(p.DropFrames))
+	rec = appendUvarint(rec, e.intern(p.KeepFrames))
+	rec = appendVarint(rec, p.TimeNanos)
+	rec = appendVarint(rec, p.DurationNanos)
+	if p.PeriodType != nil {
+		rec = append(rec, 1)
+		rec = appendUvarint(rec, e.intern(p.PeriodType.Type))
+		rec = appendUvarint(rec, e.intern(p.PeriodType.Unit))
+	} else {
+		rec = append(rec, 0)
+	}
+	rec = appendVarint(rec, p.Period)
+	rec = appendUvarint(rec, uint64(len(p.Comments)))
+	for _, c := range p.Comments {
+		rec = appendUvarint(rec, e.intern(c))
+	}
+
+	// Samples tend to be in the same order in every profile, so the stack
+	// IDs are encoded as the difference to the previous sample's.
+	var prevStack uint64
+	rec = appendUvarint(rec, uint64(len(p.Sample)))
+	for _, s := range p.Sample {
+		stack := e.stack(s.Location)
+		rec = appendVarint(rec, int64(stack-prevStack))
+		prevStack = stack
+
+		prev := e.values[stack]
+		rec = appendUvarint(rec, uint64(len(s.Value)))
+		for i, v := range s.Value {
+			rec = appendVarint(rec, v-valueAt(prev, i))
+		}
+		e.values[stack] = append(prev[:0], s.Value...)
+
+		rec = appendUvarint(rec, uint64(len(s.Label)))
+		for _, k := range labelKeys(s.Label) {
+			rec = appendUvarint(rec, e.intern(k))
+			rec = appendUvarint(rec, uint64(len(s.Label[k])))
+			for _, v := range s.Label[k] {
+				rec = appendUvarint(rec, e.intern(v))
+			}
+		}
+
+		rec = appendUvarint(rec, uint64(len(s.NumLabel)))
+		for _, k := range numLabelKeys(s.NumLabel) {
+			rec = appendUvarint(rec, e.intern(k))
+			rec = appendUvarint(rec, uint64(len(s.NumLabel[k])))
+			for _, v := range s.NumLabel[k] {
+				rec = appendVarint(rec, v)
+			}
+			units := s.NumUnit[k]
+			rec = appendUvarint(rec, uint64(len(units)))
+			for _, u := range units {
+				rec = appendUvarint(rec, e.intern(u))
+			}
+		}
+	}
+
+	e.body = appendVarint(e.body, t-e.lastT)
+	e.lastT = t
+	e.body = appendUvarint(e.body, e.numStrings)
+	e.body = append(e.body, e.newStrings...)
+	e.body = appendUvarint(e.body, e.numMappings)
+	e.body = append(e.body, e.newMappings...)
+	e.body = appendUvarint(e.body, e.numFunctions)
+	e.body = append(e.body, e.newFunctions...)
+	e.body = appendUvarint(e.body, e.numLocations)
+	e.body = append(e.body, e.newLocations...)
+	e.body = appendUvarint(e.body, e.numStacks)
+	e.body = append(e.body, e.newStacks...)
+	e.body = append(e.body, rec...)
+}
+
+func labelKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func numLabelKeys(m map[string][]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func valueAt(values []int64, i int) int64 {
+	if i < len(values) {
+		return values[i]
+	}
+	return 0
+}
+
+func appendUvarint(b []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+func appendVarint(b []byte, x int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], x)]...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func uvarintLen(x uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], x)
+}
+
+// profileDecoder decodes the records of a chunk, building up the tables
+// shared by the profiles as it goes.
+type profileDecoder struct {
+	body []byte
+	pos  int
+	err  error
+
+	lastT     int64
+	strings   []string
+	mappings  []*profile.Mapping
+	functions []*profile.Function
+	locations []*profile.Location
+	stacks    [][]*profile.Location
+	values    map[uint64][]int64
+	prevStack uint64
+}
+
+var errProfileChunkCorrupted = errors.New("profile chunk corrupted")
+
+func (d *profileDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.body[d.pos:])
+	if n <= 0 {
+		d.err = errProfileChunkCorrupted
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+func (d *profileDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.body[d.pos:])
+	if n <= 0 {
+		d.err = errProfileChunkCorrupted
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+func (d *profileDecoder) readByte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.pos >= len(d.body) {
+		d.err = errProfileChunkCorrupted
+		return 0
+	}
+	b := d.body[d.pos]
+	d.pos++
+	return b
+}
+
+// count reads the number of entries that follow, each at least one byte
+// long, so that corrupted counts don't cause huge allocations.
+func (d *profileDecoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.body)-d.pos) || n > math.MaxInt32 {
+		if d.err == nil {
+			d.err = errProfileChunkCorrupted
+		}
+		return 0
+	}
+	return int(n)
+}
+
+func (d *profileDecoder) readString() string {
+	i := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if i >= uint64(len(d.strings)) {
+		d.err = errProfileChunkCorrupted
+		return ""
+	}
+	return d.strings[i]
+}
+
+func (d *profileDecoder) mapping() *profile.Mapping {
+	id := d.uvarint()
+	if d.err != nil || id == 0 {
+		return nil
+	}
+	if id > uint64(len(d.mappings)) {
+		d.err = errProfileChunkCorrupted
+		return nil
+	}
+	return d.mappings[id-1]
+}
+
+func (d *profileDecoder) function() *profile.Function {
+	id := d.uvarint()
+	if d.err != nil || id == 0 {
+		return nil
+	}
+	if id > uint64(len(d.functions)) {
+		d.err = errProfileChunkCorrupted
+		return nil
+	}
+	return d.functions[id-1]
+}
+
+func (d *profileDecoder) location() *profile.Location {
+	id := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if id == 0 || id > uint64(len(d.locations)) {
+		d.err = errProfileChunkCorrupted
+		return nil
+	}
+	return d.locations[id-1]
+}
+
+// stack returns the ID and locations of the sample's stack.
+func (d *profileDecoder) stack() (uint64, []*profile.Location) {
+	i := d.prevStack + uint64(d.varint())
+	if d.err != nil {
+		return 0, nil
+	}
+	if i >= uint64(len(d.stacks)) {
+		d.err = errProfileChunkCorrupted
+		return 0, nil
+	}
+	d.prevStack = i
+	return i, d.stacks[i]
+}
+
+func (d *profileDecoder) tables() {
+	if len(d.strings) == 0 {
+		d.strings = append(d.strings, "")
+	}
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		l := d.count()
+		if d.err != nil {
+			return
+		}
+		d.strings = append(d.strings, string(d.body[d.pos:d.pos+l]))
+		d.pos += l
+	}
+
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		m := &profile.Mapping{
+			ID:     uint64(len(d.mappings)) + 1,
+			Start:  d.uvarint(),
+			Limit:  d.uvarint(),
+			Offset: d.uvarint(),
+		}
+		m.File = d.readString()
+		m.BuildID = d.readString()
+		flags := d.readByte()
+		m.HasFunctions = flags&mappingHasFunctions != 0
+		m.HasFilenames = flags&mappingHasFilenames != 0
+		m.HasLineNumbers = flags&mappingHasLineNumbers != 0
+		m.HasInlineFrames = flags&mappingHasInlineFrames != 0
+		d.mappings = append(d.mappings, m)
+	}
+
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		f := &profile.Function{ID: uint64(len(d.functions)) + 1}
+		f.Name = d.readString()
+		f.SystemName = d.readString()
+		f.Filename = d.readString()
+		f.StartLine = d.varint()
+		d.functions = append(d.functions, f)
+	}
+
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		l := &profile.Location{ID: uint64(len(d.locations)) + 1}
+		l.Mapping = d.mapping()
+		l.Address = d.uvarint()
+		l.IsFolded = d.readByte() == 1
+		lines := d.count()
+		if lines > 0 {
+			l.Line = make([]profile.Line, lines)
+		}
+		for i := range l.Line {
+			l.Line[i].Function = d.function()
+			l.Line[i].Line = d.varint()
+		}
+		d.locations = append(d.locations, l)
+	}
+
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		s := make([]*profile.Location, d.count())
+		for i := range s {
+			s[i] = d.location()
+		}
+		d.stacks = append(d.stacks, s)
+	}
+}
+
+// next decodes the next record, it returns the profile with only the
+// locations, functions and mappings it references.
+func (d *profileDecoder) next() (int64, *profile.Profile, error) {
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+	d.lastT += d.varint()
+	d.tables()
+
+	p := &profile.Profile{}
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		if m := d.mapping(); m != nil {
+			p.Mapping = append(p.Mapping, m)
+		}
+	}
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		p.SampleType = append(p.SampleType, &profile.ValueType{Type: d.readString(), Unit: d.readString()})
+	}
+	p.DefaultSampleType = d.readString()
+	p.DropFrames = d.readString()
+	p.KeepFrames = d.readString()
+	p.TimeNanos = d.varint()
+	p.DurationNanos = d.varint()
+	if d.readByte() == 1 {
+		p.PeriodType = &profile.ValueType{Type: d.readString(), Unit: d.readString()}
+	}
+	p.Period = d.varint()
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		p.Comments = append(p.Comments, d.readString())
+	}
+
+	mappings := make(map[*profile.Mapping]bool, len(p.Mapping))
+	for _, m := range p.Mapping {
+		mappings[m] = true
+	}
+	locations := map[*profile.Location]bool{}
+	functions := map[*profile.Function]bool{}
+
+	if d.values == nil {
+		d.values = map[uint64][]int64{}
+	}
+	d.prevStack = 0
+	n := d.count()
+	if d.err == nil {
+		p.Sample = make([]*profile.Sample, 0, n)
+	}
+	for ; n > 0 && d.err == nil; n-- {
+		stack, locs := d.stack()
+		s := &profile.Sample{Location: locs}
+
+		prev := d.values[stack]
+		s.Value = make([]int64, d.count())
+		for i := range s.Value {
+			s.Value[i] = d.varint() + valueAt(prev, i)
+		}
+		d.values[stack] = append(prev[:0], s.Value...)
+
+		if labels := d.count(); labels > 0 {
+			s.Label = make(map[string][]string, labels)
+			for ; labels > 0 && d.err == nil; labels-- {
+				k := d.readString()
+				vals := make([]string, d.count())
+				for i := range vals {
+					vals[i] = d.readString()
+				}
+				s.Label[k] = vals
+			}
+		}
+		if labels := d.count(); labels > 0 {
+			s.NumLabel = make(map[string][]int64, labels)
+			for ; labels > 0 && d.err == nil; labels-- {
+				k := d.readString()
+				vals := make([]int64, d.count())
+				for i := range vals {
+					vals[i] = d.varint()
+				}
+				s.NumLabel[k] = vals
+				if units := d.count(); units > 0 {
+					if s.NumUnit == nil {
+						s.NumUnit = map[string][]string{}
+					}
+					s.NumUnit[k] = make([]string, units)
+					for i := range s.NumUnit[k] {
+						s.NumUnit[k][i] = d.readString()
+					}
+				}
+			}
+		}
+		p.Sample = append(p.Sample, s)
+
+		for _, l := range s.Location {
+			if l == nil || locations[l] {
+				continue
+			}
+			locations[l] = true
+			p.Location = append(p.Location, l)
+			if l.Mapping != nil && !mappings[l.Mapping] {
+				mappings[l.Mapping] = true
+				p.Mapping = append(p.Mapping, l.Mapping)
+			}
+			for _, line := range l.Line {
+				if line.Function != nil && !functions[line.Function] {
+					functions[line.Function] = true
+					p.Function = append(p.Function, line.Function)
+				}
+			}
+		}
+	}
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+	return d.lastT, p, nil
+}
+
+// FromData returns the chunk of the encoded bytes. Chunks of any encoding
+// other than EncProfile are loaded as bytes chunks, which is how all chunks
+// were encoded before. The iterators of bytes chunks decompress the
+// profiles.
+func FromData(e tsdbchunkenc.Encoding, d []byte) (tsdbchunkenc.Chunk, error) {
+	if e == EncProfile {
+		return LoadProfileChunk(d)
+	}
+	c, err := tsdbchunkenc.FromData(tsdbchunkenc.EncBytes, d)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressingChunk{Chunk: c}, nil
+}
+
+// Reencode returns a profile chunk of the profiles of the chunk.
+func Reencode(c tsdbchunkenc.Chunk) (*ProfileChunk, error) {
+	pc := NewProfileChunk()
+	app, err := pc.Appender()
+	if err != nil {
+		return nil, err
+	}
+	it := c.Iterator(nil)
+	for it.Next() {
+		app.Append(it.At())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	if pc.enc.err != nil {
+		return nil, pc.enc.err
+	}
+	return pc, nil
+}
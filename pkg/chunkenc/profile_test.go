@@ -0,0 +1,297 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunkenc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	tsdbchunkenc "github.com/conprof/db/tsdb/chunkenc"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+// scrapes returns consecutive profiles of the same process, which only
+// differ in their values and time.
+func scrapes(t *testing.T, n int) [][]byte {
+	f, err := ioutil.ReadFile("../../api/testdata/alloc_objects.pb.gz")
+	require.NoError(t, err)
+
+	res := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		p, err := profile.ParseData(f)
+		require.NoError(t, err)
+		p.TimeNanos += int64(i) * 1e10
+		for j, s := range p.Sample {
+			if (i+j)%3 == 0 {
+				continue
+			}
+			for k := range s.Value {
+				s.Value[k] += int64(i * j)
+			}
+		}
+		if i%2 == 1 {
+			p.Sample[0].Label = map[string][]string{"tenant": {"a"}}
+			p.Sample[1].NumLabel = map[string][]int64{"bytes": {1024}}
+			p.Sample[1].NumUnit = map[string][]string{"bytes": {"bytes"}}
+		}
+
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, p.WriteUncompressed(buf))
+		res = append(res, buf.Bytes())
+	}
+	return res
+}
+
+// samples returns the profile's samples by their stack and labels.
+func samples(p *profile.Profile) map[string][]int64 {
+	res := map[string][]int64{}
+	for _, s := range p.Sample {
+		var b strings.Builder
+		for _, l := range s.Location {
+			fmt.Fprintf(&b, "%x %s;", l.Address, l.Mapping.File)
+			for _, line := range l.Line {
+				fmt.Fprintf(&b, "%s:%d;", line.Function.Name, line.Line)
+			}
+		}
+		keys := make([]string, 0, len(s.Label))
+		for k := range s.Label {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s=%v;", k, s.Label[k])
+		}
+		fmt.Fprintf(&b, "%v %v", s.NumLabel, s.NumUnit)
+
+		k := b.String()
+		if res[k] == nil {
+			res[k] = make([]int64, len(s.Value))
+		}
+		for i, v := range s.Value {
+			res[k][i] += v
+		}
+	}
+	return res
+}
+
+func valueTypes(vts ...*profile.ValueType) []string {
+	res := make([]string, 0, len(vts))
+	for _, vt := range vts {
+		res = append(res, vt.Type+"/"+vt.Unit)
+	}
+	return res
+}
+
+func requireEqualProfiles(t *testing.T, expected []byte, actual *profile.Profile) {
+	t.Helper()
+	require.NoError(t, actual.CheckValid())
+
+	p, err := profile.ParseData(expected)
+	require.NoError(t, err)
+	require.Equal(t, valueTypes(p.SampleType...), valueTypes(actual.SampleType...))
+	require.Equal(t, valueTypes(p.PeriodType), valueTypes(actual.PeriodType))
+	require.Equal(t, p.Period, actual.Period)
+	require.Equal(t, p.TimeNanos, actual.TimeNanos)
+	require.Equal(t, p.DurationNanos, actual.DurationNanos)
+	require.Equal(t, p.Comments, actual.Comments)
+	require.Equal(t, p.DefaultSampleType, actual.DefaultSampleType)
+	require.Equal(t, len(p.Sample), len(actual.Sample))
+	require.Equal(t, p.Mapping[0].File, actual.Mapping[0].File)
+	require.Equal(t, samples(p), samples(actual))
+}
+
+func TestProfileChunk(t *testing.T) {
+	profiles := scrapes(t, 10)
+
+	c := NewProfileChunk()
+	app, err := c.Appender()
+	require.NoError(t, err)
+	for i, p := range profiles {
+		app.Append(int64(i*10), p)
+	}
+	require.Equal(t, len(profiles), c.NumSamples())
+
+	b, err := c.Bytes()
+	require.NoError(t, err)
+
+	loaded, err := FromData(EncProfile, b)
+	require.NoError(t, err)
+	require.Equal(t, EncProfile, loaded.Encoding())
+	require.Equal(t, len(profiles), loaded.NumSamples())
+
+	it := loaded.Iterator(nil)
+	i := 0
+	for it.Next() {
+		ts, v := it.At()
+		require.Equal(t, int64(i*10), ts)
+
+		p, err := profile.ParseData(v)
+		require.NoError(t, err)
+		requireEqualProfiles(t, profiles[i], p)
+
+		p, err = it.(ProfileIterator).AtProfile()
+		require.NoError(t, err)
+		requireEqualProfiles(t, profiles[i], p)
+		i++
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, len(profiles), i)
+
+	for _, tc := range []struct {
+		name  string
+		nexts int
+		seeks []int64
+		exp   int64
+	}{
+		{name: "seek", seeks: []int64{45}, exp: 50},
+		{name: "seek to first", seeks: []int64{-1}, exp: 0},
+		{name: "seek twice", seeks: []int64{15, 45}, exp: 50},
+		{name: "seek backwards", seeks: []int64{45, 15}, exp: 50},
+		{name: "seek after next", nexts: 3, seeks: []int64{35}, exp: 40},
+		{name: "seek to current", nexts: 3, seeks: []int64{15}, exp: 20},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			it := loaded.Iterator(nil)
+			for i := 0; i < tc.nexts; i++ {
+				require.True(t, it.Next())
+			}
+			for _, s := range tc.seeks {
+				require.True(t, it.Seek(s))
+			}
+			ts, v := it.At()
+			require.Equal(t, tc.exp, ts)
+
+			p, err := profile.ParseData(v)
+			require.NoError(t, err)
+			requireEqualProfiles(t, profiles[tc.exp/10], p)
+
+			require.True(t, it.Next())
+			ts, _ = it.At()
+			require.Equal(t, tc.exp+10, ts)
+			require.NoError(t, it.Err())
+		})
+	}
+
+	it = loaded.Iterator(nil)
+	require.True(t, it.Seek(45))
+	require.False(t, it.Seek(100))
+	require.False(t, it.Next())
+	require.NoError(t, it.Err())
+}
+
+func TestProfileChunkAppendLoaded(t *testing.T) {
+	profiles := scrapes(t, 4)
+
+	c := NewProfileChunk()
+	app, err := c.Appender()
+	require.NoError(t, err)
+	for i, p := range profiles[:2] {
+		app.Append(int64(i), p)
+	}
+	b, err := c.Bytes()
+	require.NoError(t, err)
+
+	loaded, err := LoadProfileChunk(b)
+	require.NoError(t, err)
+	app, err = loaded.Appender()
+	require.NoError(t, err)
+	for i, p := range profiles[2:] {
+		app.Append(int64(i+2), p)
+	}
+
+	b, err = loaded.Bytes()
+	require.NoError(t, err)
+	loaded, err = LoadProfileChunk(b)
+	require.NoError(t, err)
+	require.Equal(t, len(profiles), loaded.NumSamples())
+
+	it := loaded.Iterator(nil)
+	i := 0
+	for it.Next() {
+		p, err := it.(ProfileIterator).AtProfile()
+		require.NoError(t, err)
+		requireEqualProfiles(t, profiles[i], p)
+		i++
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, len(profiles), i)
+}
+
+func TestProfileChunkInvalidProfile(t *testing.T) {
+	c := NewProfileChunk()
+	app, err := c.Appender()
+	require.NoError(t, err)
+	app.Append(0, []byte("not a profile"))
+
+	_, err = c.Bytes()
+	require.Error(t, err)
+}
+
+func TestProfileChunkCorrupted(t *testing.T) {
+	profiles := scrapes(t, 2)
+
+	c := NewProfileChunk()
+	app, err := c.Appender()
+	require.NoError(t, err)
+	for i, p := range profiles {
+		app.Append(int64(i), p)
+	}
+
+	// Claim a sample more than there are.
+	c.num++
+	it := c.Iterator(nil)
+	for it.Next() {
+	}
+	require.Error(t, it.Err())
+}
+
+func TestReencodeBytesChunk(t *testing.T) {
+	profiles := scrapes(t, 10)
+
+	bc := tsdbchunkenc.NewBytesChunk()
+	app, err := bc.Appender()
+	require.NoError(t, err)
+	for i, p := range profiles {
+		app.Append(int64(i), p)
+	}
+	bb, err := bc.Bytes()
+	require.NoError(t, err)
+
+	// Chunks of any other encoding are read as bytes chunks.
+	loaded, err := FromData(tsdbchunkenc.EncBytes, bb)
+	require.NoError(t, err)
+	require.Equal(t, tsdbchunkenc.EncBytes, loaded.Encoding())
+
+	pc, err := Reencode(loaded)
+	require.NoError(t, err)
+	pb, err := pc.Bytes()
+	require.NoError(t, err)
+	require.Less(t, len(pb), len(bb))
+
+	it := pc.Iterator(nil)
+	i := 0
+	for it.Next() {
+		p, err := it.(ProfileIterator).AtProfile()
+		require.NoError(t, err)
+		requireEqualProfiles(t, profiles[i], p)
+		i++
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, len(profiles), i)
+}
@@ -0,0 +1,40 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"bytes"
+
+	"github.com/google/pprof/profile"
+)
+
+// Formats of profiles that can be parsed.
+const (
+	FormatPprof  = "pprof"
+	FormatFolded = "folded"
+	FormatJFR    = "jfr"
+)
+
+// Parse parses b in the given format, converting it to pprof if necessary.
+// Profiles of an empty format are parsed as pprof.
+func Parse(format string, b []byte) (*profile.Profile, error) {
+	switch format {
+	case FormatFolded:
+		return ParseFolded(bytes.NewReader(b))
+	case FormatJFR:
+		return ParseJFR(bytes.NewReader(b))
+	default:
+		return profile.ParseData(b)
+	}
+}
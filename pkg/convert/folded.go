@@ -0,0 +1,145 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package convert turns profiles in formats other than pprof into
+// profile.Profile, so they can be stored and queried like any other profile.
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/pprof/profile"
+)
+
+// fileLineRe matches frames annotated with their source location the way
+// py-spy and similar tools do, e.g. "handle (server.py:42)".
+var fileLineRe = regexp.MustCompile(`^(.+) \(([^()]+):(\d+)\)$`)
+
+// ParseFolded parses profiles in Brendan Gregg's folded (collapsed) stack
+// format as produced by stackcollapse-perf.pl, async-profiler or py-spy.
+// Every line holds the semicolon separated frames of a stack, root first,
+// followed by a space and the number of samples seen for it:
+//
+//	main;handler;json.Marshal 42
+//
+// As the format carries no addresses, functions and locations are
+// synthesized from the frame names.
+func ParseFolded(r io.Reader) (*profile.Profile, error) {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+		PeriodType: &profile.ValueType{Type: "samples", Unit: "count"},
+		Period:     1,
+	}
+	b := newFoldedBuilder(p)
+
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: missing sample count", n)
+		}
+		count, err := strconv.ParseInt(line[i+1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid sample count: %w", n, err)
+		}
+		stack := strings.TrimSpace(line[:i])
+		if stack == "" {
+			return nil, fmt.Errorf("line %d: empty stack", n)
+		}
+
+		frames := strings.Split(stack, ";")
+		locs := make([]*profile.Location, 0, len(frames))
+		// pprof stores stacks leaf first.
+		for j := len(frames) - 1; j >= 0; j-- {
+			locs = append(locs, b.location(frames[j]))
+		}
+		p.Sample = append(p.Sample, &profile.Sample{
+			Location: locs,
+			Value:    []int64{count},
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("read folded profile: %w", err)
+	}
+	if len(p.Sample) == 0 {
+		return nil, fmt.Errorf("no samples in folded profile")
+	}
+
+	return p, p.CheckValid()
+}
+
+type foldedKey struct {
+	name string
+	file string
+	line int64
+}
+
+type foldedBuilder struct {
+	p         *profile.Profile
+	functions map[foldedKey]*profile.Function
+	locations map[foldedKey]*profile.Location
+}
+
+func newFoldedBuilder(p *profile.Profile) *foldedBuilder {
+	return &foldedBuilder{
+		p:         p,
+		functions: map[foldedKey]*profile.Function{},
+		locations: map[foldedKey]*profile.Location{},
+	}
+}
+
+func (b *foldedBuilder) location(frame string) *profile.Location {
+	k := foldedKey{name: frame}
+	if m := fileLineRe.FindStringSubmatch(frame); m != nil {
+		line, err := strconv.ParseInt(m[3], 10, 64)
+		if err == nil {
+			k = foldedKey{name: m[1], file: m[2], line: line}
+		}
+	}
+
+	if l, ok := b.locations[k]; ok {
+		return l
+	}
+
+	fk := foldedKey{name: k.name, file: k.file}
+	f, ok := b.functions[fk]
+	if !ok {
+		f = &profile.Function{
+			ID:         uint64(len(b.p.Function) + 1),
+			Name:       k.name,
+			SystemName: k.name,
+			Filename:   k.file,
+		}
+		b.functions[fk] = f
+		b.p.Function = append(b.p.Function, f)
+	}
+
+	l := &profile.Location{
+		ID:   uint64(len(b.p.Location) + 1),
+		Line: []profile.Line{{Function: f, Line: k.line}},
+	}
+	b.locations[k] = l
+	b.p.Location = append(b.p.Location, l)
+	return l
+}
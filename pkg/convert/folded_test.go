@@ -0,0 +1,71 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFolded(t *testing.T) {
+	p, err := ParseFolded(strings.NewReader(`
+main;handler;json.Marshal 42
+main;handler 8
+# comments are ignored
+main;worker (worker.py:12);compute (worker.py:30) 5
+`))
+	require.NoError(t, err)
+	require.Len(t, p.Sample, 3)
+	require.Len(t, p.Function, 5)
+	require.Len(t, p.Location, 5)
+
+	stack := func(s *profile.Sample) []string {
+		res := []string{}
+		for _, l := range s.Location {
+			res = append(res, l.Line[0].Function.Name)
+		}
+		return res
+	}
+	require.Equal(t, []string{"json.Marshal", "handler", "main"}, stack(p.Sample[0]))
+	require.Equal(t, []int64{42}, p.Sample[0].Value)
+	require.Equal(t, []string{"handler", "main"}, stack(p.Sample[1]))
+	require.Equal(t, p.Sample[0].Location[1], p.Sample[1].Location[0])
+
+	leaf := p.Sample[2].Location[0].Line[0]
+	require.Equal(t, "compute", leaf.Function.Name)
+	require.Equal(t, "worker.py", leaf.Function.Filename)
+	require.Equal(t, int64(30), leaf.Line)
+
+	// The result has to survive a round trip through the pprof encoding.
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, p.WriteUncompressed(buf))
+	_, err = profile.ParseData(buf.Bytes())
+	require.NoError(t, err)
+}
+
+func TestParseFoldedInvalid(t *testing.T) {
+	for _, tc := range []string{
+		"",
+		"main;handler",
+		"main;handler many",
+		" 12",
+	} {
+		_, err := ParseFolded(strings.NewReader(tc))
+		require.Error(t, err, tc)
+	}
+}
@@ -0,0 +1,819 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/google/pprof/profile"
+)
+
+const (
+	jfrMagic           = "FLR\x00"
+	jfrChunkHeaderSize = 68
+
+	jfrMetadataEventType     = 0
+	jfrConstantPoolEventType = 1
+
+	// jfrCompressedInts is the chunk feature flag signaling LEB128 encoded
+	// integers.
+	jfrCompressedInts = 1
+)
+
+// JFR events converted into samples.
+const (
+	jfrExecutionSample             = "jdk.ExecutionSample"
+	jfrObjectAllocationInNewTLAB   = "jdk.ObjectAllocationInNewTLAB"
+	jfrObjectAllocationOutsideTLAB = "jdk.ObjectAllocationOutsideTLAB"
+	jfrObjectAllocationSample      = "jdk.ObjectAllocationSample"
+)
+
+// Indices of the sample types of converted profiles.
+const (
+	jfrSamplesIndex = iota
+	jfrAllocObjectsIndex
+	jfrAllocSpaceIndex
+)
+
+const (
+	// Limits protecting against corrupt recordings.
+	jfrMaxArrayLength       = 1 << 24
+	jfrMaxNestedObjectDepth = 64
+)
+
+// ParseJFR parses a Java Flight Recorder recording and converts its
+// execution sample and allocation events into a single profile with the
+// sample types samples/count, alloc_objects/count and alloc_space/bytes.
+// All other events are skipped.
+func ParseJFR(r io.Reader) (*profile.Profile, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read jfr recording: %w", err)
+	}
+
+	c := newJFRConverter()
+	for off := 0; off < len(b); {
+		n, err := c.parseChunk(b[off:])
+		if err != nil {
+			return nil, fmt.Errorf("chunk at offset %d: %w", off, err)
+		}
+		off += n
+	}
+
+	return c.profile()
+}
+
+type jfrClass struct {
+	id     int64
+	name   string
+	fields []jfrField
+}
+
+type jfrField struct {
+	name         string
+	typeID       int64
+	constantPool bool
+	array        bool
+}
+
+// jfrObject is a decoded value of a non-primitive JFR type.
+type jfrObject struct {
+	class  *jfrClass
+	values []interface{}
+}
+
+func (o *jfrObject) get(name string) interface{} {
+	for i, f := range o.class.fields {
+		if f.name == name {
+			return o.values[i]
+		}
+	}
+	return nil
+}
+
+// jfrRef references an entry of a constant pool of the chunk.
+type jfrRef struct {
+	typeID int64
+	key    int64
+}
+
+type jfrElement struct {
+	name     string
+	attrs    map[string]string
+	children []*jfrElement
+}
+
+type jfrReader struct {
+	b          []byte
+	pos        int
+	compressed bool
+}
+
+var errJFRShortRead = errors.New("unexpected end of chunk")
+
+func (r *jfrReader) byte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, errJFRShortRead
+	}
+	b := r.b[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *jfrReader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.b) {
+		return nil, errJFRShortRead
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// varint reads JFR's compressed integer encoding, which stores 7 bits per
+// byte for the first eight bytes and a full byte in the ninth.
+func (r *jfrReader) varint() (int64, error) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b&0x7f) << (7 * uint(i))
+		if b&0x80 == 0 {
+			return int64(v), nil
+		}
+	}
+	b, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	return int64(v | uint64(b)<<56), nil
+}
+
+func (r *jfrReader) fixed(n int) (int64, error) {
+	b, err := r.bytes(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 2:
+		return int64(int16(binary.BigEndian.Uint16(b))), nil
+	case 4:
+		return int64(int32(binary.BigEndian.Uint32(b))), nil
+	default:
+		return int64(binary.BigEndian.Uint64(b)), nil
+	}
+}
+
+func (r *jfrReader) short() (int64, error) {
+	if r.compressed {
+		return r.varint()
+	}
+	return r.fixed(2)
+}
+
+func (r *jfrReader) int() (int64, error) {
+	if r.compressed {
+		return r.varint()
+	}
+	return r.fixed(4)
+}
+
+func (r *jfrReader) long() (int64, error) {
+	if r.compressed {
+		return r.varint()
+	}
+	return r.fixed(8)
+}
+
+func (r *jfrReader) length() (int, error) {
+	n, err := r.int()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > jfrMaxArrayLength || int(n) > len(r.b)-r.pos {
+		return 0, fmt.Errorf("invalid length %d", n)
+	}
+	return int(n), nil
+}
+
+// string reads a string in any of JFR's encodings. Strings stored in the
+// constant pool are returned as a jfrRef to stringType.
+func (r *jfrReader) string(stringType int64) (interface{}, error) {
+	enc, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch enc {
+	case 0, 1: // null, empty
+		return "", nil
+	case 2: // constant pool
+		key, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		return jfrRef{typeID: stringType, key: key}, nil
+	case 3: // UTF-8
+		n, err := r.length()
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 4: // char array
+		n, err := r.length()
+		if err != nil {
+			return nil, err
+		}
+		chars := make([]uint16, 0, n)
+		for i := 0; i < n; i++ {
+			c, err := r.short()
+			if err != nil {
+				return nil, err
+			}
+			chars = append(chars, uint16(c))
+		}
+		return string(utf16.Decode(chars)), nil
+	case 5: // Latin-1
+		n, err := r.length()
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		runes := make([]rune, 0, n)
+		for _, c := range b {
+			runes = append(runes, rune(c))
+		}
+		return string(runes), nil
+	default:
+		return nil, fmt.Errorf("unknown string encoding %d", enc)
+	}
+}
+
+// jfrChunk holds the type information and constant pools of a single chunk,
+// which are needed to decode its events.
+type jfrChunk struct {
+	classes    map[int64]*jfrClass
+	stringType int64
+	pools      map[int64]map[int64]interface{}
+}
+
+func (c *jfrChunk) readValue(r *jfrReader, t *jfrClass, depth int) (interface{}, error) {
+	switch t.name {
+	case "boolean":
+		b, err := r.byte()
+		return b != 0, err
+	case "byte":
+		b, err := r.byte()
+		return int64(int8(b)), err
+	case "char", "short":
+		return r.short()
+	case "int":
+		return r.int()
+	case "long":
+		return r.long()
+	case "float":
+		v, err := r.fixed(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case "double":
+		v, err := r.fixed(8)
+		return math.Float64frombits(uint64(v)), err
+	case "java.lang.String":
+		return r.string(c.stringType)
+	}
+
+	if depth > jfrMaxNestedObjectDepth {
+		return nil, fmt.Errorf("type %s nested too deeply", t.name)
+	}
+	o := &jfrObject{class: t, values: make([]interface{}, 0, len(t.fields))}
+	for _, f := range t.fields {
+		v, err := c.readField(r, f, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %w", t.name, f.name, err)
+		}
+		o.values = append(o.values, v)
+	}
+	return o, nil
+}
+
+func (c *jfrChunk) readField(r *jfrReader, f jfrField, depth int) (interface{}, error) {
+	if !f.array {
+		return c.readSingle(r, f, depth)
+	}
+	n, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := c.readSingle(r, f, depth)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (c *jfrChunk) readSingle(r *jfrReader, f jfrField, depth int) (interface{}, error) {
+	if f.constantPool {
+		key, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		return jfrRef{typeID: f.typeID, key: key}, nil
+	}
+	t, ok := c.classes[f.typeID]
+	if !ok {
+		return nil, fmt.Errorf("unknown type id %d", f.typeID)
+	}
+	return c.readValue(r, t, depth)
+}
+
+// resolve looks up constant pool references, other values are returned as is.
+func (c *jfrChunk) resolve(v interface{}) interface{} {
+	if ref, ok := v.(jfrRef); ok {
+		return c.pools[ref.typeID][ref.key]
+	}
+	return v
+}
+
+// field returns the resolved value of the named field of the object v.
+func (c *jfrChunk) field(v interface{}, name string) interface{} {
+	o, ok := c.resolve(v).(*jfrObject)
+	if !ok {
+		return nil
+	}
+	return c.resolve(o.get(name))
+}
+
+// str returns the string of v, which is either a string or a symbol.
+func (c *jfrChunk) str(v interface{}) string {
+	switch v := c.resolve(v).(type) {
+	case string:
+		return v
+	case *jfrObject:
+		s, _ := c.resolve(v.get("string")).(string)
+		return s
+	}
+	return ""
+}
+
+func (c *jfrChunk) int(v interface{}) int64 {
+	i, _ := c.resolve(v).(int64)
+	return i
+}
+
+type jfrLocationKey struct {
+	function string
+	line     int64
+}
+
+type jfrConverter struct {
+	p         *profile.Profile
+	functions map[string]*profile.Function
+	locations map[jfrLocationKey]*profile.Location
+	samples   map[string]*profile.Sample
+}
+
+func newJFRConverter() *jfrConverter {
+	return &jfrConverter{
+		p: &profile.Profile{
+			SampleType: []*profile.ValueType{
+				jfrSamplesIndex:      {Type: "samples", Unit: "count"},
+				jfrAllocObjectsIndex: {Type: "alloc_objects", Unit: "count"},
+				jfrAllocSpaceIndex:   {Type: "alloc_space", Unit: "bytes"},
+			},
+			PeriodType: &profile.ValueType{Type: "samples", Unit: "count"},
+			Period:     1,
+		},
+		functions: map[string]*profile.Function{},
+		locations: map[jfrLocationKey]*profile.Location{},
+		samples:   map[string]*profile.Sample{},
+	}
+}
+
+// parseChunk converts the events of the chunk at the start of b and returns
+// the chunk's size.
+func (c *jfrConverter) parseChunk(b []byte) (int, error) {
+	if len(b) < jfrChunkHeaderSize || string(b[:4]) != jfrMagic {
+		return 0, errors.New("not a jfr chunk")
+	}
+	if major := binary.BigEndian.Uint16(b[4:]); major != 2 {
+		return 0, fmt.Errorf("unsupported jfr version %d", major)
+	}
+	var (
+		size           = int64(binary.BigEndian.Uint64(b[8:]))
+		cpOffset       = int64(binary.BigEndian.Uint64(b[16:]))
+		metadataOffset = int64(binary.BigEndian.Uint64(b[24:]))
+		startNanos     = int64(binary.BigEndian.Uint64(b[32:]))
+		durationNanos  = int64(binary.BigEndian.Uint64(b[40:]))
+		features       = binary.BigEndian.Uint32(b[64:])
+	)
+	if size < jfrChunkHeaderSize || size > int64(len(b)) {
+		return 0, fmt.Errorf("invalid chunk size %d", size)
+	}
+	b = b[:size]
+	if metadataOffset < jfrChunkHeaderSize || metadataOffset >= size {
+		return 0, fmt.Errorf("invalid metadata offset %d", metadataOffset)
+	}
+	if cpOffset < jfrChunkHeaderSize || cpOffset >= size {
+		return 0, fmt.Errorf("invalid constant pool offset %d", cpOffset)
+	}
+
+	compressed := features&jfrCompressedInts != 0
+	chunk, err := parseJFRMetadata(&jfrReader{b: b, pos: int(metadataOffset), compressed: compressed})
+	if err != nil {
+		return 0, fmt.Errorf("metadata: %w", err)
+	}
+	if err := chunk.parseConstantPools(&jfrReader{b: b, compressed: compressed}, cpOffset); err != nil {
+		return 0, fmt.Errorf("constant pool: %w", err)
+	}
+
+	r := &jfrReader{b: b, pos: jfrChunkHeaderSize, compressed: compressed}
+	for r.pos < len(b) {
+		start := r.pos
+		eventSize, err := r.int()
+		if err != nil {
+			return 0, err
+		}
+		if eventSize <= 0 || int64(start)+eventSize > size {
+			return 0, fmt.Errorf("invalid event size %d at offset %d", eventSize, start)
+		}
+		typeID, err := r.long()
+		if err != nil {
+			return 0, err
+		}
+
+		if t, ok := chunk.classes[typeID]; ok && isJFRSampleEvent(t.name) {
+			er := &jfrReader{b: b[:start+int(eventSize)], pos: r.pos, compressed: compressed}
+			event, err := chunk.readValue(er, t, 0)
+			if err != nil {
+				return 0, fmt.Errorf("event %s at offset %d: %w", t.name, start, err)
+			}
+			c.addEvent(chunk, t.name, event)
+		}
+		r.pos = start + int(eventSize)
+	}
+
+	if c.p.TimeNanos == 0 || startNanos < c.p.TimeNanos {
+		c.p.TimeNanos = startNanos
+	}
+	c.p.DurationNanos += durationNanos
+
+	return int(size), nil
+}
+
+func isJFRSampleEvent(name string) bool {
+	switch name {
+	case jfrExecutionSample, jfrObjectAllocationInNewTLAB, jfrObjectAllocationOutsideTLAB, jfrObjectAllocationSample:
+		return true
+	}
+	return false
+}
+
+func parseJFRMetadata(r *jfrReader) (*jfrChunk, error) {
+	if _, err := r.int(); err != nil { // size
+		return nil, err
+	}
+	typeID, err := r.long()
+	if err != nil {
+		return nil, err
+	}
+	if typeID != jfrMetadataEventType {
+		return nil, fmt.Errorf("unexpected event type %d", typeID)
+	}
+	// Start time, duration and metadata id.
+	for i := 0; i < 3; i++ {
+		if _, err := r.long(); err != nil {
+			return nil, err
+		}
+	}
+
+	n, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		s, err := r.string(-1)
+		if err != nil {
+			return nil, err
+		}
+		str, ok := s.(string)
+		if !ok {
+			return nil, errors.New("constant pool string in metadata")
+		}
+		strs = append(strs, str)
+	}
+
+	root, err := readJFRElement(r, strs, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	chunk := &jfrChunk{
+		classes:    map[int64]*jfrClass{},
+		stringType: -1,
+		pools:      map[int64]map[int64]interface{}{},
+	}
+	var walk func(e *jfrElement) error
+	walk = func(e *jfrElement) error {
+		if e.name == "class" {
+			t, err := newJFRClass(e)
+			if err != nil {
+				return err
+			}
+			chunk.classes[t.id] = t
+			if t.name == "java.lang.String" {
+				chunk.stringType = t.id
+			}
+			return nil
+		}
+		for _, child := range e.children {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return chunk, walk(root)
+}
+
+func readJFRElement(r *jfrReader, strs []string, depth int) (*jfrElement, error) {
+	if depth > jfrMaxNestedObjectDepth {
+		return nil, errors.New("metadata nested too deeply")
+	}
+	lookup := func() (string, error) {
+		i, err := r.int()
+		if err != nil {
+			return "", err
+		}
+		if i < 0 || int(i) >= len(strs) {
+			return "", fmt.Errorf("invalid string index %d", i)
+		}
+		return strs[i], nil
+	}
+
+	name, err := lookup()
+	if err != nil {
+		return nil, err
+	}
+	e := &jfrElement{name: name, attrs: map[string]string{}}
+
+	n, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		k, err := lookup()
+		if err != nil {
+			return nil, err
+		}
+		v, err := lookup()
+		if err != nil {
+			return nil, err
+		}
+		e.attrs[k] = v
+	}
+
+	n, err = r.length()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		child, err := readJFRElement(r, strs, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		e.children = append(e.children, child)
+	}
+	return e, nil
+}
+
+func newJFRClass(e *jfrElement) (*jfrClass, error) {
+	id, err := strconv.ParseInt(e.attrs["id"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("class %q: invalid id: %w", e.attrs["name"], err)
+	}
+	t := &jfrClass{id: id, name: e.attrs["name"]}
+	for _, child := range e.children {
+		if child.name != "field" {
+			continue
+		}
+		typeID, err := strconv.ParseInt(child.attrs["class"], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: invalid class: %w", t.name, child.attrs["name"], err)
+		}
+		t.fields = append(t.fields, jfrField{
+			name:         child.attrs["name"],
+			typeID:       typeID,
+			constantPool: child.attrs["constantPool"] == "true",
+			array:        child.attrs["dimension"] == "1",
+		})
+	}
+	return t, nil
+}
+
+// parseConstantPools follows the chain of constant pool events starting at
+// offset, which link to each other by relative offsets.
+func (c *jfrChunk) parseConstantPools(r *jfrReader, offset int64) error {
+	for seen := 0; ; seen++ {
+		if offset < jfrChunkHeaderSize || offset >= int64(len(r.b)) || seen > len(r.b) {
+			return fmt.Errorf("invalid offset %d", offset)
+		}
+		r.pos = int(offset)
+		if _, err := r.int(); err != nil { // size
+			return err
+		}
+		typeID, err := r.long()
+		if err != nil {
+			return err
+		}
+		if typeID != jfrConstantPoolEventType {
+			return fmt.Errorf("unexpected event type %d", typeID)
+		}
+		// Start time and duration.
+		for i := 0; i < 2; i++ {
+			if _, err := r.long(); err != nil {
+				return err
+			}
+		}
+		delta, err := r.long()
+		if err != nil {
+			return err
+		}
+		if _, err := r.byte(); err != nil { // flush
+			return err
+		}
+
+		pools, err := r.length()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < pools; i++ {
+			typeID, err := r.long()
+			if err != nil {
+				return err
+			}
+			t, ok := c.classes[typeID]
+			if !ok {
+				return fmt.Errorf("unknown type id %d", typeID)
+			}
+			if c.pools[typeID] == nil {
+				c.pools[typeID] = map[int64]interface{}{}
+			}
+			n, err := r.length()
+			if err != nil {
+				return err
+			}
+			for j := 0; j < n; j++ {
+				key, err := r.long()
+				if err != nil {
+					return err
+				}
+				v, err := c.readValue(r, t, 0)
+				if err != nil {
+					return fmt.Errorf("%s %d: %w", t.name, key, err)
+				}
+				c.pools[typeID][key] = v
+			}
+		}
+
+		if delta == 0 {
+			return nil
+		}
+		offset += delta
+	}
+}
+
+func (c *jfrConverter) addEvent(chunk *jfrChunk, eventType string, event interface{}) {
+	values := make([]int64, len(c.p.SampleType))
+	var allocated string
+	switch eventType {
+	case jfrExecutionSample:
+		values[jfrSamplesIndex] = 1
+	case jfrObjectAllocationInNewTLAB:
+		values[jfrAllocObjectsIndex] = 1
+		values[jfrAllocSpaceIndex] = chunk.int(chunk.field(event, "tlabSize"))
+	case jfrObjectAllocationOutsideTLAB:
+		values[jfrAllocObjectsIndex] = 1
+		values[jfrAllocSpaceIndex] = chunk.int(chunk.field(event, "allocationSize"))
+	case jfrObjectAllocationSample:
+		values[jfrAllocObjectsIndex] = 1
+		values[jfrAllocSpaceIndex] = chunk.int(chunk.field(event, "weight"))
+	}
+	if eventType != jfrExecutionSample {
+		allocated = javaClassName(chunk.str(chunk.field(chunk.field(event, "objectClass"), "name")))
+	}
+
+	var locs []*profile.Location
+	if allocated != "" {
+		// Attribute allocations to the allocated type the way async-profiler
+		// does, by adding it as the leaf frame.
+		locs = append(locs, c.location("new "+allocated, 0))
+	}
+	frames, _ := chunk.field(chunk.field(event, "stackTrace"), "frames").([]interface{})
+	// JFR stack traces are leaf first, as are pprof's.
+	for _, frame := range frames {
+		method := chunk.field(frame, "method")
+		class := javaClassName(chunk.str(chunk.field(chunk.field(method, "type"), "name")))
+		name := chunk.str(chunk.field(method, "name"))
+		if class != "" {
+			name = class + "." + name
+		}
+		locs = append(locs, c.location(name, chunk.int(chunk.field(frame, "lineNumber"))))
+	}
+	if len(locs) == 0 {
+		return
+	}
+
+	key := make([]string, 0, len(locs))
+	for _, l := range locs {
+		key = append(key, strconv.FormatUint(l.ID, 10))
+	}
+	k := strings.Join(key, ",")
+	if s, ok := c.samples[k]; ok {
+		for i, v := range values {
+			s.Value[i] += v
+		}
+		return
+	}
+	s := &profile.Sample{Location: locs, Value: values}
+	c.samples[k] = s
+	c.p.Sample = append(c.p.Sample, s)
+}
+
+// javaClassName turns internal class names like java/lang/String into their
+// binary name.
+func javaClassName(name string) string {
+	return strings.ReplaceAll(name, "/", ".")
+}
+
+func (c *jfrConverter) location(function string, line int64) *profile.Location {
+	k := jfrLocationKey{function: function, line: line}
+	if l, ok := c.locations[k]; ok {
+		return l
+	}
+
+	f, ok := c.functions[function]
+	if !ok {
+		f = &profile.Function{
+			ID:         uint64(len(c.p.Function) + 1),
+			Name:       function,
+			SystemName: function,
+		}
+		c.functions[function] = f
+		c.p.Function = append(c.p.Function, f)
+	}
+
+	l := &profile.Location{
+		ID:   uint64(len(c.p.Location) + 1),
+		Line: []profile.Line{{Function: f, Line: line}},
+	}
+	c.locations[k] = l
+	c.p.Location = append(c.p.Location, l)
+	return l
+}
+
+func (c *jfrConverter) profile() (*profile.Profile, error) {
+	if len(c.p.Sample) == 0 {
+		return nil, errors.New("no execution sample or allocation events in jfr recording")
+	}
+
+	c.p.DefaultSampleType = c.p.SampleType[jfrSamplesIndex].Type
+	var samples int64
+	for _, s := range c.p.Sample {
+		samples += s.Value[jfrSamplesIndex]
+	}
+	if samples == 0 {
+		c.p.DefaultSampleType = c.p.SampleType[jfrAllocSpaceIndex].Type
+	}
+
+	return c.p, c.p.CheckValid()
+}
@@ -0,0 +1,347 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+)
+
+// The writer below produces a minimal chunk in the same layout the JVM does,
+// as there is no JVM available to record test data with.
+
+func putVarint(b *bytes.Buffer, v int64) {
+	u := uint64(v)
+	for i := 0; i < 8; i++ {
+		if u < 0x80 {
+			b.WriteByte(byte(u))
+			return
+		}
+		b.WriteByte(byte(u&0x7f | 0x80))
+		u >>= 7
+	}
+	b.WriteByte(byte(u))
+}
+
+func putString(b *bytes.Buffer, s string) {
+	b.WriteByte(3)
+	putVarint(b, int64(len(s)))
+	b.WriteString(s)
+}
+
+// writeEvent writes the event with its size as padded varint like the JVM.
+func writeEvent(b *bytes.Buffer, typeID int64, body func(b *bytes.Buffer)) {
+	e := &bytes.Buffer{}
+	putVarint(e, typeID)
+	body(e)
+	size := e.Len() + 4
+	b.Write([]byte{byte(size&0x7f | 0x80), byte(size>>7&0x7f | 0x80), byte(size>>14&0x7f | 0x80), byte(size >> 21 & 0x7f)})
+	b.Write(e.Bytes())
+}
+
+type testElement struct {
+	name     string
+	attrs    [][2]string
+	children []testElement
+}
+
+type testField struct {
+	name  string
+	class int64
+	cp    bool
+	array bool
+}
+
+func testClass(id int64, name string, fields ...testField) testElement {
+	e := testElement{name: "class", attrs: [][2]string{{"id", strconv.FormatInt(id, 10)}, {"name", name}}}
+	for _, f := range fields {
+		fe := testElement{name: "field", attrs: [][2]string{{"name", f.name}, {"class", strconv.FormatInt(f.class, 10)}}}
+		if f.cp {
+			fe.attrs = append(fe.attrs, [2]string{"constantPool", "true"})
+		}
+		if f.array {
+			fe.attrs = append(fe.attrs, [2]string{"dimension", "1"})
+		}
+		e.children = append(e.children, fe)
+	}
+	return e
+}
+
+func writeMetadata(b *bytes.Buffer, root testElement) {
+	strs := []string{}
+	idx := map[string]int64{}
+	intern := func(s string) int64 {
+		if i, ok := idx[s]; ok {
+			return i
+		}
+		idx[s] = int64(len(strs))
+		strs = append(strs, s)
+		return idx[s]
+	}
+	elements := &bytes.Buffer{}
+	var write func(e testElement)
+	write = func(e testElement) {
+		putVarint(elements, intern(e.name))
+		putVarint(elements, int64(len(e.attrs)))
+		for _, a := range e.attrs {
+			putVarint(elements, intern(a[0]))
+			putVarint(elements, intern(a[1]))
+		}
+		putVarint(elements, int64(len(e.children)))
+		for _, c := range e.children {
+			write(c)
+		}
+	}
+	write(root)
+
+	writeEvent(b, jfrMetadataEventType, func(b *bytes.Buffer) {
+		putVarint(b, 0) // start
+		putVarint(b, 0) // duration
+		putVarint(b, 1) // metadata id
+		putVarint(b, int64(len(strs)))
+		for _, s := range strs {
+			putString(b, s)
+		}
+		b.Write(elements.Bytes())
+	})
+}
+
+const (
+	testLong int64 = 20 + iota
+	testInt
+	testBoolean
+	testString
+	testSymbol
+	testClassType
+	testMethod
+	testStackFrame
+	testStackTrace
+	testExecutionSample
+	testAllocationSample
+	testUnknownEvent
+)
+
+func testRecording(t *testing.T) []byte {
+	t.Helper()
+
+	b := &bytes.Buffer{}
+	b.Write(make([]byte, jfrChunkHeaderSize))
+
+	sample := func(stackTrace int64) {
+		writeEvent(b, testExecutionSample, func(b *bytes.Buffer) {
+			putVarint(b, 1000)
+			putVarint(b, stackTrace)
+		})
+	}
+	sample(1)
+	writeEvent(b, testUnknownEvent, func(b *bytes.Buffer) {
+		putVarint(b, 1000)
+		putString(b, "skipped")
+	})
+	sample(2)
+	sample(1)
+	writeEvent(b, testAllocationSample, func(b *bytes.Buffer) {
+		putVarint(b, 1000)
+		putVarint(b, 2) // objectClass
+		putVarint(b, 1024)
+		putVarint(b, 1) // stackTrace
+	})
+
+	metadataOffset := b.Len()
+	writeMetadata(b, testElement{name: "root", children: []testElement{{name: "metadata", children: []testElement{
+		testClass(testLong, "long"),
+		testClass(testInt, "int"),
+		testClass(testBoolean, "boolean"),
+		testClass(testString, "java.lang.String"),
+		testClass(testSymbol, "jdk.types.Symbol", testField{name: "string", class: testString}),
+		testClass(testClassType, "java.lang.Class", testField{name: "name", class: testSymbol, cp: true}),
+		testClass(testMethod, "jdk.types.Method",
+			testField{name: "type", class: testClassType, cp: true},
+			testField{name: "name", class: testSymbol, cp: true},
+		),
+		testClass(testStackFrame, "jdk.types.StackFrame",
+			testField{name: "method", class: testMethod, cp: true},
+			testField{name: "lineNumber", class: testInt},
+		),
+		testClass(testStackTrace, "jdk.types.StackTrace",
+			testField{name: "truncated", class: testBoolean},
+			testField{name: "frames", class: testStackFrame, array: true},
+		),
+		testClass(testExecutionSample, jfrExecutionSample,
+			testField{name: "startTime", class: testLong},
+			testField{name: "stackTrace", class: testStackTrace, cp: true},
+		),
+		testClass(testAllocationSample, jfrObjectAllocationSample,
+			testField{name: "startTime", class: testLong},
+			testField{name: "objectClass", class: testClassType, cp: true},
+			testField{name: "weight", class: testLong},
+			testField{name: "stackTrace", class: testStackTrace, cp: true},
+		),
+		testClass(testUnknownEvent, "jdk.Unknown",
+			testField{name: "startTime", class: testLong},
+			testField{name: "message", class: testString},
+		),
+	}}}})
+
+	// The strings and symbols go into a first constant pool event, the rest
+	// into a second one that links back to it.
+	firstPoolOffset := b.Len()
+	writeEvent(b, jfrConstantPoolEventType, func(b *bytes.Buffer) {
+		putVarint(b, 0) // start
+		putVarint(b, 0) // duration
+		putVarint(b, 0) // delta
+		b.WriteByte(1)  // flush
+		putVarint(b, 2)
+
+		putVarint(b, testString)
+		putVarint(b, 1)
+		putVarint(b, 1)
+		putString(b, "main")
+
+		putVarint(b, testSymbol)
+		putVarint(b, 5)
+		putVarint(b, 1)
+		b.WriteByte(2) // constant pool string
+		putVarint(b, 1)
+		putVarint(b, 2)
+		putString(b, "handle")
+		putVarint(b, 3)
+		putString(b, "com/example/Server")
+		putVarint(b, 4)
+		b.WriteByte(5) // Latin-1
+		putVarint(b, 16)
+		b.WriteString("java/lang/String")
+		putVarint(b, 5)
+		b.WriteByte(4) // char array
+		putVarint(b, 3)
+		for _, c := range "run" {
+			putVarint(b, int64(c))
+		}
+	})
+
+	secondPoolOffset := b.Len()
+	writeEvent(b, jfrConstantPoolEventType, func(b *bytes.Buffer) {
+		putVarint(b, 0) // start
+		putVarint(b, 0) // duration
+		putVarint(b, int64(firstPoolOffset-secondPoolOffset))
+		b.WriteByte(1) // flush
+		putVarint(b, 3)
+
+		putVarint(b, testClassType)
+		putVarint(b, 2)
+		putVarint(b, 1)
+		putVarint(b, 3)
+		putVarint(b, 2)
+		putVarint(b, 4)
+
+		putVarint(b, testMethod)
+		putVarint(b, 3)
+		for key, m := range [][2]int64{{1, 1}, {1, 2}, {1, 5}} {
+			putVarint(b, int64(key+1))
+			putVarint(b, m[0])
+			putVarint(b, m[1])
+		}
+
+		putVarint(b, testStackTrace)
+		putVarint(b, 2)
+		for key, frames := range [][][2]int64{{{2, 20}, {1, 10}}, {{3, 30}, {1, 11}}} {
+			putVarint(b, int64(key+1))
+			b.WriteByte(0) // truncated
+			putVarint(b, int64(len(frames)))
+			for _, f := range frames {
+				putVarint(b, f[0])
+				putVarint(b, f[1])
+			}
+		}
+	})
+
+	chunk := b.Bytes()
+	copy(chunk, jfrMagic)
+	binary.BigEndian.PutUint16(chunk[4:], 2)
+	binary.BigEndian.PutUint16(chunk[6:], 0)
+	binary.BigEndian.PutUint64(chunk[8:], uint64(len(chunk)))
+	binary.BigEndian.PutUint64(chunk[16:], uint64(secondPoolOffset))
+	binary.BigEndian.PutUint64(chunk[24:], uint64(metadataOffset))
+	binary.BigEndian.PutUint64(chunk[32:], uint64(time.Unix(1600000000, 0).UnixNano()))
+	binary.BigEndian.PutUint64(chunk[40:], uint64(10*time.Second))
+	binary.BigEndian.PutUint64(chunk[56:], uint64(time.Second))
+	binary.BigEndian.PutUint32(chunk[64:], jfrCompressedInts)
+	return chunk
+}
+
+func jfrStacks(p *profile.Profile) map[string][]int64 {
+	res := map[string][]int64{}
+	for _, s := range p.Sample {
+		k := ""
+		for _, l := range s.Location {
+			k += l.Line[0].Function.Name + ":" + strconv.FormatInt(l.Line[0].Line, 10) + ";"
+		}
+		res[k] = s.Value
+	}
+	return res
+}
+
+func TestParseJFR(t *testing.T) {
+	p, err := ParseJFR(bytes.NewReader(testRecording(t)))
+	require.NoError(t, err)
+
+	require.Equal(t, []*profile.ValueType{
+		{Type: "samples", Unit: "count"},
+		{Type: "alloc_objects", Unit: "count"},
+		{Type: "alloc_space", Unit: "bytes"},
+	}, p.SampleType)
+	require.Equal(t, "samples", p.DefaultSampleType)
+	require.Equal(t, time.Unix(1600000000, 0).UnixNano(), p.TimeNanos)
+	require.Equal(t, int64(10*time.Second), p.DurationNanos)
+	require.Equal(t, map[string][]int64{
+		"com.example.Server.handle:20;com.example.Server.main:10;":                        {2, 0, 0},
+		"com.example.Server.run:30;com.example.Server.main:11;":                           {1, 0, 0},
+		"new java.lang.String:0;com.example.Server.handle:20;com.example.Server.main:10;": {0, 1, 1024},
+	}, jfrStacks(p))
+	require.Len(t, p.Function, 4)
+	require.Len(t, p.Location, 5)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, p.WriteUncompressed(buf))
+	_, err = profile.ParseData(buf.Bytes())
+	require.NoError(t, err)
+}
+
+func TestParseJFRMultipleChunks(t *testing.T) {
+	chunk := testRecording(t)
+	p, err := ParseJFR(bytes.NewReader(append(append([]byte{}, chunk...), chunk...)))
+	require.NoError(t, err)
+
+	require.Equal(t, int64(20*time.Second), p.DurationNanos)
+	require.Len(t, p.Sample, 3)
+	require.Equal(t, []int64{4, 0, 0}, jfrStacks(p)["com.example.Server.handle:20;com.example.Server.main:10;"])
+}
+
+func TestParseJFRInvalid(t *testing.T) {
+	chunk := testRecording(t)
+	for name, b := range map[string][]byte{
+		"empty":     {},
+		"not jfr":   []byte("main;handler 1\n"),
+		"truncated": chunk[:len(chunk)-10],
+	} {
+		_, err := ParseJFR(bytes.NewReader(b))
+		require.Error(t, err, name)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conprof/conprof/pkg/store/storepb"
+	"github.com/conprof/db/tsdb"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SeriesDeleter deletes the profiles of series.
+type SeriesDeleter interface {
+	// DeleteSeries deletes the profiles of the series matching the matchers
+	// in the time range.
+	DeleteSeries(ctx context.Context, mint, maxt int64, ms ...*labels.Matcher) error
+	// CleanTombstones removes the deleted profiles from disk.
+	CleanTombstones(ctx context.Context) error
+}
+
+type ProfileStoreOption func(*profileStore)
+
+// WithAdminAPI makes the store serve the admin RPCs, deleting series with
+// the deleter.
+func WithAdminAPI(d SeriesDeleter) ProfileStoreOption {
+	return func(s *profileStore) {
+		s.deleter = d
+	}
+}
+
+type tsdbSeriesDeleter struct {
+	db *tsdb.DB
+}
+
+// NewTSDBSeriesDeleter returns a deleter writing tombstones to the TSDB.
+func NewTSDBSeriesDeleter(db *tsdb.DB) SeriesDeleter {
+	return &tsdbSeriesDeleter{db: db}
+}
+
+func (d *tsdbSeriesDeleter) DeleteSeries(_ context.Context, mint, maxt int64, ms ...*labels.Matcher) error {
+	return d.db.Delete(mint, maxt, ms...)
+}
+
+func (d *tsdbSeriesDeleter) CleanTombstones(_ context.Context) error {
+	return d.db.CleanTombstones()
+}
+
+func (s *profileStore) DeleteSeries(ctx context.Context, r *storepb.DeleteSeriesRequest) (*storepb.DeleteSeriesResponse, error) {
+	if s.deleter == nil {
+		return nil, status.Error(codes.PermissionDenied, "admin APIs are disabled")
+	}
+
+	m, err := translatePbMatchers(r.Matchers)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "could not translate matchers: %v", err)
+	}
+	if len(m) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no matchers given")
+	}
+
+	if err := s.deleter.DeleteSeries(ctx, r.MinTime, r.MaxTime, m...); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	level.Info(s.logger).Log("msg", "deleted series", "matchers", fmt.Sprint(m), "mint", r.MinTime, "maxt", r.MaxTime)
+	return &storepb.DeleteSeriesResponse{}, nil
+}
+
+func (s *profileStore) CleanTombstones(ctx context.Context, r *storepb.CleanTombstonesRequest) (*storepb.CleanTombstonesResponse, error) {
+	if s.deleter == nil {
+		return nil, status.Error(codes.PermissionDenied, "admin APIs are disabled")
+	}
+
+	if err := s.deleter.CleanTombstones(ctx); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &storepb.CleanTombstonesResponse{}, nil
+}
+
+func (c *grpcStoreClient) DeleteSeries(ctx context.Context, mint, maxt int64, ms ...*labels.Matcher) error {
+	m, err := translatePromMatchers(ms)
+	if err != nil {
+		return err
+	}
+	_, err = c.c.DeleteSeries(ctx, &storepb.DeleteSeriesRequest{
+		MinTime:  mint,
+		MaxTime:  maxt,
+		Matchers: m,
+	})
+	return err
+}
+
+func (c *grpcStoreClient) CleanTombstones(ctx context.Context) error {
+	_, err := c.c.CleanTombstones(ctx, &storepb.CleanTombstonesRequest{})
+	return err
+}
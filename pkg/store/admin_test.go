@@ -0,0 +1,113 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/conprof/conprof/pkg/store/storepb"
+	"github.com/conprof/conprof/pkg/testutil"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStoreDeleteSeries(t *testing.T) {
+	db, err := testutil.NewTSDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	app := db.Appender(context.Background())
+	for _, job := range []string{"a", "b"} {
+		ls := labels.Labels{{Name: "__name__", Value: "allocs"}, {Name: "job", Value: job}}
+		for _, ts := range []int64{1, 2, 3} {
+			if _, err := app.Add(ls, ts, []byte("profile")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := app.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(opts ...ProfileStoreOption) (*grpcStoreClient, func()) {
+		lis, err := net.Listen("tcp", ":0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		grpcServer := grpc.NewServer()
+		storepb.RegisterReadableProfileStoreServer(grpcServer, NewProfileStore(log.NewNopLogger(), db, 100000, opts...))
+		go grpcServer.Serve(lis)
+
+		conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewGRPCQueryable(storepb.NewReadableProfileStoreClient(conn)), func() {
+			conn.Close()
+			grpcServer.Stop()
+		}
+	}
+
+	c, stop := serve()
+	err = c.DeleteSeries(context.Background(), 0, 10, labels.MustNewMatcher(labels.MatchEqual, "job", "a"))
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected admin APIs to be disabled, got %v", err)
+	}
+	stop()
+
+	c, stop = serve(WithAdminAPI(NewTSDBSeriesDeleter(db)))
+	defer stop()
+	if err := c.DeleteSeries(context.Background(), 0, 10); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected deleting without matchers to fail, got %v", err)
+	}
+	if err := c.DeleteSeries(context.Background(), 2, 10, labels.MustNewMatcher(labels.MatchEqual, "job", "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CleanTombstones(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := c.Querier(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := q.Select(false, nil, labels.MustNewMatcher(labels.MatchEqual, "__name__", "allocs"))
+	timestamps := map[string][]int64{}
+	for set.Next() {
+		job := set.At().Labels().Get("job")
+		it := set.At().Iterator()
+		for it.Next() {
+			ts, _ := it.At()
+			timestamps[job] = append(timestamps[job], ts)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := set.Err(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]int64{"a": {1}, "b": {1, 2, 3}}
+	if !reflect.DeepEqual(expected, timestamps) {
+		t.Fatalf("expected timestamps %v, got %v", expected, timestamps)
+	}
+}
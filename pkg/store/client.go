@@ -19,36 +19,57 @@ import (
 	"fmt"
 	"io"
 
+	conprofchunkenc "github.com/conprof/conprof/pkg/chunkenc"
 	"github.com/conprof/conprof/pkg/store/storepb"
 	"github.com/conprof/db/storage"
 	"github.com/conprof/db/tsdb/chunkenc"
+	"github.com/google/pprof/profile"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
 )
 
 type grpcStoreClient struct {
-	c storepb.ReadableProfileStoreClient
+	c             storepb.ReadableProfileStoreClient
+	profileChunks bool
 }
 
-func NewGRPCQueryable(c storepb.ReadableProfileStoreClient) *grpcStoreClient {
-	return &grpcStoreClient{
+// GRPCQueryableOption configures the queryable of a store.
+type GRPCQueryableOption func(*grpcStoreClient)
+
+// WithProfileChunks requests chunks in the profile encoding, so that merges
+// don't parse the profiles again. The store re-encodes the chunks for every
+// query, as its blocks keep the bytes encoding.
+func WithProfileChunks() GRPCQueryableOption {
+	return func(c *grpcStoreClient) {
+		c.profileChunks = true
+	}
+}
+
+func NewGRPCQueryable(c storepb.ReadableProfileStoreClient, opts ...GRPCQueryableOption) *grpcStoreClient {
+	client := &grpcStoreClient{
 		c: c,
 	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
 }
 
 func (c *grpcStoreClient) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
 	return &grpcStoreQuerier{
-		ctx:  ctx,
-		mint: mint,
-		maxt: maxt,
-		c:    c.c,
+		ctx:           ctx,
+		mint:          mint,
+		maxt:          maxt,
+		c:             c.c,
+		profileChunks: c.profileChunks,
 	}, nil
 }
 
 type grpcStoreQuerier struct {
-	ctx        context.Context
-	mint, maxt int64
-	c          storepb.ReadableProfileStoreClient
+	ctx           context.Context
+	mint, maxt    int64
+	c             storepb.ReadableProfileStoreClient
+	profileChunks bool
 }
 
 func (q *grpcStoreQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
@@ -65,6 +86,10 @@ func (q *grpcStoreQuerier) Select(sortSeries bool, hints *storage.SelectHints, m
 		MaxTime:     q.maxt,
 		Matchers:    m,
 		SelectHints: storepb.PbSelectHints(hints),
+		// Stores that don't know the profile encoding ignore this and send
+		// bytes chunks, which are still read. Chunks read for a single
+		// profile aren't worth re-encoding.
+		ProfileChunks: q.profileChunks && (hints == nil || hints.Func != "profile"),
 	})
 	if err != nil {
 		ss.err = fmt.Errorf("series: %w", err)
@@ -118,8 +143,14 @@ type rawChunkIterator struct {
 }
 
 func (s *rawChunkIterator) Next() bool {
-	if s.curIt != nil && s.curIt.Next() {
-		return true
+	if s.curIt != nil {
+		if s.curIt.Next() {
+			return true
+		}
+		if err := s.curIt.Err(); err != nil {
+			s.err = fmt.Errorf("iterate chunk: %w", err)
+			return false
+		}
 	}
 
 	if (s.pos + 1) == len(s.chunks) {
@@ -128,7 +159,7 @@ func (s *rawChunkIterator) Next() bool {
 	}
 
 	s.pos++
-	c, err := chunkenc.FromData(chunkenc.EncBytes, s.chunks[s.pos].Raw.Data)
+	c, err := decodeChunk(s.chunks[s.pos].Raw)
 	if err != nil {
 		s.err = fmt.Errorf("decode chunk: %w", err)
 		return false
@@ -142,7 +173,7 @@ func (s *rawChunkIterator) Seek(t int64) bool {
 	for i, c := range s.chunks {
 		if c.MinTime <= t && c.MaxTime >= t {
 			s.pos = i
-			c, err := chunkenc.FromData(chunkenc.EncBytes, s.chunks[s.pos].Raw.Data)
+			c, err := decodeChunk(s.chunks[s.pos].Raw)
 			if err != nil {
 				s.err = fmt.Errorf("decode chunk: %w", err)
 				return false
@@ -158,10 +189,31 @@ func (s *rawChunkIterator) At() (int64, []byte) {
 	return s.curIt.At()
 }
 
+// AtProfile returns the current profile, which is only parsed if the chunk
+// isn't in the profile encoding.
+func (s *rawChunkIterator) AtProfile() (*profile.Profile, error) {
+	if it, ok := s.curIt.(conprofchunkenc.ProfileIterator); ok {
+		return it.AtProfile()
+	}
+	_, b := s.curIt.At()
+	return profile.ParseData(b)
+}
+
 func (s *rawChunkIterator) Err() error {
 	return s.err
 }
 
+// decodeChunk returns the chunk sent by a store. Chunks of any type other
+// than Profile are bytes chunks, as stores sent their chunks with varying
+// types before.
+func decodeChunk(c *storepb.Chunk) (chunkenc.Chunk, error) {
+	e := chunkenc.EncBytes
+	if c.Type == storepb.Chunk_Profile {
+		e = conprofchunkenc.EncProfile
+	}
+	return conprofchunkenc.FromData(e, c.Data)
+}
+
 func (s *grpcSeriesSet) At() storage.Series {
 	return s.curSeries
 }
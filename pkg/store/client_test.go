@@ -82,6 +82,14 @@ func (s *fakeProfileStore) LabelValues(ctx context.Context, r *storepb.LabelValu
 	return nil, nil
 }
 
+func (s *fakeProfileStore) DeleteSeries(ctx context.Context, r *storepb.DeleteSeriesRequest) (*storepb.DeleteSeriesResponse, error) {
+	return nil, nil
+}
+
+func (s *fakeProfileStore) CleanTombstones(ctx context.Context, r *storepb.CleanTombstonesRequest) (*storepb.CleanTombstonesResponse, error) {
+	return nil, nil
+}
+
 func TestAPIQueryRangeGRPCCall(t *testing.T) {
 	lis, err := net.Listen("tcp", ":0")
 	if err != nil {
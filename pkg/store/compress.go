@@ -0,0 +1,101 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+
+	conprofchunkenc "github.com/conprof/conprof/pkg/chunkenc"
+	"github.com/conprof/db/storage"
+	"github.com/conprof/db/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+type compressingAppendable struct {
+	app   storage.Appendable
+	codec conprofchunkenc.Codec
+}
+
+// NewCompressingAppendable returns an appendable compressing every profile
+// with the codec before it is appended to app.
+func NewCompressingAppendable(app storage.Appendable, codec conprofchunkenc.Codec) storage.Appendable {
+	return &compressingAppendable{app: app, codec: codec}
+}
+
+func (a *compressingAppendable) Appender(ctx context.Context) storage.Appender {
+	return &compressingAppender{Appender: a.app.Appender(ctx), codec: a.codec}
+}
+
+type compressingAppender struct {
+	storage.Appender
+	codec conprofchunkenc.Codec
+}
+
+func (a *compressingAppender) Add(l labels.Labels, t int64, v []byte) (uint64, error) {
+	v, err := conprofchunkenc.Compress(a.codec, v)
+	if err != nil {
+		return 0, err
+	}
+	return a.Appender.Add(l, t, v)
+}
+
+func (a *compressingAppender) AddFast(ref uint64, t int64, v []byte) error {
+	v, err := conprofchunkenc.Compress(a.codec, v)
+	if err != nil {
+		return err
+	}
+	return a.Appender.AddFast(ref, t, v)
+}
+
+type decompressingQueryable struct {
+	q storage.Queryable
+}
+
+// NewDecompressingQueryable returns a queryable decompressing the profiles
+// of q, whatever codec they are compressed with.
+func NewDecompressingQueryable(q storage.Queryable) storage.Queryable {
+	return &decompressingQueryable{q: q}
+}
+
+func (q *decompressingQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	querier, err := q.q.Querier(ctx, mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	return &decompressingQuerier{Querier: querier}, nil
+}
+
+type decompressingQuerier struct {
+	storage.Querier
+}
+
+func (q *decompressingQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	return &decompressingSeriesSet{SeriesSet: q.Querier.Select(sortSeries, hints, matchers...)}
+}
+
+type decompressingSeriesSet struct {
+	storage.SeriesSet
+}
+
+func (s *decompressingSeriesSet) At() storage.Series {
+	return &decompressingSeries{Series: s.SeriesSet.At()}
+}
+
+type decompressingSeries struct {
+	storage.Series
+}
+
+func (s *decompressingSeries) Iterator() chunkenc.Iterator {
+	return conprofchunkenc.NewDecompressingIterator(s.Series.Iterator())
+}
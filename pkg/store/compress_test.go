@@ -0,0 +1,95 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	conprofchunkenc "github.com/conprof/conprof/pkg/chunkenc"
+	"github.com/conprof/conprof/pkg/store/storepb"
+	"github.com/conprof/conprof/pkg/testutil"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+func TestCompressingAppendable(t *testing.T) {
+	db, err := testutil.NewTSDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ls := labels.Labels{{Name: "__name__", Value: "allocs"}}
+	app := NewCompressingAppendable(db, conprofchunkenc.CodecZstd).Appender(context.Background())
+	ref, err := app.Add(ls, 1, []byte("profile 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.AddFast(ref, 2, []byte("profile 2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The TSDB stores the compressed profiles.
+	q, err := db.Querier(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := q.Select(false, nil, labels.MustNewMatcher(labels.MatchEqual, "__name__", "allocs"))
+	if !set.Next() {
+		t.Fatalf("expected a series: %v", set.Err())
+	}
+	it := set.At().Iterator()
+	for it.Next() {
+		if _, b := it.At(); conprofchunkenc.CodecOf(b) != conprofchunkenc.CodecZstd {
+			t.Fatalf("expected zstd compressed profile, got %q", b)
+		}
+	}
+
+	q, err = NewDecompressingQueryable(db).Querier(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set = q.Select(false, nil, labels.MustNewMatcher(labels.MatchEqual, "__name__", "allocs"))
+	if !set.Next() {
+		t.Fatalf("expected a series: %v", set.Err())
+	}
+	it = set.At().Iterator()
+	var profiles []string
+	for it.Next() {
+		_, b := it.At()
+		profiles = append(profiles, string(b))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 || profiles[0] != "profile 1" || profiles[1] != "profile 2" {
+		t.Fatalf("unexpected profiles %q", profiles)
+	}
+
+	res, err := NewProfileStore(log.NewNopLogger(), db, 100000).Profile(context.Background(), &storepb.ProfileRequest{
+		Timestamp: 2,
+		Matchers:  []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "__name__", Value: "allocs"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res.Data, []byte("profile 2")) {
+		t.Fatalf("unexpected profile %q", res.Data)
+	}
+}
@@ -106,3 +106,11 @@ func (s *EndlessProfileStore) LabelNames(ctx context.Context, r *storepb.LabelNa
 func (s *EndlessProfileStore) LabelValues(ctx context.Context, r *storepb.LabelValuesRequest) (*storepb.LabelValuesResponse, error) {
 	return nil, nil
 }
+
+func (s *EndlessProfileStore) DeleteSeries(ctx context.Context, r *storepb.DeleteSeriesRequest) (*storepb.DeleteSeriesResponse, error) {
+	return nil, grpcstatus.Error(codes.Unimplemented, "deleting series is not implemented by the endless store")
+}
+
+func (s *EndlessProfileStore) CleanTombstones(ctx context.Context, r *storepb.CleanTombstonesRequest) (*storepb.CleanTombstonesResponse, error) {
+	return nil, grpcstatus.Error(codes.Unimplemented, "cleaning tombstones is not implemented by the endless store")
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"math"
+
+	"github.com/conprof/db/storage"
+	"github.com/conprof/db/tsdb"
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	conprofchunkenc "github.com/conprof/conprof/pkg/chunkenc"
+)
+
+// Profile is a stored profile of a series.
+type Profile struct {
+	Labels labels.Labels
+	T      int64
+	V      []byte
+}
+
+// ProfileRewriter replaces stored profiles. It reads the profiles
+// decompressed.
+type ProfileRewriter interface {
+	storage.Queryable
+	// RewritableBefore returns the time before which stored profiles can be
+	// rewritten.
+	RewritableBefore() int64
+	// Rewrite replaces the stored profiles of the series at the times of the
+	// given ones. Profiles that aren't stored anymore are skipped.
+	Rewrite(ctx context.Context, profiles []Profile) error
+}
+
+type tsdbProfileRewriter struct {
+	storage.Queryable
+	logger log.Logger
+	db     *tsdb.DB
+	codec  conprofchunkenc.Codec
+}
+
+// NewTSDBProfileRewriter returns a rewriter replacing profiles in the blocks
+// of the TSDB, compressing them with the codec.
+//
+// The profiles are written to a new block overlapping the one they are
+// stored in, and deleted from the latter, before both are compacted into one.
+// The TSDB has to allow overlapping blocks, so that it can be opened if it
+// is stopped in between.
+func NewTSDBProfileRewriter(logger log.Logger, db *tsdb.DB, codec conprofchunkenc.Codec) ProfileRewriter {
+	return &tsdbProfileRewriter{
+		Queryable: NewDecompressingQueryable(db),
+		logger:    logger,
+		db:        db,
+		codec:     codec,
+	}
+}
+
+// RewritableBefore returns the end of the last block, profiles in the head
+// can't be replaced as it only appends in order.
+func (r *tsdbProfileRewriter) RewritableBefore() int64 {
+	blocks := r.db.Blocks()
+	if len(blocks) == 0 {
+		return math.MinInt64
+	}
+	return blocks[len(blocks)-1].MaxTime()
+}
+
+func (r *tsdbProfileRewriter) Rewrite(ctx context.Context, profiles []Profile) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	// Blocks mustn't be compacted before the replaced profiles are deleted,
+	// or the compacted block would keep either of them.
+	r.db.DisableCompactions()
+	defer r.db.EnableCompactions()
+
+	blocks := r.db.Blocks()
+	byBlock := make([][]Profile, len(blocks))
+	for _, p := range profiles {
+		for i, b := range blocks {
+			if b.MinTime() <= p.T && p.T < b.MaxTime() {
+				byBlock[i] = append(byBlock[i], p)
+				break
+			}
+		}
+	}
+
+	var deletes [][]*labels.Matcher
+	var times []int64
+	for i, ps := range byBlock {
+		if len(ps) == 0 {
+			continue
+		}
+		replaced, err := r.writeBlock(ctx, blocks[i], ps)
+		if err != nil {
+			return err
+		}
+		for _, p := range replaced {
+			deletes = append(deletes, seriesMatchers(p.Labels))
+			times = append(times, p.T)
+		}
+	}
+	if len(deletes) == 0 {
+		return nil
+	}
+
+	for i, ms := range deletes {
+		if err := r.db.Delete(times[i], times[i], ms...); err != nil {
+			return errors.Wrap(err, "delete replaced profiles")
+		}
+	}
+	// Compacting merges the written blocks into the ones they overlap.
+	return errors.Wrap(r.db.Compact(), "compact rewritten profiles")
+}
+
+// writeBlock writes the profiles stored in the block to a new block, and
+// returns the ones it replaces. Deleting a profile by the matchers of its
+// series deletes the profiles of series with more labels at the same time
+// too, so those are written to the new block as they are.
+func (r *tsdbProfileRewriter) writeBlock(ctx context.Context, b *tsdb.Block, profiles []Profile) ([]Profile, error) {
+	q, err := r.db.Querier(ctx, b.MinTime(), b.MaxTime()-1)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	w, err := tsdb.NewBlockWriter(r.logger, r.db.Dir(), b.MaxTime()-b.MinTime())
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	app := w.Appender(ctx)
+	var replaced []Profile
+	for _, p := range profiles {
+		var (
+			samples []Profile
+			found   bool
+		)
+		ss := q.Select(false, &storage.SelectHints{Start: p.T, End: p.T}, seriesMatchers(p.Labels)...)
+		for ss.Next() {
+			s := ss.At()
+			it := s.Iterator()
+			if !it.Seek(p.T) {
+				if err := it.Err(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			t, v := it.At()
+			if t != p.T {
+				continue
+			}
+			if labels.Equal(s.Labels(), p.Labels) {
+				found = true
+				if v, err = conprofchunkenc.Compress(r.codec, p.V); err != nil {
+					return nil, err
+				}
+			}
+			samples = append(samples, Profile{Labels: s.Labels(), T: t, V: v})
+		}
+		if err := ss.Err(); err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		for _, s := range samples {
+			if _, err := app.Add(s.Labels, s.T, s.V); err != nil {
+				return nil, err
+			}
+		}
+		replaced = append(replaced, p)
+	}
+	if err := app.Commit(); err != nil {
+		return nil, err
+	}
+	if len(replaced) == 0 {
+		return nil, nil
+	}
+
+	if _, err := w.Flush(ctx); err != nil {
+		return nil, errors.Wrap(err, "write rewritten profiles")
+	}
+	return replaced, nil
+}
+
+func seriesMatchers(lset labels.Labels) []*labels.Matcher {
+	ms := make([]*labels.Matcher, 0, len(lset))
+	for _, l := range lset {
+		ms = append(ms, labels.MustNewMatcher(labels.MatchEqual, l.Name, l.Value))
+	}
+	return ms
+}
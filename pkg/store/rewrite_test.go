@@ -0,0 +1,101 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	conprofchunkenc "github.com/conprof/conprof/pkg/chunkenc"
+	"github.com/conprof/conprof/pkg/testutil"
+	"github.com/conprof/db/tsdb"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+func TestTSDBProfileRewriter(t *testing.T) {
+	db, err := testutil.NewTSDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	a := labels.FromStrings("__name__", "allocs", "job", "a")
+	// The matchers of series a select series b as well.
+	b := labels.FromStrings("__name__", "allocs", "job", "a", "zone", "b")
+	app := NewCompressingAppendable(db, conprofchunkenc.CodecZstd).Appender(context.Background())
+	for _, ts := range []int64{1, 2, 3} {
+		for _, ls := range []labels.Labels{a, b} {
+			if _, err := app.Add(ls, ts, []byte(ls.Get("job")+ls.Get("zone"))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := app.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewTSDBProfileRewriter(log.NewNopLogger(), db, conprofchunkenc.CodecZstd)
+	// Profiles in the head can't be rewritten.
+	if err := r.Rewrite(context.Background(), []Profile{{Labels: a, T: 2, V: []byte("rewritten")}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompactHead(tsdb.NewRangeHead(db.Head(), 0, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if before := r.RewritableBefore(); before != 4 {
+		t.Fatalf("expected profiles before 4 to be rewritable, got %d", before)
+	}
+
+	if err := r.Rewrite(context.Background(), []Profile{
+		{Labels: a, T: 2, V: []byte("rewritten")},
+		// Profiles that aren't stored aren't written.
+		{Labels: a, T: 0, V: []byte("deleted")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if blocks := db.Blocks(); len(blocks) != 1 {
+		t.Fatalf("expected the rewritten profiles to be compacted into one block, got %d blocks", len(blocks))
+	}
+
+	q, err := r.Querier(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	set := q.Select(false, nil, labels.MustNewMatcher(labels.MatchEqual, "__name__", "allocs"))
+	profiles := map[string][]string{}
+	for set.Next() {
+		series := set.At().Labels().String()
+		it := set.At().Iterator()
+		for it.Next() {
+			_, v := it.At()
+			profiles[series] = append(profiles[series], string(v))
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := set.Err(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		a.String(): {"a", "rewritten", "a"},
+		b.String(): {"ab", "ab", "ab"},
+	}
+	if !reflect.DeepEqual(expected, profiles) {
+		t.Fatalf("expected profiles %v, got %v", expected, profiles)
+	}
+}
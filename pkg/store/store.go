@@ -19,12 +19,14 @@ import (
 	"fmt"
 	"sort"
 
+	conprofchunkenc "github.com/conprof/conprof/pkg/chunkenc"
 	"github.com/conprof/conprof/pkg/runutil"
 	"github.com/conprof/conprof/pkg/store/storepb"
 	"github.com/conprof/db/storage"
 	"github.com/conprof/db/tsdb"
 	"github.com/conprof/db/tsdb/chunkenc"
 	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
 	"go.opentelemetry.io/otel"
@@ -46,6 +48,7 @@ type profileStore struct {
 	logger           log.Logger
 	db               db
 	maxBytesPerFrame int
+	deleter          SeriesDeleter
 }
 
 func RegisterSymbolStore(storeSrv storepb.SymbolStoreServer) func(*grpc.Server) {
@@ -68,12 +71,16 @@ func RegisterWritableStoreServer(storeSrv storepb.WritableProfileStoreServer) fu
 	}
 }
 
-func NewProfileStore(logger log.Logger, db db, maxBytesPerFrame int) *profileStore {
-	return &profileStore{
+func NewProfileStore(logger log.Logger, db db, maxBytesPerFrame int, opts ...ProfileStoreOption) *profileStore {
+	s := &profileStore{
 		logger:           logger,
 		db:               db,
 		maxBytesPerFrame: maxBytesPerFrame,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 var _ storepb.ReadableProfileStoreServer = &profileStore{}
@@ -127,6 +134,10 @@ func (s *profileStore) Profile(ctx context.Context, r *storepb.ProfileRequest) (
 	}
 
 	_, buf := i.At()
+	buf, err = conprofchunkenc.Decompress(buf)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
 	return &storepb.ProfileResponse{
 		Data: buf,
 	}, nil
@@ -184,11 +195,20 @@ func (s *profileStore) Series(r *storepb.SeriesRequest, srv storepb.ReadableProf
 			}
 
 			tc := chk.Chunk
-			if r.SelectHints != nil && r.SelectHints.Func == "timestamps" {
+			switch {
+			case r.SelectHints != nil && r.SelectHints.Func == "timestamps":
 				it, tc, err = tsdb.ReencodeChunk(&tsdb.TimestampChunk{Chunk: tc}, it)
 				if err != nil {
 					return status.Error(codes.Aborted, err.Error())
 				}
+			case r.ProfileChunks:
+				// Chunks with values that aren't profiles are sent as they are.
+				pc, err := conprofchunkenc.Reencode(tc)
+				if err != nil {
+					level.Debug(s.logger).Log("msg", "failed to re-encode chunk to profile encoding", "ref", chk.Ref, "err", err)
+					break
+				}
+				tc = pc
 			}
 
 			tcBytes, err := tc.Bytes()
@@ -200,7 +220,7 @@ func (s *profileStore) Series(r *storepb.SeriesRequest, srv storepb.ReadableProf
 				MinTime: chk.MinTime,
 				MaxTime: chk.MaxTime,
 				Raw: &storepb.Chunk{
-					Type: storepb.Chunk_Encoding(chk.Chunk.Encoding() - 1), // Proto chunk encoding is one off to TSDB one.
+					Type: chunkEncoding(tc.Encoding()),
 					Data: tcBytes,
 				},
 			}
@@ -238,6 +258,14 @@ func (s *profileStore) Series(r *storepb.SeriesRequest, srv storepb.ReadableProf
 	return nil
 }
 
+// chunkEncoding returns the proto encoding of the TSDB chunk encoding.
+func chunkEncoding(e chunkenc.Encoding) storepb.Chunk_Encoding {
+	if e == conprofchunkenc.EncProfile {
+		return storepb.Chunk_Profile
+	}
+	return storepb.Chunk_Encoding(e - 1) // Proto chunk encoding is one off to TSDB one.
+}
+
 func (s *profileStore) noopChunks(r *storepb.SeriesRequest, srv storepb.ReadableProfileStore_SeriesServer) error {
 	ctx := srv.Context()
 
@@ -27,11 +27,15 @@ import (
 	"time"
 
 	"github.com/conprof/conprof/api"
+	conprofchunkenc "github.com/conprof/conprof/pkg/chunkenc"
 	"github.com/conprof/conprof/pkg/store/storepb"
+	"github.com/conprof/conprof/pkg/testutil"
 	"github.com/conprof/db/storage"
 	"github.com/conprof/db/tsdb"
+	"github.com/conprof/db/tsdb/chunkenc"
 	"github.com/conprof/db/tsdb/wal"
 	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
@@ -272,3 +276,141 @@ func TestStore(t *testing.T) {
 		t.Fatalf("Unexpected timestamps, expected %s, got %s", fmt.Sprintf("%#+v", expectedTimestamps), fmt.Sprintf("%#+v", res.Timestamps))
 	}
 }
+
+func TestStoreProfileChunks(t *testing.T) {
+	db, err := testutil.NewTSDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	f, err := ioutil.ReadFile("../../api/testdata/alloc_objects.pb.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := profile.ParseData(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := p.WriteUncompressed(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	ls := labels.Labels{{Name: "__name__", Value: "allocs"}}
+	app := db.Appender(context.Background())
+	for _, ts := range []int64{1, 2, 3} {
+		if _, err := app.Add(ls, ts, buf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	lis, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer lis.Close()
+	grpcServer := grpc.NewServer()
+	storepb.RegisterReadableProfileStoreServer(grpcServer, NewProfileStore(log.NewNopLogger(), db, 100000))
+	go grpcServer.Serve(lis)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := storepb.NewReadableProfileStoreClient(conn)
+
+	stream, err := rc.Series(context.Background(), &storepb.SeriesRequest{
+		MinTime:       0,
+		MaxTime:       10,
+		Matchers:      []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "__name__", Value: "allocs"}},
+		ProfileChunks: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range res.GetSeries().Chunks {
+		if c.Raw.Type != storepb.Chunk_Profile {
+			t.Fatalf("unexpected chunk encoding %s", c.Raw.Type)
+		}
+	}
+
+	q, err := NewGRPCQueryable(rc, WithProfileChunks()).Querier(context.Background(), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := q.Select(false, nil, labels.MustNewMatcher(labels.MatchEqual, "__name__", "allocs"))
+	if !set.Next() {
+		t.Fatalf("expected a series: %v", set.Err())
+	}
+	it := set.At().Iterator()
+	var timestamps []int64
+	for it.Next() {
+		ts, b := it.At()
+		timestamps = append(timestamps, ts)
+
+		res, err := profile.ParseData(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Sample) != len(p.Sample) {
+			t.Fatalf("expected %d samples, got %d", len(p.Sample), len(res.Sample))
+		}
+
+		res, err = it.(conprofchunkenc.ProfileIterator).AtProfile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Sample) != len(p.Sample) {
+			t.Fatalf("expected %d samples, got %d", len(p.Sample), len(res.Sample))
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]int64{1, 2, 3}, timestamps) {
+		t.Fatalf("unexpected timestamps %v", timestamps)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		opts     []GRPCQueryableOption
+		hints    *storage.SelectHints
+		encoding chunkenc.Encoding
+	}{
+		{name: "default", encoding: chunkenc.EncBytes},
+		{name: "profile chunks", opts: []GRPCQueryableOption{WithProfileChunks()}, encoding: conprofchunkenc.EncProfile},
+		{name: "single profile", opts: []GRPCQueryableOption{WithProfileChunks()}, hints: &storage.SelectHints{Start: 0, End: 10, Func: "profile"}, encoding: chunkenc.EncBytes},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := NewGRPCQueryable(rc, tc.opts...).Querier(context.Background(), 0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			set := q.Select(false, tc.hints, labels.MustNewMatcher(labels.MatchEqual, "__name__", "allocs"))
+			if !set.Next() {
+				t.Fatalf("expected a series: %v", set.Err())
+			}
+			chks := set.At().(*protoSeries).chunks
+			if len(chks) == 0 {
+				t.Fatal("expected chunks")
+			}
+			for _, c := range chks {
+				dc, err := decodeChunk(c.Raw)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if dc.Encoding() != tc.encoding {
+					t.Fatalf("expected chunk encoding %s, got %s", tc.encoding, dc.Encoding())
+				}
+			}
+		})
+	}
+}
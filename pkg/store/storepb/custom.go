@@ -34,6 +34,14 @@ func (c *SymbolizeClient) Symbolize(ctx context.Context, r *SymbolizeRequest) (*
 	return c.c.Symbolize(ctx, r)
 }
 
+func (c *SymbolizeClient) Source(ctx context.Context, r *SourceRequest) (*SourceResponse, error) {
+	return c.c.Source(ctx, r)
+}
+
+func (c *SymbolizeClient) Download(ctx context.Context, r *DownloadRequest) (SymbolStore_DownloadClient, error) {
+	return c.c.Download(ctx, r)
+}
+
 func NewSymbolizeClient(conn *grpc.ClientConn) *SymbolizeClient {
 	return &SymbolizeClient{
 		c: NewSymbolStoreClient(conn),
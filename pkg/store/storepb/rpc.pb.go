@@ -65,18 +65,21 @@ func (LabelMatcher_Type) EnumDescriptor() ([]byte, []int) {
 type Chunk_Encoding int32
 
 const (
-	Chunk_XOR  Chunk_Encoding = 0
-	Chunk_None Chunk_Encoding = 1
+	Chunk_XOR     Chunk_Encoding = 0
+	Chunk_None    Chunk_Encoding = 1
+	Chunk_Profile Chunk_Encoding = 2
 )
 
 var Chunk_Encoding_name = map[int32]string{
 	0: "XOR",
 	1: "None",
+	2: "Profile",
 }
 
 var Chunk_Encoding_value = map[string]int32{
-	"XOR":  0,
-	"None": 1,
+	"XOR":     0,
+	"None":    1,
+	"Profile": 2,
 }
 
 func (x Chunk_Encoding) String() string {
@@ -283,6 +286,10 @@ type SeriesRequest struct {
 	Matchers    []LabelMatcher `protobuf:"bytes,3,rep,name=matchers,proto3" json:"matchers"`
 	SkipChunks  bool           `protobuf:"varint,4,opt,name=skip_chunks,json=skipChunks,proto3" json:"skip_chunks,omitempty"`
 	SelectHints *SelectHints   `protobuf:"bytes,5,opt,name=select_hints,json=selectHints,proto3" json:"select_hints,omitempty"`
+	// If set, chunks are sent in the profile encoding, which stores the
+	// strings, functions, locations and mappings shared by the profiles of a
+	// chunk only once.
+	ProfileChunks bool `protobuf:"varint,6,opt,name=profile_chunks,json=profileChunks,proto3" json:"profile_chunks,omitempty"`
 }
 
 func (m *SeriesRequest) Reset()         { *m = SeriesRequest{} }
@@ -786,6 +793,153 @@ func (m *LabelValuesResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_LabelValuesResponse proto.InternalMessageInfo
 
+type DeleteSeriesRequest struct {
+	MinTime  int64          `protobuf:"varint,1,opt,name=min_time,json=minTime,proto3" json:"min_time,omitempty"`
+	MaxTime  int64          `protobuf:"varint,2,opt,name=max_time,json=maxTime,proto3" json:"max_time,omitempty"`
+	Matchers []LabelMatcher `protobuf:"bytes,3,rep,name=matchers,proto3" json:"matchers"`
+}
+
+func (m *DeleteSeriesRequest) Reset()         { *m = DeleteSeriesRequest{} }
+func (m *DeleteSeriesRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteSeriesRequest) ProtoMessage()    {}
+func (*DeleteSeriesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{17}
+}
+func (m *DeleteSeriesRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DeleteSeriesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DeleteSeriesRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *DeleteSeriesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteSeriesRequest.Merge(m, src)
+}
+func (m *DeleteSeriesRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *DeleteSeriesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteSeriesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteSeriesRequest proto.InternalMessageInfo
+
+type DeleteSeriesResponse struct {
+}
+
+func (m *DeleteSeriesResponse) Reset()         { *m = DeleteSeriesResponse{} }
+func (m *DeleteSeriesResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteSeriesResponse) ProtoMessage()    {}
+func (*DeleteSeriesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{18}
+}
+func (m *DeleteSeriesResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DeleteSeriesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DeleteSeriesResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *DeleteSeriesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteSeriesResponse.Merge(m, src)
+}
+func (m *DeleteSeriesResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *DeleteSeriesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteSeriesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteSeriesResponse proto.InternalMessageInfo
+
+type CleanTombstonesRequest struct {
+}
+
+func (m *CleanTombstonesRequest) Reset()         { *m = CleanTombstonesRequest{} }
+func (m *CleanTombstonesRequest) String() string { return proto.CompactTextString(m) }
+func (*CleanTombstonesRequest) ProtoMessage()    {}
+func (*CleanTombstonesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{19}
+}
+func (m *CleanTombstonesRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *CleanTombstonesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_CleanTombstonesRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *CleanTombstonesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CleanTombstonesRequest.Merge(m, src)
+}
+func (m *CleanTombstonesRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *CleanTombstonesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CleanTombstonesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CleanTombstonesRequest proto.InternalMessageInfo
+
+type CleanTombstonesResponse struct {
+}
+
+func (m *CleanTombstonesResponse) Reset()         { *m = CleanTombstonesResponse{} }
+func (m *CleanTombstonesResponse) String() string { return proto.CompactTextString(m) }
+func (*CleanTombstonesResponse) ProtoMessage()    {}
+func (*CleanTombstonesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{20}
+}
+func (m *CleanTombstonesResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *CleanTombstonesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_CleanTombstonesResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *CleanTombstonesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CleanTombstonesResponse.Merge(m, src)
+}
+func (m *CleanTombstonesResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *CleanTombstonesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CleanTombstonesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CleanTombstonesResponse proto.InternalMessageInfo
+
 type SymbolExistsRequest struct {
 	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 }
@@ -794,7 +948,7 @@ func (m *SymbolExistsRequest) Reset()         { *m = SymbolExistsRequest{} }
 func (m *SymbolExistsRequest) String() string { return proto.CompactTextString(m) }
 func (*SymbolExistsRequest) ProtoMessage()    {}
 func (*SymbolExistsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{17}
+	return fileDescriptor_a938d55a388af629, []int{21}
 }
 func (m *SymbolExistsRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -831,7 +985,7 @@ func (m *SymbolExistsResponse) Reset()         { *m = SymbolExistsResponse{} }
 func (m *SymbolExistsResponse) String() string { return proto.CompactTextString(m) }
 func (*SymbolExistsResponse) ProtoMessage()    {}
 func (*SymbolExistsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{18}
+	return fileDescriptor_a938d55a388af629, []int{22}
 }
 func (m *SymbolExistsResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -871,7 +1025,7 @@ func (m *SymbolUploadRequest) Reset()         { *m = SymbolUploadRequest{} }
 func (m *SymbolUploadRequest) String() string { return proto.CompactTextString(m) }
 func (*SymbolUploadRequest) ProtoMessage()    {}
 func (*SymbolUploadRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{19}
+	return fileDescriptor_a938d55a388af629, []int{23}
 }
 func (m *SymbolUploadRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -947,13 +1101,23 @@ func (*SymbolUploadRequest) XXX_OneofWrappers() []interface{} {
 
 type SymbolUploadInfo struct {
 	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// file_name is the path the binary runs at, set if it has no GNU build ID
+	// and is identified by its path and the hash of its content instead.
+	FileName string `protobuf:"bytes,2,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	// kernel_release is set if a kallsyms snapshot of the kernel with the ID as
+	// build ID is uploaded, instead of debuginfo.
+	KernelRelease string `protobuf:"bytes,3,opt,name=kernel_release,json=kernelRelease,proto3" json:"kernel_release,omitempty"`
+	// source_path is set if a source file of the binary with the ID as build ID
+	// is uploaded, instead of debuginfo. It's the path the binary was compiled
+	// from, as found in its debuginfo.
+	SourcePath string `protobuf:"bytes,4,opt,name=source_path,json=sourcePath,proto3" json:"source_path,omitempty"`
 }
 
 func (m *SymbolUploadInfo) Reset()         { *m = SymbolUploadInfo{} }
 func (m *SymbolUploadInfo) String() string { return proto.CompactTextString(m) }
 func (*SymbolUploadInfo) ProtoMessage()    {}
 func (*SymbolUploadInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{20}
+	return fileDescriptor_a938d55a388af629, []int{24}
 }
 func (m *SymbolUploadInfo) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -991,7 +1155,7 @@ func (m *SymbolUploadResponse) Reset()         { *m = SymbolUploadResponse{} }
 func (m *SymbolUploadResponse) String() string { return proto.CompactTextString(m) }
 func (*SymbolUploadResponse) ProtoMessage()    {}
 func (*SymbolUploadResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{21}
+	return fileDescriptor_a938d55a388af629, []int{25}
 }
 func (m *SymbolUploadResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1028,7 +1192,7 @@ func (m *SymbolizeRequest) Reset()         { *m = SymbolizeRequest{} }
 func (m *SymbolizeRequest) String() string { return proto.CompactTextString(m) }
 func (*SymbolizeRequest) ProtoMessage()    {}
 func (*SymbolizeRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{22}
+	return fileDescriptor_a938d55a388af629, []int{26}
 }
 func (m *SymbolizeRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1065,7 +1229,7 @@ func (m *SymbolizeResponse) Reset()         { *m = SymbolizeResponse{} }
 func (m *SymbolizeResponse) String() string { return proto.CompactTextString(m) }
 func (*SymbolizeResponse) ProtoMessage()    {}
 func (*SymbolizeResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{23}
+	return fileDescriptor_a938d55a388af629, []int{27}
 }
 func (m *SymbolizeResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1100,13 +1264,19 @@ type Mapping struct {
 	MemoryLimit uint64      `protobuf:"varint,3,opt,name=memory_limit,json=memoryLimit,proto3" json:"memory_limit,omitempty"`
 	FileOffset  uint64      `protobuf:"varint,4,opt,name=file_offset,json=fileOffset,proto3" json:"file_offset,omitempty"`
 	Locations   []*Location `protobuf:"bytes,5,rep,name=locations,proto3" json:"locations,omitempty"`
+	// file is the path of the mapped binary, used to look up its debuginfo if
+	// it has no build ID.
+	File string `protobuf:"bytes,6,opt,name=file,proto3" json:"file,omitempty"`
+	// kernel is true if the mapping is the kernel, which is symbolized using
+	// the uploaded kallsyms of its build ID.
+	Kernel bool `protobuf:"varint,7,opt,name=kernel,proto3" json:"kernel,omitempty"`
 }
 
 func (m *Mapping) Reset()         { *m = Mapping{} }
 func (m *Mapping) String() string { return proto.CompactTextString(m) }
 func (*Mapping) ProtoMessage()    {}
 func (*Mapping) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{24}
+	return fileDescriptor_a938d55a388af629, []int{28}
 }
 func (m *Mapping) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1144,7 +1314,7 @@ func (m *Location) Reset()         { *m = Location{} }
 func (m *Location) String() string { return proto.CompactTextString(m) }
 func (*Location) ProtoMessage()    {}
 func (*Location) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{25}
+	return fileDescriptor_a938d55a388af629, []int{29}
 }
 func (m *Location) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1182,7 +1352,7 @@ func (m *Line) Reset()         { *m = Line{} }
 func (m *Line) String() string { return proto.CompactTextString(m) }
 func (*Line) ProtoMessage()    {}
 func (*Line) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{26}
+	return fileDescriptor_a938d55a388af629, []int{30}
 }
 func (m *Line) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1220,7 +1390,7 @@ func (m *Function) Reset()         { *m = Function{} }
 func (m *Function) String() string { return proto.CompactTextString(m) }
 func (*Function) ProtoMessage()    {}
 func (*Function) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a938d55a388af629, []int{27}
+	return fileDescriptor_a938d55a388af629, []int{31}
 }
 func (m *Function) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -1249,6 +1419,155 @@ func (m *Function) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Function proto.InternalMessageInfo
 
+type SourceRequest struct {
+	BuildId string `protobuf:"bytes,1,opt,name=build_id,json=buildId,proto3" json:"build_id,omitempty"`
+	Path    string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+}
+
+func (m *SourceRequest) Reset()         { *m = SourceRequest{} }
+func (m *SourceRequest) String() string { return proto.CompactTextString(m) }
+func (*SourceRequest) ProtoMessage()    {}
+func (*SourceRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{32}
+}
+func (m *SourceRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SourceRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SourceRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SourceRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SourceRequest.Merge(m, src)
+}
+func (m *SourceRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *SourceRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SourceRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SourceRequest proto.InternalMessageInfo
+
+type SourceResponse struct {
+	Content []byte `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
+}
+
+func (m *SourceResponse) Reset()         { *m = SourceResponse{} }
+func (m *SourceResponse) String() string { return proto.CompactTextString(m) }
+func (*SourceResponse) ProtoMessage()    {}
+func (*SourceResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{33}
+}
+func (m *SourceResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SourceResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SourceResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SourceResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SourceResponse.Merge(m, src)
+}
+func (m *SourceResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *SourceResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SourceResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SourceResponse proto.InternalMessageInfo
+
+type DownloadRequest struct {
+	BuildId string `protobuf:"bytes,1,opt,name=build_id,json=buildId,proto3" json:"build_id,omitempty"`
+}
+
+func (m *DownloadRequest) Reset()         { *m = DownloadRequest{} }
+func (m *DownloadRequest) String() string { return proto.CompactTextString(m) }
+func (*DownloadRequest) ProtoMessage()    {}
+func (*DownloadRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{34}
+}
+func (m *DownloadRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DownloadRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DownloadRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *DownloadRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DownloadRequest.Merge(m, src)
+}
+func (m *DownloadRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *DownloadRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DownloadRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DownloadRequest proto.InternalMessageInfo
+
+type DownloadResponse struct {
+	ChunkData []byte `protobuf:"bytes,1,opt,name=chunk_data,json=chunkData,proto3" json:"chunk_data,omitempty"`
+}
+
+func (m *DownloadResponse) Reset()         { *m = DownloadResponse{} }
+func (m *DownloadResponse) String() string { return proto.CompactTextString(m) }
+func (*DownloadResponse) ProtoMessage()    {}
+func (*DownloadResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a938d55a388af629, []int{35}
+}
+func (m *DownloadResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DownloadResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_DownloadResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *DownloadResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DownloadResponse.Merge(m, src)
+}
+func (m *DownloadResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *DownloadResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DownloadResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DownloadResponse proto.InternalMessageInfo
+
 func init() {
 	proto.RegisterEnum("conprof.LabelMatcher_Type", LabelMatcher_Type_name, LabelMatcher_Type_value)
 	proto.RegisterEnum("conprof.Chunk_Encoding", Chunk_Encoding_name, Chunk_Encoding_value)
@@ -1269,6 +1588,10 @@ func init() {
 	proto.RegisterType((*LabelNamesResponse)(nil), "conprof.LabelNamesResponse")
 	proto.RegisterType((*LabelValuesRequest)(nil), "conprof.LabelValuesRequest")
 	proto.RegisterType((*LabelValuesResponse)(nil), "conprof.LabelValuesResponse")
+	proto.RegisterType((*DeleteSeriesRequest)(nil), "conprof.DeleteSeriesRequest")
+	proto.RegisterType((*DeleteSeriesResponse)(nil), "conprof.DeleteSeriesResponse")
+	proto.RegisterType((*CleanTombstonesRequest)(nil), "conprof.CleanTombstonesRequest")
+	proto.RegisterType((*CleanTombstonesResponse)(nil), "conprof.CleanTombstonesResponse")
 	proto.RegisterType((*SymbolExistsRequest)(nil), "conprof.SymbolExistsRequest")
 	proto.RegisterType((*SymbolExistsResponse)(nil), "conprof.SymbolExistsResponse")
 	proto.RegisterType((*SymbolUploadRequest)(nil), "conprof.SymbolUploadRequest")
@@ -1280,96 +1603,118 @@ func init() {
 	proto.RegisterType((*Location)(nil), "conprof.Location")
 	proto.RegisterType((*Line)(nil), "conprof.Line")
 	proto.RegisterType((*Function)(nil), "conprof.Function")
+	proto.RegisterType((*SourceRequest)(nil), "conprof.SourceRequest")
+	proto.RegisterType((*SourceResponse)(nil), "conprof.SourceResponse")
+	proto.RegisterType((*DownloadRequest)(nil), "conprof.DownloadRequest")
+	proto.RegisterType((*DownloadResponse)(nil), "conprof.DownloadResponse")
 }
 
 func init() { proto.RegisterFile("store/storepb/rpc.proto", fileDescriptor_a938d55a388af629) }
 
 var fileDescriptor_a938d55a388af629 = []byte{
-	// 1339 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x57, 0xcb, 0x72, 0x1b, 0x45,
-	0x17, 0x9e, 0x91, 0xe4, 0x91, 0x74, 0x64, 0x3b, 0x4a, 0x47, 0xbf, 0xad, 0xcc, 0x9f, 0xc8, 0xa6,
-	0xa9, 0x54, 0xa9, 0x0a, 0x22, 0xa5, 0x9c, 0x45, 0x20, 0xc9, 0x82, 0x98, 0x28, 0x58, 0x55, 0xb9,
-	0xb6, 0xc3, 0xa5, 0xd8, 0xa8, 0x5a, 0x52, 0x4b, 0x6e, 0x3c, 0x9a, 0x1e, 0x66, 0x46, 0x24, 0xce,
-	0x53, 0x50, 0x3c, 0x02, 0xc5, 0x82, 0x87, 0xe0, 0x01, 0xb2, 0xcc, 0x32, 0xc5, 0x22, 0x05, 0xc9,
-	0x82, 0xd7, 0xa0, 0xfa, 0x32, 0x17, 0x49, 0x86, 0xc2, 0x2c, 0xd8, 0xa8, 0xe6, 0x5c, 0xfa, 0x3b,
-	0xe7, 0x3b, 0x7d, 0x4e, 0x77, 0x0b, 0xb6, 0xa3, 0x58, 0x84, 0xac, 0xab, 0x7e, 0x83, 0x61, 0x37,
-	0x0c, 0x46, 0x9d, 0x20, 0x14, 0xb1, 0x40, 0xe5, 0x91, 0xf0, 0x83, 0x50, 0x4c, 0xdc, 0xc6, 0x54,
-	0x4c, 0x85, 0xd2, 0x75, 0xe5, 0x97, 0x36, 0xbb, 0x17, 0xa7, 0x42, 0x4c, 0x3d, 0xd6, 0x55, 0xd2,
-	0x70, 0x3e, 0xe9, 0x52, 0xff, 0xc4, 0x98, 0x3e, 0x9e, 0xf2, 0xf8, 0x68, 0x3e, 0xec, 0x8c, 0xc4,
-	0xac, 0x1b, 0x1f, 0x51, 0x5f, 0x44, 0x57, 0xb9, 0x30, 0x5f, 0xdd, 0xe0, 0x78, 0xaa, 0x83, 0x75,
-	0x3d, 0x3a, 0x64, 0x5e, 0x30, 0xec, 0xc6, 0x27, 0x01, 0x8b, 0xf4, 0x52, 0x7c, 0x0e, 0x36, 0xbe,
-	0x0c, 0x79, 0xcc, 0x08, 0x8b, 0x02, 0xe1, 0x47, 0x0c, 0x7f, 0x03, 0xeb, 0x46, 0xf1, 0xed, 0x9c,
-	0x45, 0x31, 0xda, 0x87, 0x0d, 0x99, 0x14, 0xf7, 0xd8, 0x21, 0x0b, 0x39, 0x8b, 0x9a, 0xf6, 0x6e,
-	0xb1, 0x5d, 0xdb, 0xdb, 0xea, 0x98, 0x6c, 0x3b, 0x8f, 0xf3, 0xd6, 0xfd, 0xd2, 0xcb, 0x37, 0x3b,
-	0x16, 0x59, 0x5c, 0x82, 0xb6, 0xc0, 0x89, 0x99, 0x4f, 0xfd, 0xb8, 0x59, 0xd8, 0xb5, 0xdb, 0x55,
-	0x62, 0x24, 0xfc, 0x93, 0x0d, 0x1b, 0x0b, 0xcb, 0xd1, 0x10, 0x1c, 0x95, 0x65, 0x12, 0x66, 0xa3,
-	0xa3, 0x59, 0x74, 0xee, 0x4b, 0xed, 0xfe, 0x2d, 0x89, 0xfe, 0xeb, 0x9b, 0x9d, 0xeb, 0x67, 0x22,
-	0xac, 0x17, 0x13, 0x83, 0x8c, 0xba, 0x50, 0x8e, 0xe8, 0x2c, 0xf0, 0x58, 0xd4, 0x2c, 0xa8, 0x20,
-	0xe7, 0x52, 0x2e, 0x87, 0x4a, 0x6f, 0x48, 0x24, 0x5e, 0xf8, 0x36, 0x38, 0xda, 0x80, 0x1a, 0xb0,
-	0xf6, 0x1d, 0xf5, 0xe6, 0xac, 0x69, 0xef, 0xda, 0xed, 0x75, 0xa2, 0x05, 0x74, 0x09, 0xaa, 0x31,
-	0x9f, 0xb1, 0x28, 0xa6, 0xb3, 0x40, 0x31, 0x2c, 0x92, 0x4c, 0x81, 0xfb, 0x50, 0x3b, 0x64, 0x1e,
-	0x1b, 0xc5, 0x07, 0xdc, 0x8f, 0x23, 0x09, 0x11, 0xc5, 0x34, 0x8c, 0x15, 0x44, 0x91, 0x68, 0x01,
-	0xd5, 0xa1, 0xc8, 0xfc, 0xb1, 0x59, 0x2c, 0x3f, 0x11, 0x82, 0xd2, 0x64, 0xee, 0x8f, 0x9a, 0x45,
-	0x55, 0x31, 0xf5, 0x8d, 0x5f, 0xdb, 0xb0, 0xa1, 0x0b, 0x95, 0xec, 0xce, 0x45, 0xa8, 0xcc, 0xb8,
-	0x3f, 0x90, 0xd1, 0x0c, 0x60, 0x79, 0xc6, 0xfd, 0xa7, 0x7c, 0xc6, 0x94, 0x89, 0x3e, 0xd7, 0xa6,
-	0x82, 0x31, 0xd1, 0xe7, 0xca, 0x74, 0x43, 0x9a, 0xe2, 0xd1, 0x11, 0x0b, 0xa3, 0x66, 0x51, 0x95,
-	0xe0, 0x7f, 0x69, 0x09, 0x54, 0xad, 0x1e, 0x68, 0xab, 0x29, 0x44, 0xea, 0x8c, 0x76, 0xa0, 0x16,
-	0x1d, 0xf3, 0x60, 0x30, 0x3a, 0x9a, 0xfb, 0xc7, 0x51, 0xb3, 0xb4, 0x6b, 0xb7, 0x2b, 0x04, 0xa4,
-	0xea, 0x53, 0xa5, 0x41, 0x37, 0x60, 0x3d, 0x52, 0x64, 0x07, 0x47, 0x92, 0x6d, 0x73, 0x6d, 0xd7,
-	0x6e, 0xd7, 0xf6, 0x1a, 0x59, 0x81, 0xb3, 0x4a, 0x90, 0x5a, 0x94, 0x09, 0xf8, 0x07, 0x1b, 0xd6,
-	0xf3, 0xa1, 0x51, 0x07, 0x4a, 0xb2, 0x4f, 0x15, 0xab, 0xcd, 0x3d, 0xf7, 0xd4, 0xfc, 0x3a, 0x4f,
-	0x4f, 0x02, 0x46, 0x94, 0x9f, 0xac, 0x97, 0x4f, 0x0d, 0xd5, 0x2a, 0x51, 0xdf, 0xd9, 0x76, 0xe9,
-	0x22, 0x6a, 0x01, 0xb7, 0xa1, 0x24, 0xd7, 0x21, 0x07, 0x0a, 0xbd, 0x27, 0x75, 0x0b, 0x95, 0xa1,
-	0xf8, 0xb0, 0xf7, 0xa4, 0x6e, 0x4b, 0x05, 0xe9, 0xd5, 0x0b, 0x4a, 0x41, 0x7a, 0xf5, 0x22, 0x1e,
-	0x41, 0xf5, 0xce, 0x74, 0x1a, 0x2a, 0x6e, 0xff, 0xb2, 0xd4, 0xbb, 0x50, 0x0c, 0xe9, 0x33, 0x95,
-	0x40, 0x6d, 0x6f, 0x33, 0x65, 0xa1, 0x20, 0x89, 0x34, 0xe1, 0x29, 0xac, 0xe9, 0x00, 0x1f, 0x2c,
-	0x30, 0xde, 0x5e, 0xf4, 0xed, 0xf4, 0xfc, 0x91, 0x18, 0x73, 0x7f, 0x9a, 0xd1, 0x1d, 0xd3, 0x98,
-	0xaa, 0x70, 0xeb, 0x44, 0x7d, 0xe3, 0xcb, 0x50, 0x49, 0xbc, 0x24, 0x87, 0xaf, 0x1e, 0x91, 0xba,
-	0x85, 0x2a, 0x50, 0x7a, 0x28, 0x7c, 0x56, 0xb7, 0xf1, 0xcf, 0x36, 0xd4, 0x09, 0x7d, 0xf6, 0xdf,
-	0x0f, 0xdc, 0x35, 0x70, 0x4c, 0xc3, 0xe8, 0x79, 0x43, 0x29, 0xb5, 0xb4, 0xba, 0xa6, 0xd3, 0x8c,
-	0x1f, 0x3e, 0x86, 0xcd, 0xa4, 0xcf, 0xf5, 0xb1, 0x84, 0xae, 0x83, 0x13, 0x25, 0xe7, 0x8f, 0x2c,
-	0xe5, 0xc5, 0x14, 0x63, 0x99, 0xd2, 0x81, 0x45, 0x8c, 0x2b, 0x72, 0xa1, 0xfc, 0x8c, 0x86, 0x3e,
-	0xf7, 0xa7, 0xba, 0x2d, 0x0e, 0x2c, 0x92, 0x28, 0xf6, 0x2b, 0xe0, 0x84, 0x2c, 0x9a, 0x7b, 0x31,
-	0x9e, 0xc2, 0xa6, 0x01, 0x48, 0xa6, 0x6a, 0x61, 0xa0, 0xed, 0xa5, 0x81, 0x5e, 0x98, 0x9e, 0xc2,
-	0x19, 0xa6, 0x07, 0x5f, 0x81, 0x73, 0x69, 0x20, 0x43, 0x2b, 0xd9, 0x46, 0x3b, 0xb7, 0x8d, 0xb7,
-	0xe0, 0xbc, 0x82, 0x79, 0x48, 0x67, 0xd9, 0xa0, 0xff, 0xc3, 0x63, 0x03, 0xdf, 0x03, 0x94, 0x5f,
-	0x6c, 0xc2, 0x34, 0x60, 0x4d, 0x0e, 0x84, 0xde, 0xe4, 0x2a, 0xd1, 0x02, 0x72, 0xa1, 0x62, 0xaa,
-	0xa1, 0x89, 0x54, 0x49, 0x2a, 0x63, 0x62, 0x70, 0xbe, 0x90, 0x23, 0x93, 0xcf, 0x42, 0xed, 0xa9,
-	0xca, 0xa2, 0x4a, 0xb4, 0x90, 0xe5, 0x56, 0x38, 0x25, 0xb7, 0x62, 0x96, 0x5b, 0x1f, 0x2e, 0x2c,
-	0x60, 0x9a, 0xe4, 0xb6, 0xc0, 0x51, 0x83, 0x99, 0x64, 0x67, 0xa4, 0xbf, 0x4d, 0xef, 0x0a, 0x5c,
-	0x38, 0x3c, 0x99, 0x0d, 0x85, 0xd7, 0x7b, 0xce, 0xa3, 0x38, 0xcd, 0x6f, 0x13, 0x0a, 0x7c, 0x6c,
-	0x92, 0x2b, 0xf0, 0x31, 0xee, 0x40, 0x63, 0xd1, 0x2d, 0x0b, 0xc9, 0x94, 0x46, 0xf9, 0x56, 0x88,
-	0x91, 0xb0, 0x48, 0x60, 0x3f, 0x0f, 0x3c, 0x41, 0xc7, 0x09, 0x6c, 0x17, 0x4a, 0xdc, 0x9f, 0x88,
-	0x95, 0xd6, 0xcb, 0xfb, 0xf6, 0xfd, 0x89, 0x38, 0xb0, 0x88, 0x72, 0x44, 0x3b, 0x00, 0xaa, 0x93,
-	0x07, 0xd9, 0x8c, 0x1e, 0x58, 0xa4, 0xaa, 0x74, 0x77, 0x69, 0x4c, 0xf7, 0x1d, 0xbd, 0xef, 0x18,
-	0x43, 0x7d, 0x19, 0x64, 0x85, 0xc4, 0xcd, 0x84, 0x44, 0x92, 0x94, 0x21, 0xb1, 0xe4, 0x27, 0x7b,
-	0x29, 0xe2, 0x2f, 0xf4, 0x09, 0x54, 0x22, 0xea, 0x1b, 0x7f, 0x92, 0xe0, 0xf3, 0x17, 0x69, 0x77,
-	0x7f, 0x28, 0xfb, 0x37, 0x08, 0x54, 0x5d, 0xf5, 0xd0, 0xd7, 0x53, 0x46, 0x0f, 0xb4, 0x81, 0xa4,
-	0x1e, 0xf8, 0x0e, 0x9c, 0xcf, 0x21, 0x98, 0xd0, 0x67, 0x83, 0xf8, 0xc5, 0x86, 0xb2, 0xd1, 0xca,
-	0xa3, 0x72, 0x38, 0xe7, 0xde, 0x78, 0x90, 0xa6, 0x5e, 0x56, 0x72, 0x7f, 0x8c, 0xde, 0x83, 0xf5,
-	0x19, 0x9b, 0x89, 0xf0, 0x64, 0x90, 0x75, 0x53, 0x89, 0xd4, 0xb4, 0xee, 0x50, 0xf5, 0x54, 0xe6,
-	0xe2, 0xf1, 0x19, 0x8f, 0x55, 0x73, 0xa5, 0x2e, 0xf7, 0xa5, 0x4a, 0x5e, 0x51, 0x72, 0xc2, 0x06,
-	0x62, 0x32, 0x89, 0x58, 0xac, 0xae, 0xa8, 0x12, 0x01, 0xa9, 0x7a, 0xa4, 0x34, 0xa8, 0x0b, 0x55,
-	0x4f, 0x8c, 0x68, 0xcc, 0x85, 0x2f, 0xef, 0x27, 0x99, 0xfc, 0xf9, 0x6c, 0x7e, 0x8d, 0x85, 0x64,
-	0x3e, 0xb8, 0x0f, 0x95, 0x44, 0x8d, 0x9a, 0x50, 0xa6, 0xe3, 0x71, 0xc8, 0x22, 0xdd, 0x39, 0x25,
-	0x92, 0x88, 0xe8, 0x7d, 0x58, 0xf3, 0xb8, 0x9f, 0xbe, 0x29, 0x36, 0x32, 0x48, 0xee, 0x33, 0xa2,
-	0x6d, 0xb8, 0x0f, 0x25, 0x29, 0xca, 0xad, 0x92, 0x0a, 0x33, 0xcc, 0xea, 0x1b, 0x5d, 0x85, 0x8a,
-	0xbc, 0xe4, 0x65, 0x18, 0x45, 0x3d, 0x9f, 0xd6, 0x3d, 0x63, 0x20, 0xa9, 0x0b, 0xbe, 0x09, 0x95,
-	0x44, 0x9b, 0xde, 0x7d, 0x76, 0xee, 0xee, 0x73, 0xa1, 0x22, 0x49, 0xe7, 0xee, 0xc4, 0x54, 0xde,
-	0x7b, 0x0c, 0x0d, 0xf9, 0xc6, 0xa3, 0x43, 0x8f, 0x25, 0x47, 0xa7, 0x3c, 0xbe, 0xd1, 0x47, 0xb0,
-	0xa6, 0xde, 0x7e, 0x28, 0x3b, 0xd0, 0xf2, 0x6f, 0x41, 0x77, 0x6b, 0x59, 0x6d, 0xde, 0x8c, 0xd6,
-	0xde, 0x1f, 0x36, 0xd4, 0x74, 0x9b, 0x68, 0xa4, 0xcf, 0xc0, 0xd1, 0x23, 0x87, 0x2e, 0x2d, 0x4d,
-	0xcb, 0xc2, 0xc0, 0xba, 0x97, 0xff, 0xc2, 0x9a, 0x00, 0xa3, 0x3e, 0x38, 0xba, 0xed, 0x57, 0x80,
-	0x16, 0x46, 0x74, 0x05, 0x68, 0x71, 0x56, 0xb0, 0xd5, 0xb6, 0xd1, 0x5d, 0xa8, 0xa6, 0x9d, 0x8c,
-	0x96, 0x87, 0x38, 0x9b, 0x0f, 0xd7, 0x3d, 0xcd, 0x94, 0x32, 0xfd, 0xb1, 0x00, 0x0d, 0xc2, 0xe8,
-	0x78, 0xa5, 0x78, 0xb7, 0xc0, 0x49, 0x9e, 0xbb, 0xb9, 0xe7, 0x4e, 0xee, 0xb1, 0xe6, 0x6e, 0xaf,
-	0xe8, 0x35, 0xea, 0x35, 0x1b, 0xdd, 0x86, 0xb2, 0x01, 0x43, 0xdb, 0xcb, 0x2f, 0xeb, 0x64, 0x79,
-	0x73, 0xd5, 0x60, 0xc6, 0xb1, 0x07, 0x90, 0x1d, 0xfa, 0x68, 0xe9, 0xad, 0x94, 0xbf, 0x46, 0xdc,
-	0xff, 0x9f, 0x6a, 0x33, 0x30, 0x07, 0x50, 0xcb, 0x9d, 0xcf, 0x68, 0xc9, 0x77, 0xe1, 0x26, 0x70,
-	0x2f, 0x9d, 0x6e, 0xd4, 0x48, 0xfb, 0x57, 0x5e, 0xfe, 0xde, 0xb2, 0x5e, 0xbe, 0x6d, 0xd9, 0xaf,
-	0xde, 0xb6, 0xec, 0xdf, 0xde, 0xb6, 0xec, 0xef, 0xdf, 0xb5, 0xac, 0x57, 0xef, 0x5a, 0xd6, 0xeb,
-	0x77, 0x2d, 0xeb, 0xeb, 0xb2, 0xf9, 0xef, 0x33, 0x74, 0xd4, 0x7f, 0x90, 0xeb, 0x7f, 0x06, 0x00,
-	0x00, 0xff, 0xff, 0x25, 0xea, 0x68, 0x42, 0x13, 0x0d, 0x00, 0x00,
+	// 1617 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xc4, 0x58, 0x4b, 0x6f, 0xdb, 0xc6,
+	0x16, 0x16, 0x25, 0x9a, 0x92, 0x8e, 0x2c, 0x5b, 0x99, 0xe8, 0xda, 0x34, 0x13, 0x3f, 0xee, 0x5c,
+	0x18, 0x30, 0x72, 0x13, 0x29, 0xd7, 0x59, 0xe4, 0x36, 0x09, 0x8a, 0xc6, 0xb1, 0x53, 0x1b, 0xcd,
+	0x73, 0xec, 0xa6, 0x45, 0x37, 0x02, 0x25, 0x8d, 0x65, 0xd6, 0x14, 0xc9, 0x92, 0xa3, 0x3a, 0xce,
+	0xba, 0x40, 0xb7, 0x45, 0x7f, 0x43, 0x17, 0xfd, 0x23, 0x05, 0xb2, 0xcc, 0xb2, 0xe8, 0x22, 0x68,
+	0x93, 0x4d, 0xfb, 0x0b, 0xba, 0x6c, 0x31, 0x0f, 0xbe, 0x24, 0xb9, 0x68, 0x8a, 0x3e, 0x36, 0xc2,
+	0x9c, 0xc7, 0x7c, 0x73, 0xce, 0x99, 0x73, 0xce, 0x1c, 0x0a, 0x16, 0x23, 0xe6, 0x87, 0xb4, 0x2d,
+	0x7e, 0x83, 0x6e, 0x3b, 0x0c, 0x7a, 0xad, 0x20, 0xf4, 0x99, 0x8f, 0xca, 0x3d, 0xdf, 0x0b, 0x42,
+	0xff, 0xd0, 0x6a, 0x0e, 0xfc, 0x81, 0x2f, 0x78, 0x6d, 0xbe, 0x92, 0x62, 0x6b, 0x69, 0xe0, 0xfb,
+	0x03, 0x97, 0xb6, 0x05, 0xd5, 0x1d, 0x1d, 0xb6, 0x6d, 0xef, 0x54, 0x89, 0xde, 0x1a, 0x38, 0xec,
+	0x68, 0xd4, 0x6d, 0xf5, 0xfc, 0x61, 0x9b, 0x1d, 0xd9, 0x9e, 0x1f, 0x5d, 0x71, 0x7c, 0xb5, 0x6a,
+	0x07, 0xc7, 0x03, 0x79, 0x58, 0xdb, 0xb5, 0xbb, 0xd4, 0x0d, 0xba, 0x6d, 0x76, 0x1a, 0xd0, 0x48,
+	0x6e, 0xc5, 0xf3, 0x50, 0xff, 0x20, 0x74, 0x18, 0x25, 0x34, 0x0a, 0x7c, 0x2f, 0xa2, 0xf8, 0x63,
+	0x98, 0x55, 0x8c, 0x4f, 0x46, 0x34, 0x62, 0x68, 0x0b, 0xea, 0xdc, 0x28, 0xc7, 0xa5, 0xfb, 0x34,
+	0x74, 0x68, 0x64, 0x6a, 0x6b, 0xa5, 0x8d, 0xda, 0xe6, 0x42, 0x4b, 0x59, 0xdb, 0x7a, 0x94, 0x95,
+	0x6e, 0xe9, 0xcf, 0x5f, 0xae, 0x16, 0x48, 0x7e, 0x0b, 0x5a, 0x00, 0x83, 0x51, 0xcf, 0xf6, 0x98,
+	0x59, 0x5c, 0xd3, 0x36, 0xaa, 0x44, 0x51, 0xf8, 0x2b, 0x0d, 0xea, 0xb9, 0xed, 0xa8, 0x0b, 0x86,
+	0xb0, 0x32, 0x3e, 0xa6, 0xde, 0x92, 0x5e, 0xb4, 0xee, 0x71, 0xee, 0xd6, 0x4d, 0x8e, 0xfe, 0xdd,
+	0xcb, 0xd5, 0x6b, 0x6f, 0xe4, 0xb0, 0xdc, 0x4c, 0x14, 0x32, 0x6a, 0x43, 0x39, 0xb2, 0x87, 0x81,
+	0x4b, 0x23, 0xb3, 0x28, 0x0e, 0x99, 0x4f, 0x7c, 0xd9, 0x17, 0x7c, 0xe5, 0x44, 0xac, 0x85, 0x6f,
+	0x81, 0x21, 0x05, 0xa8, 0x09, 0x33, 0x9f, 0xda, 0xee, 0x88, 0x9a, 0xda, 0x9a, 0xb6, 0x31, 0x4b,
+	0x24, 0x81, 0x2e, 0x42, 0x95, 0x39, 0x43, 0x1a, 0x31, 0x7b, 0x18, 0x08, 0x0f, 0x4b, 0x24, 0x65,
+	0xe0, 0x3d, 0xa8, 0xed, 0x53, 0x97, 0xf6, 0xd8, 0xae, 0xe3, 0xb1, 0x88, 0x43, 0x44, 0xcc, 0x0e,
+	0x99, 0x80, 0x28, 0x11, 0x49, 0xa0, 0x06, 0x94, 0xa8, 0xd7, 0x57, 0x9b, 0xf9, 0x12, 0x21, 0xd0,
+	0x0f, 0x47, 0x5e, 0xcf, 0x2c, 0x89, 0x88, 0x89, 0x35, 0xfe, 0x45, 0x83, 0xba, 0x0c, 0x54, 0x7c,
+	0x3b, 0x4b, 0x50, 0x19, 0x3a, 0x5e, 0x87, 0x9f, 0xa6, 0x00, 0xcb, 0x43, 0xc7, 0x3b, 0x70, 0x86,
+	0x54, 0x88, 0xec, 0xa7, 0x52, 0x54, 0x54, 0x22, 0xfb, 0xa9, 0x10, 0x5d, 0xe7, 0x22, 0xd6, 0x3b,
+	0xa2, 0x61, 0x64, 0x96, 0x44, 0x08, 0xfe, 0x95, 0x84, 0x40, 0xc4, 0xea, 0xbe, 0x94, 0xaa, 0x40,
+	0x24, 0xca, 0x68, 0x15, 0x6a, 0xd1, 0xb1, 0x13, 0x74, 0x7a, 0x47, 0x23, 0xef, 0x38, 0x32, 0xf5,
+	0x35, 0x6d, 0xa3, 0x42, 0x80, 0xb3, 0xee, 0x08, 0x0e, 0xba, 0x0e, 0xb3, 0x91, 0x70, 0xb6, 0x73,
+	0xc4, 0xbd, 0x35, 0x67, 0xd6, 0xb4, 0x8d, 0xda, 0x66, 0x33, 0x0d, 0x70, 0x1a, 0x09, 0x52, 0x8b,
+	0x52, 0x02, 0xad, 0xc3, 0x9c, 0xca, 0x99, 0x18, 0xdc, 0x10, 0xe0, 0x71, 0x26, 0x49, 0x7c, 0xfc,
+	0xa5, 0x06, 0xb3, 0x59, 0x0b, 0x51, 0x0b, 0x74, 0x9e, 0xce, 0xc2, 0xf9, 0xb9, 0x4d, 0x6b, 0xaa,
+	0x1b, 0xad, 0x83, 0xd3, 0x80, 0x12, 0xa1, 0xc7, 0xc3, 0xea, 0xd9, 0x2a, 0x22, 0x55, 0x22, 0xd6,
+	0xe9, 0xad, 0xca, 0x58, 0x4b, 0x02, 0x6f, 0x80, 0xce, 0xf7, 0x21, 0x03, 0x8a, 0x3b, 0x8f, 0x1b,
+	0x05, 0x54, 0x86, 0xd2, 0x83, 0x9d, 0xc7, 0x0d, 0x8d, 0x33, 0xc8, 0x4e, 0xa3, 0x28, 0x18, 0x64,
+	0xa7, 0x51, 0xc2, 0x3d, 0xa8, 0xde, 0x1e, 0x0c, 0x42, 0x61, 0xe2, 0x1f, 0xbc, 0x91, 0x35, 0x28,
+	0x85, 0xf6, 0x89, 0x30, 0xa0, 0xb6, 0x39, 0x97, 0x78, 0x21, 0x20, 0x09, 0x17, 0x61, 0x06, 0x33,
+	0xf2, 0x80, 0xff, 0xe6, 0x3c, 0x5e, 0xcc, 0xeb, 0xb6, 0x76, 0xbc, 0x9e, 0xdf, 0x77, 0xbc, 0x41,
+	0xea, 0x6e, 0xdf, 0x66, 0xb6, 0x38, 0x6e, 0x96, 0x88, 0x35, 0xbe, 0x04, 0x95, 0x58, 0x8b, 0xfb,
+	0xf0, 0xe1, 0x43, 0xd2, 0x28, 0xa0, 0x0a, 0xe8, 0x0f, 0x7c, 0x8f, 0x36, 0x34, 0x54, 0x83, 0xb2,
+	0xaa, 0xc9, 0x46, 0x11, 0x7f, 0xad, 0x41, 0x83, 0xd8, 0x27, 0x7f, 0x7f, 0x91, 0x5e, 0x05, 0x43,
+	0xe5, 0x81, 0xac, 0x51, 0x94, 0xf8, 0x99, 0x84, 0x5a, 0x65, 0xa7, 0xd2, 0xc3, 0xc7, 0x30, 0x17,
+	0xd7, 0x86, 0x6c, 0x65, 0xe8, 0x1a, 0x18, 0x51, 0xdc, 0xb3, 0x78, 0x5c, 0x97, 0x12, 0x8c, 0x71,
+	0x97, 0x76, 0x0b, 0x44, 0xa9, 0x22, 0x0b, 0xca, 0x27, 0x76, 0xe8, 0x39, 0xde, 0x40, 0xe6, 0xc8,
+	0x6e, 0x81, 0xc4, 0x8c, 0xad, 0x0a, 0x18, 0x21, 0x8d, 0x46, 0x2e, 0xc3, 0x03, 0x98, 0x53, 0x00,
+	0x71, 0x25, 0xe6, 0x9a, 0x80, 0x36, 0xd6, 0x04, 0x72, 0x15, 0x57, 0x7c, 0x83, 0x8a, 0xc3, 0xeb,
+	0x30, 0x9f, 0x1c, 0xa4, 0xdc, 0x8a, 0xef, 0x54, 0xcb, 0xdc, 0xe9, 0x4d, 0x38, 0x27, 0x60, 0x1e,
+	0xd8, 0xc3, 0xb4, 0x39, 0xfc, 0xce, 0x56, 0x83, 0xef, 0x02, 0xca, 0x6e, 0x56, 0xc7, 0x34, 0x61,
+	0x86, 0x57, 0x87, 0xbc, 0xe4, 0x2a, 0x91, 0x04, 0xb2, 0xa0, 0xa2, 0xa2, 0x21, 0x1d, 0xa9, 0x92,
+	0x84, 0xc6, 0x44, 0xe1, 0x3c, 0xe1, 0xf5, 0x93, 0xb5, 0x42, 0xdc, 0xa9, 0xb0, 0xa2, 0x4a, 0x24,
+	0x91, 0xda, 0x56, 0x9c, 0x62, 0x5b, 0x29, 0xb5, 0x6d, 0x0f, 0xce, 0xe7, 0x30, 0x95, 0x71, 0x0b,
+	0x60, 0x88, 0x2a, 0x8d, 0xad, 0x53, 0xd4, 0x6f, 0x9a, 0xf7, 0x99, 0x06, 0xe7, 0xb7, 0xa9, 0x4b,
+	0x19, 0xfd, 0x27, 0x7b, 0x28, 0x5e, 0x80, 0x66, 0xde, 0x0a, 0xf5, 0xf0, 0x9a, 0xb0, 0x70, 0xc7,
+	0xa5, 0xb6, 0x77, 0xe0, 0x0f, 0xbb, 0x11, 0xf3, 0xbd, 0xc4, 0x40, 0xbc, 0x04, 0x8b, 0x13, 0x12,
+	0xb5, 0x69, 0x1d, 0xce, 0xef, 0x9f, 0x0e, 0xbb, 0xbe, 0xbb, 0xf3, 0xd4, 0x89, 0x58, 0xe2, 0xd2,
+	0x1c, 0x14, 0x9d, 0xbe, 0x0a, 0x78, 0xd1, 0xe9, 0xe3, 0x16, 0x34, 0xf3, 0x6a, 0x69, 0x18, 0xa9,
+	0xe0, 0x08, 0xdd, 0x0a, 0x51, 0x14, 0xf6, 0x63, 0xd8, 0xf7, 0x03, 0xd7, 0xb7, 0xfb, 0x31, 0x6c,
+	0x1b, 0x74, 0xc7, 0x3b, 0xf4, 0x27, 0xca, 0x29, 0xab, 0xbb, 0xe7, 0x1d, 0xfa, 0xbb, 0x05, 0x22,
+	0x14, 0xd1, 0x2a, 0x80, 0xa8, 0xce, 0x4e, 0xda, 0x84, 0x76, 0x0b, 0xa4, 0x2a, 0x78, 0xdb, 0x36,
+	0xb3, 0xb7, 0x0c, 0x99, 0xcb, 0xf8, 0x73, 0x0d, 0x1a, 0xe3, 0x28, 0xe3, 0x5e, 0xa0, 0x0b, 0x50,
+	0x15, 0x0f, 0x44, 0xa6, 0x81, 0x57, 0x38, 0x83, 0xe7, 0x2d, 0x7f, 0x40, 0x8e, 0x69, 0xe8, 0x51,
+	0xb7, 0x13, 0x52, 0x97, 0xda, 0x51, 0xdc, 0xcd, 0xeb, 0x92, 0x4b, 0x24, 0x53, 0xbc, 0x60, 0xfe,
+	0x28, 0xec, 0xd1, 0x4e, 0x60, 0xb3, 0x23, 0xf1, 0x82, 0x55, 0x09, 0x48, 0xd6, 0x23, 0x9b, 0x1d,
+	0xe1, 0x1b, 0xd0, 0xcc, 0x1a, 0x92, 0x84, 0x6a, 0xdc, 0x18, 0x04, 0x7a, 0xe4, 0x3c, 0x93, 0x76,
+	0xe8, 0x44, 0xac, 0xf1, 0x3b, 0xb1, 0x13, 0xce, 0xb3, 0xa4, 0x2f, 0x5c, 0xe6, 0x79, 0x12, 0x04,
+	0x22, 0x23, 0x65, 0xbb, 0x6c, 0x24, 0x71, 0xbb, 0x2f, 0x05, 0x24, 0xd1, 0xc0, 0xb7, 0xe1, 0x5c,
+	0x06, 0x41, 0x1d, 0xfd, 0x66, 0x10, 0x3f, 0x6a, 0x50, 0x56, 0x5c, 0x9e, 0xbf, 0xdd, 0x91, 0xe3,
+	0xf6, 0x3b, 0x89, 0xe9, 0x65, 0x41, 0xef, 0xf5, 0xd1, 0xbf, 0x61, 0x76, 0x48, 0x87, 0x7e, 0x78,
+	0xda, 0x49, 0xeb, 0x50, 0x27, 0x35, 0xc9, 0xdb, 0xe7, 0xac, 0x8c, 0x8a, 0xeb, 0x0c, 0x1d, 0x66,
+	0x96, 0xb2, 0x2a, 0xf7, 0x38, 0x8b, 0x87, 0x53, 0x5c, 0x89, 0x7f, 0x78, 0x18, 0x51, 0x26, 0xc2,
+	0xa9, 0x13, 0xe0, 0xac, 0x87, 0x82, 0x83, 0xda, 0x50, 0x75, 0xfd, 0x9e, 0xcd, 0x1c, 0xdf, 0xe3,
+	0xd3, 0x00, 0x37, 0xfe, 0x5c, 0x5a, 0x27, 0x4a, 0x42, 0x52, 0x1d, 0x31, 0xf7, 0x38, 0x2e, 0x35,
+	0x0d, 0x35, 0xf7, 0x38, 0xae, 0x48, 0x53, 0x79, 0x8b, 0x66, 0x59, 0xa6, 0xa9, 0xa4, 0xf0, 0x1e,
+	0x54, 0x62, 0x08, 0x64, 0x42, 0xd9, 0xee, 0xf7, 0x43, 0x1a, 0xc9, 0x5c, 0xd6, 0x49, 0x4c, 0xa2,
+	0xff, 0xc0, 0x8c, 0xeb, 0x78, 0xc9, 0xb4, 0x57, 0x4f, 0x8f, 0x77, 0x3c, 0x4a, 0xa4, 0x0c, 0xef,
+	0x81, 0xce, 0x49, 0x7e, 0x3c, 0x67, 0xa8, 0x46, 0x20, 0xd6, 0xe8, 0x0a, 0x54, 0xf8, 0xf8, 0xc5,
+	0x8f, 0x11, 0x61, 0xca, 0xba, 0x70, 0x57, 0x09, 0x48, 0xa2, 0x82, 0x6f, 0x40, 0x25, 0xe6, 0x26,
+	0xe3, 0x86, 0x96, 0x19, 0x37, 0x2c, 0x10, 0x59, 0x3b, 0x9e, 0xc5, 0x9c, 0xc6, 0x6f, 0x43, 0x7d,
+	0x5f, 0xe4, 0x62, 0xa6, 0x39, 0x9d, 0x75, 0x83, 0x08, 0x74, 0x91, 0xc3, 0x6a, 0x94, 0xe1, 0x6b,
+	0x7c, 0x09, 0xe6, 0xe2, 0xfd, 0x2a, 0x79, 0x4c, 0xe0, 0xdf, 0x15, 0x8c, 0x7a, 0x4c, 0x3d, 0x18,
+	0x31, 0x89, 0x2f, 0xc3, 0xfc, 0xb6, 0x7f, 0xe2, 0x65, 0x0b, 0xfc, 0xec, 0xd3, 0xf0, 0xff, 0xa0,
+	0x91, 0x6a, 0x2b, 0xec, 0xe5, 0x5c, 0x79, 0x4b, 0xf8, 0xb4, 0xb8, 0x37, 0x7f, 0xd6, 0xa0, 0xc9,
+	0xbf, 0x25, 0xec, 0xae, 0x4b, 0xe3, 0xe7, 0x96, 0x3f, 0xf9, 0xe8, 0xff, 0x30, 0xc3, 0xf9, 0x14,
+	0xa5, 0x2d, 0x33, 0xfb, 0xcd, 0x61, 0x2d, 0x8c, 0xb3, 0x55, 0xb7, 0x2b, 0xa0, 0xfb, 0x30, 0x9b,
+	0x6d, 0x9e, 0xe8, 0x62, 0xa2, 0x39, 0xa5, 0xb3, 0x5b, 0xcb, 0x67, 0x48, 0x13, 0xb8, 0x27, 0x30,
+	0x3f, 0xd6, 0x59, 0xd1, 0x6a, 0x3a, 0x50, 0x4d, 0xed, 0xc6, 0xd6, 0xda, 0xd9, 0x0a, 0x31, 0xee,
+	0xe6, 0x4f, 0x45, 0xa8, 0xc9, 0x3a, 0x96, 0x0e, 0xbf, 0x0b, 0x86, 0xec, 0xbc, 0x19, 0x83, 0xa7,
+	0xf4, 0x6d, 0x6b, 0xf9, 0x0c, 0x69, 0x62, 0xf0, 0x1e, 0x18, 0xb2, 0x2f, 0x4d, 0x00, 0xe5, 0x3a,
+	0xb5, 0xb5, 0x7c, 0x86, 0x34, 0x06, 0xda, 0xd0, 0xd0, 0x36, 0x54, 0x93, 0x56, 0x83, 0xc6, 0x7b,
+	0x79, 0xda, 0xc0, 0x2c, 0x6b, 0x9a, 0x28, 0x31, 0xe8, 0x26, 0x18, 0x32, 0xe1, 0x50, 0x7a, 0x69,
+	0xb9, 0x0c, 0xb6, 0x16, 0x27, 0xf8, 0xc9, 0xe6, 0x3b, 0x50, 0x89, 0x73, 0x0a, 0x99, 0xe9, 0x5d,
+	0xe5, 0x93, 0xd2, 0x5a, 0x9a, 0x22, 0x89, 0x21, 0xae, 0x6a, 0x9b, 0xdf, 0x94, 0xa0, 0x49, 0xa8,
+	0xdd, 0x9f, 0xc8, 0x32, 0x6e, 0x9a, 0xfa, 0xfe, 0x4c, 0x4d, 0xc8, 0xe5, 0xc7, 0xe2, 0x04, 0x5f,
+	0xe2, 0x5e, 0xd5, 0xd0, 0xad, 0x64, 0x0a, 0x46, 0x8b, 0xe3, 0x9f, 0xba, 0xf1, 0x76, 0x73, 0x52,
+	0xa0, 0x0a, 0x63, 0x07, 0x20, 0x9d, 0xa8, 0xd0, 0xd8, 0x57, 0x49, 0x76, 0x46, 0xb3, 0x2e, 0x4c,
+	0x95, 0x29, 0x98, 0x5d, 0xa8, 0x65, 0x86, 0x1f, 0x34, 0xa6, 0x9b, 0x1b, 0xb3, 0xac, 0x8b, 0xd3,
+	0x85, 0x0a, 0xe9, 0xbd, 0x3f, 0xb1, 0x6e, 0xd0, 0xc1, 0x5f, 0x51, 0x35, 0x5b, 0xeb, 0xcf, 0x7f,
+	0x58, 0x29, 0x3c, 0x7f, 0xb5, 0xa2, 0xbd, 0x78, 0xb5, 0xa2, 0x7d, 0xff, 0x6a, 0x45, 0xfb, 0xe2,
+	0xf5, 0x4a, 0xe1, 0xc5, 0xeb, 0x95, 0xc2, 0xb7, 0xaf, 0x57, 0x0a, 0x1f, 0x95, 0xd5, 0xff, 0x25,
+	0x5d, 0x43, 0xfc, 0x6f, 0x71, 0xed, 0xd7, 0x01, 0x00, 0x6d, 0xfc, 0xb7, 0x6c, 0x47, 0x11, 0x00,
+	0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -1385,6 +1730,12 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type WritableProfileStoreClient interface {
 	Write(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*WriteResponse, error)
+	/// DeleteSeries deletes the profiles of the series matching the matchers in the time range, by writing tombstones.
+	/// It is only served when the admin API is enabled.
+	DeleteSeries(ctx context.Context, in *DeleteSeriesRequest, opts ...grpc.CallOption) (*DeleteSeriesResponse, error)
+	/// CleanTombstones rewrites the blocks with tombstones, removing the deleted profiles from disk.
+	/// It is only served when the admin API is enabled.
+	CleanTombstones(ctx context.Context, in *CleanTombstonesRequest, opts ...grpc.CallOption) (*CleanTombstonesResponse, error)
 }
 
 type writableProfileStoreClient struct {
@@ -1404,9 +1755,33 @@ func (c *writableProfileStoreClient) Write(ctx context.Context, in *WriteRequest
 	return out, nil
 }
 
+func (c *writableProfileStoreClient) DeleteSeries(ctx context.Context, in *DeleteSeriesRequest, opts ...grpc.CallOption) (*DeleteSeriesResponse, error) {
+	out := new(DeleteSeriesResponse)
+	err := c.cc.Invoke(ctx, "/conprof.WritableProfileStore/DeleteSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *writableProfileStoreClient) CleanTombstones(ctx context.Context, in *CleanTombstonesRequest, opts ...grpc.CallOption) (*CleanTombstonesResponse, error) {
+	out := new(CleanTombstonesResponse)
+	err := c.cc.Invoke(ctx, "/conprof.WritableProfileStore/CleanTombstones", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WritableProfileStoreServer is the server API for WritableProfileStore service.
 type WritableProfileStoreServer interface {
 	Write(context.Context, *WriteRequest) (*WriteResponse, error)
+	/// DeleteSeries deletes the profiles of the series matching the matchers in the time range, by writing tombstones.
+	/// It is only served when the admin API is enabled.
+	DeleteSeries(context.Context, *DeleteSeriesRequest) (*DeleteSeriesResponse, error)
+	/// CleanTombstones rewrites the blocks with tombstones, removing the deleted profiles from disk.
+	/// It is only served when the admin API is enabled.
+	CleanTombstones(context.Context, *CleanTombstonesRequest) (*CleanTombstonesResponse, error)
 }
 
 // UnimplementedWritableProfileStoreServer can be embedded to have forward compatible implementations.
@@ -1416,6 +1791,12 @@ type UnimplementedWritableProfileStoreServer struct {
 func (*UnimplementedWritableProfileStoreServer) Write(ctx context.Context, req *WriteRequest) (*WriteResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Write not implemented")
 }
+func (*UnimplementedWritableProfileStoreServer) DeleteSeries(ctx context.Context, req *DeleteSeriesRequest) (*DeleteSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSeries not implemented")
+}
+func (*UnimplementedWritableProfileStoreServer) CleanTombstones(ctx context.Context, req *CleanTombstonesRequest) (*CleanTombstonesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CleanTombstones not implemented")
+}
 
 func RegisterWritableProfileStoreServer(s *grpc.Server, srv WritableProfileStoreServer) {
 	s.RegisterService(&_WritableProfileStore_serviceDesc, srv)
@@ -1439,6 +1820,42 @@ func _WritableProfileStore_Write_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WritableProfileStore_DeleteSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WritableProfileStoreServer).DeleteSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/conprof.WritableProfileStore/DeleteSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WritableProfileStoreServer).DeleteSeries(ctx, req.(*DeleteSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WritableProfileStore_CleanTombstones_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CleanTombstonesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WritableProfileStoreServer).CleanTombstones(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/conprof.WritableProfileStore/CleanTombstones",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WritableProfileStoreServer).CleanTombstones(ctx, req.(*CleanTombstonesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _WritableProfileStore_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "conprof.WritableProfileStore",
 	HandlerType: (*WritableProfileStoreServer)(nil),
@@ -1447,6 +1864,14 @@ var _WritableProfileStore_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Write",
 			Handler:    _WritableProfileStore_Write_Handler,
 		},
+		{
+			MethodName: "DeleteSeries",
+			Handler:    _WritableProfileStore_DeleteSeries_Handler,
+		},
+		{
+			MethodName: "CleanTombstones",
+			Handler:    _WritableProfileStore_CleanTombstones_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "store/storepb/rpc.proto",
@@ -1459,6 +1884,8 @@ type SymbolStoreClient interface {
 	Exists(ctx context.Context, in *SymbolExistsRequest, opts ...grpc.CallOption) (*SymbolExistsResponse, error)
 	Upload(ctx context.Context, opts ...grpc.CallOption) (SymbolStore_UploadClient, error)
 	Symbolize(ctx context.Context, in *SymbolizeRequest, opts ...grpc.CallOption) (*SymbolizeResponse, error)
+	Source(ctx context.Context, in *SourceRequest, opts ...grpc.CallOption) (*SourceResponse, error)
+	Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (SymbolStore_DownloadClient, error)
 }
 
 type symbolStoreClient struct {
@@ -1521,18 +1948,61 @@ func (c *symbolStoreClient) Symbolize(ctx context.Context, in *SymbolizeRequest,
 	return out, nil
 }
 
-// SymbolStoreServer is the server API for SymbolStore service.
-type SymbolStoreServer interface {
-	Exists(context.Context, *SymbolExistsRequest) (*SymbolExistsResponse, error)
-	Upload(SymbolStore_UploadServer) error
-	Symbolize(context.Context, *SymbolizeRequest) (*SymbolizeResponse, error)
+func (c *symbolStoreClient) Source(ctx context.Context, in *SourceRequest, opts ...grpc.CallOption) (*SourceResponse, error) {
+	out := new(SourceResponse)
+	err := c.cc.Invoke(ctx, "/conprof.SymbolStore/Source", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-// UnimplementedSymbolStoreServer can be embedded to have forward compatible implementations.
-type UnimplementedSymbolStoreServer struct {
+func (c *symbolStoreClient) Download(ctx context.Context, in *DownloadRequest, opts ...grpc.CallOption) (SymbolStore_DownloadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_SymbolStore_serviceDesc.Streams[1], "/conprof.SymbolStore/Download", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &symbolStoreDownloadClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
 }
 
-func (*UnimplementedSymbolStoreServer) Exists(ctx context.Context, req *SymbolExistsRequest) (*SymbolExistsResponse, error) {
+type SymbolStore_DownloadClient interface {
+	Recv() (*DownloadResponse, error)
+	grpc.ClientStream
+}
+
+type symbolStoreDownloadClient struct {
+	grpc.ClientStream
+}
+
+func (x *symbolStoreDownloadClient) Recv() (*DownloadResponse, error) {
+	m := new(DownloadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SymbolStoreServer is the server API for SymbolStore service.
+type SymbolStoreServer interface {
+	Exists(context.Context, *SymbolExistsRequest) (*SymbolExistsResponse, error)
+	Upload(SymbolStore_UploadServer) error
+	Symbolize(context.Context, *SymbolizeRequest) (*SymbolizeResponse, error)
+	Source(context.Context, *SourceRequest) (*SourceResponse, error)
+	Download(*DownloadRequest, SymbolStore_DownloadServer) error
+}
+
+// UnimplementedSymbolStoreServer can be embedded to have forward compatible implementations.
+type UnimplementedSymbolStoreServer struct {
+}
+
+func (*UnimplementedSymbolStoreServer) Exists(ctx context.Context, req *SymbolExistsRequest) (*SymbolExistsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Exists not implemented")
 }
 func (*UnimplementedSymbolStoreServer) Upload(srv SymbolStore_UploadServer) error {
@@ -1541,6 +2011,12 @@ func (*UnimplementedSymbolStoreServer) Upload(srv SymbolStore_UploadServer) erro
 func (*UnimplementedSymbolStoreServer) Symbolize(ctx context.Context, req *SymbolizeRequest) (*SymbolizeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Symbolize not implemented")
 }
+func (*UnimplementedSymbolStoreServer) Source(ctx context.Context, req *SourceRequest) (*SourceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Source not implemented")
+}
+func (*UnimplementedSymbolStoreServer) Download(req *DownloadRequest, srv SymbolStore_DownloadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Download not implemented")
+}
 
 func RegisterSymbolStoreServer(s *grpc.Server, srv SymbolStoreServer) {
 	s.RegisterService(&_SymbolStore_serviceDesc, srv)
@@ -1608,6 +2084,45 @@ func _SymbolStore_Symbolize_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _SymbolStore_Source_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SourceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SymbolStoreServer).Source(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/conprof.SymbolStore/Source",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SymbolStoreServer).Source(ctx, req.(*SourceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SymbolStore_Download_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SymbolStoreServer).Download(m, &symbolStoreDownloadServer{stream})
+}
+
+type SymbolStore_DownloadServer interface {
+	Send(*DownloadResponse) error
+	grpc.ServerStream
+}
+
+type symbolStoreDownloadServer struct {
+	grpc.ServerStream
+}
+
+func (x *symbolStoreDownloadServer) Send(m *DownloadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _SymbolStore_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "conprof.SymbolStore",
 	HandlerType: (*SymbolStoreServer)(nil),
@@ -1620,6 +2135,10 @@ var _SymbolStore_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Symbolize",
 			Handler:    _SymbolStore_Symbolize_Handler,
 		},
+		{
+			MethodName: "Source",
+			Handler:    _SymbolStore_Source_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -1627,6 +2146,11 @@ var _SymbolStore_serviceDesc = grpc.ServiceDesc{
 			Handler:       _SymbolStore_Upload_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Download",
+			Handler:       _SymbolStore_Download_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "store/storepb/rpc.proto",
 }
@@ -1651,6 +2175,12 @@ type ReadableProfileStoreClient interface {
 	LabelNames(ctx context.Context, in *LabelNamesRequest, opts ...grpc.CallOption) (*LabelNamesResponse, error)
 	/// LabelValues returns all label values for given label name.
 	LabelValues(ctx context.Context, in *LabelValuesRequest, opts ...grpc.CallOption) (*LabelValuesResponse, error)
+	/// DeleteSeries deletes the profiles of the series matching the matchers in the time range, by writing tombstones.
+	/// It is only served when the admin API is enabled.
+	DeleteSeries(ctx context.Context, in *DeleteSeriesRequest, opts ...grpc.CallOption) (*DeleteSeriesResponse, error)
+	/// CleanTombstones rewrites the blocks with tombstones, removing the deleted profiles from disk.
+	/// It is only served when the admin API is enabled.
+	CleanTombstones(ctx context.Context, in *CleanTombstonesRequest, opts ...grpc.CallOption) (*CleanTombstonesResponse, error)
 }
 
 type readableProfileStoreClient struct {
@@ -1720,6 +2250,24 @@ func (c *readableProfileStoreClient) LabelValues(ctx context.Context, in *LabelV
 	return out, nil
 }
 
+func (c *readableProfileStoreClient) DeleteSeries(ctx context.Context, in *DeleteSeriesRequest, opts ...grpc.CallOption) (*DeleteSeriesResponse, error) {
+	out := new(DeleteSeriesResponse)
+	err := c.cc.Invoke(ctx, "/conprof.ReadableProfileStore/DeleteSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *readableProfileStoreClient) CleanTombstones(ctx context.Context, in *CleanTombstonesRequest, opts ...grpc.CallOption) (*CleanTombstonesResponse, error) {
+	out := new(CleanTombstonesResponse)
+	err := c.cc.Invoke(ctx, "/conprof.ReadableProfileStore/CleanTombstones", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ReadableProfileStoreServer is the server API for ReadableProfileStore service.
 type ReadableProfileStoreServer interface {
 	/// Series streams each Series (Labels and chunk/downsampling chunk) for given label matchers and time range.
@@ -1738,6 +2286,12 @@ type ReadableProfileStoreServer interface {
 	LabelNames(context.Context, *LabelNamesRequest) (*LabelNamesResponse, error)
 	/// LabelValues returns all label values for given label name.
 	LabelValues(context.Context, *LabelValuesRequest) (*LabelValuesResponse, error)
+	/// DeleteSeries deletes the profiles of the series matching the matchers in the time range, by writing tombstones.
+	/// It is only served when the admin API is enabled.
+	DeleteSeries(context.Context, *DeleteSeriesRequest) (*DeleteSeriesResponse, error)
+	/// CleanTombstones rewrites the blocks with tombstones, removing the deleted profiles from disk.
+	/// It is only served when the admin API is enabled.
+	CleanTombstones(context.Context, *CleanTombstonesRequest) (*CleanTombstonesResponse, error)
 }
 
 // UnimplementedReadableProfileStoreServer can be embedded to have forward compatible implementations.
@@ -1756,6 +2310,12 @@ func (*UnimplementedReadableProfileStoreServer) LabelNames(ctx context.Context,
 func (*UnimplementedReadableProfileStoreServer) LabelValues(ctx context.Context, req *LabelValuesRequest) (*LabelValuesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method LabelValues not implemented")
 }
+func (*UnimplementedReadableProfileStoreServer) DeleteSeries(ctx context.Context, req *DeleteSeriesRequest) (*DeleteSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSeries not implemented")
+}
+func (*UnimplementedReadableProfileStoreServer) CleanTombstones(ctx context.Context, req *CleanTombstonesRequest) (*CleanTombstonesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CleanTombstones not implemented")
+}
 
 func RegisterReadableProfileStoreServer(s *grpc.Server, srv ReadableProfileStoreServer) {
 	s.RegisterService(&_ReadableProfileStore_serviceDesc, srv)
@@ -1836,6 +2396,42 @@ func _ReadableProfileStore_LabelValues_Handler(srv interface{}, ctx context.Cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ReadableProfileStore_DeleteSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReadableProfileStoreServer).DeleteSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/conprof.ReadableProfileStore/DeleteSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReadableProfileStoreServer).DeleteSeries(ctx, req.(*DeleteSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReadableProfileStore_CleanTombstones_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CleanTombstonesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReadableProfileStoreServer).CleanTombstones(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/conprof.ReadableProfileStore/CleanTombstones",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReadableProfileStoreServer).CleanTombstones(ctx, req.(*CleanTombstonesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _ReadableProfileStore_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "conprof.ReadableProfileStore",
 	HandlerType: (*ReadableProfileStoreServer)(nil),
@@ -1852,6 +2448,14 @@ var _ReadableProfileStore_serviceDesc = grpc.ServiceDesc{
 			MethodName: "LabelValues",
 			Handler:    _ReadableProfileStore_LabelValues_Handler,
 		},
+		{
+			MethodName: "DeleteSeries",
+			Handler:    _ReadableProfileStore_DeleteSeries_Handler,
+		},
+		{
+			MethodName: "CleanTombstones",
+			Handler:    _ReadableProfileStore_CleanTombstones_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -2076,6 +2680,16 @@ func (m *SeriesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.ProfileChunks {
+		i--
+		if m.ProfileChunks {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x30
+	}
 	if m.SelectHints != nil {
 		{
 			size, err := m.SelectHints.MarshalToSizedBuffer(dAtA[:i])
@@ -2592,6 +3206,122 @@ func (m *LabelValuesResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *DeleteSeriesRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DeleteSeriesRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DeleteSeriesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Matchers) > 0 {
+		for iNdEx := len(m.Matchers) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Matchers[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if m.MaxTime != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.MaxTime))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.MinTime != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.MinTime))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DeleteSeriesResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DeleteSeriesResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DeleteSeriesResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *CleanTombstonesRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CleanTombstonesRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CleanTombstonesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *CleanTombstonesResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *CleanTombstonesResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *CleanTombstonesResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
 func (m *SymbolExistsRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -2744,6 +3474,27 @@ func (m *SymbolUploadInfo) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.SourcePath) > 0 {
+		i -= len(m.SourcePath)
+		copy(dAtA[i:], m.SourcePath)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.SourcePath)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.KernelRelease) > 0 {
+		i -= len(m.KernelRelease)
+		copy(dAtA[i:], m.KernelRelease)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.KernelRelease)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.FileName) > 0 {
+		i -= len(m.FileName)
+		copy(dAtA[i:], m.FileName)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.FileName)))
+		i--
+		dAtA[i] = 0x12
+	}
 	if len(m.Id) > 0 {
 		i -= len(m.Id)
 		copy(dAtA[i:], m.Id)
@@ -2883,6 +3634,23 @@ func (m *Mapping) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Kernel {
+		i--
+		if m.Kernel {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x38
+	}
+	if len(m.File) > 0 {
+		i -= len(m.File)
+		copy(dAtA[i:], m.File)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.File)))
+		i--
+		dAtA[i] = 0x32
+	}
 	if len(m.Locations) > 0 {
 		for iNdEx := len(m.Locations) - 1; iNdEx >= 0; iNdEx-- {
 			{
@@ -3041,27 +3809,154 @@ func (m *Function) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func encodeVarintRpc(dAtA []byte, offset int, v uint64) int {
-	offset -= sovRpc(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+func (m *SourceRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	dAtA[offset] = uint8(v)
-	return base
+	return dAtA[:n], nil
 }
-func (m *WriteResponse) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	return n
+
+func (m *SourceRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *WriteRequest) Size() (n int) {
+func (m *SourceRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Path) > 0 {
+		i -= len(m.Path)
+		copy(dAtA[i:], m.Path)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.Path)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.BuildId) > 0 {
+		i -= len(m.BuildId)
+		copy(dAtA[i:], m.BuildId)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.BuildId)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *SourceResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SourceResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SourceResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Content) > 0 {
+		i -= len(m.Content)
+		copy(dAtA[i:], m.Content)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.Content)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DownloadRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DownloadRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DownloadRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.BuildId) > 0 {
+		i -= len(m.BuildId)
+		copy(dAtA[i:], m.BuildId)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.BuildId)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DownloadResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DownloadResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DownloadResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.ChunkData) > 0 {
+		i -= len(m.ChunkData)
+		copy(dAtA[i:], m.ChunkData)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.ChunkData)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintRpc(dAtA []byte, offset int, v uint64) int {
+	offset -= sovRpc(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *WriteResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *WriteRequest) Size() (n int) {
 	if m == nil {
 		return 0
 	}
@@ -3161,6 +4056,9 @@ func (m *SeriesRequest) Size() (n int) {
 		l = m.SelectHints.Size()
 		n += 1 + l + sovRpc(uint64(l))
 	}
+	if m.ProfileChunks {
+		n += 2
+	}
 	return n
 }
 
@@ -3381,6 +4279,54 @@ func (m *LabelValuesResponse) Size() (n int) {
 	return n
 }
 
+func (m *DeleteSeriesRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.MinTime != 0 {
+		n += 1 + sovRpc(uint64(m.MinTime))
+	}
+	if m.MaxTime != 0 {
+		n += 1 + sovRpc(uint64(m.MaxTime))
+	}
+	if len(m.Matchers) > 0 {
+		for _, e := range m.Matchers {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *DeleteSeriesResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *CleanTombstonesRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *CleanTombstonesResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
 func (m *SymbolExistsRequest) Size() (n int) {
 	if m == nil {
 		return 0
@@ -3452,6 +4398,18 @@ func (m *SymbolUploadInfo) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovRpc(uint64(l))
 	}
+	l = len(m.FileName)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	l = len(m.KernelRelease)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	l = len(m.SourcePath)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
 	return n
 }
 
@@ -3526,6 +4484,13 @@ func (m *Mapping) Size() (n int) {
 			n += 1 + l + sovRpc(uint64(l))
 		}
 	}
+	l = len(m.File)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	if m.Kernel {
+		n += 2
+	}
 	return n
 }
 
@@ -3580,6 +4545,62 @@ func (m *Function) Size() (n int) {
 	return n
 }
 
+func (m *SourceRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.BuildId)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	l = len(m.Path)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	return n
+}
+
+func (m *SourceResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Content)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	return n
+}
+
+func (m *DownloadRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.BuildId)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	return n
+}
+
+func (m *DownloadResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.ChunkData)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	return n
+}
+
 func sovRpc(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -4250,6 +5271,26 @@ func (m *SeriesRequest) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProfileChunks", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ProfileChunks = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -5489,7 +6530,7 @@ func (m *LabelValuesResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SymbolExistsRequest) Unmarshal(dAtA []byte) error {
+func (m *DeleteSeriesRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5512,17 +6553,17 @@ func (m *SymbolExistsRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SymbolExistsRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: DeleteSeriesRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SymbolExistsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: DeleteSeriesRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MinTime", wireType)
 			}
-			var stringLen uint64
+			m.MinTime = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRpc
@@ -5532,23 +6573,63 @@ func (m *SymbolExistsRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				m.MinTime |= int64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthRpc
-			}
-			postIndex := iNdEx + intStringLen
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxTime", wireType)
+			}
+			m.MaxTime = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxTime |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Matchers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthRpc
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Id = string(dAtA[iNdEx:postIndex])
+			m.Matchers = append(m.Matchers, LabelMatcher{})
+			if err := m.Matchers[len(m.Matchers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -5571,7 +6652,7 @@ func (m *SymbolExistsRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SymbolExistsResponse) Unmarshal(dAtA []byte) error {
+func (m *DeleteSeriesResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5594,32 +6675,12 @@ func (m *SymbolExistsResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SymbolExistsResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: DeleteSeriesResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SymbolExistsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: DeleteSeriesResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
-		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Exists", wireType)
-			}
-			var v int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRpc
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				v |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			m.Exists = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -5641,7 +6702,7 @@ func (m *SymbolExistsResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SymbolUploadRequest) Unmarshal(dAtA []byte) error {
+func (m *CleanTombstonesRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5664,80 +6725,62 @@ func (m *SymbolUploadRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SymbolUploadRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: CleanTombstonesRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SymbolUploadRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: CleanTombstonesRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
-		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Info", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRpc
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if msglen < 0 {
-				return ErrInvalidLengthRpc
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthRpc
 			}
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			v := &SymbolUploadInfo{}
-			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
-			m.Data = &SymbolUploadRequest_Info{v}
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ChunkData", wireType)
-			}
-			var byteLen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRpc
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				byteLen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			if byteLen < 0 {
-				return ErrInvalidLengthRpc
-			}
-			postIndex := iNdEx + byteLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRpc
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *CleanTombstonesResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
 			}
-			if postIndex > l {
+			if iNdEx >= l {
 				return io.ErrUnexpectedEOF
 			}
-			v := make([]byte, postIndex-iNdEx)
-			copy(v, dAtA[iNdEx:postIndex])
-			m.Data = &SymbolUploadRequest_ChunkData{v}
-			iNdEx = postIndex
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: CleanTombstonesResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: CleanTombstonesResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -5759,7 +6802,7 @@ func (m *SymbolUploadRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SymbolUploadInfo) Unmarshal(dAtA []byte) error {
+func (m *SymbolExistsRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5782,10 +6825,10 @@ func (m *SymbolUploadInfo) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SymbolUploadInfo: wiretype end group for non-group")
+			return fmt.Errorf("proto: SymbolExistsRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SymbolUploadInfo: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SymbolExistsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -5841,7 +6884,7 @@ func (m *SymbolUploadInfo) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SymbolUploadResponse) Unmarshal(dAtA []byte) error {
+func (m *SymbolExistsResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5864,49 +6907,17 @@ func (m *SymbolUploadResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SymbolUploadResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: SymbolExistsResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SymbolUploadResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SymbolExistsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRpc
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthRpc
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRpc
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Id = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 2:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Size_", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Exists", wireType)
 			}
-			m.Size_ = 0
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRpc
@@ -5916,11 +6927,12 @@ func (m *SymbolUploadResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Size_ |= uint64(b&0x7F) << shift
+				v |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			m.Exists = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -5942,7 +6954,7 @@ func (m *SymbolUploadResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SymbolizeRequest) Unmarshal(dAtA []byte) error {
+func (m *SymbolUploadRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -5965,15 +6977,927 @@ func (m *SymbolizeRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SymbolizeRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: SymbolUploadRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SymbolizeRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SymbolUploadRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Mappings", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Info", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &SymbolUploadInfo{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Data = &SymbolUploadRequest_Info{v}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ChunkData", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := make([]byte, postIndex-iNdEx)
+			copy(v, dAtA[iNdEx:postIndex])
+			m.Data = &SymbolUploadRequest_ChunkData{v}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SymbolUploadInfo) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SymbolUploadInfo: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SymbolUploadInfo: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Id = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FileName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FileName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KernelRelease", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.KernelRelease = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SourcePath", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SourcePath = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SymbolUploadResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SymbolUploadResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SymbolUploadResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Id = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Size_", wireType)
+			}
+			m.Size_ = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Size_ |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SymbolizeRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SymbolizeRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SymbolizeRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Mappings", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Mappings = append(m.Mappings, &Mapping{})
+			if err := m.Mappings[len(m.Mappings)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SymbolizeResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SymbolizeResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SymbolizeResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Mappings", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Mappings = append(m.Mappings, &Mapping{})
+			if err := m.Mappings[len(m.Mappings)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Mapping) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Mapping: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Mapping: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BuildId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BuildId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MemoryStart", wireType)
+			}
+			m.MemoryStart = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MemoryStart |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MemoryLimit", wireType)
+			}
+			m.MemoryLimit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MemoryLimit |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FileOffset", wireType)
+			}
+			m.FileOffset = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FileOffset |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Locations", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Locations = append(m.Locations, &Location{})
+			if err := m.Locations[len(m.Locations)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field File", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.File = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Kernel", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Kernel = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Location) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Location: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Location: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Address", wireType)
+			}
+			m.Address = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Address |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Lines", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Lines = append(m.Lines, &Line{})
+			if err := m.Lines[len(m.Lines)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Line) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Line: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Line: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Line", wireType)
+			}
+			m.Line = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Line |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Function", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -6000,8 +7924,10 @@ func (m *SymbolizeRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Mappings = append(m.Mappings, &Mapping{})
-			if err := m.Mappings[len(m.Mappings)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if m.Function == nil {
+				m.Function = &Function{}
+			}
+			if err := m.Function.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
@@ -6026,7 +7952,7 @@ func (m *SymbolizeRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SymbolizeResponse) Unmarshal(dAtA []byte) error {
+func (m *Function) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -6049,17 +7975,17 @@ func (m *SymbolizeResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SymbolizeResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: Function: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SymbolizeResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Function: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Mappings", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRpc
@@ -6069,25 +7995,55 @@ func (m *SymbolizeResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthRpc
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthRpc
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Mappings = append(m.Mappings, &Mapping{})
-			if err := m.Mappings[len(m.Mappings)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.Name = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Filename", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
 			}
+			m.Filename = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -6110,7 +8066,7 @@ func (m *SymbolizeResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Mapping) Unmarshal(dAtA []byte) error {
+func (m *SourceRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -6133,10 +8089,10 @@ func (m *Mapping) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Mapping: wiretype end group for non-group")
+			return fmt.Errorf("proto: SourceRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Mapping: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SourceRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
@@ -6172,67 +8128,10 @@ func (m *Mapping) Unmarshal(dAtA []byte) error {
 			m.BuildId = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MemoryStart", wireType)
-			}
-			m.MemoryStart = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRpc
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.MemoryStart |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MemoryLimit", wireType)
-			}
-			m.MemoryLimit = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRpc
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.MemoryLimit |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 4:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field FileOffset", wireType)
-			}
-			m.FileOffset = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRpc
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.FileOffset |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 5:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Locations", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Path", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRpc
@@ -6242,25 +8141,23 @@ func (m *Mapping) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthRpc
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthRpc
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Locations = append(m.Locations, &Location{})
-			if err := m.Locations[len(m.Locations)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.Path = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -6283,7 +8180,7 @@ func (m *Mapping) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Location) Unmarshal(dAtA []byte) error {
+func (m *SourceResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -6306,36 +8203,17 @@ func (m *Location) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Location: wiretype end group for non-group")
+			return fmt.Errorf("proto: SourceResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Location: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SourceResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Address", wireType)
-			}
-			m.Address = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRpc
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Address |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Lines", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Content", wireType)
 			}
-			var msglen int
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRpc
@@ -6345,24 +8223,24 @@ func (m *Location) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthRpc
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthRpc
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Lines = append(m.Lines, &Line{})
-			if err := m.Lines[len(m.Lines)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			m.Content = append(m.Content[:0], dAtA[iNdEx:postIndex]...)
+			if m.Content == nil {
+				m.Content = []byte{}
 			}
 			iNdEx = postIndex
 		default:
@@ -6386,7 +8264,7 @@ func (m *Location) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Line) Unmarshal(dAtA []byte) error {
+func (m *DownloadRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -6409,36 +8287,17 @@ func (m *Line) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Line: wiretype end group for non-group")
+			return fmt.Errorf("proto: DownloadRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Line: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: DownloadRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Line", wireType)
-			}
-			m.Line = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRpc
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Line |= int64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Function", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field BuildId", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRpc
@@ -6448,27 +8307,23 @@ func (m *Line) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthRpc
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthRpc
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Function == nil {
-				m.Function = &Function{}
-			}
-			if err := m.Function.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.BuildId = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -6491,7 +8346,7 @@ func (m *Line) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Function) Unmarshal(dAtA []byte) error {
+func (m *DownloadResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -6514,17 +8369,17 @@ func (m *Function) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Function: wiretype end group for non-group")
+			return fmt.Errorf("proto: DownloadResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Function: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: DownloadResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Name", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ChunkData", wireType)
 			}
-			var stringLen uint64
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRpc
@@ -6534,55 +8389,25 @@ func (m *Function) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if byteLen < 0 {
 				return ErrInvalidLengthRpc
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + byteLen
 			if postIndex < 0 {
 				return ErrInvalidLengthRpc
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Name = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Filename", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowRpc
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthRpc
+			m.ChunkData = append(m.ChunkData[:0], dAtA[iNdEx:postIndex]...)
+			if m.ChunkData == nil {
+				m.ChunkData = []byte{}
 			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthRpc
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.Filename = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -56,6 +56,68 @@ func (t *perRequestBearerToken) RequireTransportSecurity() bool {
 	return !t.insecure
 }
 
+// grpcClientFlags are the flags of commands that connect to a store.
+type grpcClientFlags struct {
+	storeAddress       *string
+	bearerToken        *string
+	bearerTokenFile    *string
+	insecure           *bool
+	insecureSkipVerify *bool
+}
+
+func registerGRPCClientFlags(cmd *kingpin.CmdClause) *grpcClientFlags {
+	f := &grpcClientFlags{
+		storeAddress: cmd.Flag("store", "Address of statically configured store.").
+			Default("127.0.0.1:10901").String(),
+	}
+	registerGRPCClientAuthFlags(cmd, "", f)
+	return f
+}
+
+// registerGRPCClientAuthFlags registers the transport and authentication
+// flags of a store client, their names prefixed with prefix for commands
+// connecting to a store besides their main one.
+func registerGRPCClientAuthFlags(cmd *kingpin.CmdClause, prefix string, f *grpcClientFlags) {
+	f.bearerToken = cmd.Flag(prefix+"bearer-token", "Bearer token to authenticate with store.").String()
+	f.bearerTokenFile = cmd.Flag(prefix+"bearer-token-file", "File to read bearer token from to authenticate with store.").String()
+	f.insecure = cmd.Flag(prefix+"insecure", "Send gRPC requests via plaintext instead of TLS.").Default("false").Bool()
+	f.insecureSkipVerify = cmd.Flag(prefix+"insecure-skip-verify", "Skip TLS certificate verification.").Default("false").Bool()
+}
+
+// dialOptions returns the transport and authentication options to dial the
+// store with.
+func (f *grpcClientFlags) dialOptions() ([]grpc.DialOption, error) {
+	opts := []grpc.DialOption{}
+	if *f.insecure {
+		opts = append(opts, grpc.WithInsecure())
+	} else {
+		config := &tls.Config{
+			InsecureSkipVerify: *f.insecureSkipVerify,
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	}
+
+	if f.bearerToken != nil && *f.bearerToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(&perRequestBearerToken{
+			token:    *f.bearerToken,
+			insecure: *f.insecure,
+		}))
+	}
+
+	if f.bearerTokenFile != nil && *f.bearerTokenFile != "" {
+		b, err := ioutil.ReadFile(*f.bearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bearer token from file: %w", err)
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(&perRequestBearerToken{
+			token:    string(b),
+			insecure: *f.insecure,
+		}))
+	}
+
+	return opts, nil
+}
+
 // registerSampler registers a sampler command.
 func registerSampler(m map[string]setupFunc, app *kingpin.Application, name string, reloadCh chan struct{}, reloaders *configReloaders) {
 	cmd := app.Command(name, "Run a sampler, that appends profiles to a configured storage.")
@@ -63,51 +125,22 @@ func registerSampler(m map[string]setupFunc, app *kingpin.Application, name stri
 	configFile := cmd.Flag("config.file", "Config file to use.").
 		Default("conprof.yaml").String()
 	targets := cmd.Flag("target", "Targets to scrape.").Strings()
-	storeAddress := cmd.Flag("store", "Address of statically configured store.").
-		Default("127.0.0.1:10901").String()
-	bearerToken := cmd.Flag("bearer-token", "Bearer token to authenticate with store.").String()
-	bearerTokenFile := cmd.Flag("bearer-token-file", "File to read bearer token from to authenticate with store.").String()
-	insecure := cmd.Flag("insecure", "Send gRPC requests via plaintext instead of TLS.").Default("false").Bool()
-	insecureSkipVerify := cmd.Flag("insecure-skip-verify", "Skip TLS certificate verification.").Default("false").Bool()
+	grpcFlags := registerGRPCClientFlags(cmd)
 
 	m[name] = func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
 		met := grpc_prometheus.NewClientMetrics()
 		met.EnableClientHandlingTimeHistogram()
 		reg.MustRegister(met)
 
-		opts := []grpc.DialOption{
-			grpc.WithUnaryInterceptor(
-				met.UnaryClientInterceptor(),
-			),
-		}
-		if *insecure {
-			opts = append(opts, grpc.WithInsecure())
-		} else {
-			config := &tls.Config{
-				InsecureSkipVerify: *insecureSkipVerify,
-			}
-			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
-		}
-
-		if bearerToken != nil && *bearerToken != "" {
-			opts = append(opts, grpc.WithPerRPCCredentials(&perRequestBearerToken{
-				token:    *bearerToken,
-				insecure: *insecure,
-			}))
-		}
-
-		if bearerTokenFile != nil && *bearerTokenFile != "" {
-			b, err := ioutil.ReadFile(*bearerTokenFile)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read bearer token from file: %w", err)
-			}
-			opts = append(opts, grpc.WithPerRPCCredentials(&perRequestBearerToken{
-				token:    string(b),
-				insecure: *insecure,
-			}))
+		opts, err := grpcFlags.dialOptions()
+		if err != nil {
+			return nil, err
 		}
+		opts = append(opts, grpc.WithUnaryInterceptor(
+			met.UnaryClientInterceptor(),
+		))
 
-		conn, err := grpc.Dial(*storeAddress, opts...)
+		conn, err := grpc.Dial(*grpcFlags.storeAddress, opts...)
 		if err != nil {
 			return probe, err
 		}
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -38,7 +39,10 @@ import (
 	"golang.org/x/net/context/ctxhttp"
 
 	"github.com/conprof/conprof/config"
+	"github.com/conprof/conprof/internal/pprof/plugin"
+	"github.com/conprof/conprof/internal/pprof/symbolz"
 	"github.com/conprof/conprof/internal/trace"
+	"github.com/conprof/conprof/pkg/convert"
 )
 
 var (
@@ -97,6 +101,18 @@ var (
 			Help: "Total number of samples rejected due to timestamp falling outside of the time bounds",
 		},
 	)
+	targetScrapeSplitLimit = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "conprof_target_scrapes_exceeded_split_limit_total",
+			Help: "Total number of scraped profiles whose samples would have been split into more live series of their target than the split limit allows.",
+		},
+	)
+	targetSymbolzFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "conprof_target_symbolz_failures_total",
+			Help: "Total number of scraped profiles that couldn't be symbolized via the target's symbol handler.",
+		},
+	)
 )
 
 func init() {
@@ -108,6 +124,8 @@ func init() {
 	prometheus.MustRegister(targetScrapeSampleDuplicate)
 	prometheus.MustRegister(targetScrapeSampleOutOfOrder)
 	prometheus.MustRegister(targetScrapeSampleOutOfBounds)
+	prometheus.MustRegister(targetScrapeSplitLimit)
+	prometheus.MustRegister(targetSymbolzFailures)
 }
 
 // scrapePool manages scrapes for sets of targets.
@@ -231,7 +249,7 @@ func (sp *scrapePool) reload(cfg *config.ScrapeConfig) {
 	for fp, oldLoop := range sp.loops {
 		var (
 			t       = sp.activeTargets[fp]
-			s       = &targetScraper{Target: t, client: sp.client, timeout: timeout}
+			s       = newTargetScraper(t, sp.client, timeout, sp.logger, sp.config)
 			newLoop = sp.newLoop(t, s)
 		)
 		wg.Add(1)
@@ -303,7 +321,7 @@ func (sp *scrapePool) sync(targets []*Target) {
 		uniqueTargets[hash] = struct{}{}
 
 		if _, ok := sp.activeTargets[hash]; !ok {
-			s := &targetScraper{Target: t, client: sp.client, timeout: timeout, logger: sp.logger}
+			s := newTargetScraper(t, sp.client, timeout, sp.logger, sp.config)
 			l := sp.newLoop(t, s)
 
 			sp.activeTargets[hash] = t
@@ -343,7 +361,9 @@ func (sp *scrapePool) sync(targets []*Target) {
 
 // A scraper retrieves samples and accepts a status report at the end.
 type scraper interface {
-	scrape(ctx context.Context, w io.Writer, profileType string) error
+	// scrape writes the scraped profile to w, and returns the parts of it
+	// that are stored in separate series.
+	scrape(ctx context.Context, w io.Writer, profileType string) ([]splitProfile, error)
 	offset(interval time.Duration) time.Duration
 }
 
@@ -354,31 +374,85 @@ type targetScraper struct {
 	logger  log.Logger
 	client  *http.Client
 	req     *http.Request
+	body    []byte
 	timeout time.Duration
+
+	// profileConfig describes how to request the profile, it may be nil in
+	// which case a bare GET is issued.
+	profileConfig *config.PprofProfilingConfig
+	// symbolz makes the scraper symbolize profiles via the target's symbol
+	// handler.
+	symbolz bool
+	// splitLabels are the sample label keys profiles are split by into
+	// separate series, as many as splitLimiter admits.
+	splitLabels  []string
+	splitLimiter *splitLimiter
+}
+
+func newTargetScraper(t *Target, client *http.Client, timeout time.Duration, logger log.Logger, cfg *config.ScrapeConfig) *targetScraper {
+	s := &targetScraper{
+		Target:      t,
+		client:      client,
+		timeout:     timeout,
+		logger:      logger,
+		symbolz:     cfg.SymbolzFallback,
+		splitLabels: cfg.SplitByLabels,
+	}
+	if len(cfg.SplitByLabels) > 0 {
+		s.splitLimiter = newSplitLimiter(cfg.SplitSeriesLimit())
+	}
+	if cfg.ProfilingConfig != nil {
+		s.profileConfig = cfg.ProfilingConfig.PprofConfig[t.labels.Get(ProfileName)]
+	}
+	return s
 }
 
 var userAgentHeader = fmt.Sprintf("conprof/%s", version.Version)
 
-func (s *targetScraper) scrape(ctx context.Context, w io.Writer, profileType string) error {
+func (s *targetScraper) scrape(ctx context.Context, w io.Writer, profileType string) ([]splitProfile, error) {
 	if s.req == nil {
-		req, err := http.NewRequest("GET", s.URL().String(), nil)
+		method := http.MethodGet
+		if s.profileConfig != nil {
+			method = s.profileConfig.RequestMethod()
+
+			body, err := s.profileConfig.RequestBody()
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to render request body")
+			}
+			s.body = body
+		}
+
+		req, err := http.NewRequest(method, s.URL().String(), nil)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if s.profileConfig != nil {
+			for name, value := range s.profileConfig.Headers {
+				req.Header.Set(name, value)
+			}
 		}
 		req.Header.Set("User-Agent", userAgentHeader)
 
 		s.req = req
 	}
 
-	level.Debug(s.logger).Log("msg", "scraping profile", "url", s.req.URL.String())
-	resp, err := ctxhttp.Do(ctx, s.client, s.req)
+	req := s.req
+	if s.body != nil {
+		// The body is consumed by every request, so each scrape gets its own.
+		req = s.req.Clone(ctx)
+		req.Body = ioutil.NopCloser(bytes.NewReader(s.body))
+		req.ContentLength = int64(len(s.body))
+	}
+
+	level.Debug(s.logger).Log("msg", "scraping profile", "url", req.URL.String(), "method", req.Method)
+	resp, err := ctxhttp.Do(ctx, s.client, req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned HTTP status %s", resp.Status)
+		return nil, fmt.Errorf("server returned HTTP status %s", resp.Status)
 	}
 
 	switch profileType {
@@ -387,34 +461,150 @@ func (s *targetScraper) scrape(ctx context.Context, w io.Writer, profileType str
 		// when you pass resp.Body
 		b, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return errors.Wrap(err, "failed to read body")
+			return nil, errors.Wrap(err, "failed to read body")
 		}
 		_, err = trace.Parse(io.TeeReader(bytes.NewBuffer(b), w), "")
 		if err != nil {
-			return errors.Wrap(err, "failed to parse target's trace profile")
+			return nil, errors.Wrap(err, "failed to parse target's trace profile")
 		}
 
 	default:
 		b, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return errors.Wrap(err, "failed to read body")
+			return nil, errors.Wrap(err, "failed to read body")
 		}
 
-		p, err := profile.ParseData(b)
+		p, err := s.parseProfile(b)
 		if err != nil {
-			return errors.Wrap(err, "failed to parse target's pprof profile")
+			return nil, err
 		}
 
 		if len(p.Sample) == 0 {
-			return fmt.Errorf("empty %s profile from %s", profileType, s.req.URL.String())
+			return nil, fmt.Errorf("empty %s profile from %s", profileType, s.req.URL.String())
+		}
+
+		if s.symbolz {
+			s.symbolize(ctx, p)
+		}
+
+		if len(s.splitLabels) > 0 {
+			return s.split(p, w)
 		}
 
 		if err := p.WriteUncompressed(w); err != nil {
-			return fmt.Errorf("write profile: %w", err)
+			return nil, fmt.Errorf("write profile: %w", err)
+		}
+	}
+
+	return nil, nil
+}
+
+// split splits the profile by the configured sample labels. The samples
+// without any of the labels are written to w, and nothing is written if
+// there are none.
+func (s *targetScraper) split(p *profile.Profile, w io.Writer) ([]splitProfile, error) {
+	base, groups, exceeded, err := splitByLabels(p, s.splitLabels, s.splitLimiter, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("split profile: %w", err)
+	}
+	if exceeded {
+		targetScrapeSplitLimit.Inc()
+		level.Debug(s.logger).Log("msg", "split limit exceeded, keeping remaining samples unsplit", "limit", s.splitLimiter.limit)
+	}
+
+	if len(base.Sample) > 0 {
+		if err := base.WriteUncompressed(w); err != nil {
+			return nil, fmt.Errorf("write profile: %w", err)
+		}
+	}
+
+	splits := make([]splitProfile, 0, len(groups))
+	for _, g := range groups {
+		buf := bytes.NewBuffer(nil)
+		if err := g.profile.WriteUncompressed(buf); err != nil {
+			return nil, fmt.Errorf("write profile of %s: %w", g.labels, err)
+		}
+		splits = append(splits, splitProfile{labels: g.labels, data: buf.Bytes()})
+	}
+	return splits, nil
+}
+
+// parseProfile parses the scraped profile according to the configured
+// format, converting it to pprof if necessary.
+func (s *targetScraper) parseProfile(b []byte) (*profile.Profile, error) {
+	format := config.ProfileFormatPprof
+	if s.profileConfig != nil {
+		format = s.profileConfig.ProfileFormat()
+	}
+
+	p, err := convert.Parse(format, b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse target's %s profile", format)
+	}
+	if format == config.ProfileFormatFolded {
+		// Folded stacks carry no time or duration.
+		p.TimeNanos = time.Now().UnixNano()
+		if s.profileConfig.Seconds > 0 {
+			p.DurationNanos = (time.Duration(s.profileConfig.Seconds) * time.Second).Nanoseconds()
+		}
+	}
+	return p, nil
+}
+
+// symbolize resolves the addresses of mappings without function names via
+// the target's symbol handler, while the process is still alive. Failures
+// are only logged, the profile may still be symbolized with uploaded
+// debuginfo later.
+func (s *targetScraper) symbolize(ctx context.Context, p *profile.Profile) {
+	source := s.URL().String()
+	sources := plugin.MappingSources{}
+	for _, m := range p.Mapping {
+		key := m.BuildID
+		if key == "" {
+			key = m.File
+		}
+		sources[key] = append(sources[key], struct {
+			Source string
+			Start  uint64
+		}{source, m.Start})
+	}
+
+	post := func(source, addresses string) ([]byte, error) {
+		return s.postSymbolz(ctx, source, addresses)
+	}
+	if err := symbolz.Symbolize(p, false, sources, post, nil); err != nil {
+		targetSymbolzFailures.Inc()
+		level.Warn(s.logger).Log("msg", "failed to symbolize profile via symbolz", "url", source, "err", err)
+	}
+}
+
+// postSymbolz asks the symbol handler at url for the symbols of the
+// addresses, which are separated by '+'. The configured headers are sent
+// like with the profile request, e.g. to authenticate.
+func (s *targetScraper) postSymbolz(ctx context.Context, url, addresses string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(addresses))
+	if err != nil {
+		return nil, err
+	}
+	if s.profileConfig != nil {
+		for name, value := range s.profileConfig.Headers {
+			req.Header.Set(name, value)
 		}
 	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("User-Agent", userAgentHeader)
 
-	return nil
+	level.Debug(s.logger).Log("msg", "symbolizing profile", "url", url)
+	resp, err := ctxhttp.Do(ctx, s.client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("symbol handler returned HTTP status %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
 }
 
 // A loop can run and be stopped again. It must not be reused after it was stopped.
@@ -511,7 +701,7 @@ mainLoop:
 		}
 
 		scrapeCtx, cancel := context.WithTimeout(sl.ctx, timeout)
-		scrapeErr := sl.scraper.scrape(scrapeCtx, buf, profileType)
+		splits, scrapeErr := sl.scraper.scrape(scrapeCtx, buf, profileType)
 		cancel()
 
 		if scrapeErr == nil {
@@ -530,13 +720,22 @@ mainLoop:
 			level.Debug(sl.l).Log("msg", "appending new sample", "labels", tl.String())
 
 			app := sl.appendable.Appender(sl.ctx)
-			_, err := app.Add(tl, timestamp.FromTime(start), buf.Bytes())
-			if err != nil && errc != nil {
-				level.Debug(sl.l).Log("err", err)
-				errc <- err
+			if len(b) > 0 {
+				_, err := app.Add(tl, timestamp.FromTime(start), b)
+				if err != nil && errc != nil {
+					level.Debug(sl.l).Log("err", err)
+					errc <- err
+				}
+			}
+			for _, split := range splits {
+				_, err := app.Add(splitSeriesLabels(tl, split.labels), timestamp.FromTime(start), split.data)
+				if err != nil && errc != nil {
+					level.Debug(sl.l).Log("err", err)
+					errc <- err
+				}
 			}
 
-			err = app.Commit()
+			err := app.Commit()
 			if err != nil && errc != nil {
 				level.Debug(sl.l).Log("err", err)
 				errc <- err
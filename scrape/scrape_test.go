@@ -0,0 +1,340 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrape
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/conprof/db/storage"
+	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/conprof/conprof/config"
+)
+
+// unsymbolizedProfile has a single location of a native binary without
+// function names.
+func unsymbolizedProfile() *profile.Profile {
+	m := &profile.Mapping{ID: 1, Start: 0x400000, Limit: 0x500000, File: "/usr/bin/app"}
+	l := &profile.Location{ID: 1, Mapping: m, Address: 0x401000}
+	return &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+		Sample:     []*profile.Sample{{Location: []*profile.Location{l}, Value: []int64{1}}},
+		Location:   []*profile.Location{l},
+		Mapping:    []*profile.Mapping{m},
+	}
+}
+
+// newSymbolzTarget serves the profile at /debug/pprof/heap and its symbols at
+// /debug/pprof/symbol, requiring the Authorization header for both.
+func newSymbolzTarget(t *testing.T, p *profile.Profile, symbolStatus int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/heap", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		require.NoError(t, p.Write(w))
+	})
+	mux.HandleFunc("/debug/pprof/symbol", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "0x401000", string(b))
+		w.WriteHeader(symbolStatus)
+		w.Write([]byte("0x401000 main.main\n"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func newSymbolzScraper(t *testing.T, server *httptest.Server) *targetScraper {
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	target := NewTarget(labels.FromMap(map[string]string{
+		model.SchemeLabel:  u.Scheme,
+		model.AddressLabel: u.Host,
+		ProfilePath:        "/debug/pprof/heap",
+		ProfileName:        "heap",
+	}), nil, nil)
+
+	enabled := true
+	cfg := &config.ScrapeConfig{
+		SymbolzFallback: true,
+		ProfilingConfig: &config.ProfilingConfig{
+			PprofConfig: config.PprofConfig{
+				"heap": &config.PprofProfilingConfig{
+					Enabled: &enabled,
+					Path:    "/debug/pprof/heap",
+					Headers: map[string]string{"Authorization": "Bearer secret"},
+				},
+			},
+		},
+	}
+	return newTargetScraper(target, server.Client(), time.Second, log.NewNopLogger(), cfg)
+}
+
+func TestTargetScraperSymbolz(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		symbolStatus int
+		symbolized   bool
+		failures     float64
+	}{
+		{name: "symbolized", symbolStatus: http.StatusOK, symbolized: true},
+		{name: "symbol handler failing", symbolStatus: http.StatusInternalServerError, failures: 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server := newSymbolzTarget(t, unsymbolizedProfile(), test.symbolStatus)
+			defer server.Close()
+			s := newSymbolzScraper(t, server)
+
+			failures := testutil.ToFloat64(targetSymbolzFailures)
+			buf := bytes.NewBuffer(nil)
+			_, err := s.scrape(context.Background(), buf, "heap")
+			require.NoError(t, err)
+			require.Equal(t, test.failures, testutil.ToFloat64(targetSymbolzFailures)-failures)
+
+			// The profile is stored even if it couldn't be symbolized.
+			p, err := profile.ParseData(buf.Bytes())
+			require.NoError(t, err)
+			if !test.symbolized {
+				require.Empty(t, p.Location[0].Line)
+				return
+			}
+			require.Len(t, p.Location[0].Line, 1)
+			require.Equal(t, "main.main", p.Location[0].Line[0].Function.Name)
+			require.True(t, p.Mapping[0].HasFunctions)
+		})
+	}
+}
+
+func TestTargetScraperPostSymbolz(t *testing.T) {
+	server := newSymbolzTarget(t, unsymbolizedProfile(), http.StatusOK)
+	defer server.Close()
+	s := newSymbolzScraper(t, server)
+
+	b, err := s.postSymbolz(context.Background(), server.URL+"/debug/pprof/symbol", "0x401000")
+	require.NoError(t, err)
+	require.Equal(t, "0x401000 main.main\n", string(b))
+
+	// Without the configured headers the target refuses.
+	s.profileConfig = nil
+	_, err = s.postSymbolz(context.Background(), server.URL+"/debug/pprof/symbol", "0x401000")
+	require.Error(t, err)
+}
+
+func TestTargetScraperRequest(t *testing.T) {
+	type request struct {
+		method, contentType, userAgent, body string
+		query                                url.Values
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests = append(requests, request{
+			method:      r.Method,
+			contentType: r.Header.Get("Content-Type"),
+			userAgent:   r.Header.Get("User-Agent"),
+			body:        string(b),
+			query:       r.URL.Query(),
+		})
+		require.NoError(t, unsymbolizedProfile().Write(w))
+	}))
+	defer server.Close()
+
+	c, err := config.Load(`
+scrape_configs:
+  - job_name: 'test'
+    static_configs:
+      - targets: [ 'localhost:8080' ]
+    profiling_config:
+      pprof_config:
+        profile:
+          path: /profile
+          seconds: 10
+          method: POST
+          headers:
+            Content-Type: application/json
+          body: '{"duration": "{{ .Duration }}"}'
+          duration_param: 'duration={{ .Seconds }}s'
+`)
+	require.NoError(t, err)
+	cfg := c.ScrapeConfigs[0]
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	targets, err := targetsFromGroup(&targetgroup.Group{
+		Targets: []model.LabelSet{{model.AddressLabel: model.LabelValue(u.Host)}},
+	}, cfg)
+	require.NoError(t, err)
+	var target *Target
+	for _, tg := range targets {
+		if tg.labels.Get(ProfileName) == ProfileProfileType {
+			target = tg
+		}
+	}
+	require.NotNil(t, target)
+
+	s := newTargetScraper(target, server.Client(), time.Second, log.NewNopLogger(), cfg)
+	// Every scrape sends the body, not only the first one.
+	for i := 0; i < 2; i++ {
+		_, err := s.scrape(context.Background(), ioutil.Discard, ProfileProfileType)
+		require.NoError(t, err)
+	}
+
+	exp := request{
+		method:      http.MethodPost,
+		contentType: "application/json",
+		userAgent:   userAgentHeader,
+		body:        `{"duration": "10s"}`,
+		query:       url.Values{"duration": {"10s"}},
+	}
+	require.Equal(t, []request{exp, exp}, requests)
+}
+
+type addedSample struct {
+	lset labels.Labels
+	v    []byte
+}
+
+// collectingAppendable collects the samples of the first commit.
+type collectingAppendable struct {
+	samples   []addedSample
+	committed chan struct{}
+}
+
+func (a *collectingAppendable) Appender(_ context.Context) storage.Appender {
+	return &collectingAppender{a: a}
+}
+
+type collectingAppender struct {
+	a       *collectingAppendable
+	samples []addedSample
+}
+
+func (a *collectingAppender) Add(l labels.Labels, t int64, v []byte) (uint64, error) {
+	a.samples = append(a.samples, addedSample{lset: l, v: v})
+	return 0, nil
+}
+
+func (a *collectingAppender) AddFast(ref uint64, t int64, v []byte) error {
+	return storage.ErrNotFound
+}
+
+func (a *collectingAppender) Commit() error {
+	select {
+	case <-a.a.committed:
+	default:
+		a.a.samples = a.samples
+		close(a.a.committed)
+	}
+	return nil
+}
+
+func (a *collectingAppender) Rollback() error {
+	return nil
+}
+
+func TestScrapeLoopSplitByLabels(t *testing.T) {
+	allLabeled := tenantProfile()
+	allLabeled.Sample = allLabeled.Sample[:4]
+
+	for _, test := range []struct {
+		name     string
+		profile  *profile.Profile
+		expected map[string]int64
+	}{
+		{
+			name:    "with unlabeled samples",
+			profile: tenantProfile(),
+			expected: map[string]int64{
+				`{__name__="heap", instance="%s", job="test"}`:             10,
+				`{__name__="heap", instance="%s", job="test", tenant="a"}`: 300,
+				`{__name__="heap", instance="%s", job="test", tenant="b"}`: 300,
+				`{__name__="heap", instance="%s", job="test", tenant="c"}`: 50,
+			},
+		},
+		{
+			// Nothing is stored in the target's own series if all samples
+			// are split off.
+			name:    "all samples labeled",
+			profile: allLabeled,
+			expected: map[string]int64{
+				`{__name__="heap", instance="%s", job="test", tenant="a"}`: 300,
+				`{__name__="heap", instance="%s", job="test", tenant="b"}`: 300,
+				`{__name__="heap", instance="%s", job="test", tenant="c"}`: 50,
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, test.profile.Write(w))
+			}))
+			defer server.Close()
+			u, err := url.Parse(server.URL)
+			require.NoError(t, err)
+
+			target := NewTarget(labels.FromMap(map[string]string{
+				model.SchemeLabel:   u.Scheme,
+				model.AddressLabel:  u.Host,
+				model.InstanceLabel: u.Host,
+				model.JobLabel:      "test",
+				ProfilePath:         "/debug/pprof/heap",
+				ProfileName:         "heap",
+			}), nil, nil)
+			cfg := &config.ScrapeConfig{SplitByLabels: []string{"tenant"}}
+			scraper := newTargetScraper(target, server.Client(), time.Second, log.NewNopLogger(), cfg)
+
+			app := &collectingAppendable{committed: make(chan struct{})}
+			sl := newScrapeLoop(context.Background(), target, scraper, log.NewNopLogger(), nil, app)
+			go sl.run(time.Millisecond, time.Second, nil)
+			select {
+			case <-app.committed:
+			case <-time.After(10 * time.Second):
+				t.Fatal("timed out waiting for a scrape")
+			}
+			sl.stop()
+
+			series := map[string]int64{}
+			for _, s := range app.samples {
+				p, err := profile.ParseData(s.v)
+				require.NoError(t, err)
+				series[s.lset.String()] = sum(p)
+			}
+			expected := map[string]int64{}
+			for lset, v := range test.expected {
+				expected[fmt.Sprintf(lset, u.Host)] = v
+			}
+			require.Equal(t, expected, series)
+		})
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrape
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+// splitProfile is the part of a scraped profile with the same values of the
+// sample labels it was split by, which is stored in its own series.
+type splitProfile struct {
+	// labels are the sample labels that are added to the target's labels.
+	labels labels.Labels
+	data   []byte
+}
+
+// splitSeriesTTL is how long a series split off a target's profiles counts
+// towards the split limit after its last profile, about as long as it stays
+// in the TSDB's head.
+const splitSeriesTTL = 3 * time.Hour
+
+// splitLimiter caps the number of live series a target's profiles are split
+// into across scrapes, as label values changing over time would otherwise
+// create series without bound.
+type splitLimiter struct {
+	limit  int
+	ttl    time.Duration
+	series map[string]time.Time
+}
+
+func newSplitLimiter(limit int) *splitLimiter {
+	return &splitLimiter{
+		limit:  limit,
+		ttl:    splitSeriesTTL,
+		series: map[string]time.Time{},
+	}
+}
+
+// admit returns whether a profile may be split off into the series with the
+// split labels at t. Series that are live already are always admitted, new
+// ones only while there are fewer live series than the limit.
+func (l *splitLimiter) admit(ls labels.Labels, t time.Time) bool {
+	id := ls.String()
+	if _, ok := l.series[id]; ok {
+		l.series[id] = t
+		return true
+	}
+	if len(l.series) >= l.limit {
+		for id, last := range l.series {
+			if t.Sub(last) > l.ttl {
+				delete(l.series, id)
+			}
+		}
+		if len(l.series) >= l.limit {
+			return false
+		}
+	}
+	l.series[id] = t
+	return true
+}
+
+// splitGroup are the samples with the same values of the split labels.
+type splitGroup struct {
+	labels  labels.Labels
+	samples []*profile.Sample
+	total   int64
+
+	// profile is the profile of the samples.
+	profile *profile.Profile
+}
+
+// splitByLabels splits the profile by the values of the sample label keys.
+// Samples without any of the labels stay in the returned base profile, as do
+// the samples of the groups the limiter doesn't admit, which are admitted by
+// their total value. It returns whether any group wasn't admitted.
+func splitByLabels(p *profile.Profile, keys []string, limiter *splitLimiter, t time.Time) (*profile.Profile, []*splitGroup, bool, error) {
+	index, err := p.SampleIndexByName("")
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	groups := map[string]*splitGroup{}
+	var base []*profile.Sample
+	for _, s := range p.Sample {
+		var ls labels.Labels
+		for _, k := range keys {
+			if vals := s.Label[k]; len(vals) > 0 {
+				ls = append(ls, labels.Label{Name: k, Value: vals[0]})
+			}
+		}
+		if len(ls) == 0 {
+			base = append(base, s)
+			continue
+		}
+
+		sort.Sort(ls)
+		id := ls.String()
+		g, ok := groups[id]
+		if !ok {
+			g = &splitGroup{labels: ls}
+			groups[id] = g
+		}
+		g.samples = append(g.samples, s)
+		g.total += abs(s.Value[index])
+	}
+
+	sorted := make([]*splitGroup, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].total != sorted[j].total {
+			return sorted[i].total > sorted[j].total
+		}
+		return labels.Compare(sorted[i].labels, sorted[j].labels) < 0
+	})
+
+	exceeded := false
+	admitted := sorted[:0]
+	for _, g := range sorted {
+		if !limiter.admit(g.labels, t) {
+			exceeded = true
+			base = append(base, g.samples...)
+			continue
+		}
+		admitted = append(admitted, g)
+	}
+	sorted = admitted
+
+	for _, g := range sorted {
+		g.profile, err = withSamples(p, g.samples)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
+
+	if len(base) == len(p.Sample) {
+		return p, nil, exceeded, nil
+	}
+	bp, err := withSamples(p, base)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return bp, sorted, exceeded, nil
+}
+
+// withSamples returns a copy of the profile with only the samples, and only
+// the locations, functions and mappings they reference.
+func withSamples(p *profile.Profile, samples []*profile.Sample) (*profile.Profile, error) {
+	if len(samples) == 0 {
+		return &profile.Profile{
+			SampleType:        p.SampleType,
+			DefaultSampleType: p.DefaultSampleType,
+			TimeNanos:         p.TimeNanos,
+			DurationNanos:     p.DurationNanos,
+			PeriodType:        p.PeriodType,
+			Period:            p.Period,
+		}, nil
+	}
+	return profile.Merge([]*profile.Profile{{
+		SampleType:        p.SampleType,
+		DefaultSampleType: p.DefaultSampleType,
+		Sample:            samples,
+		Mapping:           p.Mapping,
+		Location:          p.Location,
+		Function:          p.Function,
+		Comments:          p.Comments,
+		DropFrames:        p.DropFrames,
+		KeepFrames:        p.KeepFrames,
+		TimeNanos:         p.TimeNanos,
+		DurationNanos:     p.DurationNanos,
+		PeriodType:        p.PeriodType,
+		Period:            p.Period,
+	}})
+}
+
+// splitSeriesLabels returns the labels of the series of a split profile.
+// Split labels that conflict with the target's labels are prefixed with
+// "exported_", like Prometheus does for conflicting scraped labels.
+func splitSeriesLabels(target, split labels.Labels) labels.Labels {
+	b := labels.NewBuilder(target)
+	for _, l := range split {
+		name := l.Name
+		for target.Has(name) {
+			name = "exported_" + name
+		}
+		b.Set(name, l.Value)
+	}
+	return b.Labels()
+}
+
+func abs(i int64) int64 {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrape
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func tenantProfile() *profile.Profile {
+	fn := &profile.Function{ID: 1, Name: "main.handle"}
+	other := &profile.Function{ID: 2, Name: "main.background"}
+	loc := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn}}}
+	otherLoc := &profile.Location{ID: 2, Line: []profile.Line{{Function: other}}}
+
+	sample := func(loc *profile.Location, v int64, lbls map[string][]string) *profile.Sample {
+		return &profile.Sample{Location: []*profile.Location{loc}, Value: []int64{v}, Label: lbls}
+	}
+	return &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "cpu", Unit: "nanoseconds"}},
+		Sample: []*profile.Sample{
+			sample(loc, 100, map[string][]string{"tenant": {"a"}, "endpoint": {"/write"}}),
+			sample(loc, 300, map[string][]string{"tenant": {"b"}}),
+			sample(loc, 50, map[string][]string{"tenant": {"c"}}),
+			sample(loc, 200, map[string][]string{"tenant": {"a"}, "endpoint": {"/write"}}),
+			sample(otherLoc, 10, nil),
+		},
+		Location: []*profile.Location{loc, otherLoc},
+		Function: []*profile.Function{fn, other},
+	}
+}
+
+func sum(p *profile.Profile) int64 {
+	s := int64(0)
+	for _, sample := range p.Sample {
+		s += sample.Value[0]
+	}
+	return s
+}
+
+func TestSplitByLabels(t *testing.T) {
+	base, groups, exceeded, err := splitByLabels(tenantProfile(), []string{"tenant", "endpoint"}, newSplitLimiter(100), time.Now())
+	require.NoError(t, err)
+	require.False(t, exceeded)
+
+	require.Equal(t, int64(10), sum(base))
+	require.Len(t, base.Function, 1)
+	require.NoError(t, base.CheckValid())
+
+	require.Len(t, groups, 3)
+	require.Equal(t, labels.FromStrings("endpoint", "/write", "tenant", "a"), groups[0].labels)
+	require.Equal(t, int64(300), sum(groups[0].profile))
+	require.Equal(t, labels.FromStrings("tenant", "b"), groups[1].labels)
+	require.Equal(t, int64(300), sum(groups[1].profile))
+	require.Equal(t, labels.FromStrings("tenant", "c"), groups[2].labels)
+	require.Equal(t, int64(50), sum(groups[2].profile))
+	for _, g := range groups {
+		require.NoError(t, g.profile.CheckValid())
+		require.Len(t, g.profile.Function, 1)
+	}
+
+	// Groups beyond the limit are kept unsplit.
+	base, groups, exceeded, err = splitByLabels(tenantProfile(), []string{"tenant"}, newSplitLimiter(2), time.Now())
+	require.NoError(t, err)
+	require.True(t, exceeded)
+	require.Len(t, groups, 2)
+	require.Equal(t, int64(60), sum(base))
+
+	// Profiles without the labels aren't split.
+	p := tenantProfile()
+	base, groups, _, err = splitByLabels(p, []string{"pod"}, newSplitLimiter(2), time.Now())
+	require.NoError(t, err)
+	require.Empty(t, groups)
+	require.Equal(t, p, base)
+}
+
+func TestSplitLimiter(t *testing.T) {
+	l := newSplitLimiter(2)
+	now := time.Now()
+
+	base, groups, exceeded, err := splitByLabels(tenantProfile(), []string{"tenant"}, l, now)
+	require.NoError(t, err)
+	require.True(t, exceeded)
+	require.Len(t, groups, 2)
+	require.Equal(t, int64(60), sum(base))
+
+	// The live series of earlier scrapes count towards the limit, even if
+	// the new series have more samples.
+	p := tenantProfile()
+	p.Sample[2].Value[0] = 1000
+	_, groups, exceeded, err = splitByLabels(p, []string{"tenant"}, l, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, exceeded)
+	require.Len(t, groups, 2)
+	require.Equal(t, labels.FromStrings("tenant", "a"), groups[0].labels)
+	require.Equal(t, labels.FromStrings("tenant", "b"), groups[1].labels)
+
+	// Once a series isn't live anymore, another one can take its place.
+	require.True(t, l.admit(labels.FromStrings("tenant", "a"), now.Add(splitSeriesTTL)))
+	require.True(t, l.admit(labels.FromStrings("tenant", "c"), now.Add(splitSeriesTTL+2*time.Minute)))
+	require.False(t, l.admit(labels.FromStrings("tenant", "d"), now.Add(splitSeriesTTL+2*time.Minute)))
+}
+
+func TestSplitSeriesLabels(t *testing.T) {
+	target := labels.FromStrings("__name__", "profile", "instance", "a:8080", "tenant", "x")
+	require.Equal(t,
+		labels.FromStrings("__name__", "profile", "endpoint", "/write", "exported_tenant", "a", "instance", "a:8080", "tenant", "x"),
+		splitSeriesLabels(target, labels.FromStrings("endpoint", "/write", "tenant", "a")),
+	)
+}
@@ -19,7 +19,6 @@ import (
 	"hash/fnv"
 	"net"
 	"net/url"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -335,6 +334,14 @@ func populateLabels(lset labels.Labels, cfg *config.ScrapeConfig) (res, orig lab
 	return res, lset, nil
 }
 
+// requestsDuration returns whether the profile is requested for its
+// configured duration. CPU profiles always are, other profiles only if their
+// duration_param is configured, as e.g. heap and allocs profiles requested
+// with seconds are delta profiles instead of snapshots.
+func requestsDuration(profileType string, pc *config.PprofProfilingConfig) bool {
+	return profileType == ProfileProfileType || pc.DurationParam != ""
+}
+
 // targetsFromGroup builds targets based on the given TargetGroup and config.
 func targetsFromGroup(tg *targetgroup.Group, cfg *config.ScrapeConfig) ([]*Target, error) {
 	targets := make([]*Target, 0, len(tg.Targets))
@@ -366,13 +373,18 @@ func targetsFromGroup(tg *targetgroup.Group, cfg *config.ScrapeConfig) ([]*Targe
 				return nil, fmt.Errorf("instance %d in group %s: %s", i, tg, err)
 			}
 			if lbls != nil || origLabels != nil {
-				params := cfg.Params
-				switch profType {
-				case ProfileProfileType:
-					if params == nil {
-						params = url.Values{}
+				params := url.Values{}
+				for k, v := range cfg.Params {
+					params[k] = append([]string(nil), v...)
+				}
+				if pc := cfg.ProfilingConfig.PprofConfig[profType]; pc != nil && requestsDuration(profType, pc) {
+					durationParams, err := pc.DurationParams()
+					if err != nil {
+						return nil, fmt.Errorf("instance %d in group %s: %s", i, tg, err)
+					}
+					for k, v := range durationParams {
+						params[k] = append(params[k], v...)
 					}
-					params.Add("seconds", strconv.Itoa(cfg.ProfilingConfig.PprofConfig[ProfileProfileType].Seconds))
 				}
 
 				targets = append(targets, NewTarget(lbls, origLabels, params))
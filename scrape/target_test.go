@@ -0,0 +1,69 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scrape
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/discovery/targetgroup"
+	"github.com/stretchr/testify/require"
+
+	"github.com/conprof/conprof/config"
+)
+
+func TestTargetsFromGroupDurationParams(t *testing.T) {
+	c, err := config.Load(`
+scrape_configs:
+  - job_name: 'test'
+    params:
+      debug: ['1']
+    static_configs:
+      - targets: [ 'localhost:8080' ]
+    profiling_config:
+      pprof_config:
+        heap:
+          seconds: 10
+        fgprof:
+          enabled: true
+          path: /debug/fgprof
+          seconds: 10
+          duration_param: 'seconds={{ .Seconds }}'
+`)
+	require.NoError(t, err)
+
+	targets, err := targetsFromGroup(&targetgroup.Group{
+		Targets: []model.LabelSet{{model.AddressLabel: "localhost:8080"}},
+	}, c.ScrapeConfigs[0])
+	require.NoError(t, err)
+
+	params := map[string]url.Values{}
+	for _, target := range targets {
+		params[target.labels.Get(ProfileName)] = target.Params()
+	}
+	require.Equal(t, map[string]url.Values{
+		// CPU profiles are requested for their duration by default.
+		"profile": {"debug": {"1"}, "seconds": {"30"}},
+		"fgprof":  {"debug": {"1"}, "seconds": {"10"}},
+		// Other profiles only when their duration_param is configured, heap
+		// profiles requested for a duration are deltas.
+		"heap":         {"debug": {"1"}},
+		"allocs":       {"debug": {"1"}},
+		"block":        {"debug": {"1"}},
+		"goroutine":    {"debug": {"1"}},
+		"mutex":        {"debug": {"1"}},
+		"threadcreate": {"debug": {"1"}},
+	}, params)
+}
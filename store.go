@@ -16,6 +16,7 @@ package main
 import (
 	"time"
 
+	"github.com/conprof/db/storage"
 	"github.com/conprof/db/tsdb"
 	"github.com/conprof/db/tsdb/wal"
 	"github.com/go-kit/kit/log"
@@ -25,6 +26,7 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 	"github.com/thanos-io/thanos/pkg/component"
 	"github.com/thanos-io/thanos/pkg/extkingpin"
 	"github.com/thanos-io/thanos/pkg/extprom"
@@ -35,7 +37,10 @@ import (
 	"google.golang.org/grpc"
 	"gopkg.in/alecthomas/kingpin.v2"
 
+	"github.com/conprof/conprof/pkg/chunkenc"
 	"github.com/conprof/conprof/pkg/store"
+	"github.com/conprof/conprof/pkg/store/storepb"
+	"github.com/conprof/conprof/symbol"
 )
 
 type componentString string
@@ -44,14 +49,58 @@ func (c componentString) String() string {
 	return string(c)
 }
 
+// registerProfileCompressionFlag registers the flag of the codec profiles
+// are compressed with before they are appended to the TSDB.
+func registerProfileCompressionFlag(cmd *kingpin.CmdClause) *string {
+	return cmd.Flag("storage.tsdb.profile-compression", "Codec to compress every profile with before it is appended to the TSDB. Profiles are read regardless of the codec they were compressed with, existing blocks can be converted with the tsdb recompress command.").
+		Default(string(chunkenc.CodecNone)).Enum(chunkenc.Codecs...)
+}
+
+// registerAdminAPIFlag registers the flag enabling the admin APIs, which
+// delete series.
+func registerAdminAPIFlag(cmd *kingpin.CmdClause) *bool {
+	return cmd.Flag("enable-admin-api", "Enable the admin APIs deleting series and cleaning tombstones.").
+		Default("false").Bool()
+}
+
+// registerTSDBRetentionFlag registers the retention flag of the storage.
+func registerTSDBRetentionFlag(cmd *kingpin.CmdClause) *model.Duration {
+	return extkingpin.ModelDuration(cmd.Flag("storage.tsdb.retention.time", "How long to retain raw samples on local storage. 0d - disables this retention").Default("15d"))
+}
+
+// tsdbOptions returns the options the storage opens the TSDB with.
+func tsdbOptions(retention time.Duration) *tsdb.Options {
+	return &tsdb.Options{
+		RetentionDuration:      retention.Milliseconds(),
+		WALSegmentSize:         wal.DefaultSegmentSize,
+		MinBlockDuration:       tsdb.DefaultBlockDuration,
+		MaxBlockDuration:       retention.Milliseconds() / 10,
+		NoLockfile:             true,
+		AllowOverlappingBlocks: false,
+		WALCompression:         true,
+		StripeSize:             tsdb.DefaultStripeSize,
+	}
+}
+
+// registerProfileChunksFlag registers the flag requesting chunks in the
+// profile encoding from the store.
+func registerProfileChunksFlag(cmd *kingpin.CmdClause) *bool {
+	return cmd.Flag("store.profile-chunks", "Request chunks in the profile encoding from the store, so that merges don't parse every profile. Blocks store bytes chunks, so the store re-encodes the chunks of every query, which costs CPU on the store and doesn't reduce its storage.").
+		Default("false").Bool()
+}
+
 // registerStorage registers a sampler command.
 func registerStorage(m map[string]setupFunc, app *kingpin.Application, name string, reloadCh chan struct{}) {
 	cmd := app.Command(name, "Run a sampler, that appends profiles to a configured storage.")
 
 	storagePath := cmd.Flag("storage.tsdb.path", "Directory to read storage from.").
 		Default("./data").String()
-	retention := extkingpin.ModelDuration(cmd.Flag("storage.tsdb.retention.time", "How long to retain raw samples on local storage. 0d - disables this retention").Default("15d"))
+	retention := registerTSDBRetentionFlag(cmd)
+	profileCompression := registerProfileCompressionFlag(cmd)
+	enableAdminAPI := registerAdminAPIFlag(cmd)
 	grpcBindAddr, grpcGracePeriod, grpcCert, grpcKey, grpcClientCA := extkingpin.RegisterGRPCFlags(cmd)
+	symbolServer := cmd.Flag("symbol-server", "Symbol server to request to symbolize native stacktraces at ingest.").String()
+	ingestFlags := registerIngestSymbolizationFlags(cmd)
 	reqLogConfig := extkingpin.RegisterRequestLoggingFlags(cmd)
 
 	m[name] = func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
@@ -60,20 +109,15 @@ func registerStorage(m map[string]setupFunc, app *kingpin.Application, name stri
 			return probe, errors.Wrap(err, "error while parsing config for request logging")
 		}
 
+		opts := tsdbOptions(time.Duration(*retention))
+		// Rewriting the profiles symbolized after they are stored writes
+		// overlapping blocks.
+		opts.AllowOverlappingBlocks = *ingestFlags.enabled
 		db, err := tsdb.Open(
 			*storagePath,
 			logger,
 			prometheus.DefaultRegisterer,
-			&tsdb.Options{
-				RetentionDuration:      time.Duration(*retention).Milliseconds(),
-				WALSegmentSize:         wal.DefaultSegmentSize,
-				MinBlockDuration:       tsdb.DefaultBlockDuration,
-				MaxBlockDuration:       time.Duration(*retention).Milliseconds() / 10,
-				NoLockfile:             true,
-				AllowOverlappingBlocks: false,
-				WALCompression:         true,
-				StripeSize:             tsdb.DefaultStripeSize,
-			},
+			opts,
 		)
 		if err != nil {
 			return probe, err
@@ -87,6 +131,10 @@ func registerStorage(m map[string]setupFunc, app *kingpin.Application, name stri
 			grpcLogOpts,
 			tagOpts,
 			db,
+			chunkenc.Codec(*profileCompression),
+			*enableAdminAPI,
+			*symbolServer,
+			ingestFlags,
 			*grpcBindAddr,
 			time.Duration(*grpcGracePeriod),
 			*grpcCert,
@@ -105,6 +153,10 @@ func runStorage(
 	grpcLogOpts []grpc_logging.Option,
 	tagOpts []tags.Option,
 	db *tsdb.DB,
+	codec chunkenc.Codec,
+	enableAdminAPI bool,
+	symbolServer string,
+	ingestFlags *ingestSymbolizationFlags,
 	grpcBindAddr string,
 	grpcGracePeriod time.Duration,
 	grpcCert string,
@@ -117,8 +169,29 @@ func runStorage(
 		grpcProbe,
 		prober.NewInstrumentation(comp, logger, extprom.WrapRegistererWithPrefix("conprof_", reg)),
 	)
+
+	var app storage.Appendable = db
+	if codec != chunkenc.CodecNone {
+		app = store.NewCompressingAppendable(db, codec)
+	}
+	if *ingestFlags.enabled {
+		if symbolServer == "" {
+			return nil, errors.New("symbolizing at ingest requires a symbol server")
+		}
+		conn, err := grpc.Dial(symbolServer, grpc.WithInsecure())
+		if err != nil {
+			return nil, err
+		}
+		sym := symbol.NewSymbolizer(logger, storepb.NewSymbolizeClient(conn))
+		app = symbolizeAtIngest(g, logger, reg, db, app, codec, sym, ingestFlags)
+	}
+
 	maxBytesPerFrame := 1024 * 1024 * 2 // 2 Mb default, might need to be tuned later on.
-	s := store.NewProfileStore(logger, db, maxBytesPerFrame)
+	var storeOpts []store.ProfileStoreOption
+	if enableAdminAPI {
+		storeOpts = append(storeOpts, store.WithAdminAPI(store.NewTSDBSeriesDeleter(db)))
+	}
+	s := store.NewProfileStore(logger, &writeDB{DB: db, app: app}, maxBytesPerFrame, storeOpts...)
 
 	srv := grpcserver.New(logger, reg, &opentracing.NoopTracer{}, grpcLogOpts, tagOpts, comp, grpcProbe,
 		grpcserver.WithServer(store.RegisterReadableStoreServer(s)),
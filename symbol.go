@@ -14,6 +14,7 @@
 package main
 
 import (
+	"context"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -36,6 +37,7 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 
 	"github.com/conprof/conprof/pkg/store"
+	"github.com/conprof/conprof/pkg/store/storepb"
 	"github.com/conprof/conprof/symbol"
 )
 
@@ -47,6 +49,21 @@ func registerSymbol(m map[string]setupFunc, app *kingpin.Application, name strin
 	objStoreConfig := *extkingpin.RegisterCommonObjStoreFlags(cmd, "", false)
 	symbolCache := cmd.Flag("symbol-cache", "Directory to use to cache symbol data from object storage.").
 		Default("/tmp").String()
+	symbolExtractDebugSections := cmd.Flag("symbol-extract-debug-sections", "Only keep the sections needed for symbolization of uploaded debug information, which reduces the storage used for unstripped binaries.").
+		Default("false").Bool()
+	symbolCacheSize := cmd.Flag("symbol-cache-size", "Maximum size of the symbol data cached on local disk, the least recently used symbol data is evicted. 0 disables the limit.").
+		Default("10GB").Bytes()
+	symbolRetention := extkingpin.ModelDuration(cmd.Flag("symbol-retention", "How long to retain symbol data in object storage that is neither uploaded nor used to symbolize. 0d disables this retention.").
+		Default("0d"))
+	symbolGCInterval := extkingpin.ModelDuration(cmd.Flag("symbol-gc-interval", "Interval of deleting symbol data from object storage that is past the retention.").
+		Default("1h"))
+	symbolGCStore := &grpcClientFlags{
+		storeAddress: cmd.Flag("symbol-gc-store", "Address of the store whose profiles are read when garbage collecting, the symbol data referenced by their mappings is retained regardless of the retention.").String(),
+	}
+	registerGRPCClientAuthFlags(cmd, "symbol-gc-store.", symbolGCStore)
+	debuginfodUpstreams := cmd.Flag("debuginfod-upstream", "Upstream debuginfod server to download debug information missing from object storage from, for example a distribution's mirror. Can be repeated.").Strings()
+	debuginfodTimeout := extkingpin.ModelDuration(cmd.Flag("debuginfod-timeout", "Timeout of downloading debug information from an upstream debuginfod server.").
+		Default("5m"))
 	reqLogConfig := extkingpin.RegisterRequestLoggingFlags(cmd)
 
 	m[name] = func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
@@ -54,15 +71,55 @@ func registerSymbol(m map[string]setupFunc, app *kingpin.Application, name strin
 		if err != nil {
 			return probe, errors.Wrap(err, "error while parsing config for request logging")
 		}
+
+		symbolStoreOpts := []symbol.SymbolStoreOption{
+			symbol.WithCacheSize(int64(*symbolCacheSize)),
+			symbol.WithRetention(time.Duration(*symbolRetention)),
+		}
+		if *symbolExtractDebugSections {
+			symbolStoreOpts = append(symbolStoreOpts, symbol.WithDebugSectionExtraction())
+		}
+		if len(*debuginfodUpstreams) > 0 {
+			symbolStoreOpts = append(symbolStoreOpts, symbol.WithDebuginfod(
+				symbol.NewDebuginfodClient(logger, *debuginfodUpstreams, time.Duration(*debuginfodTimeout)),
+			))
+		}
+		if *symbolGCStore.storeAddress != "" {
+			opts, err := symbolGCStore.dialOptions()
+			if err != nil {
+				return probe, err
+			}
+			conn, err := grpc.Dial(
+				*symbolGCStore.storeAddress,
+				append(opts,
+					grpc.WithUnaryInterceptor(
+						otelgrpc.UnaryClientInterceptor(),
+					),
+					grpc.WithStreamInterceptor(
+						otelgrpc.StreamClientInterceptor(),
+					),
+				)...,
+			)
+			if err != nil {
+				return probe, err
+			}
+			symbolStoreOpts = append(symbolStoreOpts, symbol.WithReferences(
+				store.NewGRPCQueryable(storepb.NewReadableProfileStoreClient(conn)),
+			))
+		}
+
 		return runSymbol(
 			comp,
 			g,
+			mux,
 			probe,
 			reg,
 			logger,
 			grpcLogOpts,
 			tagOpts,
 			*symbolCache,
+			symbolStoreOpts,
+			time.Duration(*symbolGCInterval),
 			objStoreConfig,
 			*grpcBindAddr,
 			time.Duration(*grpcGracePeriod),
@@ -76,12 +133,15 @@ func registerSymbol(m map[string]setupFunc, app *kingpin.Application, name strin
 func runSymbol(
 	comp component.Component,
 	g *run.Group,
+	mux httpMux,
 	probe prober.Probe,
 	reg *prometheus.Registry,
 	logger log.Logger,
 	grpcLogOpts []grpc_logging.Option,
 	tagOpts []tags.Option,
 	symbolCache string,
+	symbolStoreOpts []symbol.SymbolStoreOption,
+	symbolGCInterval time.Duration,
 	objStoreConfig extflag.PathOrContent,
 	grpcBindAddr string,
 	grpcGracePeriod time.Duration,
@@ -105,7 +165,15 @@ func runSymbol(
 	if err != nil {
 		return nil, errors.Wrap(err, "create object store bucket client")
 	}
-	sym := symbol.NewSymbolStore(logger, bkt, symbolCache)
+	sym := symbol.NewSymbolStore(logger, reg, bkt, symbolCache, symbolStoreOpts...)
+	mux.Handle(symbol.DebuginfodPrefix, symbol.NewDebuginfodHandler(logger, bkt))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g.Add(func() error {
+		return sym.RunGarbageCollection(ctx, symbolGCInterval)
+	}, func(error) {
+		cancel()
+	})
 
 	srv := grpcserver.New(logger, reg, &opentracing.NoopTracer{}, grpcLogOpts, tagOpts, comp, grpcProbe,
 		grpcserver.WithServer(store.RegisterSymbolStore(sym)),
@@ -0,0 +1,235 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// debuginfoCache keeps debuginfo downloaded from object storage on local disk.
+// Once the cached files exceed maxSize bytes, the least recently used ones
+// that aren't in use are evicted. A maxSize of zero disables the limit.
+type debuginfoCache struct {
+	logger  log.Logger
+	dir     string
+	maxSize int64
+
+	mtx     sync.Mutex
+	size    int64
+	lru     *list.List
+	entries map[string]*list.Element
+
+	hits      prometheus.Counter
+	misses    prometheus.Counter
+	evictions prometheus.Counter
+	bytes     prometheus.Gauge
+}
+
+type cacheEntry struct {
+	id   string
+	size int64
+	// refs counts the users of the entry, which must not be evicted while
+	// they use it.
+	refs int
+}
+
+func newDebuginfoCache(logger log.Logger, reg prometheus.Registerer, dir string, maxSize int64) *debuginfoCache {
+	return &debuginfoCache{
+		logger:  logger,
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+		hits: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "conprof_symbol_cache_hits_total",
+			Help: "Total number of symbolizations served by debuginfo in the local cache.",
+		}),
+		misses: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "conprof_symbol_cache_misses_total",
+			Help: "Total number of symbolizations that weren't served by debuginfo in the local cache.",
+		}),
+		evictions: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "conprof_symbol_cache_evictions_total",
+			Help: "Total number of debuginfo files evicted from the local cache.",
+		}),
+		bytes: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "conprof_symbol_cache_size_bytes",
+			Help: "Size of the debuginfo files in the local cache.",
+		}),
+	}
+}
+
+func (c *debuginfoCache) path(id string) string {
+	return path.Join(c.dir, debuginfoPath(id))
+}
+
+// load adds the debuginfo cached by previous runs, least recently modified
+// ones are evicted first.
+func (c *debuginfoCache) load() error {
+	dirs, err := ioutil.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read cache directory: %w", err)
+	}
+
+	files := []os.FileInfo{}
+	ids := map[os.FileInfo]string{}
+	for _, d := range dirs {
+		if !d.IsDir() || validateId(d.Name()) != nil {
+			continue
+		}
+		fi, err := os.Stat(c.path(d.Name()))
+		if err != nil {
+			continue
+		}
+		files = append(files, fi)
+		ids[fi] = d.Name()
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, fi := range files {
+		if _, ok := c.entries[ids[fi]]; ok {
+			continue
+		}
+		c.entries[ids[fi]] = c.lru.PushBack(&cacheEntry{id: ids[fi], size: fi.Size()})
+		c.size += fi.Size()
+	}
+	c.evict()
+	return nil
+}
+
+// get returns the path of the cached debuginfo of id. The entry can't be
+// evicted until release is called.
+func (c *debuginfoCache) get(id string) (file string, release func(), ok bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.entries[id]
+	if !ok {
+		c.misses.Inc()
+		return "", nil, false
+	}
+	c.hits.Inc()
+	c.lru.MoveToFront(e)
+	return c.path(id), c.acquire(e), true
+}
+
+// add moves the file into the cache as the debuginfo of id and returns its
+// path like get does.
+func (c *debuginfoCache) add(id, file string) (string, func(), error) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := os.MkdirAll(path.Join(c.dir, id), 0700); err != nil {
+		return "", nil, fmt.Errorf("create object file directory: %w", err)
+	}
+	// Need to use rename to make the "creation" atomic.
+	if err := os.Rename(file, c.path(id)); err != nil {
+		return "", nil, fmt.Errorf("atomically move downloaded object file: %w", err)
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.entries[id]
+	if ok {
+		// Downloaded concurrently, the file was replaced.
+		entry := e.Value.(*cacheEntry)
+		c.size += fi.Size() - entry.size
+		entry.size = fi.Size()
+		c.lru.MoveToFront(e)
+	} else {
+		e = c.lru.PushFront(&cacheEntry{id: id, size: fi.Size()})
+		c.entries[id] = e
+		c.size += fi.Size()
+	}
+	release := c.acquire(e)
+	c.evict()
+	return c.path(id), release, nil
+}
+
+// remove deletes the cached debuginfo of id, unless it's in use.
+func (c *debuginfoCache) remove(id string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.entries[id]
+	if !ok || e.Value.(*cacheEntry).refs > 0 {
+		return
+	}
+	c.delete(e)
+	c.bytes.Set(float64(c.size))
+}
+
+func (c *debuginfoCache) acquire(e *list.Element) func() {
+	entry := e.Value.(*cacheEntry)
+	entry.refs++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mtx.Lock()
+			defer c.mtx.Unlock()
+			entry.refs--
+			c.evict()
+		})
+	}
+}
+
+// evict deletes least recently used entries until the cache fits maxSize.
+// Must be called with mtx held.
+func (c *debuginfoCache) evict() {
+	defer func() { c.bytes.Set(float64(c.size)) }()
+
+	if c.maxSize <= 0 {
+		return
+	}
+	for e := c.lru.Back(); e != nil && c.size > c.maxSize; {
+		prev := e.Prev()
+		if e.Value.(*cacheEntry).refs == 0 {
+			c.delete(e)
+			c.evictions.Inc()
+		}
+		e = prev
+	}
+}
+
+// delete must be called with mtx held.
+func (c *debuginfoCache) delete(e *list.Element) {
+	entry := e.Value.(*cacheEntry)
+	if err := os.RemoveAll(path.Join(c.dir, entry.id)); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to remove cached debuginfo", "id", entry.id, "err", err)
+	}
+	c.lru.Remove(e)
+	delete(c.entries, entry.id)
+	c.size -= entry.size
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func addToCache(t *testing.T, c *debuginfoCache, id string, size int) func() {
+	f, err := ioutil.TempFile(t.TempDir(), "debuginfo")
+	require.NoError(t, err)
+	_, err = f.Write(bytes.Repeat([]byte{0}, size))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	file, release, err := c.add(id, f.Name())
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(c.dir, id, "debuginfo"), file)
+	return release
+}
+
+func TestDebuginfoCacheEviction(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c := newDebuginfoCache(log.NewNopLogger(), reg, t.TempDir(), 250)
+
+	addToCache(t, c, "aaaa", 100)()
+	addToCache(t, c, "bbbb", 100)()
+
+	// Using aaaa makes bbbb the least recently used.
+	_, release, ok := c.get("aaaa")
+	require.True(t, ok)
+	release()
+
+	addToCache(t, c, "cccc", 100)()
+
+	_, _, ok = c.get("bbbb")
+	require.False(t, ok)
+	_, err := os.Stat(filepath.Join(c.dir, "bbbb"))
+	require.True(t, os.IsNotExist(err))
+
+	require.Equal(t, 1.0, testutil.ToFloat64(c.evictions))
+	require.Equal(t, 1.0, testutil.ToFloat64(c.hits))
+	require.Equal(t, 1.0, testutil.ToFloat64(c.misses))
+	require.Equal(t, 200.0, testutil.ToFloat64(c.bytes))
+}
+
+func TestDebuginfoCacheKeepsEntriesInUse(t *testing.T) {
+	c := newDebuginfoCache(log.NewNopLogger(), nil, t.TempDir(), 150)
+
+	release := addToCache(t, c, "aaaa", 100)
+	addToCache(t, c, "bbbb", 100)()
+
+	// aaaa is in use, so bbbb is evicted although used more recently.
+	_, _, ok := c.get("bbbb")
+	require.False(t, ok)
+	file, releaseAgain, ok := c.get("aaaa")
+	require.True(t, ok)
+	releaseAgain()
+
+	// The cache is allowed to exceed its size while entries are in use.
+	releaseLatest := addToCache(t, c, "cccc", 100)
+	_, err := os.Stat(file)
+	require.NoError(t, err)
+	require.Equal(t, int64(200), c.size)
+
+	// Once released, aaaa is evicted as the least recently used.
+	release()
+	releaseLatest()
+	_, _, ok = c.get("aaaa")
+	require.False(t, ok)
+	_, releaseAgain, ok = c.get("cccc")
+	require.True(t, ok)
+	releaseAgain()
+}
+
+func TestDebuginfoCacheLoad(t *testing.T) {
+	dir := t.TempDir()
+	c := newDebuginfoCache(log.NewNopLogger(), nil, dir, 0)
+	addToCache(t, c, "aaaa", 100)()
+	addToCache(t, c, "bbbb", 50)()
+	// Not debuginfo cached by a symbol store.
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "not-an-id"), 0700))
+
+	c = newDebuginfoCache(log.NewNopLogger(), nil, dir, 0)
+	require.NoError(t, c.load())
+	require.Equal(t, int64(150), c.size)
+
+	_, release, ok := c.get("aaaa")
+	require.True(t, ok)
+	release()
+	_, release, ok = c.get("bbbb")
+	require.True(t, ok)
+	release()
+}
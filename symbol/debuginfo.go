@@ -0,0 +1,141 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"path"
+	"time"
+)
+
+const (
+	debuginfoObject = "debuginfo"
+	metadataObject  = "metadata"
+)
+
+func debuginfoPath(id string) string {
+	return path.Join(id, debuginfoObject)
+}
+
+func metadataPath(id string) string {
+	return path.Join(id, metadataObject)
+}
+
+// DebuginfoSourceDebuginfod is the source of debuginfo downloaded from an
+// upstream debuginfod server.
+const DebuginfoSourceDebuginfod = "debuginfod"
+
+// DebuginfoMetadata is stored next to every uploaded debuginfo object.
+type DebuginfoMetadata struct {
+	// Size is the size of the uploaded file in bytes.
+	Size uint64 `json:"size"`
+	// StoredSize is the size of the stored object in bytes, which is smaller
+	// than Size if only the debug sections were kept.
+	StoredSize uint64 `json:"storedSize"`
+	// Extracted is true if only the debug sections of the upload are stored.
+	Extracted bool `json:"extracted"`
+	HasDWARF  bool `json:"hasDWARF"`
+	HasSymtab bool `json:"hasSymtab"`
+	// Source is where the debuginfo was obtained from if it wasn't
+	// uploaded.
+	Source string `json:"source,omitempty"`
+	// KernelReleases are the releases of the kernel with the build ID whose
+	// kallsyms were uploaded, the one uploaded last is last.
+	KernelReleases []string  `json:"kernelReleases,omitempty"`
+	UploadedAt     time.Time `json:"uploadedAt"`
+	// LastAccessedAt is the last time the debuginfo was used to symbolize,
+	// persisted when garbage collecting.
+	LastAccessedAt time.Time `json:"lastAccessedAt,omitempty"`
+}
+
+// validateDebuginfo checks that r is an ELF file with the given build ID
+// that carries DWARF or a symbol table, as otherwise it's useless for
+// symbolization. Binaries without build ID must be identified by the file ID
+// of the given file name.
+func validateDebuginfo(r io.ReaderAt, id, fileName string) (*DebuginfoMetadata, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("not an ELF file: %w", err)
+	}
+	defer f.Close()
+
+	buildID, err := BuildID(r)
+	switch {
+	case errors.Is(err, ErrNoBuildID) && fileName != "":
+		fileID, err := FileID(fileName, io.NewSectionReader(r, 0, math.MaxInt64))
+		if err != nil {
+			return nil, err
+		}
+		if fileID != id {
+			return nil, fmt.Errorf("file id %s of the uploaded file does not match %s", fileID, id)
+		}
+	case err != nil:
+		return nil, err
+	case buildID != id:
+		return nil, fmt.Errorf("build id %s of the uploaded file does not match %s", buildID, id)
+	}
+
+	m := &DebuginfoMetadata{}
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOBITS {
+			continue
+		}
+		switch s.Name {
+		case ".debug_info", ".zdebug_info":
+			m.HasDWARF = true
+		case ".symtab":
+			m.HasSymtab = true
+		}
+	}
+	if !m.HasDWARF && !m.HasSymtab {
+		return nil, fmt.Errorf("ELF file has neither DWARF nor a symbol table")
+	}
+	return m, nil
+}
+
+// extractDebugSections writes a copy of the ELF file at in to out, keeping
+// only the sections needed for symbolization.
+func extractDebugSections(ctx context.Context, in, out string) error {
+	stderr := bytes.NewBuffer(nil)
+	cmd := exec.CommandContext(ctx, "objcopy", "--only-keep-debug", in, out)
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("objcopy: %w: %s", err, stderr.String())
+	}
+	return nil
+}
+
+func unmarshalMetadata(b []byte) (*DebuginfoMetadata, error) {
+	m := &DebuginfoMetadata{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func marshalMetadata(m *DebuginfoMetadata) (io.Reader, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
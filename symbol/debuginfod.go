@@ -0,0 +1,214 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+// DebuginfodPrefix is the path prefix of the debuginfod protocol, see
+// https://www.mankier.com/8/debuginfod#Webapi.
+const DebuginfodPrefix = "/buildid/"
+
+// DebuginfodHandler serves the debuginfo in the bucket via the debuginfod
+// protocol, so gdb, perf and other tools can use it. Only the debuginfo and
+// executable artifacts are supported, the latter only if the whole binary was
+// stored.
+type DebuginfodHandler struct {
+	logger log.Logger
+	bucket objstore.BucketReader
+}
+
+func NewDebuginfodHandler(logger log.Logger, bucket objstore.BucketReader) *DebuginfodHandler {
+	return &DebuginfodHandler{
+		logger: logger,
+		bucket: bucket,
+	}
+}
+
+func (h *DebuginfodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, DebuginfodPrefix), "/")
+	if len(parts) != 2 || validateId(parts[0]) != nil {
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[0]
+
+	switch parts[1] {
+	case "debuginfo":
+	case "executable":
+		// Only the debug sections of executables are kept when extracting
+		// them.
+		meta, err := h.metadata(r.Context(), id)
+		if err != nil {
+			level.Error(h.logger).Log("msg", "failed to get debuginfo metadata", "id", id, "err", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if meta != nil && meta.Extracted {
+			http.NotFound(w, r)
+			return
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	attrs, err := h.bucket.Attributes(r.Context(), debuginfoPath(id))
+	if h.bucket.IsObjNotFoundErr(err) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		level.Error(h.logger).Log("msg", "failed to get debuginfo attributes", "id", id, "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(attrs.Size, 10))
+	w.Header().Set("X-Debuginfod-Size", strconv.FormatInt(attrs.Size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	rc, err := h.bucket.Get(r.Context(), debuginfoPath(id))
+	if err != nil {
+		level.Error(h.logger).Log("msg", "failed to get debuginfo", "id", id, "err", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	if _, err := io.Copy(w, rc); err != nil {
+		level.Debug(h.logger).Log("msg", "failed to send debuginfo", "id", id, "err", err)
+	}
+}
+
+func (h *DebuginfodHandler) metadata(ctx context.Context, id string) (*DebuginfoMetadata, error) {
+	rc, err := h.bucket.Get(ctx, metadataPath(id))
+	if h.bucket.IsObjNotFoundErr(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalMetadata(b)
+}
+
+// ErrDebuginfoNotFound is returned if none of the upstream servers has the
+// requested debuginfo.
+var ErrDebuginfoNotFound = errors.New("debuginfo not found")
+
+// DebuginfodClient fetches debuginfo from upstream debuginfod servers, for
+// example a distribution's mirror. Build IDs none of them has are remembered
+// for a while, to not ask for them on every request.
+type DebuginfodClient struct {
+	logger    log.Logger
+	client    *http.Client
+	upstreams []string
+
+	notFoundTTL time.Duration
+	notFound    *lru.Cache
+}
+
+func NewDebuginfodClient(logger log.Logger, upstreams []string, timeout time.Duration) *DebuginfodClient {
+	// Only fails for non-positive sizes.
+	notFound, _ := lru.New(10000)
+	return &DebuginfodClient{
+		logger:      logger,
+		client:      &http.Client{Timeout: timeout},
+		upstreams:   upstreams,
+		notFoundTTL: 10 * time.Minute,
+		notFound:    notFound,
+	}
+}
+
+// Download writes the debuginfo of the build ID from the first upstream server
+// that has it to the file.
+func (c *DebuginfodClient) Download(ctx context.Context, id string, file *os.File) error {
+	if t, ok := c.notFound.Get(id); ok && time.Since(t.(time.Time)) < c.notFoundTTL {
+		return ErrDebuginfoNotFound
+	}
+
+	for _, upstream := range c.upstreams {
+		err := c.download(ctx, strings.TrimSuffix(upstream, "/")+DebuginfodPrefix+id+"/debuginfo", file)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrDebuginfoNotFound) {
+			level.Warn(c.logger).Log("msg", "failed to download debuginfo from upstream", "upstream", upstream, "id", id, "err", err)
+		}
+		if err := resetFile(file); err != nil {
+			return err
+		}
+	}
+
+	c.notFound.Add(id, time.Now())
+	return ErrDebuginfoNotFound
+}
+
+func (c *DebuginfodClient) download(ctx context.Context, url string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return ErrDebuginfoNotFound
+	case res.StatusCode/100 != 2:
+		return fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+func resetFile(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
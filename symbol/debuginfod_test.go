@@ -0,0 +1,124 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestDebuginfodHandler(t *testing.T) {
+	ctx := context.Background()
+	bucket := objstore.NewInMemBucket()
+	require.NoError(t, bucket.Upload(ctx, debuginfoPath("aaaa"), bytes.NewReader([]byte("full binary"))))
+	require.NoError(t, bucket.Upload(ctx, debuginfoPath("bbbb"), bytes.NewReader([]byte("debug sections"))))
+	m, err := marshalMetadata(&DebuginfoMetadata{Extracted: true})
+	require.NoError(t, err)
+	require.NoError(t, bucket.Upload(ctx, metadataPath("bbbb"), m))
+
+	srv := httptest.NewServer(NewDebuginfodHandler(log.NewNopLogger(), bucket))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{method: http.MethodGet, path: "/buildid/aaaa/debuginfo", status: http.StatusOK, body: "full binary"},
+		{method: http.MethodGet, path: "/buildid/aaaa/executable", status: http.StatusOK, body: "full binary"},
+		{method: http.MethodHead, path: "/buildid/aaaa/debuginfo", status: http.StatusOK},
+		{method: http.MethodGet, path: "/buildid/bbbb/debuginfo", status: http.StatusOK, body: "debug sections"},
+		// Only the debug sections were kept.
+		{method: http.MethodGet, path: "/buildid/bbbb/executable", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/buildid/cccc/debuginfo", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/buildid/aaaa/source/main.c", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/buildid/not-an-id/debuginfo", status: http.StatusNotFound},
+		{method: http.MethodPost, path: "/buildid/aaaa/debuginfo", status: http.StatusMethodNotAllowed},
+	} {
+		req, err := http.NewRequest(tc.method, srv.URL+tc.path, nil)
+		require.NoError(t, err)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		b, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		res.Body.Close()
+
+		require.Equal(t, tc.status, res.StatusCode, tc.method+" "+tc.path)
+		if tc.status == http.StatusOK {
+			require.Equal(t, tc.body, string(b), tc.method+" "+tc.path)
+			require.Equal(t, "application/octet-stream", res.Header.Get("Content-Type"))
+		}
+	}
+}
+
+func TestSymbolStoreDebuginfodFallback(t *testing.T) {
+	ctx := context.Background()
+
+	b, err := ioutil.ReadFile(testBinaryPath)
+	require.NoError(t, err)
+	upstreamBucket := objstore.NewInMemBucket()
+	require.NoError(t, upstreamBucket.Upload(ctx, debuginfoPath(testBinaryID), bytes.NewReader(b)))
+
+	var requests int32
+	handler := NewDebuginfodHandler(log.NewNopLogger(), upstreamBucket)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		handler.ServeHTTP(w, r)
+	}))
+	defer upstream.Close()
+	// Upstreams without the debuginfo are skipped.
+	empty := httptest.NewServer(http.NotFoundHandler())
+	defer empty.Close()
+
+	bucket := objstore.NewInMemBucket()
+	s := NewSymbolStore(log.NewNopLogger(), nil, bucket, t.TempDir(),
+		WithDebuginfod(NewDebuginfodClient(log.NewNopLogger(), []string{empty.URL, upstream.URL + "/"}, time.Minute)),
+	)
+
+	file, release, err := s.fetch(ctx, testBinaryID)
+	require.NoError(t, err)
+	require.NotNil(t, release)
+	release()
+	cached, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, b, cached)
+
+	// The debuginfo is stored in the bucket.
+	require.Equal(t, b, bucket.Objects()[debuginfoPath(testBinaryID)])
+	meta, err := unmarshalMetadata(bucket.Objects()[metadataPath(testBinaryID)])
+	require.NoError(t, err)
+	require.Equal(t, DebuginfoSourceDebuginfod, meta.Source)
+	require.Equal(t, uint64(len(b)), meta.Size)
+	require.True(t, meta.HasDWARF)
+
+	// Missing debuginfo is only requested once for a while.
+	_, release, err = s.fetch(ctx, "abcd")
+	require.NoError(t, err)
+	require.Nil(t, release)
+	_, release, err = s.fetch(ctx, "abcd")
+	require.NoError(t, err)
+	require.Nil(t, release)
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
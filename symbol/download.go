@@ -0,0 +1,166 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/status"
+	"google.golang.org/grpc/codes"
+
+	"github.com/conprof/conprof/pkg/store/storepb"
+)
+
+// ErrBinaryNotFound is returned if the binary of a build ID isn't stored.
+var ErrBinaryNotFound = errors.New("binary not found")
+
+// errOnlyDebugSections is returned for binaries stored without their code.
+var errOnlyDebugSections = errors.New("only the debug sections of the binary are stored")
+
+const downloadChunkSize = 64 * 1024
+
+// BinaryDownloader downloads the binaries of build IDs, for example to
+// disassemble them.
+type BinaryDownloader interface {
+	DownloadBinary(ctx context.Context, buildID string, w io.Writer) error
+}
+
+// DownloadBinary writes the stored binary of the build ID to w.
+func (s *SymbolStore) DownloadBinary(ctx context.Context, id string, w io.Writer) error {
+	if err := validateId(id); err != nil {
+		return err
+	}
+	meta, err := s.metadata(ctx, id)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		return ErrBinaryNotFound
+	}
+	if meta.Extracted {
+		return errOnlyDebugSections
+	}
+
+	file, release, err := s.fetch(ctx, id)
+	if err != nil {
+		return err
+	}
+	if release == nil {
+		return ErrBinaryNotFound
+	}
+	defer release()
+	s.touch(id)
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Download streams the stored binary of the build ID.
+func (s *SymbolStore) Download(req *storepb.DownloadRequest, stream storepb.SymbolStore_DownloadServer) error {
+	err := s.DownloadBinary(stream.Context(), req.BuildId, &downloadWriter{stream: stream})
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrBinaryNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, errOnlyDebugSections):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case validateId(req.BuildId) != nil:
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		level.Error(s.logger).Log("msg", "failed to download binary", "id", req.BuildId, "err", err)
+		return status.Error(codes.Internal, "failed to download binary")
+	}
+}
+
+type downloadWriter struct {
+	stream storepb.SymbolStore_DownloadServer
+}
+
+func (w *downloadWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > downloadChunkSize {
+			chunk = chunk[:downloadChunkSize]
+		}
+		if err := w.stream.Send(&storepb.DownloadResponse{ChunkData: chunk}); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+// DownloadBinary writes the binary of the build ID to w.
+func (c *SymbolStoreClient) DownloadBinary(ctx context.Context, buildID string, w io.Writer) error {
+	stream, err := c.c.Download(ctx, &storepb.DownloadRequest{BuildId: buildID})
+	if err != nil {
+		return fmt.Errorf("initiate download: %w", err)
+	}
+	return receiveDownload(stream, w)
+}
+
+func receiveDownload(stream storepb.SymbolStore_DownloadClient, w io.Writer) error {
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if status.Code(err) == codes.NotFound {
+			return ErrBinaryNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("receive download: %w", err)
+		}
+		if _, err := w.Write(res.ChunkData); err != nil {
+			return err
+		}
+	}
+}
+
+// DownloadClient can download binaries from a symbol store.
+type DownloadClient interface {
+	Download(context.Context, *storepb.DownloadRequest) (storepb.SymbolStore_DownloadClient, error)
+}
+
+// DownloadBinary writes the binary of the build ID to w, using the symbol
+// store the symbolizer uses. It returns ErrBinaryNotFound if the binary isn't
+// stored, or the symbolizer's client can't download binaries.
+func (s *Symbolizer) DownloadBinary(ctx context.Context, buildID string, w io.Writer) error {
+	switch c := s.c.(type) {
+	case BinaryDownloader:
+		return c.DownloadBinary(ctx, buildID, w)
+	case DownloadClient:
+		stream, err := c.Download(ctx, &storepb.DownloadRequest{BuildId: buildID})
+		if err != nil {
+			return fmt.Errorf("initiate download: %w", err)
+		}
+		return receiveDownload(stream, w)
+	default:
+		return ErrBinaryNotFound
+	}
+}
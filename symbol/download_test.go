@@ -0,0 +1,50 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestDownloadBinary(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	c, stop := newTestSymbolStoreClient(t, bucket)
+	defer stop()
+
+	_, _, err := c.UploadFile(context.Background(), testBinaryPath, "")
+	require.NoError(t, err)
+
+	expected, err := ioutil.ReadFile(testBinaryPath)
+	require.NoError(t, err)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, c.DownloadBinary(context.Background(), testBinaryID, buf))
+	require.Equal(t, expected, buf.Bytes())
+
+	err = c.DownloadBinary(context.Background(), "0123456789abcdef", ioutil.Discard)
+	require.True(t, errors.Is(err, ErrBinaryNotFound))
+
+	s := NewSymbolizer(log.NewNopLogger(), NewSymbolStore(log.NewNopLogger(), nil, bucket, t.TempDir()))
+	buf.Reset()
+	require.NoError(t, s.DownloadBinary(context.Background(), testBinaryID, buf))
+	require.Equal(t, expected, buf.Bytes())
+}
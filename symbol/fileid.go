@@ -0,0 +1,181 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/conprof/conprof/pkg/store/storepb"
+)
+
+// Binaries without a GNU build ID, for example Go binaries whose build ID
+// isn't propagated to profile mappings, are identified by the path they run
+// at and the hash of their content instead. The ID has the length of a SHA-1
+// build ID, so it's stored like any other debuginfo. As mappings only carry
+// the path of the binary, every upload is recorded under the path with the
+// size of the binary. A mapping is resolved to the only upload for its path
+// that is large enough to contain it, it isn't resolved if there are several.
+
+const (
+	fileIndexDir = "names"
+	// fileIDLength is the length of hex encoded file IDs.
+	fileIDLength = 40
+	// fileIDCacheTTL is how long the uploads of a path are cached, so that
+	// redeployed binaries are picked up eventually.
+	fileIDCacheTTL = time.Minute
+)
+
+// FileID returns the ID identifying a binary without GNU build ID by the path
+// it runs at and its content.
+func FileID(file string, r io.Reader) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(file))
+	h.Write([]byte{0})
+	if _, err := io.Copy(h, r); err != nil {
+		return "", fmt.Errorf("hash binary: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:fileIDLength], nil
+}
+
+// symbolizableFile returns whether the mapped file can be identified by its
+// path. Pseudo files like [vdso] or [kernel.kallsyms] can't be uploaded.
+func symbolizableFile(file string) bool {
+	return file != "" && !strings.HasPrefix(path.Base(file), "[")
+}
+
+// fileIndexPrefix is the prefix of the entries of the uploads for the path.
+func fileIndexPrefix(file string) string {
+	return path.Join(fileIndexDir, url.PathEscape(file)) + objstore.DirDelim
+}
+
+func fileIndexPath(file, id string) string {
+	return fileIndexPrefix(file) + id
+}
+
+type fileIndexEntry struct {
+	ID         string    `json:"id"`
+	Size       uint64    `json:"size"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+// contains returns whether the binary of the entry is large enough to
+// contain the mapping.
+func (e fileIndexEntry) contains(m *storepb.Mapping) bool {
+	if m.MemoryLimit <= m.MemoryStart {
+		return true
+	}
+	return m.FileOffset+m.MemoryLimit-m.MemoryStart <= e.Size
+}
+
+type cachedFileEntries struct {
+	entries    []fileIndexEntry
+	resolvedAt time.Time
+}
+
+// indexFile records id as the debuginfo of the binary of the size running at
+// the path.
+func (s *SymbolStore) indexFile(ctx context.Context, file, id string, size uint64) error {
+	b, err := json.Marshal(fileIndexEntry{ID: id, Size: size, UploadedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err := s.bucket.Upload(ctx, fileIndexPath(file, id), bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("upload file index of %s: %w", file, err)
+	}
+	s.fileIDs.Remove(file)
+	return nil
+}
+
+// resolveFile returns the ID of the debuginfo of the binary mapped by m,
+// identified by its path and size. It returns an empty string if there is
+// none, or if there are several it can't tell apart.
+func (s *SymbolStore) resolveFile(ctx context.Context, m *storepb.Mapping) (string, error) {
+	entries, err := s.fileEntries(ctx, m.File)
+	if err != nil {
+		return "", err
+	}
+
+	id := ""
+	for _, e := range entries {
+		if !e.contains(m) || e.ID == id {
+			continue
+		}
+		if id != "" {
+			level.Debug(s.logger).Log("msg", "several binaries uploaded for the mapped file, not symbolizing it", "file", m.File, "id", id, "other", e.ID)
+			return "", nil
+		}
+		id = e.ID
+	}
+	return id, nil
+}
+
+// fileEntries returns the entries of the uploads for the path.
+func (s *SymbolStore) fileEntries(ctx context.Context, file string) ([]fileIndexEntry, error) {
+	if c, ok := s.fileIDs.Get(file); ok && time.Since(c.(cachedFileEntries).resolvedAt) < fileIDCacheTTL {
+		return c.(cachedFileEntries).entries, nil
+	}
+
+	var entries []fileIndexEntry
+	err := s.bucket.Iter(ctx, fileIndexPrefix(file), func(name string) error {
+		e, err := s.readFileIndexEntry(ctx, name)
+		if err != nil || e == nil {
+			return err
+		}
+		entries = append(entries, *e)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterate file index of %s: %w", file, err)
+	}
+
+	s.fileIDs.Add(file, cachedFileEntries{entries: entries, resolvedAt: time.Now()})
+	return entries, nil
+}
+
+// readFileIndexEntry returns the file index entry of the object, or nil if it
+// doesn't exist anymore or is malformed.
+func (s *SymbolStore) readFileIndexEntry(ctx context.Context, name string) (*fileIndexEntry, error) {
+	r, err := s.bucket.Get(ctx, name)
+	if s.bucket.IsObjNotFoundErr(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get file index entry %s: %w", name, err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read file index entry %s: %w", name, err)
+	}
+	e := &fileIndexEntry{}
+	if err := json.Unmarshal(b, e); err != nil {
+		level.Warn(s.logger).Log("msg", "ignoring malformed file index entry", "name", name, "err", err)
+		return nil, nil
+	}
+	return e, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/conprof/conprof/pkg/store/storepb"
+)
+
+func TestResolveFile(t *testing.T) {
+	ctx := context.Background()
+	s := NewSymbolStore(log.NewNopLogger(), nil, objstore.NewInMemBucket(), t.TempDir())
+
+	resolve := func(file string, offset uint64) string {
+		id, err := s.resolveFile(ctx, &storepb.Mapping{File: file, MemoryStart: 0x1000, MemoryLimit: 0x1100, FileOffset: offset})
+		require.NoError(t, err)
+		return id
+	}
+
+	// Different binaries with the same base name are told apart by their
+	// path.
+	require.NoError(t, s.indexFile(ctx, "/opt/a/app", "aaaa", 1000))
+	require.NoError(t, s.indexFile(ctx, "/opt/b/app", "bbbb", 2000))
+	require.Equal(t, "aaaa", resolve("/opt/a/app", 0))
+	require.Equal(t, "bbbb", resolve("/opt/b/app", 0))
+	require.Equal(t, "", resolve("/opt/c/app", 0))
+
+	// Uploading the same binary again keeps resolving it.
+	require.NoError(t, s.indexFile(ctx, "/opt/a/app", "aaaa", 1000))
+	require.Equal(t, "aaaa", resolve("/opt/a/app", 0))
+
+	// Every upload is kept, mappings are only resolved to the binaries large
+	// enough to contain them, and not at all if that's ambiguous.
+	require.NoError(t, s.indexFile(ctx, "/opt/a/app", "cccc", 500))
+	require.Equal(t, "", resolve("/opt/a/app", 0))
+	require.Equal(t, "aaaa", resolve("/opt/a/app", 600))
+	require.Equal(t, "", resolve("/opt/a/app", 1000))
+	require.Equal(t, "bbbb", resolve("/opt/b/app", 0))
+}
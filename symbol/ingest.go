@@ -0,0 +1,365 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/conprof/db/storage"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/conprof/conprof/pkg/store"
+)
+
+const (
+	// DefaultIngestRetryInterval is the default interval of retrying to
+	// symbolize stored profiles.
+	DefaultIngestRetryInterval = time.Minute
+	// DefaultIngestMaxAge is the default duration stored profiles are retried
+	// for at most, waiting for their debuginfo.
+	DefaultIngestMaxAge = 24 * time.Hour
+	// DefaultIngestMaxPending is the default number of stored profiles
+	// retried at most.
+	DefaultIngestMaxPending = 10000
+)
+
+// IngestSymbolizer symbolizes profiles as they are appended to the
+// underlying storage, so they don't have to be symbolized by every query.
+//
+// Profiles are appended right away with the locations resolved so far, so
+// they are written to the WAL like any other. Profiles with locations that
+// can't be resolved yet, as their debuginfo isn't uploaded yet, are retried
+// in the background once they can be rewritten, and replaced by the rewriter
+// when they are symbolized completely. Until then, or if they aren't within
+// the max age, they are symbolized when queried instead.
+type IngestSymbolizer struct {
+	logger log.Logger
+	app    storage.Appendable
+	s      *Symbolizer
+	r      store.ProfileRewriter
+
+	retryInterval time.Duration
+	maxAge        time.Duration
+	maxPending    int
+
+	mtx     sync.Mutex
+	queue   []pendingProfile
+	pending prometheus.Gauge
+
+	resolvedLater prometheus.Counter
+	incomplete    prometheus.Counter
+}
+
+// pendingProfile is a stored profile with unresolved locations.
+type pendingProfile struct {
+	lset     labels.Labels
+	t        int64
+	queuedAt time.Time
+}
+
+type IngestOption func(*IngestSymbolizer)
+
+// WithIngestRewriter retries to symbolize stored profiles with unresolved
+// locations, and replaces them with the rewriter once they are symbolized.
+func WithIngestRewriter(r store.ProfileRewriter) IngestOption {
+	return func(i *IngestSymbolizer) {
+		i.r = r
+	}
+}
+
+// WithIngestRetryInterval sets the interval of retrying to symbolize stored
+// profiles.
+func WithIngestRetryInterval(d time.Duration) IngestOption {
+	return func(i *IngestSymbolizer) {
+		i.retryInterval = d
+	}
+}
+
+// WithIngestMaxAge sets how long stored profiles are retried at most.
+func WithIngestMaxAge(d time.Duration) IngestOption {
+	return func(i *IngestSymbolizer) {
+		i.maxAge = d
+	}
+}
+
+// WithIngestMaxPending limits the number of stored profiles retried, further
+// ones are only symbolized when queried.
+func WithIngestMaxPending(n int) IngestOption {
+	return func(i *IngestSymbolizer) {
+		i.maxPending = n
+	}
+}
+
+func NewIngestSymbolizer(logger log.Logger, reg prometheus.Registerer, app storage.Appendable, s *Symbolizer, opts ...IngestOption) *IngestSymbolizer {
+	i := &IngestSymbolizer{
+		logger:        logger,
+		app:           app,
+		s:             s,
+		retryInterval: DefaultIngestRetryInterval,
+		maxAge:        DefaultIngestMaxAge,
+		maxPending:    DefaultIngestMaxPending,
+		pending: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "conprof_ingest_symbolization_pending_profiles",
+			Help: "Number of stored profiles retried until their locations can be symbolized.",
+		}),
+		resolvedLater: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "conprof_ingest_symbolization_resolved_later_total",
+			Help: "Total number of stored profiles that were symbolized completely and rewritten.",
+		}),
+		incomplete: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "conprof_ingest_symbolization_incomplete_total",
+			Help: "Total number of profiles stored with locations that couldn't be symbolized.",
+		}),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+func (i *IngestSymbolizer) Appender(ctx context.Context) storage.Appender {
+	return &ingestAppender{i: i, ctx: ctx, app: i.app.Appender(ctx)}
+}
+
+// Run retries to symbolize stored profiles until the context is canceled.
+func (i *IngestSymbolizer) Run(ctx context.Context) error {
+	if i.r == nil {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(i.retryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := i.retry(ctx, time.Now()); err != nil {
+				level.Warn(i.logger).Log("msg", "failed to rewrite symbolized profiles", "err", err)
+			}
+		}
+	}
+}
+
+// enqueue adds stored profiles to be retried.
+func (i *IngestSymbolizer) enqueue(profiles []pendingProfile) {
+	if i.r == nil || len(profiles) == 0 {
+		return
+	}
+
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+	for _, p := range profiles {
+		if len(i.queue) >= i.maxPending {
+			level.Debug(i.logger).Log("msg", "too many pending profiles, symbolizing profile on query", "series", p.lset.String(), "t", p.t)
+			continue
+		}
+		i.queue = append(i.queue, p)
+	}
+	i.pending.Set(float64(len(i.queue)))
+}
+
+// retry symbolizes the pending profiles that can be rewritten, and rewrites
+// the ones symbolized completely. Profiles older than the max age are
+// dropped.
+func (i *IngestSymbolizer) retry(ctx context.Context, now time.Time) error {
+	before := i.r.RewritableBefore()
+
+	i.mtx.Lock()
+	var (
+		retry      []pendingProfile
+		mint, maxt int64 = math.MaxInt64, math.MinInt64
+	)
+	queue := i.queue[:0]
+	for _, p := range i.queue {
+		switch {
+		case p.t < before:
+			retry = append(retry, p)
+			if p.t < mint {
+				mint = p.t
+			}
+			if p.t > maxt {
+				maxt = p.t
+			}
+		case now.Sub(p.queuedAt) <= i.maxAge:
+			queue = append(queue, p)
+		}
+	}
+	i.queue = queue
+	i.pending.Set(float64(len(i.queue)))
+	i.mtx.Unlock()
+
+	if len(retry) == 0 {
+		return nil
+	}
+
+	var (
+		requeue   []pendingProfile
+		rewritten []store.Profile
+	)
+	q, err := i.r.Querier(ctx, mint, maxt)
+	if err != nil {
+		i.requeue(retry, now)
+		return err
+	}
+	for _, p := range retry {
+		v, ok, err := storedProfile(q, p.lset, p.t)
+		if err != nil {
+			q.Close()
+			i.requeue(retry, now)
+			return err
+		}
+		if !ok {
+			// The profile was deleted meanwhile.
+			continue
+		}
+
+		v, complete := i.symbolize(ctx, v)
+		if !complete {
+			requeue = append(requeue, p)
+			continue
+		}
+		rewritten = append(rewritten, store.Profile{Labels: p.lset, T: p.t, V: v})
+	}
+	q.Close()
+
+	if err := i.r.Rewrite(ctx, rewritten); err != nil {
+		i.requeue(retry, now)
+		return err
+	}
+	i.resolvedLater.Add(float64(len(rewritten)))
+	i.requeue(requeue, now)
+	return nil
+}
+
+// requeue adds profiles that were retried back to the queue, unless they are
+// older than the max age.
+func (i *IngestSymbolizer) requeue(profiles []pendingProfile, now time.Time) {
+	var keep []pendingProfile
+	for _, p := range profiles {
+		if now.Sub(p.queuedAt) <= i.maxAge {
+			keep = append(keep, p)
+		}
+	}
+	i.enqueue(keep)
+}
+
+func storedProfile(q storage.Querier, lset labels.Labels, t int64) ([]byte, bool, error) {
+	ms := make([]*labels.Matcher, 0, len(lset))
+	for _, l := range lset {
+		ms = append(ms, labels.MustNewMatcher(labels.MatchEqual, l.Name, l.Value))
+	}
+
+	ss := q.Select(false, &storage.SelectHints{Start: t, End: t}, ms...)
+	for ss.Next() {
+		s := ss.At()
+		if !labels.Equal(s.Labels(), lset) {
+			continue
+		}
+		it := s.Iterator()
+		if !it.Seek(t) {
+			return nil, false, it.Err()
+		}
+		st, v := it.At()
+		return v, st == t, nil
+	}
+	return nil, false, ss.Err()
+}
+
+// symbolize returns the profile with the locations resolved so far and
+// whether all of them are resolved. Symbolized profiles are encoded
+// uncompressed, like scraped ones.
+func (i *IngestSymbolizer) symbolize(ctx context.Context, v []byte) ([]byte, bool) {
+	p, err := profile.ParseData(v)
+	if err != nil {
+		// Not a pprof profile, nothing to symbolize.
+		return v, true
+	}
+	if unresolvedLocations(p) == 0 {
+		return v, true
+	}
+
+	if err := i.s.Symbolize(ctx, p); err != nil {
+		level.Warn(i.logger).Log("msg", "failed to symbolize profile at ingest", "err", err)
+		return v, false
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := p.WriteUncompressed(buf); err != nil {
+		level.Warn(i.logger).Log("msg", "failed to encode symbolized profile", "err", err)
+		return v, false
+	}
+	return buf.Bytes(), unresolvedLocations(p) == 0
+}
+
+func unresolvedLocations(p *profile.Profile) int {
+	n := 0
+	for _, l := range p.Location {
+		if needsSymbolization(l) {
+			n++
+		}
+	}
+	return n
+}
+
+type ingestAppender struct {
+	i   *IngestSymbolizer
+	ctx context.Context
+	app storage.Appender
+
+	incomplete []pendingProfile
+}
+
+func (a *ingestAppender) Add(l labels.Labels, t int64, v []byte) (uint64, error) {
+	v, complete := a.i.symbolize(a.ctx, v)
+	ref, err := a.app.Add(l, t, v)
+	if err != nil {
+		return 0, err
+	}
+	if !complete {
+		a.i.incomplete.Inc()
+		level.Debug(a.i.logger).Log("msg", "storing profile with unresolved locations", "series", l.String(), "t", t)
+		a.incomplete = append(a.incomplete, pendingProfile{lset: l.Copy(), t: t, queuedAt: time.Now()})
+	}
+	return ref, nil
+}
+
+// AddFast always fails, so that profiles are appended by Add and symbolized.
+func (a *ingestAppender) AddFast(ref uint64, t int64, v []byte) error {
+	return storage.ErrNotFound
+}
+
+func (a *ingestAppender) Commit() error {
+	if err := a.app.Commit(); err != nil {
+		return err
+	}
+	a.i.enqueue(a.incomplete)
+	a.incomplete = nil
+	return nil
+}
+
+func (a *ingestAppender) Rollback() error {
+	a.incomplete = nil
+	return a.app.Rollback()
+}
@@ -0,0 +1,199 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/conprof/db/storage"
+	"github.com/conprof/db/tsdb"
+	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/conprof/conprof/pkg/chunkenc"
+	"github.com/conprof/conprof/pkg/store"
+	"github.com/conprof/conprof/pkg/store/storepb"
+	conproftestutil "github.com/conprof/conprof/pkg/testutil"
+)
+
+type appendedSample struct {
+	lset labels.Labels
+	t    int64
+	v    []byte
+}
+
+type fakeAppendable struct {
+	samples []appendedSample
+}
+
+func (a *fakeAppendable) Appender(_ context.Context) storage.Appender {
+	return &fakeAppender{a: a}
+}
+
+type fakeAppender struct {
+	a       *fakeAppendable
+	samples []appendedSample
+}
+
+func (a *fakeAppender) Add(l labels.Labels, t int64, v []byte) (uint64, error) {
+	a.samples = append(a.samples, appendedSample{lset: l, t: t, v: v})
+	return 0, nil
+}
+
+func (a *fakeAppender) AddFast(ref uint64, t int64, v []byte) error {
+	return storage.ErrNotFound
+}
+
+func (a *fakeAppender) Commit() error {
+	a.a.samples = append(a.a.samples, a.samples...)
+	return nil
+}
+
+func (a *fakeAppender) Rollback() error {
+	return nil
+}
+
+// uploadableSymbolizeClient only resolves addresses of uploaded build IDs.
+type uploadableSymbolizeClient struct {
+	uploaded map[string]bool
+}
+
+func (c *uploadableSymbolizeClient) Symbolize(ctx context.Context, req *storepb.SymbolizeRequest) (*storepb.SymbolizeResponse, error) {
+	for _, m := range req.Mappings {
+		if !c.uploaded[m.BuildId] {
+			continue
+		}
+		for _, l := range m.Locations {
+			l.Lines = []*storepb.Line{{Line: 1, Function: &storepb.Function{Name: m.BuildId}}}
+		}
+	}
+	return &storepb.SymbolizeResponse{Mappings: req.Mappings}, nil
+}
+
+func nativeProfile(t *testing.T, buildID string) []byte {
+	m := &profile.Mapping{ID: 1, Start: 0x1000, Limit: 0x2000, BuildID: buildID}
+	l := &profile.Location{ID: 1, Mapping: m, Address: 0x1010}
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+		Sample:     []*profile.Sample{{Location: []*profile.Location{l}, Value: []int64{1}}},
+		Location:   []*profile.Location{l},
+		Mapping:    []*profile.Mapping{m},
+	}
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, p.Write(buf))
+	return buf.Bytes()
+}
+
+func requireSymbolized(t *testing.T, v []byte, symbolized bool) {
+	p, err := profile.ParseData(v)
+	require.NoError(t, err)
+	require.Equal(t, symbolized, len(p.Location[0].Line) > 0)
+}
+
+func TestIngestSymbolizer(t *testing.T) {
+	db := &fakeAppendable{}
+	c := &uploadableSymbolizeClient{uploaded: map[string]bool{"aaaa": true}}
+	i := NewIngestSymbolizer(log.NewNopLogger(), nil, db, NewSymbolizer(log.NewNopLogger(), c))
+
+	series1 := labels.FromStrings("job", "a")
+	series2 := labels.FromStrings("job", "b")
+	series3 := labels.FromStrings("job", "c")
+
+	app := i.Appender(context.Background())
+	_, err := app.Add(series1, 1, nativeProfile(t, "aaaa"))
+	require.NoError(t, err)
+	// bbbb isn't uploaded yet, the profile is stored right away anyway.
+	_, err = app.Add(series2, 1, nativeProfile(t, "bbbb"))
+	require.NoError(t, err)
+	// Not a profile at all.
+	_, err = app.Add(series3, 1, []byte("not a profile"))
+	require.NoError(t, err)
+	require.Empty(t, db.samples)
+	require.NoError(t, app.Commit())
+
+	require.Len(t, db.samples, 3)
+	require.Equal(t, series1, db.samples[0].lset)
+	requireSymbolized(t, db.samples[0].v, true)
+	require.Equal(t, series2, db.samples[1].lset)
+	requireSymbolized(t, db.samples[1].v, false)
+	require.Equal(t, []byte("not a profile"), db.samples[2].v)
+	require.Equal(t, 1.0, testutil.ToFloat64(i.incomplete))
+
+	// Symbolized profiles are stored uncompressed, like scraped ones.
+	require.False(t, bytes.HasPrefix(db.samples[0].v, []byte{0x1f, 0x8b}))
+}
+
+func TestIngestSymbolizerDebuginfoUploadedLater(t *testing.T) {
+	ctx := context.Background()
+	db, err := conproftestutil.NewTSDB()
+	require.NoError(t, err)
+	defer db.Close()
+
+	c := &uploadableSymbolizeClient{uploaded: map[string]bool{}}
+	i := NewIngestSymbolizer(log.NewNopLogger(), nil, store.NewCompressingAppendable(db, chunkenc.CodecZstd), NewSymbolizer(log.NewNopLogger(), c),
+		WithIngestRewriter(store.NewTSDBProfileRewriter(log.NewNopLogger(), db, chunkenc.CodecZstd)),
+		WithIngestMaxAge(time.Hour),
+	)
+
+	series := labels.FromStrings("job", "a")
+	app := i.Appender(ctx)
+	_, err = app.Add(series, 1, nativeProfile(t, "aaaa"))
+	require.NoError(t, err)
+	_, err = app.Add(series, 2, nativeProfile(t, "bbbb"))
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+	require.Equal(t, 2.0, testutil.ToFloat64(i.pending))
+
+	stored := func() map[int64][]byte {
+		q, err := store.NewDecompressingQueryable(db).Querier(ctx, 0, 10)
+		require.NoError(t, err)
+		defer q.Close()
+		res := map[int64][]byte{}
+		for _, ts := range []int64{1, 2} {
+			v, ok, err := storedProfile(q, series, ts)
+			require.NoError(t, err)
+			require.True(t, ok)
+			res[ts] = v
+		}
+		return res
+	}
+
+	// The debuginfo of aaaa is uploaded after the profile was stored, but the
+	// profiles in the head can't be rewritten yet.
+	c.uploaded["aaaa"] = true
+	now := time.Now()
+	require.NoError(t, i.retry(ctx, now))
+	requireSymbolized(t, stored()[1], false)
+	require.Equal(t, 2.0, testutil.ToFloat64(i.pending))
+
+	// Once the profiles are compacted into a block, the one whose debuginfo
+	// is uploaded is rewritten symbolized.
+	require.NoError(t, db.CompactHead(tsdb.NewRangeHead(db.Head(), 0, 2)))
+	require.NoError(t, i.retry(ctx, now))
+	profiles := stored()
+	requireSymbolized(t, profiles[1], true)
+	requireSymbolized(t, profiles[2], false)
+	require.Equal(t, 1.0, testutil.ToFloat64(i.resolvedLater))
+	require.Equal(t, 1.0, testutil.ToFloat64(i.pending))
+
+	// Profiles are retried up to the max age.
+	require.NoError(t, i.retry(ctx, now.Add(2*time.Hour)))
+	require.Equal(t, 0.0, testutil.ToFloat64(i.pending))
+}
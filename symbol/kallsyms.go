@@ -0,0 +1,284 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conprof/conprof/pkg/store/storepb"
+)
+
+// kallsymsDir holds the kallsyms snapshots of a kernel build ID, one per
+// kernel release.
+const kallsymsDir = "kallsyms"
+
+func kallsymsPath(id, release string) string {
+	return path.Join(id, kallsymsDir, url.PathEscape(release))
+}
+
+// kernelSymbol is a function symbol of the kernel or one of its modules.
+type kernelSymbol struct {
+	addr   uint64
+	name   string
+	module string
+}
+
+// kallsyms are the function symbols of a /proc/kallsyms snapshot, sorted by
+// address.
+type kallsyms struct {
+	symbols []kernelSymbol
+	// text is the address of the start of the kernel text, the addresses of
+	// kernel mappings are relative to it if they have a start address.
+	text uint64
+}
+
+// parseKallsyms parses the function symbols of a /proc/kallsyms snapshot.
+// Snapshots taken without the privileges to see addresses are rejected, as
+// all of their addresses are zero.
+func parseKallsyms(r io.Reader) (*kallsyms, error) {
+	k := &kallsyms{}
+	stext := uint64(0)
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// Lines look like "ffffffff81000000 T _text" or
+		// "ffffffffc0a1b000 t ext4_fill_super\t[ext4]".
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("malformed kallsyms line %q", s.Text())
+		}
+		addr, err := strconv.ParseUint(fields[0], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed kallsyms address %q: %w", fields[0], err)
+		}
+		switch fields[2] {
+		case "_text":
+			k.text = addr
+		case "_stext":
+			stext = addr
+		}
+		switch fields[1] {
+		case "t", "T", "w", "W":
+		default:
+			continue
+		}
+		if addr == 0 {
+			continue
+		}
+		sym := kernelSymbol{addr: addr, name: fields[2]}
+		if len(fields) > 3 {
+			sym.module = strings.Trim(fields[3], "[]")
+		}
+		k.symbols = append(k.symbols, sym)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("read kallsyms: %w", err)
+	}
+	if len(k.symbols) == 0 {
+		return nil, errors.New("kallsyms contain no function addresses, they must be read with the privileges to see them")
+	}
+	if k.text == 0 {
+		k.text = stext
+	}
+
+	sort.SliceStable(k.symbols, func(i, j int) bool {
+		return k.symbols[i].addr < k.symbols[j].addr
+	})
+	return k, nil
+}
+
+// lookup returns the symbol at or nearest preceding the address.
+func (k *kallsyms) lookup(addr uint64) (kernelSymbol, bool) {
+	i := sort.Search(len(k.symbols), func(i int) bool {
+		return k.symbols[i].addr > addr
+	})
+	if i == 0 {
+		return kernelSymbol{}, false
+	}
+	return k.symbols[i-1], true
+}
+
+// storeKallsyms uploads the validated kallsyms snapshot in file as the
+// symbols of the kernel with the release and build ID. The release is added
+// to the metadata of the build ID, which might also describe uploaded
+// debuginfo.
+func (s *SymbolStore) storeKallsyms(ctx context.Context, id, release string, file *os.File, size uint64) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := s.bucket.Upload(ctx, kallsymsPath(id, release), file); err != nil {
+		return fmt.Errorf("upload kallsyms: %w", err)
+	}
+	s.kernels.Remove(id)
+
+	meta, err := s.metadata(ctx, id)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		meta = &DebuginfoMetadata{
+			Size:       size,
+			StoredSize: size,
+		}
+	}
+	meta.HasSymtab = true
+	releases := []string{}
+	for _, r := range meta.KernelReleases {
+		if r != release {
+			releases = append(releases, r)
+		}
+	}
+	meta.KernelReleases = append(releases, release)
+	meta.UploadedAt = time.Now()
+	return s.uploadMetadata(ctx, id, meta)
+}
+
+// deleteKallsyms deletes the kallsyms snapshots of all releases of the build
+// ID.
+func (s *SymbolStore) deleteKallsyms(ctx context.Context, id string) error {
+	names := []string{}
+	err := s.bucket.Iter(ctx, path.Join(id, kallsymsDir), func(name string) error {
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("iterate kallsyms of %s: %w", id, err)
+	}
+	for _, name := range names {
+		if err := s.bucket.Delete(ctx, name); err != nil && !s.bucket.IsObjNotFoundErr(err) {
+			return fmt.Errorf("delete %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// kallsyms returns the parsed kallsyms of the kernel with the build ID, or nil
+// if none were uploaded. Profiles don't record the kernel release, so the
+// snapshot of the release uploaded last is used.
+func (s *SymbolStore) kallsyms(ctx context.Context, id string) (*kallsyms, error) {
+	if k, ok := s.kernels.Get(id); ok {
+		return k.(*kallsyms), nil
+	}
+
+	meta, err := s.metadata(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil || len(meta.KernelReleases) == 0 {
+		return nil, nil
+	}
+	release := meta.KernelReleases[len(meta.KernelReleases)-1]
+
+	r, err := s.bucket.Get(ctx, kallsymsPath(id, release))
+	if s.bucket.IsObjNotFoundErr(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get kallsyms of %s: %w", id, err)
+	}
+	defer r.Close()
+
+	k, err := parseKallsyms(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse kallsyms of %s: %w", id, err)
+	}
+	s.kernels.Add(id, k)
+	return k, nil
+}
+
+// symbolizeKernel resolves the locations of a kernel mapping to the symbols
+// nearest preceding them. Kernel symbols carry no line information.
+func (s *SymbolStore) symbolizeKernel(ctx context.Context, m *storepb.Mapping) error {
+	if m.BuildId == "" {
+		return nil
+	}
+	k, err := s.kallsyms(ctx, m.BuildId)
+	if err != nil {
+		return err
+	}
+	if k == nil {
+		return nil
+	}
+	s.touch(m.BuildId)
+
+	for _, location := range m.Locations {
+		addr := location.Address
+		if m.MemoryStart != 0 && k.text != 0 {
+			// The kernel might be loaded elsewhere than when the
+			// snapshot was taken.
+			addr = addr - m.MemoryStart + k.text
+		}
+		sym, ok := k.lookup(addr)
+		if !ok {
+			continue
+		}
+		location.Lines = []*storepb.Line{{
+			Function: &storepb.Function{
+				Name:     sym.name,
+				Filename: sym.module,
+			},
+		}}
+	}
+	return nil
+}
+
+// isKernelFile returns whether the mapped file is the kernel, the way perf
+// names it.
+func isKernelFile(file string) bool {
+	return strings.HasPrefix(file, "[kernel.kallsyms]")
+}
+
+const noteTypeGNUBuildID = 3
+
+// KernelBuildID returns the hex encoded GNU build ID from the ELF notes of the
+// running kernel, as found in /sys/kernel/notes. The notes are in the byte
+// order of the host, which is assumed to be little endian.
+func KernelBuildID(r io.Reader) (string, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	align := func(n uint32) uint32 { return (n + 3) &^ 3 }
+	for len(b) >= 12 {
+		namesz := binary.LittleEndian.Uint32(b[0:4])
+		descsz := binary.LittleEndian.Uint32(b[4:8])
+		typ := binary.LittleEndian.Uint32(b[8:12])
+		b = b[12:]
+		if uint64(len(b)) < uint64(align(namesz))+uint64(align(descsz)) {
+			break
+		}
+		name := strings.TrimRight(string(b[:namesz]), "\x00")
+		desc := b[align(namesz) : align(namesz)+descsz]
+		if name == "GNU" && typ == noteTypeGNUBuildID {
+			return hex.EncodeToString(desc), nil
+		}
+		b = b[align(namesz)+align(descsz):]
+	}
+	return "", ErrNoBuildID
+}
@@ -0,0 +1,184 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gogo/status"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	testKernelBuildID = "5b9f1c4a2e6d8f0a1b3c5d7e9f0a2b4c6d8e0f1a"
+	testKallsyms      = `ffffffff81000000 T _text
+ffffffff81000000 T startup_64
+ffffffff81001000 t do_one_initcall
+ffffffff81002000 D some_data
+ffffffff81003000 T schedule
+ffffffffc0a1b000 t ext4_fill_super	[ext4]
+`
+)
+
+func TestParseKallsyms(t *testing.T) {
+	k, err := parseKallsyms(strings.NewReader(testKallsyms))
+	require.NoError(t, err)
+	require.Equal(t, uint64(0xffffffff81000000), k.text)
+
+	for _, tc := range []struct {
+		addr   uint64
+		name   string
+		module string
+	}{
+		{addr: 0xffffffff81000010, name: "startup_64"},
+		{addr: 0xffffffff81001000, name: "do_one_initcall"},
+		// Data symbols are skipped.
+		{addr: 0xffffffff81002010, name: "do_one_initcall"},
+		{addr: 0xffffffff81003100, name: "schedule"},
+		{addr: 0xffffffffc0a1b100, name: "ext4_fill_super", module: "ext4"},
+	} {
+		sym, ok := k.lookup(tc.addr)
+		require.True(t, ok)
+		require.Equal(t, tc.name, sym.name)
+		require.Equal(t, tc.module, sym.module)
+	}
+
+	_, ok := k.lookup(0x1000)
+	require.False(t, ok)
+
+	// Unprivileged reads of kallsyms only have zero addresses.
+	_, err = parseKallsyms(strings.NewReader("0000000000000000 T _text\n0000000000000000 T schedule\n"))
+	require.Error(t, err)
+}
+
+func TestKernelBuildID(t *testing.T) {
+	note := func(name string, typ uint32, desc []byte) []byte {
+		b := bytes.NewBuffer(nil)
+		binary.Write(b, binary.LittleEndian, uint32(len(name)+1))
+		binary.Write(b, binary.LittleEndian, uint32(len(desc)))
+		binary.Write(b, binary.LittleEndian, typ)
+		b.WriteString(name + "\x00")
+		for b.Len()%4 != 0 {
+			b.WriteByte(0)
+		}
+		b.Write(desc)
+		for b.Len()%4 != 0 {
+			b.WriteByte(0)
+		}
+		return b.Bytes()
+	}
+
+	notes := append(note("Xen", 1, []byte{1, 2, 3}), note("GNU", noteTypeGNUBuildID, []byte{0xab, 0xcd, 0xef, 0x01})...)
+	id, err := KernelBuildID(bytes.NewReader(notes))
+	require.NoError(t, err)
+	require.Equal(t, "abcdef01", id)
+
+	_, err = KernelBuildID(bytes.NewReader(note("Xen", 1, []byte{1, 2, 3})))
+	require.True(t, errors.Is(err, ErrNoBuildID))
+}
+
+func TestSymbolizeKernel(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	c, stop := newTestSymbolStoreClient(t, bucket)
+	defer stop()
+
+	_, err := c.UploadKallsyms(context.Background(), "5.10.0-8-amd64", testKernelBuildID, strings.NewReader("0000000000000000 T schedule\n"))
+	require.Equal(t, codes.InvalidArgument, status.Code(errors.Unwrap(err)))
+
+	size, err := c.UploadKallsyms(context.Background(), "5.10.0-8-amd64", testKernelBuildID, strings.NewReader(testKallsyms))
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(testKallsyms)), size)
+
+	meta, err := unmarshalMetadata(bucket.Objects()[testKernelBuildID+"/metadata"])
+	require.NoError(t, err)
+	require.Equal(t, []string{"5.10.0-8-amd64"}, meta.KernelReleases)
+
+	st := NewSymbolStore(log.NewNopLogger(), nil, bucket, t.TempDir())
+	s := NewSymbolizer(log.NewNopLogger(), st)
+
+	// The kernel is loaded 0x200000 bytes higher than when the snapshot was
+	// taken.
+	kernel := &profile.Mapping{
+		ID:      1,
+		Start:   0xffffffff81200000,
+		Limit:   0xffffffff82200000,
+		File:    "[kernel.kallsyms]_text",
+		BuildID: testKernelBuildID,
+	}
+	user := &profile.Mapping{
+		ID:      2,
+		Start:   0x400000,
+		Limit:   0x500000,
+		File:    "/usr/bin/app",
+		BuildID: "aaaa",
+	}
+	p := &profile.Profile{
+		Location: []*profile.Location{
+			{ID: 1, Mapping: kernel, Address: 0xffffffff81203010},
+			{ID: 2, Mapping: kernel, Address: 0xffffffff81201010},
+			{ID: 3, Mapping: user, Address: 0x401000},
+		},
+		Mapping: []*profile.Mapping{kernel, user},
+	}
+	require.NoError(t, s.Symbolize(context.Background(), p))
+
+	require.Len(t, p.Location[0].Line, 1)
+	require.Equal(t, "schedule", p.Location[0].Line[0].Function.Name)
+	require.Len(t, p.Location[1].Line, 1)
+	require.Equal(t, "do_one_initcall", p.Location[1].Line[0].Function.Name)
+	require.Empty(t, p.Location[2].Line)
+	require.NoError(t, p.CheckValid())
+}
+
+func TestStoreKallsymsPerRelease(t *testing.T) {
+	ctx := context.Background()
+	bucket := objstore.NewInMemBucket()
+	c, stop := newTestSymbolStoreClient(t, bucket)
+	defer stop()
+
+	// The debuginfo of the kernel was uploaded before.
+	uploadTestDebuginfo(t, bucket, testKernelBuildID, &DebuginfoMetadata{Size: 9, StoredSize: 9, HasDWARF: true})
+
+	for _, release := range []string{"5.10.0-8-amd64", "5.10.0-8-rt-amd64", "5.10.0-8-amd64"} {
+		_, err := c.UploadKallsyms(ctx, release, testKernelBuildID, strings.NewReader(testKallsyms))
+		require.NoError(t, err)
+	}
+	for _, release := range []string{"5.10.0-8-amd64", "5.10.0-8-rt-amd64"} {
+		exists, err := bucket.Exists(ctx, kallsymsPath(testKernelBuildID, release))
+		require.NoError(t, err)
+		require.True(t, exists, release)
+	}
+
+	meta, err := unmarshalMetadata(bucket.Objects()[metadataPath(testKernelBuildID)])
+	require.NoError(t, err)
+	require.Equal(t, []string{"5.10.0-8-rt-amd64", "5.10.0-8-amd64"}, meta.KernelReleases)
+	require.Equal(t, uint64(9), meta.Size)
+	require.True(t, meta.HasDWARF)
+	require.True(t, meta.HasSymtab)
+
+	st := NewSymbolStore(log.NewNopLogger(), nil, bucket, t.TempDir())
+	require.NoError(t, st.deleteKallsyms(ctx, testKernelBuildID))
+	exists, err := bucket.Exists(ctx, kallsymsPath(testKernelBuildID, "5.10.0-8-rt-amd64"))
+	require.NoError(t, err)
+	require.False(t, exists)
+}
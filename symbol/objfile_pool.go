@@ -0,0 +1,128 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/conprof/conprof/internal/pprof/plugin"
+)
+
+// objFileKey identifies an opened object file, which depends on the mapping
+// it was opened for.
+type objFileKey struct {
+	buildID              string
+	start, limit, offset uint64
+}
+
+// pooledObjFile is an opened object file, together with the function
+// releasing the cached debuginfo it was opened from.
+type pooledObjFile struct {
+	key     objFileKey
+	f       plugin.ObjFile
+	release func()
+}
+
+func (f *pooledObjFile) close() {
+	f.f.Close()
+	f.release()
+}
+
+// objFilePool keeps up to maxIdle object files open, so that subsequent
+// requests don't have to open them, which for example starts a
+// llvm-symbolizer process. Each object file is used by a single request at a
+// time; the least recently returned ones are closed first.
+type objFilePool struct {
+	maxIdle int
+
+	mtx  sync.Mutex
+	lru  *list.List
+	idle map[objFileKey][]*list.Element
+}
+
+func newObjFilePool(maxIdle int) *objFilePool {
+	return &objFilePool{
+		maxIdle: maxIdle,
+		lru:     list.New(),
+		idle:    map[objFileKey][]*list.Element{},
+	}
+}
+
+// get takes an idle object file out of the pool.
+func (p *objFilePool) get(key objFileKey) (*pooledObjFile, bool) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	elems := p.idle[key]
+	if len(elems) == 0 {
+		return nil, false
+	}
+	e := elems[len(elems)-1]
+	p.remove(e)
+	return e.Value.(*pooledObjFile), true
+}
+
+// put returns an object file to the pool, it's closed if the pool is full.
+func (p *objFilePool) put(f *pooledObjFile) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.maxIdle <= 0 {
+		f.close()
+		return
+	}
+	p.idle[f.key] = append(p.idle[f.key], p.lru.PushFront(f))
+	for p.lru.Len() > p.maxIdle {
+		e := p.lru.Back()
+		p.remove(e)
+		e.Value.(*pooledObjFile).close()
+	}
+}
+
+// drop closes the idle object files of the build ID.
+func (p *objFilePool) drop(buildID string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for key, elems := range p.idle {
+		if key.buildID != buildID {
+			continue
+		}
+		for _, e := range elems {
+			p.lru.Remove(e)
+			e.Value.(*pooledObjFile).close()
+		}
+		delete(p.idle, key)
+	}
+}
+
+// remove must be called with mtx held.
+func (p *objFilePool) remove(e *list.Element) {
+	f := e.Value.(*pooledObjFile)
+	p.lru.Remove(e)
+
+	elems := p.idle[f.key]
+	for i := range elems {
+		if elems[i] == e {
+			elems = append(elems[:i], elems[i+1:]...)
+			break
+		}
+	}
+	if len(elems) == 0 {
+		delete(p.idle, f.key)
+		return
+	}
+	p.idle[f.key] = elems
+}
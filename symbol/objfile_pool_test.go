@@ -0,0 +1,76 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/conprof/conprof/internal/pprof/plugin"
+)
+
+type fakeObjFile struct {
+	plugin.ObjFile
+	closed bool
+}
+
+func (f *fakeObjFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+func newFakePooledObjFile(buildID string, released *int) (*pooledObjFile, *fakeObjFile) {
+	f := &fakeObjFile{}
+	return &pooledObjFile{
+		key:     objFileKey{buildID: buildID},
+		f:       f,
+		release: func() { *released++ },
+	}, f
+}
+
+func TestObjFilePool(t *testing.T) {
+	p := newObjFilePool(2)
+	released := 0
+
+	a, fa := newFakePooledObjFile("aaaa", &released)
+	b, fb := newFakePooledObjFile("bbbb", &released)
+	c, fc := newFakePooledObjFile("cccc", &released)
+
+	p.put(a)
+	p.put(b)
+	got, ok := p.get(objFileKey{buildID: "aaaa"})
+	require.True(t, ok)
+	require.Equal(t, a, got)
+	_, ok = p.get(objFileKey{buildID: "aaaa"})
+	require.False(t, ok)
+
+	// a was taken out, so b is the least recently returned one.
+	p.put(a)
+	p.put(c)
+	require.True(t, fb.closed)
+	require.False(t, fa.closed)
+	require.False(t, fc.closed)
+	require.Equal(t, 1, released)
+	_, ok = p.get(objFileKey{buildID: "bbbb"})
+	require.False(t, ok)
+
+	p.drop("cccc")
+	require.True(t, fc.closed)
+	require.Equal(t, 2, released)
+	_, ok = p.get(objFileKey{buildID: "cccc"})
+	require.False(t, ok)
+	_, ok = p.get(objFileKey{buildID: "aaaa"})
+	require.True(t, ok)
+}
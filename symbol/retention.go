@@ -0,0 +1,361 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	conprofchunkenc "github.com/conprof/conprof/pkg/chunkenc"
+)
+
+// touch records that the debuginfo of id was used. Accesses are kept in
+// memory and only persisted to the metadata when garbage collecting, to not
+// write to object storage on every request.
+func (s *SymbolStore) touch(id string) {
+	s.accessMtx.Lock()
+	defer s.accessMtx.Unlock()
+	s.accessed[id] = time.Now()
+}
+
+// RunGarbageCollection garbage collects every interval until the context is
+// canceled. It does nothing unless a retention is configured.
+func (s *SymbolStore) RunGarbageCollection(ctx context.Context, interval time.Duration) error {
+	if s.retention <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := s.GarbageCollect(ctx); err != nil {
+			level.Error(s.logger).Log("msg", "failed to garbage collect debuginfo", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// GarbageCollect deletes the debuginfo that wasn't uploaded or used to
+// symbolize within the retention, unless the mappings of stored profiles
+// still reference it. The file index entries of deleted debuginfo are deleted
+// with it.
+func (s *SymbolStore) GarbageCollect(ctx context.Context) error {
+	s.gcMtx.Lock()
+	defer s.gcMtx.Unlock()
+
+	ids := []string{}
+	err := s.bucket.Iter(ctx, "", func(name string) error {
+		id := strings.TrimSuffix(name, objstore.DirDelim)
+		if id != name && validateId(id) == nil {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("iterate debuginfo: %w", err)
+	}
+
+	index, err := s.fileIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	referenced := map[string]struct{}{}
+	if s.references != nil && s.retention > 0 {
+		refs, err := s.referenced(ctx, now)
+		if err != nil {
+			return err
+		}
+		for id := range refs.buildIDs {
+			referenced[id] = struct{}{}
+		}
+		for file := range refs.files {
+			for _, e := range index[file] {
+				referenced[e.ID] = struct{}{}
+			}
+		}
+	}
+
+	existing := map[string]struct{}{}
+	for _, id := range ids {
+		meta, err := s.metadata(ctx, id)
+		if err != nil {
+			return err
+		}
+		if meta == nil {
+			continue
+		}
+
+		s.accessMtx.Lock()
+		accessed, ok := s.accessed[id]
+		s.accessMtx.Unlock()
+		if ok && accessed.After(meta.LastAccessedAt) {
+			meta.LastAccessedAt = accessed
+			if err := s.uploadMetadata(ctx, id, meta); err != nil {
+				return err
+			}
+			s.accessMtx.Lock()
+			if s.accessed[id].Equal(accessed) {
+				delete(s.accessed, id)
+			}
+			s.accessMtx.Unlock()
+		}
+
+		lastUsed := meta.UploadedAt
+		if meta.LastAccessedAt.After(lastUsed) {
+			lastUsed = meta.LastAccessedAt
+		}
+		if _, ok := referenced[id]; ok || s.retention <= 0 || now.Sub(lastUsed) <= s.retention {
+			existing[id] = struct{}{}
+			continue
+		}
+
+		for _, name := range []string{debuginfoPath(id), metadataPath(id)} {
+			if err := s.bucket.Delete(ctx, name); err != nil && !s.bucket.IsObjNotFoundErr(err) {
+				return fmt.Errorf("delete %s: %w", name, err)
+			}
+		}
+		if err := s.deleteSources(ctx, id); err != nil {
+			return err
+		}
+		if err := s.deleteKallsyms(ctx, id); err != nil {
+			return err
+		}
+		s.objFiles.drop(id)
+		s.cache.remove(id)
+		s.kernels.Remove(id)
+		s.gcDeleted.Inc()
+		level.Debug(s.logger).Log("msg", "deleted expired debuginfo", "id", id, "lastused", lastUsed)
+	}
+
+	for file, entries := range index {
+		for _, e := range entries {
+			if _, ok := existing[e.ID]; ok {
+				continue
+			}
+			// The entry is written after the debuginfo is uploaded, so
+			// entries of uploads in progress are spared.
+			if s.retention <= 0 || now.Sub(e.UploadedAt) <= s.retention {
+				continue
+			}
+			if err := s.bucket.Delete(ctx, fileIndexPath(file, e.ID)); err != nil && !s.bucket.IsObjNotFoundErr(err) {
+				return fmt.Errorf("delete file index entry of %s: %w", file, err)
+			}
+			s.fileIDs.Remove(file)
+			level.Debug(s.logger).Log("msg", "deleted file index entry of expired debuginfo", "file", file, "id", e.ID)
+		}
+	}
+	return nil
+}
+
+// fileIndex returns the file index entries by file path.
+func (s *SymbolStore) fileIndex(ctx context.Context) (map[string][]fileIndexEntry, error) {
+	index := map[string][]fileIndexEntry{}
+	err := s.bucket.Iter(ctx, fileIndexDir+objstore.DirDelim, func(dir string) error {
+		if !strings.HasSuffix(dir, objstore.DirDelim) {
+			return nil
+		}
+		file, err := url.PathUnescape(path.Base(dir))
+		if err != nil {
+			return nil
+		}
+		return s.bucket.Iter(ctx, dir, func(name string) error {
+			e, err := s.readFileIndexEntry(ctx, name)
+			if err != nil || e == nil {
+				return err
+			}
+			index[file] = append(index[file], *e)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterate file index: %w", err)
+	}
+	return index, nil
+}
+
+// referencesSettled is how old stored profiles are before the references of
+// their mappings are cached. Profiles older than the head of the TSDB, which
+// spans one and a half block ranges, aren't appended anymore, so the
+// references of older profiles only change when the profiles are deleted.
+const referencesSettled = 24 * time.Hour
+
+// references are the build IDs and the file paths of the binaries without
+// build ID referenced by the mappings of stored profiles.
+type references struct {
+	buildIDs map[string]struct{}
+	files    map[string]struct{}
+}
+
+func newReferences() *references {
+	return &references{
+		buildIDs: map[string]struct{}{},
+		files:    map[string]struct{}{},
+	}
+}
+
+func (r *references) merge(o *references) {
+	for id := range o.buildIDs {
+		r.buildIDs[id] = struct{}{}
+	}
+	for file := range o.files {
+		r.files[file] = struct{}{}
+	}
+}
+
+// referenceCache holds the references of the profiles stored before until.
+type referenceCache struct {
+	*references
+	until     int64
+	scannedAt time.Time
+}
+
+// referenced returns the references of all stored profiles. Only the
+// profiles of the last referencesSettled are read on every call, the
+// references of older profiles are cached. As profiles deleted since are only
+// noticed by reading them again, the cache is renewed every retention, which
+// delays deleting debuginfo that isn't referenced anymore by at most one
+// retention.
+func (s *SymbolStore) referenced(ctx context.Context, now time.Time) (*references, error) {
+	settled := timestamp.FromTime(now.Add(-referencesSettled))
+	switch {
+	case s.refCache == nil || now.Sub(s.refCache.scannedAt) > s.retention:
+		refs, err := s.scanReferences(ctx, math.MinInt64, settled-1)
+		if err != nil {
+			return nil, err
+		}
+		s.refCache = &referenceCache{references: refs, until: settled, scannedAt: now}
+	case settled > s.refCache.until:
+		refs, err := s.scanReferences(ctx, s.refCache.until, settled-1)
+		if err != nil {
+			return nil, err
+		}
+		s.refCache.merge(refs)
+		s.refCache.until = settled
+	}
+
+	refs, err := s.scanReferences(ctx, s.refCache.until, math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+	refs.merge(s.refCache.references)
+	return refs, nil
+}
+
+// scanReferences reads the profiles stored from mint to maxt and returns the
+// references of their mappings.
+func (s *SymbolStore) scanReferences(ctx context.Context, mint, maxt int64) (*references, error) {
+	q, err := s.references.Querier(ctx, mint, maxt)
+	if err != nil {
+		return nil, fmt.Errorf("query stored profiles: %w", err)
+	}
+	defer q.Close()
+
+	refs := newReferences()
+	set := q.Select(false, nil, labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, ".+"))
+	for set.Next() {
+		it := set.At().Iterator()
+		for it.Next() {
+			_, b := it.At()
+			b, err := conprofchunkenc.Decompress(b)
+			if err != nil {
+				return nil, err
+			}
+			p, err := profile.ParseData(b)
+			if err != nil {
+				level.Debug(s.logger).Log("msg", "skipping unparsable profile", "series", set.At().Labels(), "err", err)
+				continue
+			}
+			for _, m := range p.Mapping {
+				switch {
+				case m.BuildID != "":
+					refs.buildIDs[m.BuildID] = struct{}{}
+				case symbolizableFile(m.File):
+					refs.files[m.File] = struct{}{}
+				}
+			}
+		}
+		if err := it.Err(); err != nil {
+			return nil, fmt.Errorf("iterate stored profiles: %w", err)
+		}
+	}
+	if err := set.Err(); err != nil {
+		return nil, fmt.Errorf("select stored profiles: %w", err)
+	}
+	return refs, nil
+}
+
+// metadata returns the metadata of the debuginfo of id, or nil if there is no
+// debuginfo. Debuginfo uploaded before metadata was recorded is treated as
+// uploaded when it was last modified.
+func (s *SymbolStore) metadata(ctx context.Context, id string) (*DebuginfoMetadata, error) {
+	r, err := s.bucket.Get(ctx, metadataPath(id))
+	if err == nil {
+		defer r.Close()
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("read metadata of %s: %w", id, err)
+		}
+		meta, err := unmarshalMetadata(b)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal metadata of %s: %w", id, err)
+		}
+		return meta, nil
+	}
+	if !s.bucket.IsObjNotFoundErr(err) {
+		return nil, fmt.Errorf("get metadata of %s: %w", id, err)
+	}
+
+	attrs, err := s.bucket.Attributes(ctx, debuginfoPath(id))
+	if s.bucket.IsObjNotFoundErr(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get attributes of %s: %w", id, err)
+	}
+	return &DebuginfoMetadata{
+		Size:       uint64(attrs.Size),
+		StoredSize: uint64(attrs.Size),
+		UploadedAt: attrs.LastModified,
+	}, nil
+}
+
+func (s *SymbolStore) uploadMetadata(ctx context.Context, id string, meta *DebuginfoMetadata) error {
+	r, err := marshalMetadata(meta)
+	if err != nil {
+		return err
+	}
+	if err := s.bucket.Upload(ctx, metadataPath(id), r); err != nil {
+		return fmt.Errorf("upload metadata of %s: %w", id, err)
+	}
+	return nil
+}
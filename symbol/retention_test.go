@@ -0,0 +1,229 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/conprof/db/storage"
+	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/conprof/conprof/pkg/store/storepb"
+	conproftestutil "github.com/conprof/conprof/pkg/testutil"
+)
+
+func uploadTestDebuginfo(t *testing.T, bucket objstore.Bucket, id string, meta *DebuginfoMetadata) {
+	ctx := context.Background()
+	require.NoError(t, bucket.Upload(ctx, debuginfoPath(id), bytes.NewReader([]byte("debuginfo"))))
+	if meta != nil {
+		b, err := json.Marshal(meta)
+		require.NoError(t, err)
+		require.NoError(t, bucket.Upload(ctx, metadataPath(id), bytes.NewReader(b)))
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	ctx := context.Background()
+	bucket := objstore.NewInMemBucket()
+	s := NewSymbolStore(log.NewNopLogger(), nil, bucket, t.TempDir(), WithRetention(time.Hour))
+
+	old := time.Now().Add(-2 * time.Hour)
+	uploadTestDebuginfo(t, bucket, "aaaa", &DebuginfoMetadata{UploadedAt: time.Now()})
+	uploadTestDebuginfo(t, bucket, "bbbb", &DebuginfoMetadata{UploadedAt: old})
+	uploadTestDebuginfo(t, bucket, "cccc", &DebuginfoMetadata{UploadedAt: old})
+	uploadTestDebuginfo(t, bucket, "dddd", &DebuginfoMetadata{UploadedAt: old, LastAccessedAt: time.Now()})
+	// Uploaded without metadata just now.
+	uploadTestDebuginfo(t, bucket, "eeee", nil)
+
+	// Symbolizing with cccc keeps it.
+	res, err := s.Symbolize(ctx, &storepb.SymbolizeRequest{
+		Mappings: []*storepb.Mapping{{BuildId: "cccc", Locations: []*storepb.Location{{Address: 0x1000}}}},
+	})
+	require.NoError(t, err)
+	// Not an actual object file, so the location stays unresolved.
+	require.Empty(t, res.Mappings[0].Locations[0].Lines)
+
+	require.NoError(t, s.GarbageCollect(ctx))
+
+	for _, id := range []string{"aaaa", "cccc", "dddd", "eeee"} {
+		exists, err := bucket.Exists(ctx, debuginfoPath(id))
+		require.NoError(t, err)
+		require.True(t, exists, id)
+	}
+	for _, name := range []string{debuginfoPath("bbbb"), metadataPath("bbbb")} {
+		exists, err := bucket.Exists(ctx, name)
+		require.NoError(t, err)
+		require.False(t, exists, name)
+	}
+	require.Equal(t, 1.0, testutil.ToFloat64(s.gcDeleted))
+
+	// The access was persisted.
+	meta, err := s.metadata(ctx, "cccc")
+	require.NoError(t, err)
+	require.True(t, meta.LastAccessedAt.After(old))
+	require.Empty(t, s.accessed)
+}
+
+func TestGarbageCollectReferenced(t *testing.T) {
+	ctx := context.Background()
+	bucket := objstore.NewInMemBucket()
+
+	db, err := conproftestutil.NewTSDB()
+	require.NoError(t, err)
+	defer db.Close()
+
+	s := NewSymbolStore(log.NewNopLogger(), nil, bucket, t.TempDir(), WithRetention(time.Hour), WithReferences(db))
+
+	old := time.Now().Add(-2 * time.Hour)
+	uploadTestDebuginfo(t, bucket, "aaaa", &DebuginfoMetadata{UploadedAt: old})
+	uploadTestDebuginfo(t, bucket, "bbbb", &DebuginfoMetadata{UploadedAt: old})
+	uploadTestDebuginfo(t, bucket, "cccc", &DebuginfoMetadata{UploadedAt: old})
+	uploadTestDebuginfo(t, bucket, "dddd", &DebuginfoMetadata{UploadedAt: old})
+
+	for file, id := range map[string]string{"/usr/bin/app": "cccc", "/usr/bin/tool": "dddd", "/usr/bin/removed": "eeee"} {
+		b, err := json.Marshal(fileIndexEntry{ID: id, UploadedAt: old})
+		require.NoError(t, err)
+		require.NoError(t, bucket.Upload(ctx, fileIndexPath(file, id), bytes.NewReader(b)))
+	}
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+		Mapping: []*profile.Mapping{
+			{ID: 1, BuildID: "aaaa"},
+			{ID: 2, File: "/usr/bin/app"},
+		},
+	}
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, p.Write(buf))
+	app := db.Appender(ctx)
+	_, err = app.Add(labels.FromStrings(labels.MetricName, "heap"), 1, buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, app.Commit())
+
+	require.NoError(t, s.GarbageCollect(ctx))
+
+	for name, expected := range map[string]bool{
+		debuginfoPath("aaaa"):                     true,
+		debuginfoPath("bbbb"):                     false,
+		debuginfoPath("cccc"):                     true,
+		debuginfoPath("dddd"):                     false,
+		fileIndexPath("/usr/bin/app", "cccc"):     true,
+		fileIndexPath("/usr/bin/tool", "dddd"):    false,
+		fileIndexPath("/usr/bin/removed", "eeee"): false,
+	} {
+		exists, err := bucket.Exists(ctx, name)
+		require.NoError(t, err)
+		require.Equal(t, expected, exists, name)
+	}
+	require.Equal(t, 2.0, testutil.ToFloat64(s.gcDeleted))
+}
+
+// queryRecorder records the time ranges its queriers are created for.
+type queryRecorder struct {
+	storage.Queryable
+	ranges [][2]int64
+}
+
+func (q *queryRecorder) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	q.ranges = append(q.ranges, [2]int64{mint, maxt})
+	return q.Queryable.Querier(ctx, mint, maxt)
+}
+
+func TestReferencedCache(t *testing.T) {
+	ctx := context.Background()
+	db, err := conproftestutil.NewTSDB()
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	app := db.Appender(ctx)
+	for _, s := range []struct {
+		name    string
+		t       time.Time
+		buildID string
+	}{
+		{name: "heap", t: now.Add(-2 * referencesSettled), buildID: "aaaa"},
+		{name: "allocs", t: now, buildID: "bbbb"},
+	} {
+		p := &profile.Profile{
+			SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+			Mapping:    []*profile.Mapping{{ID: 1, BuildID: s.buildID}},
+		}
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, p.Write(buf))
+		_, err = app.Add(labels.FromStrings(labels.MetricName, s.name), timestamp.FromTime(s.t), buf.Bytes())
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	q := &queryRecorder{Queryable: db}
+	s := NewSymbolStore(log.NewNopLogger(), nil, objstore.NewInMemBucket(), t.TempDir(), WithRetention(time.Hour), WithReferences(q))
+
+	settled := func(now time.Time) int64 {
+		return timestamp.FromTime(now.Add(-referencesSettled))
+	}
+	for _, tc := range []struct {
+		name   string
+		now    time.Time
+		ranges [][2]int64
+	}{
+		{
+			name: "first",
+			now:  now,
+			ranges: [][2]int64{
+				{math.MinInt64, settled(now) - 1},
+				{settled(now), math.MaxInt64},
+			},
+		},
+		{
+			// Only the profiles that settled since are read in addition to
+			// the recent ones.
+			name: "cached",
+			now:  now.Add(30 * time.Minute),
+			ranges: [][2]int64{
+				{settled(now), settled(now.Add(30*time.Minute)) - 1},
+				{settled(now.Add(30 * time.Minute)), math.MaxInt64},
+			},
+		},
+		{
+			// The cache is renewed every retention to notice deleted
+			// profiles.
+			name: "renewed",
+			now:  now.Add(2 * time.Hour),
+			ranges: [][2]int64{
+				{math.MinInt64, settled(now.Add(2*time.Hour)) - 1},
+				{settled(now.Add(2 * time.Hour)), math.MaxInt64},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q.ranges = nil
+			refs, err := s.referenced(ctx, tc.now)
+			require.NoError(t, err)
+			require.Equal(t, map[string]struct{}{"aaaa": {}, "bbbb": {}}, refs.buildIDs)
+			require.Equal(t, tc.ranges, q.ranges)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/status"
+	"google.golang.org/grpc/codes"
+
+	"github.com/conprof/conprof/pkg/store/storepb"
+)
+
+const (
+	sourcesDir = "sources"
+	// maxSourceSize is the size of the largest source file accepted.
+	maxSourceSize = 16 << 20
+)
+
+// ErrSourceNotFound is returned if no source file was uploaded for the build
+// ID and path.
+var ErrSourceNotFound = errors.New("source not found")
+
+// sourcePath returns the object name of the source file the binary with the
+// build ID was compiled from. Source paths are escaped, so that every source
+// file of a build ID is a direct child of its sources directory.
+func sourcePath(id, file string) string {
+	return path.Join(id, sourcesDir, url.PathEscape(path.Clean(file)))
+}
+
+// storeSource uploads the source file of the binary with the build ID.
+func (s *SymbolStore) storeSource(ctx context.Context, id, file string, f *os.File, size uint64) error {
+	if size > maxSourceSize {
+		return status.Errorf(codes.InvalidArgument, "source file is larger than %d bytes", maxSourceSize)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := s.bucket.Upload(ctx, sourcePath(id, file), f); err != nil {
+		return fmt.Errorf("upload source: %w", err)
+	}
+	return nil
+}
+
+// Source returns a source file the binary with the build ID was compiled
+// from, if it was uploaded.
+func (s *SymbolStore) Source(ctx context.Context, req *storepb.SourceRequest) (*storepb.SourceResponse, error) {
+	if err := validateId(req.BuildId); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.Path == "" {
+		return nil, status.Error(codes.InvalidArgument, "source path must not be empty")
+	}
+
+	r, err := s.bucket.Get(ctx, sourcePath(req.BuildId, req.Path))
+	if s.bucket.IsObjNotFoundErr(err) {
+		return nil, status.Error(codes.NotFound, ErrSourceNotFound.Error())
+	}
+	if err != nil {
+		level.Error(s.logger).Log("msg", "failed to get source", "id", req.BuildId, "path", req.Path, "err", err)
+		return nil, status.Error(codes.Internal, "failed to get source")
+	}
+	defer r.Close()
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to read source")
+	}
+	return &storepb.SourceResponse{Content: b}, nil
+}
+
+// deleteSources deletes all source files of the build ID.
+func (s *SymbolStore) deleteSources(ctx context.Context, id string) error {
+	names := []string{}
+	err := s.bucket.Iter(ctx, path.Join(id, sourcesDir), func(name string) error {
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("iterate sources of %s: %w", id, err)
+	}
+	for _, name := range names {
+		if err := s.bucket.Delete(ctx, name); err != nil && !s.bucket.IsObjNotFoundErr(err) {
+			return fmt.Errorf("delete %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// SourceClient can be asked for the source files binaries were compiled
+// from.
+type SourceClient interface {
+	Source(context.Context, *storepb.SourceRequest) (*storepb.SourceResponse, error)
+}
+
+// Source returns the source file at path the binary with the build ID was
+// compiled from. It returns ErrSourceNotFound if it wasn't uploaded, or the
+// symbolizer's client can't serve sources.
+func (s *Symbolizer) Source(ctx context.Context, buildID, file string) ([]byte, error) {
+	c, ok := s.c.(SourceClient)
+	if !ok {
+		return nil, ErrSourceNotFound
+	}
+	res, err := c.Source(ctx, &storepb.SourceRequest{BuildId: buildID, Path: file})
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrSourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.Content, nil
+}
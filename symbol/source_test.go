@@ -0,0 +1,59 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package symbol
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestSource(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	c, stop := newTestSymbolStoreClient(t, bucket)
+	defer stop()
+
+	const src = "package main\n\nfunc main() {}\n"
+	size, err := c.UploadSource(context.Background(), testBinaryID, "/home/user/src/app/main.go", strings.NewReader(src))
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(src)), size)
+
+	// Sources alone can't be used to symbolize.
+	exists, err := c.Exists(context.Background(), testBinaryID)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	st := NewSymbolStore(log.NewNopLogger(), nil, bucket, t.TempDir(), WithRetention(time.Hour))
+	s := NewSymbolizer(log.NewNopLogger(), st)
+
+	b, err := s.Source(context.Background(), testBinaryID, "/home/user/src/app/main.go")
+	require.NoError(t, err)
+	require.Equal(t, src, string(b))
+
+	_, err = s.Source(context.Background(), testBinaryID, "/home/user/src/app/other.go")
+	require.True(t, errors.Is(err, ErrSourceNotFound))
+
+	// Sources are deleted together with the debuginfo they belong to.
+	require.NoError(t, st.uploadMetadata(context.Background(), testBinaryID, &DebuginfoMetadata{
+		UploadedAt: time.Now().Add(-2 * time.Hour),
+	}))
+	require.NoError(t, st.GarbageCollect(context.Background()))
+	require.Empty(t, bucket.Objects())
+}
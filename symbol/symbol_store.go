@@ -22,11 +22,17 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"path"
+	"runtime"
+	"sync"
+	"time"
 
+	"github.com/conprof/db/storage"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/gogo/status"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/thanos-io/thanos/pkg/objstore"
 	"google.golang.org/grpc/codes"
 
@@ -34,22 +40,131 @@ import (
 	"github.com/conprof/conprof/pkg/store/storepb"
 )
 
+const (
+	// DefaultObjFilePoolSize is the default number of object files kept
+	// open between requests.
+	DefaultObjFilePoolSize = 64
+	// DefaultAddressCacheSize is the default number of resolved addresses
+	// cached.
+	DefaultAddressCacheSize = 1000000
+)
+
 type SymbolStore struct {
-	bucket   objstore.Bucket
-	logger   log.Logger
-	bu       *binutils.Binutils
-	cacheDir string
+	bucket objstore.Bucket
+	logger log.Logger
+	bu     *binutils.Binutils
+	cache  *debuginfoCache
+
+	objFiles   *objFilePool
+	debuginfod *DebuginfodClient
+	addrCache  *lru.Cache
+	fileIDs    *lru.Cache
+	kernels    *lru.Cache
+
+	cacheSize            int64
+	objFilePoolSize      int
+	addrCacheSize        int
+	extractDebugSections bool
+	retention            time.Duration
+	references           storage.Queryable
+
+	accessMtx sync.Mutex
+	accessed  map[string]time.Time
+
+	gcMtx    sync.Mutex
+	refCache *referenceCache
+
+	gcDeleted prometheus.Counter
+}
+
+type SymbolStoreOption func(*SymbolStore)
+
+// WithDebugSectionExtraction makes the store keep only the debug sections of
+// uploaded binaries, which requires objcopy.
+func WithDebugSectionExtraction() SymbolStoreOption {
+	return func(s *SymbolStore) {
+		s.extractDebugSections = true
+	}
 }
 
-func NewSymbolStore(logger log.Logger, bucket objstore.Bucket, cacheDir string) *SymbolStore {
+// WithCacheSize limits the size of the debuginfo cached on local disk to the
+// given number of bytes, evicting the least recently used debuginfo.
+func WithCacheSize(bytes int64) SymbolStoreOption {
+	return func(s *SymbolStore) {
+		s.cacheSize = bytes
+	}
+}
+
+// WithRetention makes garbage collection delete debuginfo from object
+// storage that wasn't uploaded or used for the given duration.
+func WithRetention(d time.Duration) SymbolStoreOption {
+	return func(s *SymbolStore) {
+		s.retention = d
+	}
+}
+
+// WithReferences makes garbage collection keep the debuginfo referenced by
+// the mappings of the profiles stored in q, regardless of the retention.
+func WithReferences(q storage.Queryable) SymbolStoreOption {
+	return func(s *SymbolStore) {
+		s.references = q
+	}
+}
+
+// WithDebuginfod makes the store download debuginfo missing from the bucket
+// from upstream debuginfod servers, storing it in the bucket.
+func WithDebuginfod(c *DebuginfodClient) SymbolStoreOption {
+	return func(s *SymbolStore) {
+		s.debuginfod = c
+	}
+}
+
+// WithObjFilePoolSize sets how many object files are kept open between
+// requests.
+func WithObjFilePoolSize(n int) SymbolStoreOption {
+	return func(s *SymbolStore) {
+		s.objFilePoolSize = n
+	}
+}
+
+// WithAddressCacheSize sets how many resolved addresses are cached.
+func WithAddressCacheSize(n int) SymbolStoreOption {
+	return func(s *SymbolStore) {
+		s.addrCacheSize = n
+	}
+}
+
+func NewSymbolStore(logger log.Logger, reg prometheus.Registerer, bucket objstore.Bucket, cacheDir string, opts ...SymbolStoreOption) *SymbolStore {
 	bu := &binutils.Binutils{}
 	level.Debug(logger).Log("msg", "initializing binutils", "binutils", bu.String())
-	return &SymbolStore{
+	s := &SymbolStore{
 		logger:   logger,
 		bucket:   bucket,
 		bu:       bu,
-		cacheDir: cacheDir,
+		accessed: map[string]time.Time{},
+
+		objFilePoolSize: DefaultObjFilePoolSize,
+		addrCacheSize:   DefaultAddressCacheSize,
+
+		gcDeleted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "conprof_symbol_gc_deleted_total",
+			Help: "Total number of debuginfo objects deleted from object storage by garbage collection.",
+		}),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.objFiles = newObjFilePool(s.objFilePoolSize)
+	s.addrCache = newAddressCache(s.addrCacheSize)
+	// Only fails for non-positive sizes.
+	s.fileIDs, _ = lru.New(10000)
+	s.kernels, _ = lru.New(16)
+	s.cache = newDebuginfoCache(logger, reg, cacheDir, s.cacheSize)
+	if err := s.cache.load(); err != nil {
+		level.Warn(logger).Log("msg", "failed to load cached debuginfo", "err", err)
+	}
+	return s
 }
 
 func validateId(id string) error {
@@ -70,17 +185,23 @@ func (s *SymbolStore) Exists(ctx context.Context, req *storepb.SymbolExistsReque
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	path := req.Id
-
-	found := false
-	err = s.bucket.Iter(ctx, path, func(_ string) error {
-		// We just need any debug files to be present.
-		found = true
-		return nil
-	})
+	// Either debuginfo or kernel symbols have to be present, uploaded
+	// sources alone can't be used to symbolize.
+	found, err := s.bucket.Exists(ctx, debuginfoPath(req.Id))
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	if !found {
+		meta, err := s.metadata(ctx, req.Id)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		found = meta != nil && len(meta.KernelReleases) > 0
+	}
+	if found {
+		// Agents checking for debuginfo are still running the binary.
+		s.touch(req.Id)
+	}
 
 	return &storepb.SymbolExistsResponse{
 		Exists: found,
@@ -96,26 +217,122 @@ func (s *SymbolStore) Upload(stream storepb.SymbolStore_UploadServer) error {
 	}
 
 	id := req.GetInfo().Id
+	fileName := req.GetInfo().FileName
 	err = validateId(id)
 	if err != nil {
 		return status.Error(codes.InvalidArgument, err.Error())
 	}
-	path := id + "/debuginfo"
 
-	r := &UploadReader{stream: stream}
-	err = s.bucket.Upload(stream.Context(), path, r)
+	// The upload has to be validated before storing it, which requires it to
+	// be on disk in its entirety.
+	tmpfile, err := ioutil.TempFile("", "symbol-upload")
 	if err != nil {
-		msg := "failed to upload"
+		level.Error(s.logger).Log("msg", "failed to create tmp file", "err", err)
+		return status.Error(codes.Internal, "failed to create tmp file")
+	}
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	r := &UploadReader{stream: stream}
+	if _, err := io.Copy(tmpfile, r); err != nil {
+		msg := "failed to receive upload"
 		level.Error(s.logger).Log("msg", msg, "err", err)
 		return status.Errorf(codes.Unknown, msg)
 	}
 
+	if file := req.GetInfo().SourcePath; file != "" {
+		if err := s.storeSource(stream.Context(), id, file, tmpfile, r.size); err != nil {
+			if status.Code(err) == codes.InvalidArgument {
+				return err
+			}
+			level.Error(s.logger).Log("msg", "failed to store source", "id", id, "path", file, "err", err)
+			return status.Error(codes.Internal, "failed to store source")
+		}
+		return stream.SendAndClose(&storepb.SymbolUploadResponse{
+			Id:    id,
+			Size_: r.size,
+		})
+	}
+
+	if release := req.GetInfo().KernelRelease; release != "" {
+		if _, err := tmpfile.Seek(0, io.SeekStart); err != nil {
+			level.Error(s.logger).Log("msg", "failed to seek tmp file", "err", err)
+			return status.Error(codes.Internal, "failed to read upload")
+		}
+		if _, err := parseKallsyms(tmpfile); err != nil {
+			level.Debug(s.logger).Log("msg", "rejected invalid kallsyms", "id", id, "err", err)
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		if err := s.storeKallsyms(stream.Context(), id, release, tmpfile, r.size); err != nil {
+			level.Error(s.logger).Log("msg", "failed to store kallsyms", "id", id, "err", err)
+			return status.Error(codes.Internal, "failed to store kallsyms")
+		}
+		return stream.SendAndClose(&storepb.SymbolUploadResponse{
+			Id:    id,
+			Size_: r.size,
+		})
+	}
+
+	meta, err := validateDebuginfo(tmpfile, id, fileName)
+	if err != nil {
+		level.Debug(s.logger).Log("msg", "rejected invalid debuginfo", "id", id, "err", err)
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	meta.Size = r.size
+	meta.StoredSize = r.size
+
+	if err := s.store(stream.Context(), id, tmpfile.Name(), meta); err != nil {
+		level.Error(s.logger).Log("msg", "failed to store debuginfo", "id", id, "err", err)
+		return status.Error(codes.Internal, "failed to store debuginfo")
+	}
+	if fileName != "" {
+		if err := s.indexFile(stream.Context(), fileName, id, r.size); err != nil {
+			level.Error(s.logger).Log("msg", "failed to index debuginfo by file path", "id", id, "file", fileName, "err", err)
+			return status.Error(codes.Internal, "failed to store debuginfo")
+		}
+	}
+
 	return stream.SendAndClose(&storepb.SymbolUploadResponse{
 		Id:    id,
 		Size_: r.size,
 	})
 }
 
+// store uploads the validated debuginfo in file and its metadata.
+func (s *SymbolStore) store(ctx context.Context, id, file string, meta *DebuginfoMetadata) error {
+	if s.extractDebugSections {
+		extracted := file + ".debug"
+		defer os.Remove(extracted)
+		if err := extractDebugSections(ctx, file, extracted); err != nil {
+			return fmt.Errorf("extract debug sections: %w", err)
+		}
+		fi, err := os.Stat(extracted)
+		if err != nil {
+			return err
+		}
+		file = extracted
+		meta.Extracted = true
+		meta.StoredSize = uint64(fi.Size())
+	}
+
+	if err := s.uploadFile(ctx, debuginfoPath(id), file); err != nil {
+		return fmt.Errorf("upload debuginfo: %w", err)
+	}
+
+	meta.UploadedAt = time.Now()
+	return s.uploadMetadata(ctx, id, meta)
+}
+
+func (s *SymbolStore) uploadFile(ctx context.Context, name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.bucket.Upload(ctx, name, f)
+}
+
 type UploadReader struct {
 	stream storepb.SymbolStore_UploadServer
 	cur    io.Reader
@@ -183,72 +400,224 @@ func contextError(ctx context.Context) error {
 	}
 }
 
+// Symbolize resolves the locations of the mappings concurrently. Mappings
+// that fail to be symbolized are logged and returned unresolved, so that one
+// broken binary doesn't fail the whole request.
 func (s *SymbolStore) Symbolize(ctx context.Context, req *storepb.SymbolizeRequest) (*storepb.SymbolizeResponse, error) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
 	for _, m := range req.Mappings {
-		mappingPath := path.Join(s.cacheDir, m.BuildId, "debuginfo")
-		if _, err := os.Stat(mappingPath); os.IsNotExist(err) {
-			r, err := s.bucket.Get(ctx, path.Join(m.BuildId, "debuginfo"))
-			if s.bucket.IsObjNotFoundErr(err) {
-				level.Debug(s.logger).Log("msg", "object not found", "object", m.BuildId)
-				continue
-			}
-			if err != nil {
-				level.Error(s.logger).Log("msg", "failed to get object", "object", m.BuildId, "err", err)
-				return nil, fmt.Errorf("get object from object storage: %w", err)
-			}
-			tmpfile, err := ioutil.TempFile("", "symbol-download")
-			if err != nil {
-				level.Error(s.logger).Log("msg", "failed to create tmp file")
-				return nil, fmt.Errorf("create temp file: %w", err)
-			}
-			_, err = io.Copy(tmpfile, r)
-			if err != nil {
-				os.Remove(tmpfile.Name())
-				return nil, fmt.Errorf("copy object storage file to local temp file: %w", err)
-			}
-			if err := tmpfile.Close(); err != nil {
-				os.Remove(tmpfile.Name())
-				return nil, fmt.Errorf("close tempfile to write object file: %w", err)
-			}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
 
-			err = os.MkdirAll(path.Join(s.cacheDir, m.BuildId), 0700)
-			if err != nil {
-				os.Remove(tmpfile.Name())
-				return nil, fmt.Errorf("create object file directory: %w", err)
-			}
-			// Need to use rename to make the "creation" atomic.
-			if err := os.Rename(tmpfile.Name(), mappingPath); err != nil {
-				os.Remove(tmpfile.Name())
-				return nil, fmt.Errorf("atomically move downloaded object file: %w", err)
+		wg.Add(1)
+		go func(m *storepb.Mapping) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := s.symbolizeMapping(ctx, m); err != nil {
+				level.Error(s.logger).Log("msg", "failed to symbolize mapping", "buildid", m.BuildId, "file", m.File, "err", err)
 			}
+		}(m)
+	}
+	wg.Wait()
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	return &storepb.SymbolizeResponse{
+		Mappings: req.Mappings,
+	}, nil
+}
+
+// fetch returns the path of the debuginfo of id in the local cache,
+// downloading it if necessary. The returned release function is nil if
+// there's no debuginfo for id.
+func (s *SymbolStore) fetch(ctx context.Context, id string) (string, func(), error) {
+	if file, release, ok := s.cache.get(id); ok {
+		return file, release, nil
+	}
+
+	r, err := s.bucket.Get(ctx, debuginfoPath(id))
+	if s.bucket.IsObjNotFoundErr(err) {
+		level.Debug(s.logger).Log("msg", "object not found", "object", id)
+		if s.debuginfod == nil {
+			return "", nil, nil
 		}
+		return s.fetchUpstream(ctx, id)
+	}
+	if err != nil {
+		level.Error(s.logger).Log("msg", "failed to get object", "object", id, "err", err)
+		return "", nil, fmt.Errorf("get object from object storage: %w", err)
+	}
+	defer r.Close()
 
-		objFile, err := s.bu.Open(mappingPath, m.MemoryStart, m.MemoryLimit, m.FileOffset)
+	tmpfile, err := ioutil.TempFile("", "symbol-download")
+	if err != nil {
+		level.Error(s.logger).Log("msg", "failed to create tmp file")
+		return "", nil, fmt.Errorf("create temp file: %w", err)
+	}
+	_, err = io.Copy(tmpfile, r)
+	if err != nil {
+		os.Remove(tmpfile.Name())
+		return "", nil, fmt.Errorf("copy object storage file to local temp file: %w", err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		os.Remove(tmpfile.Name())
+		return "", nil, fmt.Errorf("close tempfile to write object file: %w", err)
+	}
+
+	file, release, err := s.cache.add(id, tmpfile.Name())
+	if err != nil {
+		os.Remove(tmpfile.Name())
+		return "", nil, err
+	}
+	return file, release, nil
+}
+
+// fetchUpstream downloads debuginfo missing from the bucket from the upstream
+// debuginfod servers, and stores it in the bucket and the cache.
+func (s *SymbolStore) fetchUpstream(ctx context.Context, id string) (string, func(), error) {
+	tmpfile, err := ioutil.TempFile("", "symbol-debuginfod")
+	if err != nil {
+		return "", nil, fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	err = s.debuginfod.Download(ctx, id, tmpfile)
+	if errors.Is(err, ErrDebuginfoNotFound) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("download debuginfo from debuginfod: %w", err)
+	}
+
+	meta, err := validateDebuginfo(tmpfile, id, "")
+	if err != nil {
+		level.Warn(s.logger).Log("msg", "debuginfod returned invalid debuginfo", "id", id, "err", err)
+		return "", nil, nil
+	}
+	fi, err := tmpfile.Stat()
+	if err != nil {
+		return "", nil, err
+	}
+	meta.Size = uint64(fi.Size())
+	meta.StoredSize = meta.Size
+	meta.Source = DebuginfoSourceDebuginfod
+
+	if err := s.store(ctx, id, tmpfile.Name(), meta); err != nil {
+		return "", nil, fmt.Errorf("store debuginfo from debuginfod: %w", err)
+	}
+	level.Debug(s.logger).Log("msg", "stored debuginfo from debuginfod", "id", id, "size", meta.Size)
+
+	if err := tmpfile.Close(); err != nil {
+		return "", nil, err
+	}
+	return s.cache.add(id, tmpfile.Name())
+}
+
+// symbolizeMapping resolves the locations of the mapping that aren't cached
+// already. Mappings without build ID are looked up by their file path.
+func (s *SymbolStore) symbolizeMapping(ctx context.Context, m *storepb.Mapping) error {
+	if m.Kernel {
+		return s.symbolizeKernel(ctx, m)
+	}
+
+	id := m.BuildId
+	if id == "" {
+		if !symbolizableFile(m.File) {
+			return nil
+		}
+		var err error
+		id, err = s.resolveFile(ctx, m)
 		if err != nil {
-			level.Error(s.logger).Log("msg", "failed to open object file", "mappingpath", mappingPath, "start", m.MemoryStart, "limit", m.MemoryLimit, "offset", m.FileOffset, "err", err)
-			return nil, fmt.Errorf("open object file: %w", err)
+			return err
 		}
+		if id == "" {
+			return nil
+		}
+	}
 
-		for _, location := range m.Locations {
-			frames, err := objFile.SourceLine(location.Address)
-			if err != nil {
-				level.Debug(s.logger).Log("msg", "failed to open object file", "mappingpath", mappingPath, "start", m.MemoryStart, "limit", m.MemoryLimit, "offset", m.FileOffset, "address", location.Address, "err", err)
-				continue
-			}
+	unresolved := []*storepb.Location{}
+	for _, location := range m.Locations {
+		if lines, ok := s.addrCache.Get(newAddressKey(id, m.MemoryStart, m.FileOffset, location.Address)); ok {
+			location.Lines = lines.([]*storepb.Line)
+			continue
+		}
+		unresolved = append(unresolved, location)
+	}
+	if len(unresolved) == 0 {
+		s.touch(id)
+		return nil
+	}
 
-			for _, frame := range frames {
-				location.Lines = append(location.Lines, &storepb.Line{
-					Line: int64(frame.Line),
-					Function: &storepb.Function{
-						Name:     frame.Func,
-						Filename: frame.File,
-					},
-				})
-			}
+	f, err := s.objFile(ctx, id, m)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return nil
+	}
+	defer s.objFiles.put(f)
+
+	for _, location := range unresolved {
+		frames, err := f.f.SourceLine(location.Address)
+		if err != nil {
+			level.Debug(s.logger).Log("msg", "failed to open object file", "mappingpath", f.f.Name(), "start", m.MemoryStart, "limit", m.MemoryLimit, "offset", m.FileOffset, "address", location.Address, "err", err)
+			continue
+		}
+
+		// Frames are ordered from the innermost inlined function to the
+		// function it's ultimately inlined into, like profile lines.
+		for _, frame := range frames {
+			location.Lines = append(location.Lines, &storepb.Line{
+				Line: int64(frame.Line),
+				Function: &storepb.Function{
+					Name:     frame.Func,
+					Filename: frame.File,
+				},
+			})
 		}
+		s.addrCache.Add(newAddressKey(id, m.MemoryStart, m.FileOffset, location.Address), location.Lines)
 	}
+	return nil
+}
 
-	return &storepb.SymbolizeResponse{
-		Mappings: req.Mappings,
-	}, nil
+// objFile returns an opened object file for the mapping, either from the pool
+// or by opening the debuginfo of id. It returns nil if there's no debuginfo
+// for the mapping.
+func (s *SymbolStore) objFile(ctx context.Context, id string, m *storepb.Mapping) (*pooledObjFile, error) {
+	key := objFileKey{
+		buildID: id,
+		start:   m.MemoryStart,
+		limit:   m.MemoryLimit,
+		offset:  m.FileOffset,
+	}
+	if f, ok := s.objFiles.get(key); ok {
+		s.touch(id)
+		return f, nil
+	}
+
+	mappingPath, release, err := s.fetch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if release == nil {
+		return nil, nil
+	}
+	s.touch(id)
+
+	objFile, err := s.bu.Open(mappingPath, m.MemoryStart, m.MemoryLimit, m.FileOffset)
+	if err != nil {
+		release()
+		level.Error(s.logger).Log("msg", "failed to open object file", "mappingpath", mappingPath, "start", m.MemoryStart, "limit", m.MemoryLimit, "offset", m.FileOffset, "err", err)
+		return nil, fmt.Errorf("open object file: %w", err)
+	}
+	return &pooledObjFile{key: key, f: objFile, release: release}, nil
 }
@@ -16,9 +16,14 @@ package symbol
 import (
 	"bufio"
 	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 
+	"github.com/conprof/conprof/internal/pprof/elfexec"
 	"github.com/conprof/conprof/pkg/store/storepb"
 )
 
@@ -44,6 +49,28 @@ func (c *SymbolStoreClient) Exists(ctx context.Context, id string) (bool, error)
 }
 
 func (c *SymbolStoreClient) Upload(ctx context.Context, id string, r io.Reader) (uint64, error) {
+	return c.upload(ctx, &storepb.SymbolUploadInfo{Id: id}, r)
+}
+
+// UploadWithFileName uploads a binary without GNU build ID, identified by the
+// file ID of the path it runs at and its content.
+func (c *SymbolStoreClient) UploadWithFileName(ctx context.Context, id, fileName string, r io.Reader) (uint64, error) {
+	return c.upload(ctx, &storepb.SymbolUploadInfo{Id: id, FileName: fileName}, r)
+}
+
+// UploadKallsyms uploads a /proc/kallsyms snapshot of the kernel with the
+// release and build ID, which is used to symbolize kernel stacks.
+func (c *SymbolStoreClient) UploadKallsyms(ctx context.Context, release, buildID string, r io.Reader) (uint64, error) {
+	return c.upload(ctx, &storepb.SymbolUploadInfo{Id: buildID, KernelRelease: release}, r)
+}
+
+// UploadSource uploads a source file the binary with the build ID was
+// compiled from, under the path it was compiled from.
+func (c *SymbolStoreClient) UploadSource(ctx context.Context, buildID, file string, r io.Reader) (uint64, error) {
+	return c.upload(ctx, &storepb.SymbolUploadInfo{Id: buildID, SourcePath: file}, r)
+}
+
+func (c *SymbolStoreClient) upload(ctx context.Context, info *storepb.SymbolUploadInfo, r io.Reader) (uint64, error) {
 	stream, err := c.c.Upload(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("initiate upload: %w", err)
@@ -51,9 +78,7 @@ func (c *SymbolStoreClient) Upload(ctx context.Context, id string, r io.Reader)
 
 	err = stream.Send(&storepb.SymbolUploadRequest{
 		Data: &storepb.SymbolUploadRequest_Info{
-			Info: &storepb.SymbolUploadInfo{
-				Id: id,
-			},
+			Info: info,
 		},
 	})
 	if err != nil {
@@ -90,3 +115,68 @@ func (c *SymbolStoreClient) Upload(ctx context.Context, id string, r io.Reader)
 	}
 	return res.Size_, nil
 }
+
+// UploadFile uploads the ELF binary at path under its GNU build ID, or its
+// file ID if it has none, unless the store already has debug information for
+// it. Binaries without build ID are identified by the path they run at, as
+// recorded in profile mappings, which is the absolute path of the file if
+// mappedPath is empty. It returns the ID and the number of bytes uploaded,
+// which is zero if the upload was skipped.
+func (c *SymbolStoreClient) UploadFile(ctx context.Context, file, mappedPath string) (string, uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	fileName := ""
+	id, err := BuildID(f)
+	if errors.Is(err, ErrNoBuildID) {
+		fileName = mappedPath
+		if fileName == "" {
+			if fileName, err = filepath.Abs(file); err != nil {
+				return "", 0, err
+			}
+		}
+		id, err = FileID(fileName, f)
+		if err != nil {
+			return "", 0, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return "", 0, err
+		}
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	exists, err := c.Exists(ctx, id)
+	if err != nil {
+		return id, 0, fmt.Errorf("check if debuginfo exists: %w", err)
+	}
+	if exists {
+		return id, 0, nil
+	}
+
+	size, err := c.upload(ctx, &storepb.SymbolUploadInfo{Id: id, FileName: fileName}, f)
+	if err != nil {
+		return id, 0, fmt.Errorf("upload debuginfo: %w", err)
+	}
+	return id, size, nil
+}
+
+// ErrNoBuildID is returned by BuildID for ELF binaries without GNU build ID.
+var ErrNoBuildID = errors.New("binary has no GNU build id")
+
+// BuildID returns the hex encoded GNU build ID of the ELF binary, the same
+// way it is recorded in profile mappings.
+func BuildID(r io.ReaderAt) (string, error) {
+	b, err := elfexec.GetBuildID(r)
+	if err != nil {
+		return "", fmt.Errorf("read build id: %w", err)
+	}
+	if b == nil {
+		return "", ErrNoBuildID
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -16,61 +16,148 @@ package symbol
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"net"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/conprof/conprof/pkg/store/storepb"
 	"github.com/go-kit/kit/log"
+	"github.com/gogo/status"
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/thanos/pkg/objstore"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	testBinaryID   = "910b52eaddce54ae8bbeb49f93c04ded113fcf4d"
+	testBinaryPath = "../internal/pprof/binutils/testdata/exe_linux_64"
 )
 
 func TestSymbolServer(t *testing.T) {
 	bucket := objstore.NewInMemBucket()
-	s := NewSymbolStore(log.NewNopLogger(), bucket, "/tmp")
+	c, stop := newTestSymbolStoreClient(t, bucket)
+	defer stop()
+
+	b, err := ioutil.ReadFile(testBinaryPath)
+	require.NoError(t, err)
+
+	size, err := c.Upload(context.Background(), testBinaryID, bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(b)), size)
+
+	obj, ok := bucket.Objects()[testBinaryID+"/debuginfo"]
+	require.True(t, ok)
+	require.Equal(t, b, obj)
+
+	meta := &DebuginfoMetadata{}
+	require.NoError(t, json.Unmarshal(bucket.Objects()[testBinaryID+"/metadata"], meta))
+	require.Equal(t, uint64(len(b)), meta.Size)
+	require.Equal(t, uint64(len(b)), meta.StoredSize)
+	require.False(t, meta.Extracted)
+	require.True(t, meta.HasDWARF)
+	require.True(t, meta.HasSymtab)
+	require.False(t, meta.UploadedAt.IsZero())
+
+	exists, err := c.Exists(context.Background(), testBinaryID)
+	require.NoError(t, err)
+	require.True(t, exists)
+}
+
+func TestSymbolServerRejectsInvalidUploads(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	c, stop := newTestSymbolStoreClient(t, bucket)
+	defer stop()
+
+	b, err := ioutil.ReadFile(testBinaryPath)
+	require.NoError(t, err)
+
+	stripped := filepath.Join(t.TempDir(), "stripped")
+	require.NoError(t, exec.Command("objcopy", "--strip-all", testBinaryPath, stripped).Run())
+	s, err := ioutil.ReadFile(stripped)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		id   string
+		data []byte
+	}{
+		"not an ELF file":      {id: "abcd", data: bytes.Repeat([]byte("a"), 3072)},
+		"build id mismatch":    {id: "abcd", data: b},
+		"no debug information": {id: testBinaryID, data: s},
+	} {
+		_, err := c.Upload(context.Background(), tc.id, bytes.NewReader(tc.data))
+		require.Error(t, err, name)
+		require.Equal(t, codes.InvalidArgument, status.Code(errors.Unwrap(err)), name)
+	}
+	require.Empty(t, bucket.Objects())
+}
+
+func TestSymbolServerExtractDebugSections(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	c, stop := newTestSymbolStoreClient(t, bucket, WithDebugSectionExtraction())
+	defer stop()
+
+	b, err := ioutil.ReadFile(testBinaryPath)
+	require.NoError(t, err)
+
+	size, err := c.Upload(context.Background(), testBinaryID, bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(b)), size)
+
+	obj := bucket.Objects()[testBinaryID+"/debuginfo"]
+	require.Less(t, len(obj), len(b))
+
+	meta := &DebuginfoMetadata{}
+	require.NoError(t, json.Unmarshal(bucket.Objects()[testBinaryID+"/metadata"], meta))
+	require.True(t, meta.Extracted)
+	require.Equal(t, uint64(len(b)), meta.Size)
+	require.Equal(t, uint64(len(obj)), meta.StoredSize)
+
+	// The extracted debuginfo is still valid for symbolization.
+	_, err = validateDebuginfo(bytes.NewReader(obj), testBinaryID, "")
+	require.NoError(t, err)
+}
+
+func newTestSymbolStoreClient(t *testing.T, bucket objstore.Bucket, opts ...SymbolStoreOption) (*SymbolStoreClient, func()) {
+	s := NewSymbolStore(log.NewNopLogger(), nil, bucket, "/tmp", opts...)
 	lis, err := net.Listen("tcp", ":0")
 	if err != nil {
 		t.Fatalf("failed to listen: %v", err)
 	}
-	defer lis.Close()
 	grpcServer := grpc.NewServer()
 	storepb.RegisterSymbolStoreServer(grpcServer, s)
 	go grpcServer.Serve(lis)
 
 	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
 	require.NoError(t, err)
-	defer conn.Close()
-	c := NewSymbolStoreClient(storepb.NewSymbolStoreClient(conn))
-	b := bytes.NewBuffer(nil)
-	for i := 0; i < 1024; i++ {
-		b.Write([]byte("a"))
-	}
-	for i := 0; i < 1024; i++ {
-		b.Write([]byte("b"))
+	return NewSymbolStoreClient(storepb.NewSymbolStoreClient(conn)), func() {
+		conn.Close()
+		grpcServer.Stop()
+		lis.Close()
 	}
-	for i := 0; i < 1024; i++ {
-		b.Write([]byte("c"))
-	}
-	size, err := c.Upload(context.Background(), "abcd", b)
-	require.NoError(t, err)
-	require.Equal(t, uint64(3072), size)
+}
 
-	obj, ok := bucket.Objects()["abcd/debuginfo"]
-	require.True(t, ok)
-	require.Equal(t, 3072, len(obj))
+func TestUploadFile(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	c, stop := newTestSymbolStoreClient(t, bucket)
+	defer stop()
 
-	for i := 0; i < 1024; i++ {
-		require.Equal(t, []byte("a")[0], obj[i])
-	}
-	for i := 0; i < 1024; i++ {
-		require.Equal(t, []byte("b")[0], obj[i+1024])
-	}
-	for i := 0; i < 1024; i++ {
-		require.Equal(t, []byte("c")[0], obj[i+2048])
-	}
+	uploadedID, size, err := c.UploadFile(context.Background(), testBinaryPath, "")
+	require.NoError(t, err)
+	require.Equal(t, testBinaryID, uploadedID)
+	require.Equal(t, uint64(9503), size)
+	require.Len(t, bucket.Objects()[testBinaryID+"/debuginfo"], 9503)
 
-	exists, err := c.Exists(context.Background(), "abcd")
+	// Uploading again is skipped as the debuginfo exists already.
+	uploadedID, size, err = c.UploadFile(context.Background(), testBinaryPath, "")
 	require.NoError(t, err)
-	require.True(t, exists)
+	require.Equal(t, testBinaryID, uploadedID)
+	require.Equal(t, uint64(0), size)
+
+	_, _, err = c.UploadFile(context.Background(), "testdata/profile.pb.gz", "")
+	require.Error(t, err)
 }
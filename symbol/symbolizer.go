@@ -20,95 +20,183 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/google/pprof/profile"
+	lru "github.com/hashicorp/golang-lru"
 )
 
+// DefaultSymbolizerCacheSize is the default number of resolved addresses
+// cached by the Symbolizer.
+const DefaultSymbolizerCacheSize = 100000
+
 type Symbolizer struct {
 	logger log.Logger
 	c      SymbolizeClient
+
+	cacheSize int
+	cache     *lru.Cache
 }
 
 type SymbolizeClient interface {
 	Symbolize(context.Context, *storepb.SymbolizeRequest) (*storepb.SymbolizeResponse, error)
 }
 
-func NewSymbolizer(logger log.Logger, c SymbolizeClient) *Symbolizer {
-	return &Symbolizer{
-		logger: logger,
-		c:      c,
+type SymbolizerOption func(*Symbolizer)
+
+// WithSymbolizerCacheSize sets how many resolved addresses are cached, so
+// they don't have to be requested again.
+func WithSymbolizerCacheSize(n int) SymbolizerOption {
+	return func(s *Symbolizer) {
+		s.cacheSize = n
+	}
+}
+
+func NewSymbolizer(logger log.Logger, c SymbolizeClient, opts ...SymbolizerOption) *Symbolizer {
+	s := &Symbolizer{
+		logger:    logger,
+		c:         c,
+		cacheSize: DefaultSymbolizerCacheSize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.cache = newAddressCache(s.cacheSize)
+	return s
+}
+
+// addressKey identifies a resolved address. Addresses are normalized to their
+// file offset, which is the same in every process the mapping is loaded into,
+// wherever it's loaded. Mappings without build ID are identified by their
+// file.
+type addressKey struct {
+	buildID string
+	file    string
+	addr    uint64
+}
+
+func newAddressKey(buildID string, start, offset, addr uint64) addressKey {
+	return addressKey{
+		buildID: buildID,
+		addr:    addr - start + offset,
+	}
+}
+
+func newAddressCache(size int) *lru.Cache {
+	if size <= 0 {
+		size = 1
+	}
+	// Only fails for non-positive sizes.
+	c, _ := lru.New(size)
+	return c
+}
+
+// mappingAddressKey returns the key of the address in the mapping, which is
+// identified by its file if it has no build ID.
+func mappingAddressKey(buildID, file string, start, offset, addr uint64) addressKey {
+	k := newAddressKey(buildID, start, offset, addr)
+	if buildID == "" {
+		k.file = file
+	}
+	return k
+}
+
+func needsSymbolization(location *profile.Location) bool {
+	if len(location.Line) > 0 || location.Mapping == nil {
+		return false
 	}
+	return len(location.Mapping.BuildID) > 0 || symbolizableFile(location.Mapping.File)
 }
 
+func locationAddressKey(location *profile.Location) addressKey {
+	return mappingAddressKey(location.Mapping.BuildID, location.Mapping.File, location.Mapping.Start, location.Mapping.Offset, location.Address)
+}
+
+// Symbolize adds the lines of locations that don't have any. Only addresses
+// that weren't resolved before are requested. Lines of inlined functions are
+// added in the order they are returned, from the innermost function to the
+// one it's inlined into, as profile locations expect them.
 func (s *Symbolizer) Symbolize(ctx context.Context, p *profile.Profile) error {
-	mappingIndices := map[string]int{}
+	resolved := map[addressKey][]*storepb.Line{}
+	mappingIndices := map[*profile.Mapping]int{}
 	mappings := []*storepb.Mapping{}
 	for _, location := range p.Location {
-		if len(location.Line) == 0 && location.Mapping != nil && len(location.Mapping.BuildID) > 0 {
-			mappingIdx, ok := mappingIndices[location.Mapping.BuildID]
-			if !ok {
-				mappingIdx = len(mappings)
-				mappingIndices[location.Mapping.BuildID] = mappingIdx
-				mappings = append(mappings, &storepb.Mapping{
-					BuildId:     location.Mapping.BuildID,
-					MemoryStart: location.Mapping.Start,
-					MemoryLimit: location.Mapping.Limit,
-					FileOffset:  location.Mapping.Offset,
-				})
-			}
-			mapping := mappings[mappingIdx]
-			mapping.Locations = append(mapping.Locations, &storepb.Location{
-				Address: location.Address,
-			})
+		if !needsSymbolization(location) {
+			continue
 		}
-	}
+		key := locationAddressKey(location)
+		if _, ok := resolved[key]; ok {
+			continue
+		}
+		if lines, ok := s.cache.Get(key); ok {
+			resolved[key] = lines.([]*storepb.Line)
+			continue
+		}
+		// Requested already.
+		resolved[key] = nil
 
-	if len(mappings) == 0 {
-		// Nothing to symbolize.
-		return nil
+		mappingIdx, ok := mappingIndices[location.Mapping]
+		if !ok {
+			mappingIdx = len(mappings)
+			mappingIndices[location.Mapping] = mappingIdx
+			mappings = append(mappings, &storepb.Mapping{
+				BuildId:     location.Mapping.BuildID,
+				File:        location.Mapping.File,
+				Kernel:      isKernelFile(location.Mapping.File),
+				MemoryStart: location.Mapping.Start,
+				MemoryLimit: location.Mapping.Limit,
+				FileOffset:  location.Mapping.Offset,
+			})
+		}
+		mapping := mappings[mappingIdx]
+		mapping.Locations = append(mapping.Locations, &storepb.Location{
+			Address: location.Address,
+		})
 	}
 
-	level.Debug(s.logger).Log("msg", "remote symbolization request")
-	res, err := s.c.Symbolize(ctx, &storepb.SymbolizeRequest{
-		Mappings: mappings,
-	})
-	if err != nil {
-		return err
-	}
+	if len(mappings) > 0 {
+		level.Debug(s.logger).Log("msg", "remote symbolization request", "mappings", len(mappings))
+		res, err := s.c.Symbolize(ctx, &storepb.SymbolizeRequest{
+			Mappings: mappings,
+		})
+		if err != nil {
+			return err
+		}
 
-	mappingLocationIdx := map[string]map[uint64]int{}
-	for _, mapping := range res.Mappings {
-		locationIdx := map[uint64]int{}
-		for i, location := range mapping.Locations {
-			locationIdx[location.Address] = i
+		for _, m := range res.Mappings {
+			for _, l := range m.Locations {
+				key := mappingAddressKey(m.BuildId, m.File, m.MemoryStart, m.FileOffset, l.Address)
+				resolved[key] = l.Lines
+				// Addresses without lines might be resolved once
+				// debuginfo is uploaded. Files might be replaced by
+				// other binaries of the same name.
+				if len(l.Lines) > 0 && m.BuildId != "" {
+					s.cache.Add(key, l.Lines)
+				}
+			}
 		}
-		mappingLocationIdx[mapping.BuildId] = locationIdx
 	}
 
 	functionIdx := map[string]int{}
 	for _, location := range p.Location {
-		if len(location.Line) == 0 && location.Mapping != nil && len(location.Mapping.BuildID) > 0 {
-			m := res.Mappings[mappingIndices[location.Mapping.BuildID]]
-			locationIndices := mappingLocationIdx[location.Mapping.BuildID]
-
-			l := m.Locations[locationIndices[location.Address]]
-			for _, line := range l.Lines {
-				var f *profile.Function
-				fIdx, ok := functionIdx[line.Function.Name+":"+line.Function.Filename]
-				if !ok {
-					f = &profile.Function{
-						ID:       uint64(len(p.Function)) + 1,
-						Name:     line.Function.Name,
-						Filename: line.Function.Filename,
-					}
-					p.Function = append(p.Function, f)
-					fIdx = len(p.Function) - 1
-					functionIdx[line.Function.Name+":"+line.Function.Filename] = fIdx
+		if !needsSymbolization(location) {
+			continue
+		}
+		for _, line := range resolved[locationAddressKey(location)] {
+			var f *profile.Function
+			fIdx, ok := functionIdx[line.Function.Name+":"+line.Function.Filename]
+			if !ok {
+				f = &profile.Function{
+					ID:       uint64(len(p.Function)) + 1,
+					Name:     line.Function.Name,
+					Filename: line.Function.Filename,
 				}
-				f = p.Function[fIdx]
-				location.Line = append(location.Line, profile.Line{
-					Function: f,
-					Line:     line.Line,
-				})
+				p.Function = append(p.Function, f)
+				fIdx = len(p.Function) - 1
+				functionIdx[line.Function.Name+":"+line.Function.Filename] = fIdx
 			}
+			f = p.Function[fIdx]
+			location.Line = append(location.Line, profile.Line{
+				Function: f,
+				Line:     line.Line,
+			})
 		}
 	}
 
@@ -15,14 +15,18 @@ package symbol
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/conprof/conprof/pkg/store/storepb"
 	"github.com/go-kit/kit/log"
 	"github.com/google/pprof/profile"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
 	"github.com/thanos-io/thanos/pkg/objstore/filesystem"
 	"google.golang.org/grpc"
 )
@@ -37,7 +41,7 @@ func TestSymbolizer(t *testing.T) {
 
 	w := log.NewSyncWriter(os.Stderr)
 	logger := log.NewLogfmtLogger(w)
-	st := NewSymbolStore(logger, bucket, dir)
+	st := NewSymbolStore(logger, nil, bucket, dir)
 	lis, err := net.Listen("tcp", ":0")
 	require.NoError(t, err)
 	defer lis.Close()
@@ -92,7 +96,7 @@ func TestRealSymbolizer(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(dir) // clean up
 
-	st := NewSymbolStore(log.NewNopLogger(), bucket, dir)
+	st := NewSymbolStore(log.NewNopLogger(), nil, bucket, dir)
 	lis, err := net.Listen("tcp", ":0")
 	require.NoError(t, err)
 	defer lis.Close()
@@ -116,3 +120,150 @@ func TestRealSymbolizer(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, p.CheckValid())
 }
+
+type fakeSymbolizeClient struct {
+	requests  int
+	addresses int
+}
+
+func (c *fakeSymbolizeClient) Symbolize(ctx context.Context, req *storepb.SymbolizeRequest) (*storepb.SymbolizeResponse, error) {
+	c.requests++
+	for _, m := range req.Mappings {
+		for _, l := range m.Locations {
+			c.addresses++
+			if m.BuildId == "unknown" {
+				continue
+			}
+			l.Lines = []*storepb.Line{{
+				Line:     1,
+				Function: &storepb.Function{Name: fmt.Sprintf("func_%x", l.Address-m.MemoryStart+m.FileOffset)},
+			}}
+		}
+	}
+	return &storepb.SymbolizeResponse{Mappings: req.Mappings}, nil
+}
+
+func TestSymbolizerCache(t *testing.T) {
+	newProfile := func() *profile.Profile {
+		// The same binary loaded at different addresses.
+		m1 := &profile.Mapping{ID: 1, Start: 0x1000, Limit: 0x2000, BuildID: "aaaa"}
+		m2 := &profile.Mapping{ID: 2, Start: 0x5000, Limit: 0x6000, BuildID: "aaaa"}
+		m3 := &profile.Mapping{ID: 3, Start: 0x8000, Limit: 0x9000, BuildID: "unknown"}
+		return &profile.Profile{
+			Location: []*profile.Location{
+				{ID: 1, Mapping: m1, Address: 0x1010},
+				{ID: 2, Mapping: m2, Address: 0x5010},
+				{ID: 3, Mapping: m2, Address: 0x5020},
+				{ID: 4, Mapping: m3, Address: 0x8010},
+			},
+			Mapping: []*profile.Mapping{m1, m2, m3},
+		}
+	}
+
+	c := &fakeSymbolizeClient{}
+	s := NewSymbolizer(log.NewNopLogger(), c)
+
+	p := newProfile()
+	require.NoError(t, s.Symbolize(context.Background(), p))
+	require.Equal(t, 1, c.requests)
+	// The first two locations are at the same file offset.
+	require.Equal(t, 3, c.addresses)
+	require.Equal(t, "func_10", p.Location[0].Line[0].Function.Name)
+	require.Equal(t, "func_10", p.Location[1].Line[0].Function.Name)
+	require.Equal(t, "func_20", p.Location[2].Line[0].Function.Name)
+	require.Empty(t, p.Location[3].Line)
+
+	// Only the address that couldn't be resolved is requested again.
+	p = newProfile()
+	require.NoError(t, s.Symbolize(context.Background(), p))
+	require.Equal(t, 2, c.requests)
+	require.Equal(t, 4, c.addresses)
+	require.Equal(t, "func_10", p.Location[0].Line[0].Function.Name)
+	require.Equal(t, "func_20", p.Location[2].Line[0].Function.Name)
+	require.Len(t, p.Function, 2)
+}
+
+func TestSymbolizerWithoutBuildID(t *testing.T) {
+	dir, err := os.MkdirTemp("", "symbolizer-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // clean up
+
+	// The Go binary of TestSymbolizer without its build ID.
+	binary := filepath.Join(dir, "pprof-labels-example")
+	out, err := exec.Command("objcopy", "--remove-section", ".note.gnu.build-id", "testdata/2d6912fd3dd64542f6f6294f4bf9cb6c265b3085/debuginfo", binary).CombinedOutput()
+	if err != nil {
+		t.Skipf("objcopy failed: %v: %s", err, out)
+	}
+
+	bucket := objstore.NewInMemBucket()
+	st := NewSymbolStore(log.NewNopLogger(), nil, bucket, dir)
+	lis, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer lis.Close()
+	grpcServer := grpc.NewServer()
+	storepb.RegisterSymbolStoreServer(grpcServer, st)
+	go grpcServer.Serve(lis)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	id, size, err := NewSymbolStoreClient(storepb.NewSymbolStoreClient(conn)).UploadFile(context.Background(), binary, "/usr/local/bin/pprof-labels-example")
+	require.NoError(t, err)
+	require.NotZero(t, size)
+	f, err := os.Open(binary)
+	require.NoError(t, err)
+	defer f.Close()
+	fileID, err := FileID("/usr/local/bin/pprof-labels-example", f)
+	require.NoError(t, err)
+	require.Equal(t, fileID, id)
+
+	res, err := st.Symbolize(context.Background(), &storepb.SymbolizeRequest{
+		Mappings: []*storepb.Mapping{{
+			File:        "/usr/local/bin/pprof-labels-example",
+			MemoryStart: 4194304,
+			MemoryLimit: 4603904,
+			Locations:   []*storepb.Location{{Address: 0x463781}},
+		}},
+	})
+	require.NoError(t, err)
+	lines := res.Mappings[0].Locations[0].Lines
+	require.Len(t, lines, 3)
+
+	s := NewSymbolizer(log.NewNopLogger(), storepb.NewSymbolizeClient(conn))
+	m := &profile.Mapping{
+		ID:    uint64(1),
+		Start: 4194304,
+		Limit: 4603904,
+		File:  "/usr/local/bin/pprof-labels-example",
+	}
+	vdso := &profile.Mapping{
+		ID:    uint64(2),
+		Start: 0x7fff0000,
+		Limit: 0x7fff1000,
+		File:  "[vdso]",
+	}
+	p := &profile.Profile{
+		Location: []*profile.Location{
+			{ID: 1, Mapping: m, Address: 0x463781},
+			{ID: 2, Mapping: vdso, Address: 0x7fff0010},
+		},
+		Mapping: []*profile.Mapping{m, vdso},
+	}
+	require.NoError(t, s.Symbolize(context.Background(), p))
+
+	// Inlined functions come first, followed by the function they are
+	// inlined into.
+	require.Len(t, p.Location[0].Line, 3)
+	require.Equal(t, "main.iterate", p.Location[0].Line[0].Function.Name)
+	require.Equal(t, "main.iteratePerTenant", p.Location[0].Line[1].Function.Name)
+	require.Equal(t, "main.main", p.Location[0].Line[2].Function.Name)
+	require.Empty(t, p.Location[1].Line)
+	require.NoError(t, p.CheckValid())
+
+	// Binaries nobody uploaded aren't symbolized.
+	m.File = "/usr/local/bin/other"
+	p.Location[0].Line = nil
+	require.NoError(t, s.Symbolize(context.Background(), p))
+	require.Empty(t, p.Location[0].Line)
+}
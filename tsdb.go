@@ -0,0 +1,599 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/conprof/db/storage"
+	"github.com/conprof/db/tsdb"
+	tsdbchunkenc "github.com/conprof/db/tsdb/chunkenc"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/run"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/thanos-io/thanos/pkg/component"
+	"github.com/thanos-io/thanos/pkg/prober"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/conprof/conprof/pkg/chunkenc"
+	"github.com/conprof/conprof/pkg/runutil"
+)
+
+// registerTSDB registers the commands maintaining the TSDB offline.
+func registerTSDB(m map[string]setupFunc, app *kingpin.Application, name string) {
+	cmd := app.Command(name, "Inspect and maintain the TSDB. The TSDB must not be in use by a running conprof.")
+
+	registerTSDBList(m, cmd, name+" ls")
+	registerTSDBAnalyze(m, cmd, name+" analyze")
+	registerTSDBDump(m, cmd, name+" dump")
+	registerTSDBDelete(m, cmd, name+" delete")
+	registerTSDBRecompress(m, cmd, name+" recompress")
+}
+
+// registerTSDBPathFlag registers the flag of the TSDB directory, the same as
+// the storage command's.
+func registerTSDBPathFlag(cmd *kingpin.CmdClause) *string {
+	return cmd.Flag("storage.tsdb.path", "Directory of the TSDB.").
+		Default("./data").String()
+}
+
+// registerTSDBTimeFlags registers the flags of the time range, in
+// milliseconds since the epoch, to operate on.
+func registerTSDBTimeFlags(cmd *kingpin.CmdClause) (mint, maxt *int64) {
+	mint = cmd.Flag("min-time", "Start of the time range in milliseconds since the epoch, unbounded by default.").
+		Default(strconv.FormatInt(math.MinInt64, 10)).Int64()
+	maxt = cmd.Flag("max-time", "End of the time range in milliseconds since the epoch, unbounded by default.").
+		Default(strconv.FormatInt(math.MaxInt64, 10)).Int64()
+	return mint, maxt
+}
+
+// readOnlyTSDB is a TSDB opened read-only, which can also be queried without
+// a WAL, such as when it only holds blocks created by backfilling.
+type readOnlyTSDB struct {
+	*tsdb.DBReadOnly
+	dir string
+}
+
+func openReadOnlyTSDB(dir string, logger log.Logger) (*readOnlyTSDB, error) {
+	db, err := tsdb.OpenDBReadOnly(dir, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyTSDB{DBReadOnly: db, dir: dir}, nil
+}
+
+func (db *readOnlyTSDB) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	if _, err := os.Stat(filepath.Join(db.dir, "wal")); !os.IsNotExist(err) {
+		return db.DBReadOnly.Querier(ctx, mint, maxt)
+	}
+
+	blocks, err := db.Blocks()
+	if err != nil {
+		return nil, err
+	}
+	queriers := make([]storage.Querier, 0, len(blocks))
+	for _, b := range blocks {
+		q, err := tsdb.NewBlockQuerier(b, mint, maxt)
+		if err != nil {
+			for _, q := range queriers {
+				q.Close()
+			}
+			return nil, err
+		}
+		queriers = append(queriers, q)
+	}
+	return storage.NewMergeQuerier(queriers, nil, storage.ChainedSeriesMerge), nil
+}
+
+// registerTSDBList registers a command listing the blocks of the TSDB.
+func registerTSDBList(m map[string]setupFunc, parent *kingpin.CmdClause, name string) {
+	cmd := parent.Command("ls", "List the blocks of the TSDB with their time ranges, number of series and sizes.")
+
+	storagePath := registerTSDBPathFlag(cmd)
+	humanReadable := cmd.Flag("human-readable", "Print the time ranges as RFC3339 instead of milliseconds since the epoch.").
+		Short('r').Bool()
+
+	registerOneShot(m, name, func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
+		g.Add(func() error {
+			db, err := tsdb.OpenDBReadOnly(*storagePath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			blocks, err := db.Blocks()
+			if err != nil {
+				return err
+			}
+			return printBlocks(os.Stdout, blocks, *humanReadable)
+		}, func(error) {})
+
+		probe.Ready()
+		return probe, nil
+	})
+}
+
+func printBlocks(w io.Writer, blocks []tsdb.BlockReader, humanReadable bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BLOCK ULID\tMIN TIME\tMAX TIME\tDURATION\tNUM SERIES\tNUM SAMPLES\tNUM CHUNKS\tSIZE")
+	for _, b := range blocks {
+		meta := b.Meta()
+		fmt.Fprintf(tw, "%v\t%s\t%s\t%v\t%d\t%d\t%d\t%d\n",
+			meta.ULID,
+			formatTimestamp(meta.MinTime, humanReadable),
+			formatTimestamp(meta.MaxTime, humanReadable),
+			time.Duration(meta.MaxTime-meta.MinTime)*time.Millisecond,
+			meta.Stats.NumSeries,
+			meta.Stats.NumSamples,
+			meta.Stats.NumChunks,
+			b.Size(),
+		)
+	}
+	return tw.Flush()
+}
+
+func formatTimestamp(t int64, humanReadable bool) string {
+	if humanReadable {
+		return time.Unix(0, t*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+	}
+	return strconv.FormatInt(t, 10)
+}
+
+// registerTSDBAnalyze registers a command analyzing the label cardinality and
+// the size of the series of the TSDB.
+func registerTSDBAnalyze(m map[string]setupFunc, parent *kingpin.CmdClause, name string) {
+	cmd := parent.Command("analyze", "Analyze the label cardinality, the biggest series and the bytes per profile type of the TSDB.")
+
+	storagePath := registerTSDBPathFlag(cmd)
+	match := cmd.Flag("match", "Selector of the series to analyze.").
+		Default(`{__name__=~".+"}`).String()
+	mint, maxt := registerTSDBTimeFlags(cmd)
+	limit := cmd.Flag("limit", "Number of entries to print of each listing.").
+		Default("20").Int()
+
+	registerOneShot(m, name, func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
+		ms, err := parser.ParseMetricSelector(*match)
+		if err != nil {
+			return probe, fmt.Errorf("parse selector: %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			db, err := openReadOnlyTSDB(*storagePath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			return analyzeSeries(ctx, os.Stdout, db, *mint, *maxt, ms, *limit)
+		}, func(error) {
+			cancel()
+		})
+
+		probe.Ready()
+		return probe, nil
+	})
+}
+
+type seriesStats struct {
+	labels   labels.Labels
+	profiles int
+	bytes    int
+}
+
+// analyzeSeries prints the label names with the most values, the biggest
+// series and the bytes per profile type of the series matching the
+// matchers. Sizes are those of the profiles as they are stored, before the
+// compression of the chunks they are in.
+func analyzeSeries(ctx context.Context, w io.Writer, db storage.Queryable, mint, maxt int64, ms []*labels.Matcher, limit int) error {
+	q, err := db.Querier(ctx, mint, maxt)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	var (
+		series       []seriesStats
+		types        = map[string]*seriesStats{}
+		labelValues  = map[string]map[string]struct{}{}
+		labelSeries  = map[string]int{}
+		profiles     int
+		profileBytes int
+	)
+	set := q.Select(false, nil, ms...)
+	for set.Next() {
+		s := seriesStats{labels: set.At().Labels()}
+		it := set.At().Iterator()
+		for it.Next() {
+			_, v := it.At()
+			s.profiles++
+			s.bytes += len(v)
+		}
+		if err := it.Err(); err != nil {
+			return fmt.Errorf("iterate series %s: %w", s.labels, err)
+		}
+		series = append(series, s)
+		profiles += s.profiles
+		profileBytes += s.bytes
+
+		for _, l := range s.labels {
+			if labelValues[l.Name] == nil {
+				labelValues[l.Name] = map[string]struct{}{}
+			}
+			labelValues[l.Name][l.Value] = struct{}{}
+			labelSeries[l.Name]++
+		}
+
+		typ := s.labels.Get(labels.MetricName)
+		if types[typ] == nil {
+			types[typ] = &seriesStats{}
+		}
+		types[typ].profiles += s.profiles
+		types[typ].bytes += s.bytes
+	}
+	if err := set.Err(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Series: %d\nProfiles: %d\nBytes: %d\n", len(series), profiles, profileBytes)
+
+	names := make([]string, 0, len(labelValues))
+	for n := range labelValues {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(labelValues[names[i]]) != len(labelValues[names[j]]) {
+			return len(labelValues[names[i]]) > len(labelValues[names[j]])
+		}
+		return names[i] < names[j]
+	})
+	fmt.Fprintln(w, "\nLabel names with the most values:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VALUES\tSERIES\tLABEL NAME")
+	for _, n := range names[:min(limit, len(names))] {
+		fmt.Fprintf(tw, "%d\t%d\t%s\n", len(labelValues[n]), labelSeries[n], n)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].bytes > series[j].bytes
+	})
+	fmt.Fprintln(w, "\nBiggest series:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BYTES\tPROFILES\tSERIES")
+	for _, s := range series[:min(limit, len(series))] {
+		fmt.Fprintf(tw, "%d\t%d\t%s\n", s.bytes, s.profiles, s.labels)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	typeNames := make([]string, 0, len(types))
+	for t := range types {
+		typeNames = append(typeNames, t)
+	}
+	sort.Slice(typeNames, func(i, j int) bool {
+		return types[typeNames[i]].bytes > types[typeNames[j]].bytes
+	})
+	fmt.Fprintln(w, "\nBytes per profile type:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BYTES\tPROFILES\tBYTES PER PROFILE\tPROFILE TYPE")
+	for _, t := range typeNames {
+		s := types[t]
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\n", s.bytes, s.profiles, s.bytes/max(s.profiles, 1), t)
+	}
+	return tw.Flush()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// manifestFile is the name of the manifest of a directory of profiles.
+const manifestFile = "manifest.json"
+
+// manifest lists the labels of the profiles in each subdirectory of a
+// directory of profiles, as written by the tsdb dump command.
+type manifest struct {
+	Series []manifestSeries `json:"series"`
+}
+
+type manifestSeries struct {
+	Dir    string            `json:"dir"`
+	Labels map[string]string `json:"labels"`
+}
+
+// registerTSDBDump registers a command exporting the profiles of the TSDB to
+// a directory.
+func registerTSDBDump(m map[string]setupFunc, parent *kingpin.CmdClause, name string) {
+	cmd := parent.Command("dump", "Export the profiles of the series matching the selector to a directory. The profiles of each series are written as gzipped pprof files, named by their timestamp, to a directory listed with the labels of the series in the manifest.json.")
+
+	storagePath := registerTSDBPathFlag(cmd)
+	match := cmd.Flag("match", "Selector of the series to export.").
+		Default(`{__name__=~".+"}`).String()
+	mint, maxt := registerTSDBTimeFlags(cmd)
+	output := cmd.Flag("output", "Directory to export the profiles to.").
+		Required().String()
+
+	registerOneShot(m, name, func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
+		ms, err := parser.ParseMetricSelector(*match)
+		if err != nil {
+			return probe, fmt.Errorf("parse selector: %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			db, err := openReadOnlyTSDB(*storagePath, logger)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			return dumpProfiles(ctx, logger, db, *mint, *maxt, ms, *output)
+		}, func(error) {
+			cancel()
+		})
+
+		probe.Ready()
+		return probe, nil
+	})
+}
+
+// dumpProfiles writes the profiles of the series matching the matchers to
+// dir, the profiles of every series to a directory named by the hash of its
+// labels. The modification time of each file is the timestamp of its
+// profile.
+func dumpProfiles(ctx context.Context, logger log.Logger, db storage.Queryable, mint, maxt int64, ms []*labels.Matcher, dir string) error {
+	q, err := db.Querier(ctx, mint, maxt)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	var mf manifest
+	set := q.Select(false, nil, ms...)
+	for set.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		lset := set.At().Labels()
+		seriesDir := fmt.Sprintf("%016x", lset.Hash())
+		if err := os.MkdirAll(filepath.Join(dir, seriesDir), 0755); err != nil {
+			return err
+		}
+
+		n := 0
+		it := set.At().Iterator()
+		for it.Next() {
+			t, v := it.At()
+			b, err := chunkenc.Compress(chunkenc.CodecGzip, v)
+			if err != nil {
+				return fmt.Errorf("compress profile of %s at %d: %w", lset, t, err)
+			}
+			path := filepath.Join(dir, seriesDir, fmt.Sprintf("%d.pb.gz", t))
+			if err := ioutil.WriteFile(path, b, 0644); err != nil {
+				return err
+			}
+			mtime := time.Unix(0, t*int64(time.Millisecond))
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				return err
+			}
+			n++
+		}
+		if err := it.Err(); err != nil {
+			return fmt.Errorf("iterate series %s: %w", lset, err)
+		}
+		mf.Series = append(mf.Series, manifestSeries{Dir: seriesDir, Labels: lset.Map()})
+		level.Info(logger).Log("msg", "dumped series", "series", lset, "dir", seriesDir, "profiles", n)
+	}
+	if err := set.Err(); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(mf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, manifestFile), b, 0644)
+}
+
+// registerTSDBDelete registers a command deleting series from the TSDB.
+func registerTSDBDelete(m map[string]setupFunc, parent *kingpin.CmdClause, name string) {
+	cmd := parent.Command("delete", "Delete the series matching the selectors in the time range by writing tombstones. The deleted profiles are removed from disk when their blocks are compacted, or right away with --clean-tombstones.")
+
+	storagePath := registerTSDBPathFlag(cmd)
+	retention := registerTSDBRetentionFlag(cmd)
+	matches := cmd.Flag("match", "Selector of the series to delete, can be repeated.").
+		Required().Strings()
+	mint, maxt := registerTSDBTimeFlags(cmd)
+	cleanTombstones := cmd.Flag("clean-tombstones", "Rewrite the blocks with tombstones without the deleted profiles.").
+		Bool()
+
+	registerOneShot(m, name, func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
+		selectors := make([][]*labels.Matcher, 0, len(*matches))
+		for _, match := range *matches {
+			ms, err := parser.ParseMetricSelector(match)
+			if err != nil {
+				return probe, fmt.Errorf("parse selector %q: %w", match, err)
+			}
+			selectors = append(selectors, ms)
+		}
+
+		g.Add(func() error {
+			return deleteSeries(logger, *storagePath, tsdbOptions(time.Duration(*retention)), *mint, *maxt, *matches, selectors, *cleanTombstones)
+		}, func(error) {})
+
+		probe.Ready()
+		return probe, nil
+	})
+}
+
+// deleteSeries writes tombstones for the series matching each of the
+// selectors in the time range, and rewrites the blocks without them if clean
+// is set. The TSDB is opened with the options of the storage, as it is
+// written to.
+func deleteSeries(logger log.Logger, dir string, opts *tsdb.Options, mint, maxt int64, matches []string, selectors [][]*labels.Matcher, clean bool) (err error) {
+	db, err := tsdb.Open(dir, logger, nil, opts)
+	if err != nil {
+		return err
+	}
+	defer runutil.CloseWithErrCapture(&err, db, "close tsdb")
+
+	for i, ms := range selectors {
+		if err := db.Delete(mint, maxt, ms...); err != nil {
+			return fmt.Errorf("delete series of %q: %w", matches[i], err)
+		}
+		level.Info(logger).Log("msg", "deleted series", "match", matches[i])
+	}
+	if clean {
+		if err := db.CleanTombstones(); err != nil {
+			return fmt.Errorf("clean tombstones: %w", err)
+		}
+	}
+	return nil
+}
+
+// registerTSDBRecompress registers a command rewriting the blocks of the TSDB
+// with their profiles compressed with another codec.
+func registerTSDBRecompress(m map[string]setupFunc, parent *kingpin.CmdClause, name string) {
+	cmd := parent.Command("recompress", "Rewrite the blocks of the TSDB with their profiles compressed with the codec. Profiles that are still in the head are left as they are. Each compressed profile records its codec in a header byte, profiles compressed before are recompressed to record it too.")
+
+	storagePath := registerTSDBPathFlag(cmd)
+	codec := cmd.Flag("codec", "Codec to compress the profiles with.").
+		Default(string(chunkenc.CodecZstd)).Enum(chunkenc.Codecs...)
+
+	registerOneShot(m, name, func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			return recompressBlocks(ctx, logger, *storagePath, chunkenc.Codec(*codec))
+		}, func(error) {
+			cancel()
+		})
+
+		probe.Ready()
+		return probe, nil
+	})
+}
+
+// recompressBlocks rewrites every block of the TSDB in dir with its profiles
+// compressed with the codec. The rewritten blocks name the blocks they
+// replace as their parents, so the TSDB deletes those on open, should the
+// rewrite be interrupted before it deletes them itself.
+func recompressBlocks(ctx context.Context, logger log.Logger, dir string, codec chunkenc.Codec) error {
+	db, err := tsdb.OpenDBReadOnly(dir, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	blocks, err := db.Blocks()
+	if err != nil {
+		return err
+	}
+	compactor, err := tsdb.NewLeveledCompactor(ctx, nil, logger, []int64{tsdb.DefaultBlockDuration}, nil)
+	if err != nil {
+		return err
+	}
+
+	var replaced []string
+	for _, b := range blocks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		meta := b.Meta()
+		uid, err := recompressBlock(compactor, dir, b, codec)
+		if err != nil {
+			return fmt.Errorf("recompress block %s: %w", meta.ULID, err)
+		}
+		if uid == (ulid.ULID{}) {
+			level.Info(logger).Log("msg", "block has no samples, skipping", "block", meta.ULID)
+			continue
+		}
+		level.Info(logger).Log("msg", "recompressed block", "block", meta.ULID, "new", uid, "codec", codec)
+		replaced = append(replaced, b.(*tsdb.Block).Dir())
+	}
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+	for _, d := range replaced {
+		if err := os.RemoveAll(d); err != nil {
+			return fmt.Errorf("delete recompressed block: %w", err)
+		}
+	}
+	return nil
+}
+
+// recompressBlock writes the block with its profiles compressed with the
+// codec to dir, naming the block as its parent. It returns the ULID of the
+// new block, which is empty if the block has no samples.
+func recompressBlock(compactor tsdb.Compactor, dir string, b tsdb.BlockReader, codec chunkenc.Codec) (ulid.ULID, error) {
+	meta := b.Meta()
+	return compactor.Write(dir, &recompressingBlock{BlockReader: b, codec: codec}, meta.MinTime, meta.MaxTime, &meta)
+}
+
+// recompressingBlock is a block, whose chunks have their profiles compressed
+// with the codec.
+type recompressingBlock struct {
+	tsdb.BlockReader
+	codec chunkenc.Codec
+}
+
+func (b *recompressingBlock) Chunks() (tsdb.ChunkReader, error) {
+	cr, err := b.BlockReader.Chunks()
+	if err != nil {
+		return nil, err
+	}
+	return &recompressingChunkReader{ChunkReader: cr, codec: b.codec}, nil
+}
+
+type recompressingChunkReader struct {
+	tsdb.ChunkReader
+	codec chunkenc.Codec
+}
+
+func (r *recompressingChunkReader) Chunk(ref uint64) (tsdbchunkenc.Chunk, error) {
+	c, err := r.ChunkReader.Chunk(ref)
+	if err != nil {
+		return nil, err
+	}
+	return chunkenc.Recompress(r.codec, c)
+}
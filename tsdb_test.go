@@ -0,0 +1,412 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conprof/db/tsdb"
+	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/conprof/conprof/pkg/chunkenc"
+	"github.com/conprof/conprof/pkg/testutil"
+)
+
+// testProfile returns the uncompressed profile of the API's test data.
+func testProfile(t *testing.T) []byte {
+	f, err := ioutil.ReadFile("api/testdata/alloc_objects.pb.gz")
+	require.NoError(t, err)
+	p, err := profile.ParseData(f)
+	require.NoError(t, err)
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, p.WriteUncompressed(buf))
+	return buf.Bytes()
+}
+
+// newTestDB returns a TSDB with the test profile at the timestamps of each
+// series, which also have the job label.
+func newTestDB(t *testing.T, series map[string][]int64) *tsdb.DB {
+	db, err := testutil.NewTSDB()
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(db.Dir()) })
+
+	p := testProfile(t)
+	app := db.Appender(context.Background())
+	for name, timestamps := range series {
+		ls := labels.Labels{{Name: "__name__", Value: name}, {Name: "job", Value: "test"}}
+		for _, ts := range timestamps {
+			_, err := app.Add(ls, ts, p)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, app.Commit())
+	return db
+}
+
+// newTestTSDB returns the directory of a TSDB like newTestDB, with the
+// profiles compacted into a block.
+func newTestTSDB(t *testing.T, series map[string][]int64) string {
+	db := newTestDB(t, series)
+	require.NoError(t, db.CompactHead(tsdb.NewRangeHead(db.Head(), db.Head().MinTime(), db.Head().MaxTime())))
+	require.NoError(t, db.Close())
+	return db.Dir()
+}
+
+// blockDirs returns the ULIDs of the block directories in dir.
+func blockDirs(t *testing.T, dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	var res []string
+	for _, f := range files {
+		if _, err := ulid.ParseStrict(f.Name()); err == nil && f.IsDir() {
+			res = append(res, f.Name())
+		}
+	}
+	return res
+}
+
+// readProfiles returns the profiles of the series in the TSDB by timestamp.
+func readProfiles(t *testing.T, dir, name string) map[int64][]byte {
+	db, err := openReadOnlyTSDB(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	defer db.Close()
+
+	q, err := db.Querier(context.Background(), math.MinInt64, math.MaxInt64)
+	require.NoError(t, err)
+	defer q.Close()
+
+	res := map[int64][]byte{}
+	set := q.Select(false, nil, labels.MustNewMatcher(labels.MatchEqual, "__name__", name))
+	for set.Next() {
+		it := set.At().Iterator()
+		for it.Next() {
+			ts, v := it.At()
+			res[ts] = append([]byte(nil), v...)
+		}
+		require.NoError(t, it.Err())
+	}
+	require.NoError(t, set.Err())
+	return res
+}
+
+// requireCodec requires a profile at each of the timestamps, compressed with
+// the codec and decompressing to exp.
+func requireCodec(t *testing.T, codec chunkenc.Codec, profiles map[int64][]byte, timestamps []int64, exp []byte) {
+	require.Len(t, profiles, len(timestamps))
+	for _, ts := range timestamps {
+		v, ok := profiles[ts]
+		require.True(t, ok, "missing profile at %d", ts)
+		require.Equal(t, codec, chunkenc.CodecOf(v))
+
+		v, err := chunkenc.Decompress(v)
+		require.NoError(t, err)
+		require.Equal(t, exp, v)
+	}
+}
+
+func TestRecompressBlocks(t *testing.T) {
+	timestamps := []int64{1, 2, 3}
+	dir := newTestTSDB(t, map[string][]int64{"allocs": timestamps})
+	p := testProfile(t)
+
+	before := blockDirs(t, dir)
+	require.Len(t, before, 1)
+
+	require.NoError(t, recompressBlocks(context.Background(), log.NewNopLogger(), dir, chunkenc.CodecZstd))
+
+	after := blockDirs(t, dir)
+	require.Len(t, after, 1)
+	require.NotEqual(t, before, after)
+	db, err := tsdb.OpenDBReadOnly(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	blocks, err := db.Blocks()
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	require.Equal(t, before[0], blocks[0].Meta().Compaction.Parents[0].ULID.String())
+	require.NoError(t, db.Close())
+
+	requireCodec(t, chunkenc.CodecZstd, readProfiles(t, dir, "allocs"), timestamps, p)
+}
+
+func TestRecompressBlocksInterrupted(t *testing.T) {
+	timestamps := []int64{1, 2, 3}
+	dir := newTestTSDB(t, map[string][]int64{"allocs": timestamps})
+	p := testProfile(t)
+
+	before := blockDirs(t, dir)
+	require.Len(t, before, 1)
+
+	// Write the recompressed block, but stop before deleting the block it
+	// replaces.
+	db, err := tsdb.OpenDBReadOnly(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	blocks, err := db.Blocks()
+	require.NoError(t, err)
+	compactor, err := tsdb.NewLeveledCompactor(context.Background(), nil, log.NewNopLogger(), []int64{tsdb.DefaultBlockDuration}, nil)
+	require.NoError(t, err)
+	uid, err := recompressBlock(compactor, dir, blocks[0], chunkenc.CodecGzip)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+	require.Len(t, blockDirs(t, dir), 2)
+
+	// Opening the TSDB deletes the parent of the recompressed block.
+	opts := tsdb.DefaultOptions()
+	opts.RetentionDuration = math.MaxInt64
+	rw, err := tsdb.Open(dir, nil, nil, opts)
+	require.NoError(t, err)
+	require.NoError(t, rw.Close())
+	require.Equal(t, []string{uid.String()}, blockDirs(t, dir))
+
+	requireCodec(t, chunkenc.CodecGzip, readProfiles(t, dir, "allocs"), timestamps, p)
+}
+
+func TestPrintBlocks(t *testing.T) {
+	dir := newTestTSDB(t, map[string][]int64{"allocs": {1, 2, 3}, "heap": {1, 2}})
+	db, err := tsdb.OpenDBReadOnly(dir, log.NewNopLogger())
+	require.NoError(t, err)
+	defer db.Close()
+	blocks, err := db.Blocks()
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	meta := blocks[0].Meta()
+
+	for _, tc := range []struct {
+		name          string
+		humanReadable bool
+		mint, maxt    string
+	}{
+		{
+			name: "milliseconds",
+			mint: strconv.FormatInt(meta.MinTime, 10),
+			maxt: strconv.FormatInt(meta.MaxTime, 10),
+		},
+		{
+			name:          "human readable",
+			humanReadable: true,
+			mint:          "1970-01-01T00:00:00Z",
+			maxt:          "1970-01-01T00:00:00Z",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			require.NoError(t, printBlocks(buf, blocks, tc.humanReadable))
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			require.Len(t, lines, 2)
+			require.Equal(t, []string{"BLOCK", "ULID", "MIN", "TIME", "MAX", "TIME", "DURATION", "NUM", "SERIES", "NUM", "SAMPLES", "NUM", "CHUNKS", "SIZE"}, strings.Fields(lines[0]))
+			require.Equal(t, []string{
+				meta.ULID.String(),
+				tc.mint,
+				tc.maxt,
+				(time.Duration(meta.MaxTime-meta.MinTime) * time.Millisecond).String(),
+				"2",
+				"5",
+				"2",
+				strconv.FormatInt(blocks[0].Size(), 10),
+			}, strings.Fields(lines[1]))
+		})
+	}
+}
+
+func TestAnalyzeSeries(t *testing.T) {
+	db := newTestDB(t, map[string][]int64{"allocs": {1, 2, 3}, "heap": {1, 2}})
+	defer db.Close()
+	size := len(testProfile(t))
+
+	for _, tc := range []struct {
+		name  string
+		ms    []*labels.Matcher
+		limit int
+		exp   string
+	}{
+		{
+			name:  "all series",
+			ms:    []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "__name__", ".+")},
+			limit: 20,
+			exp: fmt.Sprintf(`Series: 2
+Profiles: 5
+Bytes: %[1]d
+
+Label names with the most values:
+VALUES SERIES LABEL NAME
+2 2 __name__
+1 2 job
+
+Biggest series:
+BYTES PROFILES SERIES
+%[2]d 3 {__name__="allocs", job="test"}
+%[3]d 2 {__name__="heap", job="test"}
+
+Bytes per profile type:
+BYTES PROFILES BYTES PER PROFILE PROFILE TYPE
+%[2]d 3 %[4]d allocs
+%[3]d 2 %[4]d heap
+`, 5*size, 3*size, 2*size, size),
+		},
+		{
+			name:  "limited",
+			ms:    []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "__name__", ".+")},
+			limit: 1,
+			exp: fmt.Sprintf(`Series: 2
+Profiles: 5
+Bytes: %[1]d
+
+Label names with the most values:
+VALUES SERIES LABEL NAME
+2 2 __name__
+
+Biggest series:
+BYTES PROFILES SERIES
+%[2]d 3 {__name__="allocs", job="test"}
+
+Bytes per profile type:
+BYTES PROFILES BYTES PER PROFILE PROFILE TYPE
+%[2]d 3 %[4]d allocs
+%[3]d 2 %[4]d heap
+`, 5*size, 3*size, 2*size, size),
+		},
+		{
+			name:  "matching series",
+			ms:    []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "__name__", "heap")},
+			limit: 20,
+			exp: fmt.Sprintf(`Series: 1
+Profiles: 2
+Bytes: %[1]d
+
+Label names with the most values:
+VALUES SERIES LABEL NAME
+1 1 __name__
+1 1 job
+
+Biggest series:
+BYTES PROFILES SERIES
+%[1]d 2 {__name__="heap", job="test"}
+
+Bytes per profile type:
+BYTES PROFILES BYTES PER PROFILE PROFILE TYPE
+%[1]d 2 %[2]d heap
+`, 2*size, size),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			require.NoError(t, analyzeSeries(context.Background(), buf, db, math.MinInt64, math.MaxInt64, tc.ms, tc.limit))
+			require.Equal(t, tc.exp, collapseSpaces(buf.String()))
+		})
+	}
+}
+
+// collapseSpaces replaces the runs of spaces aligning the columns of tables
+// with a single space.
+func collapseSpaces(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.Join(strings.Fields(l), " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestDumpProfiles(t *testing.T) {
+	db := newTestDB(t, map[string][]int64{"allocs": {1, 2, 3}, "heap": {1, 2}})
+	defer db.Close()
+	p := testProfile(t)
+
+	for _, tc := range []struct {
+		name string
+		ms   []*labels.Matcher
+		mint int64
+		exp  map[string][]int64
+	}{
+		{
+			name: "all series",
+			ms:   []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "__name__", ".+")},
+			mint: math.MinInt64,
+			exp:  map[string][]int64{"allocs": {1, 2, 3}, "heap": {1, 2}},
+		},
+		{
+			name: "matching series",
+			ms:   []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "__name__", "allocs")},
+			mint: math.MinInt64,
+			exp:  map[string][]int64{"allocs": {1, 2, 3}},
+		},
+		{
+			name: "time range",
+			ms:   []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, "__name__", ".+")},
+			mint: 2,
+			exp:  map[string][]int64{"allocs": {2, 3}, "heap": {2}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "conprof-dump")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			require.NoError(t, dumpProfiles(context.Background(), log.NewNopLogger(), db, tc.mint, math.MaxInt64, tc.ms, dir))
+
+			b, err := ioutil.ReadFile(filepath.Join(dir, manifestFile))
+			require.NoError(t, err)
+			var mf manifest
+			require.NoError(t, json.Unmarshal(b, &mf))
+			require.Len(t, mf.Series, len(tc.exp))
+
+			for _, s := range mf.Series {
+				require.Equal(t, "test", s.Labels["job"])
+				timestamps, ok := tc.exp[s.Labels["__name__"]]
+				require.True(t, ok, "unexpected series %v", s.Labels)
+
+				files, err := ioutil.ReadDir(filepath.Join(dir, s.Dir))
+				require.NoError(t, err)
+				require.Len(t, files, len(timestamps))
+				for _, ts := range timestamps {
+					path := filepath.Join(dir, s.Dir, fmt.Sprintf("%d.pb.gz", ts))
+					fi, err := os.Stat(path)
+					require.NoError(t, err)
+					require.Equal(t, ts, fi.ModTime().UnixNano()/int64(time.Millisecond))
+
+					b, err := ioutil.ReadFile(path)
+					require.NoError(t, err)
+					require.Equal(t, chunkenc.CodecGzip, chunkenc.CodecOf(b))
+					b, err = chunkenc.Decompress(b)
+					require.NoError(t, err)
+					require.Equal(t, p, b)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteSeries(t *testing.T) {
+	dir := newTestTSDB(t, map[string][]int64{"allocs": {1, 2, 3}, "heap": {1, 2}})
+	ms := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "__name__", "heap")}
+
+	require.NoError(t, deleteSeries(log.NewNopLogger(), dir, tsdbOptions(0), math.MinInt64, math.MaxInt64, []string{`{__name__="heap"}`}, [][]*labels.Matcher{ms}, true))
+
+	require.Empty(t, readProfiles(t, dir, "heap"))
+	require.Len(t, readProfiles(t, dir, "allocs"), 3)
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/conprof/db/storage"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/run"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/timestamp"
+	"github.com/thanos-io/thanos/pkg/component"
+	"github.com/thanos-io/thanos/pkg/prober"
+	"google.golang.org/grpc"
+	"gopkg.in/alecthomas/kingpin.v2"
+
+	"github.com/conprof/conprof/config"
+	"github.com/conprof/conprof/pkg/convert"
+	"github.com/conprof/conprof/pkg/store"
+	"github.com/conprof/conprof/pkg/store/storepb"
+)
+
+// registerUpload registers a command uploading profiles from files.
+func registerUpload(m map[string]setupFunc, app *kingpin.Application, name string) {
+	cmd := app.Command(name, "Upload profiles from files to a store, converting them to pprof if necessary.")
+
+	grpcFlags := registerGRPCClientFlags(cmd)
+	format := cmd.Flag("format", "Format of the profiles to upload.").
+		Default(config.ProfileFormatPprof).Enum(config.ProfileFormatPprof, config.ProfileFormatFolded, config.ProfileFormatJFR)
+	profileName := cmd.Flag("name", "Name of the profile series, e.g. heap or profile.").Required().String()
+	lbls := cmd.Flag("label", "Label to add to the profile series as name=value, can be repeated.").StringMap()
+	timeout := cmd.Flag("timeout", "Timeout of uploading a profile.").Default("1m").Duration()
+	files := cmd.Arg("file", "Profile files to upload.").Required().ExistingFiles()
+
+	registerOneShot(m, name, func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
+		lset := labels.NewBuilder(labels.FromMap(*lbls)).Set(labels.MetricName, *profileName).Labels()
+
+		opts, err := grpcFlags.dialOptions()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := grpc.Dial(*grpcFlags.storeAddress, opts...)
+		if err != nil {
+			return probe, err
+		}
+		db := store.NewGRPCAppendable(logger, storepb.NewWritableProfileStoreClient(conn))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		g.Add(func() error {
+			defer conn.Close()
+			for _, f := range *files {
+				if err := uploadProfile(ctx, db, f, *format, lset, *timeout); err != nil {
+					return fmt.Errorf("upload %s: %w", f, err)
+				}
+				level.Info(logger).Log("msg", "uploaded profile", "file", f, "labels", lset)
+			}
+			return nil
+		}, func(error) {
+			cancel()
+		})
+
+		probe.Ready()
+		return probe, nil
+	})
+}
+
+func uploadProfile(ctx context.Context, db storage.Appendable, file, format string, lset labels.Labels, timeout time.Duration) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	p, err := convert.Parse(format, b)
+	if err != nil {
+		return err
+	}
+	ts := timestamp.FromTime(time.Now())
+	if p.TimeNanos > 0 {
+		ts = timestamp.FromTime(time.Unix(0, p.TimeNanos))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := p.WriteUncompressed(buf); err != nil {
+		return fmt.Errorf("write profile: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	app := db.Appender(ctx)
+	if _, err := app.Add(lset, ts, buf.Bytes()); err != nil {
+		return err
+	}
+	return app.Commit()
+}
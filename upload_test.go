@@ -0,0 +1,103 @@
+// Copyright 2021 The conprof Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/pprof/profile"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/store/labelpb"
+	"google.golang.org/grpc"
+
+	"github.com/conprof/conprof/config"
+	"github.com/conprof/conprof/pkg/store"
+	"github.com/conprof/conprof/pkg/store/storepb"
+)
+
+// writeRecorder is a WritableProfileStore recording the written series.
+type writeRecorder struct {
+	storepb.UnimplementedWritableProfileStoreServer
+
+	mtx    sync.Mutex
+	series []storepb.ProfileSeries
+}
+
+func (s *writeRecorder) Write(ctx context.Context, r *storepb.WriteRequest) (*storepb.WriteResponse, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.series = append(s.series, r.ProfileSeries...)
+	return &storepb.WriteResponse{}, nil
+}
+
+func newWriteRecorder(t *testing.T) (*writeRecorder, storepb.WritableProfileStoreClient) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &writeRecorder{}
+	srv := grpc.NewServer()
+	storepb.RegisterWritableProfileStoreServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return s, storepb.NewWritableProfileStoreClient(conn)
+}
+
+func TestUploadProfile(t *testing.T) {
+	dir := tempDir(t)
+	folded := filepath.Join(dir, "cpu.folded")
+	require.NoError(t, ioutil.WriteFile(folded, []byte("main;handle;compute 3\nmain;idle 1\n"), 0644))
+	pprof := filepath.Join(dir, "heap.pb.gz")
+	writeTestProfile(t, pprof, int64(5*time.Second), time.Unix(0, 0))
+
+	s, c := newWriteRecorder(t)
+	db := store.NewGRPCAppendable(log.NewNopLogger(), c)
+	lset := labels.FromStrings("__name__", "cpu", "job", "upload")
+
+	start := time.Now()
+	require.NoError(t, uploadProfile(context.Background(), db, folded, config.ProfileFormatFolded, lset, time.Second))
+	require.NoError(t, uploadProfile(context.Background(), db, pprof, config.ProfileFormatPprof, lset, time.Second))
+
+	require.Len(t, s.series, 2)
+	for _, series := range s.series {
+		require.Equal(t, lset, labelpb.LabelsToPromLabels(series.Labels))
+		require.Len(t, series.Samples, 1)
+	}
+
+	// Folded stacks are converted to pprof and stored at the time of the
+	// upload.
+	p, err := profile.ParseData(s.series[0].Samples[0].Value)
+	require.NoError(t, err)
+	require.Len(t, p.Sample, 2)
+	require.GreaterOrEqual(t, s.series[0].Samples[0].Timestamp, start.UnixNano()/int64(time.Millisecond))
+
+	// Profiles in pprof are stored at the time they record.
+	p, err = profile.ParseData(s.series[1].Samples[0].Value)
+	require.NoError(t, err)
+	require.Equal(t, int64(5*time.Second), p.TimeNanos)
+	require.Equal(t, int64(5000), s.series[1].Samples[0].Timestamp)
+
+	// Files that aren't of the format fail.
+	require.Error(t, uploadProfile(context.Background(), db, folded, config.ProfileFormatPprof, lset, time.Second))
+}
@@ -50,6 +50,8 @@ func registerWeb(m map[string]setupFunc, app *kingpin.Application, name string,
 		Default("64MB").Bytes()
 	queryTimeout := extkingpin.ModelDuration(cmd.Flag("query.timeout", "Maximum time to process query by query node.").
 		Default("10s"))
+	enableAdminAPI := registerAdminAPIFlag(cmd)
+	profileChunks := registerProfileChunksFlag(cmd)
 
 	m[name] = func(comp component.Component, g *run.Group, mux httpMux, probe prober.Probe, logger log.Logger, reg *prometheus.Registry, debugLogging bool) (prober.Probe, error) {
 		conn, err := grpc.Dial(*storeAddress, grpc.WithInsecure())
@@ -68,14 +70,25 @@ func registerWeb(m map[string]setupFunc, app *kingpin.Application, name string,
 			s = symbol.NewSymbolizer(logger, c)
 		}
 
+		var storeOpts []store.GRPCQueryableOption
+		if *profileChunks {
+			storeOpts = append(storeOpts, store.WithProfileChunks())
+		}
+		db := store.NewGRPCQueryable(c, storeOpts...)
+		opts := []WebOption{
+			WebLogger(logger),
+			WebRegistry(reg),
+			WebSymbolizer(s),
+		}
+		if *enableAdminAPI {
+			opts = append(opts, WebSeriesDeleter(db))
+		}
 		w := NewWeb(
 			mux,
-			store.NewGRPCQueryable(c),
+			db,
 			int64(*maxMergeBatchSize),
 			*queryTimeout,
-			WebLogger(logger),
-			WebRegistry(reg),
-			WebSymbolizer(s),
+			opts...,
 		)
 		err = w.Run(context.Background(), reloadCh)
 		if err != nil {
@@ -98,6 +111,7 @@ type Web struct {
 	queryTimeout      model.Duration
 	targets           func(context.Context) conprofapi.TargetRetriever
 	symbolizer        *symbol.Symbolizer
+	deleter           conprofapi.SeriesDeleter
 	httpLogOpts       []logging.Option
 }
 
@@ -148,6 +162,13 @@ func WebSymbolizer(s *symbol.Symbolizer) WebOption {
 	}
 }
 
+// WebSeriesDeleter enables the admin API, deleting series with the deleter.
+func WebSeriesDeleter(d conprofapi.SeriesDeleter) WebOption {
+	return func(w *Web) {
+		w.deleter = d
+	}
+}
+
 func WebRegistry(registry *prometheus.Registry) WebOption {
 	return func(w *Web) {
 		w.registry = registry
@@ -172,7 +193,7 @@ func (w *Web) Run(_ context.Context, reloadCh chan struct{}) error {
 	logMiddleware := logging.NewHTTPServerMiddleware(w.logger, w.httpLogOpts...)
 
 	const apiPrefix = "/api/v1/"
-	api := conprofapi.New(log.With(w.logger, "component", "api"), w.registry,
+	apiOpts := []conprofapi.Option{
 		conprofapi.WithDB(w.db),
 		conprofapi.WithMaxMergeBatchSize(w.maxMergeBatchSize),
 		conprofapi.WithReloadChannel(reloadCh),
@@ -180,7 +201,17 @@ func (w *Web) Run(_ context.Context, reloadCh chan struct{}) error {
 		conprofapi.WithPrefix(apiPrefix),
 		conprofapi.WithQueryTimeout(time.Duration(w.queryTimeout)),
 		conprofapi.WithSymbolizer(w.symbolizer),
-	)
+	}
+	if w.symbolizer != nil {
+		apiOpts = append(apiOpts,
+			conprofapi.WithSourceReader(w.symbolizer),
+			conprofapi.WithBinaryDownloader(w.symbolizer),
+		)
+	}
+	if w.deleter != nil {
+		apiOpts = append(apiOpts, conprofapi.WithSeriesDeleter(w.deleter))
+	}
+	api := conprofapi.New(log.With(w.logger, "component", "api"), w.registry, apiOpts...)
 	w.mux.Handle(apiPrefix, logMiddleware.HTTPMiddleware("api", api.Routes()))
 
 	if w.reloaders != nil {